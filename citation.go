@@ -0,0 +1,829 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * citation.go renders EPrint records as BibTeX, RIS, CSL-JSON and
+ * EndNote XML so they can be imported into reference managers.
+ */
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CitationFormat describes a bibliographic export format
+type CitationFormat struct {
+	// Name of the format, e.g. "bibtex"
+	Name string
+	// ContentType is the MIME type of the format
+	ContentType string
+	// Extension is the file extension including the leading "."
+	Extension string
+	// Write renders the EPrint records to w
+	Write func(w io.Writer, eprints []*EPrint) error
+}
+
+var (
+	// CitationFormats holds the supported bibliographic formats
+	CitationFormats = map[string]*CitationFormat{
+		"bibtex": {
+			Name:        "bibtex",
+			ContentType: "application/x-bibtex",
+			Extension:   ".bib",
+			Write:       WriteBibTeX,
+		},
+		"ris": {
+			Name:        "ris",
+			ContentType: "application/x-research-info-systems",
+			Extension:   ".ris",
+			Write:       WriteRIS,
+		},
+		"csl-json": {
+			Name:        "csl-json",
+			ContentType: "application/vnd.citationstyles.csl+json",
+			Extension:   ".csl.json",
+			Write:       WriteCSLJSON,
+		},
+		"endnote": {
+			Name:        "endnote",
+			ContentType: "application/x-endnote+xml",
+			Extension:   ".endnote.xml",
+			Write:       WriteEndNoteXML,
+		},
+	}
+
+	// bibtexKeyRE matches characters not allowed in a citation key
+	bibtexKeyRE = regexp.MustCompile(`[^A-Za-z0-9_:-]`)
+)
+
+// CitationFormatNames returns the names of the supported formats
+func CitationFormatNames() []string {
+	names := []string{}
+	for name := range CitationFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CitationFormatForContentType returns the citation format for a
+// MIME type or nil if it isn't a citation format.
+func CitationFormatForContentType(contentType string) *CitationFormat {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, citationFormat := range CitationFormats {
+		if citationFormat.ContentType == mediaType {
+			return citationFormat
+		}
+	}
+	return nil
+}
+
+// WriteCitations renders eprints to w in the named format
+func WriteCitations(w io.Writer, format string, eprints []*EPrint) error {
+	citationFormat, ok := CitationFormats[format]
+	if !ok {
+		return fmt.Errorf("unsupported citation format %q", format)
+	}
+	return citationFormat.Write(w, eprints)
+}
+
+//
+// Helper functions shared by the formats
+//
+
+// citationNames returns the names in a list of items
+func citationNames(items []*Item) []*Name {
+	names := []*Name{}
+	for _, item := range items {
+		if item.Name == nil {
+			continue
+		}
+		if item.Name.Family == "" && item.Name.Given == "" && strings.TrimSpace(item.Name.Value) == "" {
+			continue
+		}
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+// citationCreators returns the names of the creators
+func citationCreators(eprint *EPrint) []*Name {
+	if eprint.Creators == nil {
+		return nil
+	}
+	return citationNames(eprint.Creators.Items)
+}
+
+// citationEditors returns the names of the editors
+func citationEditors(eprint *EPrint) []*Name {
+	if eprint.Editors == nil {
+		return nil
+	}
+	return citationNames(eprint.Editors.Items)
+}
+
+// citationName formats a name as "Family, Given"
+func citationName(name *Name) string {
+	family, given := strings.TrimSpace(name.Family), strings.TrimSpace(name.Given)
+	switch {
+	case family != "" && given != "":
+		return family + ", " + given
+	case family != "":
+		return family
+	case given != "":
+		return given
+	}
+	return strings.TrimSpace(name.Value)
+}
+
+// citationDate returns the year, month and day of the record's
+// date, month and day are zero if not known.
+func citationDate(eprint *EPrint) (int, int, int) {
+	dt := eprint.Date
+	if dt == "" && eprint.Type == "thesis" {
+		dt = eprint.ThesisDegreeDate
+	}
+	parts := []int{0, 0, 0}
+	for i, s := range strings.SplitN(strings.TrimSpace(dt), "-", 3) {
+		if len(s) > 2 && i == 2 {
+			s = s[0:2]
+		}
+		parts[i], _ = strconv.Atoi(s)
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+// citationPages splits a page range into first and last page
+func citationPages(pageRange string) (string, string) {
+	first, last, _ := strings.Cut(strings.TrimSpace(pageRange), "-")
+	return strings.TrimSpace(first), strings.TrimLeft(strings.TrimSpace(last), "-")
+}
+
+// citationKeywords splits the keywords field on semicolons, or
+// on commas when there are no semicolons.
+func citationKeywords(eprint *EPrint) []string {
+	sep := ";"
+	if !strings.Contains(eprint.Keywords, sep) {
+		sep = ","
+	}
+	keywords := []string{}
+	for _, keyword := range strings.Split(eprint.Keywords, sep) {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// citationURL returns the best URL for the record
+func citationURL(eprint *EPrint) string {
+	if eprint.OfficialURL != "" {
+		return eprint.OfficialURL
+	}
+	return eprint.ID
+}
+
+// citationContainer returns the title of the journal, book or
+// conference containing the record.
+func citationContainer(eprint *EPrint) string {
+	switch eprint.Type {
+	case "book_section":
+		return eprint.BookTitle
+	case "conference_item":
+		if eprint.BookTitle != "" {
+			return eprint.BookTitle
+		}
+		return eprint.EventTitle
+	}
+	return eprint.Publication
+}
+
+// isPhDThesis returns true if the thesis type is a doctorate
+func isPhDThesis(eprint *EPrint) bool {
+	s := strings.ToLower(eprint.ThesisType + " " + eprint.ThesisDegree)
+	return strings.Contains(s, "phd") || strings.Contains(s, "doctor")
+}
+
+// citationKey returns an identifier for a record, e.g. Doe2020-123
+func citationKey(eprint *EPrint) string {
+	key := "eprint"
+	if creators := citationCreators(eprint); len(creators) > 0 {
+		if family := strings.TrimSpace(creators[0].Family); family != "" {
+			key = family
+		}
+	}
+	if year, _, _ := citationDate(eprint); year > 0 {
+		key = fmt.Sprintf("%s%d", key, year)
+	}
+	if eprint.EPrintID > 0 {
+		key = fmt.Sprintf("%s-%d", key, eprint.EPrintID)
+	}
+	return bibtexKeyRE.ReplaceAllString(key, "")
+}
+
+//
+// BibTeX
+//
+
+// bibtexEscape escapes the characters with special meaning in BibTeX
+func bibtexEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		`{`, `\{`,
+		`}`, `\}`,
+		`&`, `\&`,
+		`%`, `\%`,
+		`$`, `\$`,
+		`#`, `\#`,
+		`_`, `\_`,
+	).Replace(strings.TrimSpace(s))
+}
+
+// bibtexType maps an EPrint type to a BibTeX entry type
+func bibtexType(eprint *EPrint) string {
+	switch eprint.Type {
+	case "article":
+		return "article"
+	case "book":
+		return "book"
+	case "book_section":
+		return "incollection"
+	case "conference_item":
+		return "inproceedings"
+	case "thesis":
+		if isPhDThesis(eprint) {
+			return "phdthesis"
+		}
+		return "mastersthesis"
+	case "patent":
+		return "patent"
+	case "monograph":
+		return "techreport"
+	}
+	return "misc"
+}
+
+// EPrintToBibTeX renders an EPrint record as a BibTeX entry.
+// Patents use the biblatex "patent" entry type.
+func EPrintToBibTeX(eprint *EPrint) string {
+	fields := [][2]string{}
+	add := func(key string, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fields = append(fields, [2]string{key, value})
+		}
+	}
+	joinNames := func(names []*Name) string {
+		l := []string{}
+		for _, name := range names {
+			l = append(l, citationName(name))
+		}
+		return strings.Join(l, " and ")
+	}
+	entryType := bibtexType(eprint)
+	add("title", eprint.Title)
+	add("author", joinNames(citationCreators(eprint)))
+	add("editor", joinNames(citationEditors(eprint)))
+	switch entryType {
+	case "article":
+		add("journal", eprint.Publication)
+		add("volume", eprint.Volume)
+		add("number", eprint.Number)
+		add("publisher", eprint.Publisher)
+		add("issn", eprint.ISSN)
+	case "book":
+		add("publisher", eprint.Publisher)
+		add("address", eprint.PlaceOfPub)
+		add("edition", eprint.Edition)
+		add("series", eprint.Series)
+		add("volume", eprint.Volume)
+		add("isbn", eprint.ISBN)
+	case "incollection":
+		add("booktitle", eprint.BookTitle)
+		add("publisher", eprint.Publisher)
+		add("address", eprint.PlaceOfPub)
+		add("series", eprint.Series)
+		add("volume", eprint.Volume)
+		add("isbn", eprint.ISBN)
+	case "inproceedings":
+		add("booktitle", citationContainer(eprint))
+		add("address", eprint.EventLocation)
+		add("publisher", eprint.Publisher)
+		add("series", eprint.Series)
+		add("volume", eprint.Volume)
+	case "phdthesis", "mastersthesis":
+		add("school", eprint.Institution)
+		add("type", eprint.ThesisType)
+	case "patent":
+		add("number", eprint.PatentNumber)
+		add("holder", eprint.PatentApplicant)
+	case "techreport":
+		add("institution", eprint.Institution)
+		add("type", eprint.MonographType)
+		add("number", eprint.Number)
+		add("series", eprint.Series)
+	default:
+		add("howpublished", eprint.Publication)
+		add("publisher", eprint.Publisher)
+	}
+	if first, last := citationPages(eprint.PageRange); first != "" {
+		if last != "" {
+			add("pages", first+"--"+last)
+		} else {
+			add("pages", first)
+		}
+	}
+	if year, month, _ := citationDate(eprint); year > 0 {
+		add("year", strconv.Itoa(year))
+		if month > 0 {
+			add("month", strconv.Itoa(month))
+		}
+	}
+	add("doi", eprint.DOI)
+	add("url", citationURL(eprint))
+	add("abstract", eprint.Abstract)
+	add("keywords", strings.Join(citationKeywords(eprint), ", "))
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@%s{%s", entryType, citationKey(eprint))
+	for _, field := range fields {
+		value := field[1]
+		if field[0] != "url" && field[0] != "doi" {
+			value = bibtexEscape(value)
+		}
+		fmt.Fprintf(&sb, ",\n  %s = {%s}", field[0], value)
+	}
+	sb.WriteString("\n}\n")
+	return sb.String()
+}
+
+// WriteBibTeX renders eprints as BibTeX entries
+func WriteBibTeX(w io.Writer, eprints []*EPrint) error {
+	for i, eprint := range eprints {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, EPrintToBibTeX(eprint)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//
+// RIS
+//
+
+// risType maps an EPrint type to a RIS reference type
+func risType(eprint *EPrint) string {
+	switch eprint.Type {
+	case "article":
+		return "JOUR"
+	case "book":
+		return "BOOK"
+	case "book_section":
+		return "CHAP"
+	case "conference_item":
+		return "CPAPER"
+	case "thesis":
+		return "THES"
+	case "patent":
+		return "PAT"
+	case "monograph":
+		return "RPRT"
+	}
+	return "GEN"
+}
+
+// EPrintToRIS renders an EPrint record as a RIS record
+func EPrintToRIS(eprint *EPrint) string {
+	var sb strings.Builder
+	add := func(tag string, value string) {
+		// NOTE: RIS values are a single line
+		value = strings.Join(strings.Fields(value), " ")
+		if value != "" {
+			fmt.Fprintf(&sb, "%s  - %s\n", tag, value)
+		}
+	}
+	refType := risType(eprint)
+	add("TY", refType)
+	add("ID", citationKey(eprint))
+	add("TI", eprint.Title)
+	for _, name := range citationCreators(eprint) {
+		add("AU", citationName(name))
+	}
+	for _, name := range citationEditors(eprint) {
+		add("ED", citationName(name))
+	}
+	switch refType {
+	case "JOUR":
+		add("JO", eprint.Publication)
+		add("SN", eprint.ISSN)
+	case "BOOK", "CHAP":
+		add("T2", eprint.BookTitle)
+		add("T3", eprint.Series)
+		add("ET", eprint.Edition)
+		add("SN", eprint.ISBN)
+	case "CPAPER":
+		add("T2", citationContainer(eprint))
+		add("CY", eprint.EventLocation)
+	case "THES":
+		add("PB", eprint.Institution)
+		add("M3", eprint.ThesisType)
+	case "PAT":
+		add("IS", eprint.PatentNumber)
+		add("A2", eprint.PatentApplicant)
+	case "RPRT":
+		add("PB", eprint.Institution)
+		add("M3", eprint.MonographType)
+		add("T3", eprint.Series)
+	}
+	add("VL", eprint.Volume)
+	if refType != "PAT" {
+		add("IS", eprint.Number)
+	}
+	if refType != "THES" && refType != "RPRT" {
+		add("PB", eprint.Publisher)
+	}
+	if refType != "CPAPER" {
+		add("CY", eprint.PlaceOfPub)
+	}
+	if first, last := citationPages(eprint.PageRange); first != "" {
+		add("SP", first)
+		add("EP", last)
+	}
+	if year, month, day := citationDate(eprint); year > 0 {
+		add("PY", strconv.Itoa(year))
+		if month > 0 {
+			dt := fmt.Sprintf("%04d/%02d/", year, month)
+			if day > 0 {
+				dt += fmt.Sprintf("%02d", day)
+			}
+			add("DA", dt+"/")
+		}
+	}
+	add("DO", eprint.DOI)
+	add("UR", citationURL(eprint))
+	add("AB", eprint.Abstract)
+	for _, keyword := range citationKeywords(eprint) {
+		add("KW", keyword)
+	}
+	sb.WriteString("ER  - \n")
+	return sb.String()
+}
+
+// WriteRIS renders eprints as RIS records
+func WriteRIS(w io.Writer, eprints []*EPrint) error {
+	for _, eprint := range eprints {
+		if _, err := io.WriteString(w, EPrintToRIS(eprint)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//
+// CSL-JSON
+//
+
+// CSLName is a name in CSL-JSON
+type CSLName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+// CSLDate is a date in CSL-JSON
+type CSLDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// CSLItem is a Citation Style Language JSON item
+type CSLItem struct {
+	ID             string     `json:"id"`
+	Type           string     `json:"type"`
+	Title          string     `json:"title,omitempty"`
+	Author         []*CSLName `json:"author,omitempty"`
+	Editor         []*CSLName `json:"editor,omitempty"`
+	Issued         *CSLDate   `json:"issued,omitempty"`
+	ContainerTitle string     `json:"container-title,omitempty"`
+	CollectionName string     `json:"collection-title,omitempty"`
+	Volume         string     `json:"volume,omitempty"`
+	Issue          string     `json:"issue,omitempty"`
+	Page           string     `json:"page,omitempty"`
+	Edition        string     `json:"edition,omitempty"`
+	Publisher      string     `json:"publisher,omitempty"`
+	PublisherPlace string     `json:"publisher-place,omitempty"`
+	Event          string     `json:"event,omitempty"`
+	EventPlace     string     `json:"event-place,omitempty"`
+	Genre          string     `json:"genre,omitempty"`
+	Number         string     `json:"number,omitempty"`
+	Authority      string     `json:"authority,omitempty"`
+	ISSN           string     `json:"ISSN,omitempty"`
+	ISBN           string     `json:"ISBN,omitempty"`
+	DOI            string     `json:"DOI,omitempty"`
+	URL            string     `json:"URL,omitempty"`
+	Abstract       string     `json:"abstract,omitempty"`
+	Keyword        string     `json:"keyword,omitempty"`
+}
+
+// cslType maps an EPrint type to a CSL item type
+func cslType(eprint *EPrint) string {
+	switch eprint.Type {
+	case "article":
+		return "article-journal"
+	case "book":
+		return "book"
+	case "book_section":
+		return "chapter"
+	case "conference_item":
+		return "paper-conference"
+	case "thesis":
+		return "thesis"
+	case "patent":
+		return "patent"
+	case "monograph":
+		return "report"
+	}
+	return "document"
+}
+
+// cslNames converts names to CSL names
+func cslNames(names []*Name) []*CSLName {
+	l := []*CSLName{}
+	for _, name := range names {
+		cslName := &CSLName{Family: strings.TrimSpace(name.Family), Given: strings.TrimSpace(name.Given)}
+		if cslName.Family == "" && cslName.Given == "" {
+			cslName.Literal = strings.TrimSpace(name.Value)
+		}
+		l = append(l, cslName)
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// EPrintToCSL converts an EPrint record to a CSL-JSON item
+func EPrintToCSL(eprint *EPrint) *CSLItem {
+	item := &CSLItem{
+		ID:       citationKey(eprint),
+		Type:     cslType(eprint),
+		Title:    strings.TrimSpace(eprint.Title),
+		Author:   cslNames(citationCreators(eprint)),
+		Editor:   cslNames(citationEditors(eprint)),
+		Volume:   eprint.Volume,
+		Issue:    eprint.Number,
+		DOI:      eprint.DOI,
+		URL:      citationURL(eprint),
+		Abstract: strings.TrimSpace(eprint.Abstract),
+		Keyword:  strings.Join(citationKeywords(eprint), ", "),
+	}
+	if year, month, day := citationDate(eprint); year > 0 {
+		parts := []int{year}
+		if month > 0 {
+			parts = append(parts, month)
+			if day > 0 {
+				parts = append(parts, day)
+			}
+		}
+		item.Issued = &CSLDate{DateParts: [][]int{parts}}
+	}
+	if first, last := citationPages(eprint.PageRange); first != "" {
+		item.Page = first
+		if last != "" {
+			item.Page = first + "-" + last
+		}
+	}
+	switch item.Type {
+	case "article-journal":
+		item.ContainerTitle = eprint.Publication
+		item.ISSN = eprint.ISSN
+	case "book", "chapter":
+		item.ContainerTitle = eprint.BookTitle
+		item.CollectionName = eprint.Series
+		item.Edition = eprint.Edition
+		item.ISBN = eprint.ISBN
+	case "paper-conference":
+		item.ContainerTitle = citationContainer(eprint)
+		item.Event = eprint.EventTitle
+		item.EventPlace = eprint.EventLocation
+	case "thesis":
+		item.Publisher = eprint.Institution
+		item.Genre = eprint.ThesisType
+	case "patent":
+		item.Number = eprint.PatentNumber
+		item.Authority = eprint.PatentApplicant
+	case "report":
+		item.Publisher = eprint.Institution
+		item.Genre = eprint.MonographType
+		item.CollectionName = eprint.Series
+	default:
+		item.ContainerTitle = eprint.Publication
+	}
+	if item.Publisher == "" {
+		item.Publisher = eprint.Publisher
+	}
+	item.PublisherPlace = eprint.PlaceOfPub
+	return item
+}
+
+// WriteCSLJSON renders eprints as a CSL-JSON array
+func WriteCSLJSON(w io.Writer, eprints []*EPrint) error {
+	items := []*CSLItem{}
+	for _, eprint := range eprints {
+		items = append(items, EPrintToCSL(eprint))
+	}
+	src, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", src)
+	return err
+}
+
+//
+// EndNote XML
+//
+
+// EndNoteRefType is the reference type of an EndNote record
+type EndNoteRefType struct {
+	Name  string `xml:"name,attr"`
+	Value int    `xml:",chardata"`
+}
+
+// EndNoteAuthors is a list of authors in an EndNote record
+type EndNoteAuthors struct {
+	Author []string `xml:"author"`
+}
+
+// EndNoteContributors holds the authors and editors of an EndNote record
+type EndNoteContributors struct {
+	Authors          *EndNoteAuthors `xml:"authors,omitempty"`
+	SecondaryAuthors *EndNoteAuthors `xml:"secondary-authors,omitempty"`
+}
+
+// EndNoteKeywords is the list of keywords in an EndNote record
+type EndNoteKeywords struct {
+	Keyword []string `xml:"keyword"`
+}
+
+// EndNoteRecord is a record in EndNote XML
+type EndNoteRecord struct {
+	XMLName        xml.Name             `xml:"record"`
+	RefType        *EndNoteRefType      `xml:"ref-type"`
+	Contributors   *EndNoteContributors `xml:"contributors,omitempty"`
+	Title          string               `xml:"titles>title,omitempty"`
+	SecondaryTitle string               `xml:"titles>secondary-title,omitempty"`
+	TertiaryTitle  string               `xml:"titles>tertiary-title,omitempty"`
+	Periodical     string               `xml:"periodical>full-title,omitempty"`
+	Pages          string               `xml:"pages,omitempty"`
+	Volume         string               `xml:"volume,omitempty"`
+	Number         string               `xml:"number,omitempty"`
+	Edition        string               `xml:"edition,omitempty"`
+	Keywords       *EndNoteKeywords     `xml:"keywords,omitempty"`
+	Year           string               `xml:"dates>year,omitempty"`
+	PubDate        string               `xml:"dates>pub-dates>date,omitempty"`
+	PubLocation    string               `xml:"pub-location,omitempty"`
+	Publisher      string               `xml:"publisher,omitempty"`
+	ISBN           string               `xml:"isbn,omitempty"`
+	WorkType       string               `xml:"work-type,omitempty"`
+	DOI            string               `xml:"electronic-resource-num,omitempty"`
+	Abstract       string               `xml:"abstract,omitempty"`
+	URLs           []string             `xml:"urls>related-urls>url,omitempty"`
+}
+
+// EndNoteXML is the root element of an EndNote XML document
+type EndNoteXML struct {
+	XMLName xml.Name         `xml:"xml"`
+	Records []*EndNoteRecord `xml:"records>record"`
+}
+
+// endNoteRefType maps an EPrint type to an EndNote reference type
+func endNoteRefType(eprint *EPrint) *EndNoteRefType {
+	switch eprint.Type {
+	case "article":
+		return &EndNoteRefType{"Journal Article", 17}
+	case "book":
+		return &EndNoteRefType{"Book", 6}
+	case "book_section":
+		return &EndNoteRefType{"Book Section", 5}
+	case "conference_item":
+		return &EndNoteRefType{"Conference Paper", 47}
+	case "thesis":
+		return &EndNoteRefType{"Thesis", 32}
+	case "patent":
+		return &EndNoteRefType{"Patent", 25}
+	case "monograph":
+		return &EndNoteRefType{"Report", 27}
+	}
+	return &EndNoteRefType{"Generic", 13}
+}
+
+// EPrintToEndNote converts an EPrint record to an EndNote record
+func EPrintToEndNote(eprint *EPrint) *EndNoteRecord {
+	record := &EndNoteRecord{
+		RefType:  endNoteRefType(eprint),
+		Title:    strings.TrimSpace(eprint.Title),
+		Pages:    eprint.PageRange,
+		Volume:   eprint.Volume,
+		Number:   eprint.Number,
+		DOI:      eprint.DOI,
+		Abstract: strings.TrimSpace(eprint.Abstract),
+	}
+	endNoteAuthors := func(names []*Name) *EndNoteAuthors {
+		if len(names) == 0 {
+			return nil
+		}
+		authors := new(EndNoteAuthors)
+		for _, name := range names {
+			authors.Author = append(authors.Author, citationName(name))
+		}
+		return authors
+	}
+	authors, editors := endNoteAuthors(citationCreators(eprint)), endNoteAuthors(citationEditors(eprint))
+	if authors != nil || editors != nil {
+		record.Contributors = &EndNoteContributors{Authors: authors, SecondaryAuthors: editors}
+	}
+	if keywords := citationKeywords(eprint); len(keywords) > 0 {
+		record.Keywords = &EndNoteKeywords{Keyword: keywords}
+	}
+	if u := citationURL(eprint); u != "" {
+		record.URLs = []string{u}
+	}
+	if year, month, day := citationDate(eprint); year > 0 {
+		record.Year = strconv.Itoa(year)
+		if month > 0 {
+			record.PubDate = fmt.Sprintf("%04d-%02d", year, month)
+			if day > 0 {
+				record.PubDate += fmt.Sprintf("-%02d", day)
+			}
+		}
+	}
+	record.Publisher, record.PubLocation = eprint.Publisher, eprint.PlaceOfPub
+	switch eprint.Type {
+	case "article":
+		record.SecondaryTitle = eprint.Publication
+		record.Periodical = eprint.Publication
+		record.ISBN = eprint.ISSN
+	case "book", "book_section":
+		record.SecondaryTitle = eprint.BookTitle
+		record.TertiaryTitle = eprint.Series
+		record.Edition = eprint.Edition
+		record.ISBN = eprint.ISBN
+	case "conference_item":
+		record.SecondaryTitle = citationContainer(eprint)
+		if eprint.EventLocation != "" {
+			record.PubLocation = eprint.EventLocation
+		}
+	case "thesis":
+		record.Publisher = eprint.Institution
+		record.WorkType = eprint.ThesisType
+	case "patent":
+		record.Number = eprint.PatentNumber
+		record.Publisher = eprint.PatentApplicant
+	case "monograph":
+		record.Publisher = eprint.Institution
+		record.WorkType = eprint.MonographType
+		record.TertiaryTitle = eprint.Series
+	default:
+		record.SecondaryTitle = eprint.Publication
+	}
+	return record
+}
+
+// WriteEndNoteXML renders eprints as an EndNote XML document
+func WriteEndNoteXML(w io.Writer, eprints []*EPrint) error {
+	doc := new(EndNoteXML)
+	for _, eprint := range eprints {
+		doc.Records = append(doc.Records, EPrintToEndNote(eprint))
+	}
+	src, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, src)
+	return err
+}
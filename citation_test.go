@@ -0,0 +1,146 @@
+package eprinttools
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func citationTestEPrints() []*EPrint {
+	creators := func(family string, given string) *CreatorItemList {
+		return &CreatorItemList{Items: []*Item{{Name: &Name{Family: family, Given: given}}}}
+	}
+	return []*EPrint{
+		{EPrintID: 1, Type: "article", Title: "Lemurs & Tails", Creators: creators("Doe", "Jane"), Date: "2020-05-04", Publication: "Journal of Lemurs", Volume: "3", Number: "2", PageRange: "10-20", ISSN: "1234-5678", DOI: "10.1234/lemur"},
+		{EPrintID: 2, Type: "book_section", Title: "A Chapter", Creators: creators("Doe", "Jane"), Date: "2019", BookTitle: "The Lemur Book", Publisher: "Lemur Press", ISBN: "9780306406157"},
+		{EPrintID: 3, Type: "conference_item", Title: "A Talk", Creators: creators("Roe", "Richard"), Date: "2018-06", EventTitle: "Lemur Con", EventLocation: "Pasadena, CA"},
+		{EPrintID: 4, Type: "thesis", Title: "On Lemurs", Creators: creators("Doe", "John"), Date: "2017", ThesisType: "phd", Institution: "Caltech"},
+		{EPrintID: 5, Type: "patent", Title: "Lemur Feeder", Creators: creators("Roe", "Jane"), Date: "2016", PatentNumber: "US 1,234,567", PatentApplicant: "Caltech"},
+	}
+}
+
+func TestBibTeX(t *testing.T) {
+	expected := map[int][]string{
+		1: {"@article{Doe2020-1,", "journal = {Journal of Lemurs}", "pages = {10--20}", "title = {Lemurs \\& Tails}", "month = {5}"},
+		2: {"@incollection{Doe2019-2,", "booktitle = {The Lemur Book}", "isbn = {9780306406157}"},
+		3: {"@inproceedings{Roe2018-3,", "booktitle = {Lemur Con}", "address = {Pasadena, CA}"},
+		4: {"@phdthesis{Doe2017-4,", "school = {Caltech}"},
+		5: {"@patent{Roe2016-5,", "number = {US 1,234,567}", "holder = {Caltech}"},
+	}
+	for _, eprint := range citationTestEPrints() {
+		src := EPrintToBibTeX(eprint)
+		for _, s := range expected[eprint.EPrintID] {
+			if !strings.Contains(src, s) {
+				t.Errorf("expected %q in\n%s", s, src)
+			}
+		}
+	}
+	if s := bibtexEscape(`100% {free}_#`); s != `100\% \{free\}\_\#` {
+		t.Errorf("unexpected escape %q", s)
+	}
+}
+
+func TestRIS(t *testing.T) {
+	expected := map[int][]string{
+		1: {"TY  - JOUR\n", "JO  - Journal of Lemurs\n", "SP  - 10\n", "EP  - 20\n", "DA  - 2020/05/04/\n"},
+		2: {"TY  - CHAP\n", "T2  - The Lemur Book\n", "PB  - Lemur Press\n"},
+		3: {"TY  - CPAPER\n", "T2  - Lemur Con\n", "CY  - Pasadena, CA\n"},
+		4: {"TY  - THES\n", "PB  - Caltech\n", "M3  - phd\n"},
+		5: {"TY  - PAT\n", "IS  - US 1,234,567\n"},
+	}
+	for _, eprint := range citationTestEPrints() {
+		src := EPrintToRIS(eprint)
+		if !strings.HasSuffix(src, "ER  - \n") {
+			t.Errorf("expected RIS record to end with ER, got\n%s", src)
+		}
+		for _, s := range expected[eprint.EPrintID] {
+			if !strings.Contains(src, s) {
+				t.Errorf("expected %q in\n%s", s, src)
+			}
+		}
+	}
+}
+
+func TestCSLJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := WriteCSLJSON(buf, citationTestEPrints()); err != nil {
+		t.Fatal(err)
+	}
+	items := []*CSLItem{}
+	if err := json.Unmarshal(buf.Bytes(), &items); err != nil {
+		t.Fatalf("expected a JSON array, %s", err)
+	}
+	expected := []string{"article-journal", "chapter", "paper-conference", "thesis", "patent"}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, got %d", len(expected), len(items))
+	}
+	for i, item := range items {
+		if item.Type != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], item.Type)
+		}
+	}
+	if parts := items[0].Issued.DateParts[0]; len(parts) != 3 || parts[0] != 2020 || parts[1] != 5 || parts[2] != 4 {
+		t.Errorf("unexpected date-parts %+v", parts)
+	}
+	if items[0].Author[0].Family != "Doe" || items[0].Page != "10-20" {
+		t.Errorf("unexpected item %+v", items[0])
+	}
+	if items[4].Number != "US 1,234,567" {
+		t.Errorf("expected patent number, got %q", items[4].Number)
+	}
+}
+
+func TestEndNoteXML(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := WriteEndNoteXML(buf, citationTestEPrints()); err != nil {
+		t.Fatal(err)
+	}
+	doc := new(EndNoteXML)
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("expected well formed XML, %s", err)
+	}
+	expected := []int{17, 5, 47, 32, 25}
+	if len(doc.Records) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(doc.Records))
+	}
+	for i, record := range doc.Records {
+		if record.RefType.Value != expected[i] {
+			t.Errorf("expected ref-type %d, got %d", expected[i], record.RefType.Value)
+		}
+	}
+	if doc.Records[0].Contributors.Authors.Author[0] != "Doe, Jane" {
+		t.Errorf("unexpected authors %+v", doc.Records[0].Contributors.Authors)
+	}
+}
+
+func TestWriteCitations(t *testing.T) {
+	fName := path.Join("testdata", "test_eprint1.xml")
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		t.Fatalf("Failed to read %q, %s", fName, err)
+	}
+	eprints := NewEPrints()
+	if err := xml.Unmarshal(src, &eprints); err != nil {
+		t.Fatalf("Failed to unmarshal %q, %s", fName, err)
+	}
+	for _, name := range CitationFormatNames() {
+		buf := new(bytes.Buffer)
+		if err := WriteCitations(buf, name, eprints.EPrint); err != nil {
+			t.Errorf("WriteCitations(%q) failed, %s", name, err)
+			continue
+		}
+		if !strings.Contains(buf.String(), "Singletary") {
+			t.Errorf("expected creator in %s output", name)
+		}
+		if f := CitationFormatForContentType(CitationFormats[name].ContentType + "; charset=utf-8"); f == nil || f.Name != name {
+			t.Errorf("expected %q for content type", name)
+		}
+	}
+	if err := WriteCitations(new(bytes.Buffer), "marc21", eprints.EPrint); err == nil {
+		t.Errorf("expected error for unsupported format")
+	}
+}
@@ -34,6 +34,7 @@ import (
 	// Caltech Library Packages
 	"github.com/caltechlibrary/eprinttools"
 	"github.com/caltechlibrary/eprinttools/cleaner"
+	"github.com/caltechlibrary/eprinttools/identifier"
 )
 
 func handleInitials(s string) (string, bool) {
@@ -197,6 +198,45 @@ func normalizeCreators(creators *eprinttools.CreatorItemList) (*eprinttools.Crea
 	return creators, false
 }
 
+// normalizeIdentifier returns the canonical form of an identifier
+// and true if it changed. Values that aren't valid identifiers are
+// left as they are.
+func normalizeIdentifier(kind string, s string) (string, bool) {
+	if s == "" {
+		return s, false
+	}
+	if value, err := identifier.Normalize(kind, s); err == nil && value != s {
+		return value, true
+	}
+	return s, false
+}
+
+// normalizeORCIDs conforms the ORCID of the people in an item list
+// (e.g. creators) to the hyphenated form.
+func normalizeORCIDs(itemList eprinttools.ItemsInterface) bool {
+	changed := false
+	for i := 0; i < itemList.Length(); i++ {
+		item := itemList.IndexOf(i)
+		if item == nil {
+			continue
+		}
+		if orcid, updated := normalizeIdentifier(identifier.ORCID, item.ORCID); updated {
+			item.ORCID = orcid
+			changed = true
+		}
+	}
+	return changed
+}
+
+// issnKey returns the hyphenated ISSN used to look up publishers
+// and publications, s is returned if it isn't a valid ISSN.
+func issnKey(s string) string {
+	if issn, err := identifier.NormalizeISSN(s); err == nil {
+		return issn
+	}
+	return s
+}
+
 func ClearRuleSet() map[string]bool {
 	return map[string]bool{
 		"dot_initials":          false,
@@ -217,6 +257,12 @@ func ClearRuleSet() map[string]bool {
 		"generate_id_number":    false,
 		"generate_official_url": false,
 		"strip_tags":            false,
+		"normalize_doi":         false,
+		"normalize_orcid":       false,
+		"normalize_issn":        false,
+		"normalize_isbn":        false,
+		"normalize_pmid":        false,
+		"normalize_pmcid":       false,
 	}
 }
 
@@ -267,6 +313,22 @@ func UseCLSRules() map[string]bool {
 		"generate_official_url": true,
 		// Strip HTML/XML tags from abstract
 		"strip_tags": true,
+		// NOTE: the identifier normalization rules are off by
+		// default so existing output doesn't change (e.g. ISBN-10
+		// isn't rewritten as ISBN-13). The ep3apid identifier
+		// lookups match either form.
+		// Store DOI without the https://doi.org/ or doi: prefix
+		"normalize_doi": false,
+		// Store ORCID in the hyphenated form (e.g. 0000-0002-1825-0097)
+		"normalize_orcid": false,
+		// Store ISSN in the hyphenated form (e.g. 0378-5955)
+		"normalize_issn": false,
+		// Store ISBN as ISBN-13 without hyphens
+		"normalize_isbn": false,
+		// Store PubMed ID as digits
+		"normalize_pmid": false,
+		// Store PubMed Central ID with the "PMC" prefix
+		"normalize_pmcid": false,
 	}
 }
 
@@ -326,7 +388,7 @@ func Apply(eprintsList *eprinttools.EPrints, ruleSet map[string]bool) (*eprintto
 					}
 				case "normalize_publisher":
 					if eprint.ISSN != "" {
-						if publisher, ok := issnPublisher[eprint.ISSN]; ok == true {
+						if publisher, ok := issnPublisher[issnKey(eprint.ISSN)]; ok == true {
 							eprint.Publisher = publisher
 							changed = true
 						}
@@ -334,7 +396,7 @@ func Apply(eprintsList *eprinttools.EPrints, ruleSet map[string]bool) (*eprintto
 				case "normalize_publication":
 					// Normalize Publisher name and Publication from ISSN
 					if eprint.ISSN != "" {
-						if publication, ok := issnPublication[eprint.ISSN]; ok == true {
+						if publication, ok := issnPublication[issnKey(eprint.ISSN)]; ok == true {
 							eprint.Publication = publication
 							changed = true
 						}
@@ -377,6 +439,37 @@ func Apply(eprintsList *eprinttools.EPrints, ruleSet map[string]bool) (*eprintto
 					if cleaner.HasEncodedElements([]byte(eprint.Abstract)) {
 						eprint.Abstract = string(cleaner.StripTags([]byte(eprint.Abstract)))
 					}
+				case "normalize_doi":
+					if doi, updated := normalizeIdentifier(identifier.DOI, eprint.DOI); updated {
+						eprint.DOI = doi
+						changed = true
+					}
+				case "normalize_orcid":
+					for _, itemList := range []eprinttools.ItemsInterface{eprint.Creators, eprint.Editors, eprint.Contributors, eprint.ThesisAdvisor, eprint.ThesisCommittee} {
+						if normalizeORCIDs(itemList) {
+							changed = true
+						}
+					}
+				case "normalize_issn":
+					if issn, updated := normalizeIdentifier(identifier.ISSN, eprint.ISSN); updated {
+						eprint.ISSN = issn
+						changed = true
+					}
+				case "normalize_isbn":
+					if isbn, updated := normalizeIdentifier(identifier.ISBN, eprint.ISBN); updated {
+						eprint.ISBN = isbn
+						changed = true
+					}
+				case "normalize_pmid":
+					if pmid, updated := normalizeIdentifier(identifier.PMID, eprint.PMID); updated {
+						eprint.PMID = pmid
+						changed = true
+					}
+				case "normalize_pmcid":
+					if pmcid, updated := normalizeIdentifier(identifier.PMCID, eprint.PMCID); updated {
+						eprint.PMCID = pmcid
+						changed = true
+					}
 				}
 			}
 		}
@@ -29,6 +29,9 @@ package clsrules
 
 import (
 	"testing"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/eprinttools"
 )
 
 func TestApply(t *testing.T) {
@@ -38,3 +41,64 @@ func TestApply(t *testing.T) {
 	//t.Errorf("clsrules.Apply() not implemented")
 	t.Skip("testing clsrules.Apply() implementation needed")
 }
+
+func TestNormalizeIdentifierRules(t *testing.T) {
+	eprint := &eprinttools.EPrint{
+		DOI:      "https://doi.org/10.1021/acsami.7b15651",
+		ISSN:     "03785955",
+		ISBN:     "0-306-40615-2",
+		PMID:     "PMID: 12345678",
+		PMCID:    "1234567",
+		Creators: new(eprinttools.CreatorItemList),
+		Editors:  new(eprinttools.EditorItemList),
+	}
+	eprint.Creators.Append(&eprinttools.Item{ORCID: "https://orcid.org/0000000218250097"})
+	eprint.Creators.Append(&eprinttools.Item{ORCID: "not-an-orcid"})
+	eprint.Editors.Append(&eprinttools.Item{ORCID: "0000-0002-1694-233x"})
+	eprintsList := new(eprinttools.EPrints)
+	eprintsList.Append(eprint)
+	ruleSet := ClearRuleSet()
+	for _, name := range []string{"normalize_doi", "normalize_orcid", "normalize_issn", "normalize_isbn", "normalize_pmid", "normalize_pmcid", "normalize_publisher"} {
+		ruleSet[name] = true
+	}
+	eprintsList, err := Apply(eprintsList, ruleSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eprint = eprintsList.EPrint[0]
+	for _, test := range [][3]string{
+		{"doi", "10.1021/acsami.7b15651", eprint.DOI},
+		{"issn", "0378-5955", eprint.ISSN},
+		{"isbn", "9780306406157", eprint.ISBN},
+		{"pmid", "12345678", eprint.PMID},
+		{"pmcid", "PMC1234567", eprint.PMCID},
+		{"creators orcid", "0000-0002-1825-0097", eprint.Creators.IndexOf(0).ORCID},
+		{"creators orcid (not valid)", "not-an-orcid", eprint.Creators.IndexOf(1).ORCID},
+		{"editors orcid", "0000-0002-1694-233X", eprint.Editors.IndexOf(0).ORCID},
+		{"publisher", issnPublisher["0378-5955"], eprint.Publisher},
+	} {
+		if test[1] != test[2] {
+			t.Errorf("%s, expected %q, got %q", test[0], test[1], test[2])
+		}
+	}
+}
+
+// TestNormalizeIdentifierDefaults checks the identifier rules are off
+// by default so an ISBN-10 isn't rewritten as an ISBN-13.
+func TestNormalizeIdentifierDefaults(t *testing.T) {
+	ruleSet := UseCLSRules()
+	for _, name := range []string{"normalize_doi", "normalize_orcid", "normalize_issn", "normalize_isbn", "normalize_pmid", "normalize_pmcid"} {
+		if ruleSet[name] {
+			t.Errorf("expected %s to be off by default", name)
+		}
+	}
+	eprintsList := new(eprinttools.EPrints)
+	eprintsList.Append(&eprinttools.EPrint{ISBN: "0-306-40615-2"})
+	eprintsList, err := Apply(eprintsList, ruleSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isbn := eprintsList.EPrint[0].ISBN; isbn != "0-306-40615-2" {
+		t.Errorf("expected ISBN to be unchanged, got %q", isbn)
+	}
+}
@@ -123,6 +123,9 @@ form or URL form (e.g. "10.1021/acsami.7b15651" or
 -mailto
 : (string) set the mailto value for CrossRef API access (default "helpdesk@library.caltech.edu")
 
+-normalize-identifiers
+: Use normalize DOI, ORCID, ISSN, ISBN (as ISBN-13), PMID and PMCID rules
+
 -normalize-publisher
 : Use normalize publisher rule
 
@@ -156,6 +159,15 @@ form or URL form (e.g. "10.1021/acsami.7b15651" or
 -trim-volume
 : Use trim volume rule
 
+-validate
+: check the EPrint records and output a validation report (JSON) instead of EPrint XML, exits with 1 if a record isn't valid
+
+-settings
+: (string) settings file (as used by ep3apid) holding the repository's validation rules and database connection used by -validate
+
+-repo
+: (string) repository id in the settings file used by -validate
+
 # EXAMPLES
 
 Example generating an EPrintsXML for one DOI
@@ -177,6 +189,14 @@ an XML document called "import-articles.xml".
 	{app_name} -i doi-list.txt -o import-articles.xml
 ~~~
 
+Example checking the records for a list of DOIs against the
+rules and column types of the "authors" repository before import.
+
+~~~
+	{app_name} -validate -settings settings.json -repo authors \
+	    -i doi-list.txt
+~~~
+
 {app_name} {version} {release_hash}
 
 `
@@ -208,7 +228,11 @@ an XML document called "import-articles.xml".
 	normalizeRelatedUrlRule        bool
 	normalizePublisherRule         bool
 	normalizePublicationRule       bool
+	normalizeIdentifiersRule       bool
 	dotInitials                    bool
+	validateRecords                bool
+	settingsFName                  string
+	repoID                         string
 )
 
 
@@ -261,7 +285,11 @@ func main() {
 	flag.BoolVar(&normalizeRelatedUrlRule, "normalize-related-url", false, "Use normlize related url rule")
 	flag.BoolVar(&normalizePublisherRule, "normalize-publisher", false, "Use normalize publisher rule")
 	flag.BoolVar(&normalizePublicationRule, "normlize-publication", false, "Use normalize publication rule")
+	flag.BoolVar(&normalizeIdentifiersRule, "normalize-identifiers", false, "Use normalize DOI, ORCID, ISSN, ISBN, PMID and PMCID rules")
 	flag.BoolVar(&dotInitials, "dot-initials", false, "Add period to initials in given name")
+	flag.BoolVar(&validateRecords, "validate", false, "output a validation report instead of EPrint XML")
+	flag.StringVar(&settingsFName, "settings", "", "settings file with the repository validation rules")
+	flag.StringVar(&repoID, "repo", "", "repository id used for validation")
 	flag.BoolVar(&asJSON, "json", false, "output EPrint structure as JSON")
 	flag.BoolVar(&asSimplified, "simple", false, "output EPrint structure as Simplified JSON")
 	flag.BoolVar(&attemptDownload, "D", false, "attempt to download the digital object if object URL provided")
@@ -510,6 +538,11 @@ func main() {
 	if normalizePublicationRule {
 		ruleSet["normalize_publication"] = normalizePublicationRule
 	}
+	if normalizeIdentifiersRule {
+		for _, rule := range []string{"normalize_doi", "normalize_orcid", "normalize_issn", "normalize_isbn", "normalize_pmid", "normalize_pmcid"} {
+			ruleSet[rule] = normalizeIdentifiersRule
+		}
+	}
 	eprintsList, err = clsrules.Apply(eprintsList, ruleSet)
 	if err != nil {
 		fmt.Fprintf(eout, "%s\n", err)
@@ -522,6 +555,54 @@ func main() {
 			os.Exit(1)
 		}
 	}
+	if validateRecords {
+		// NOTE: without a settings file the default rules are used
+		// and column types aren't checked.
+		ds := new(eprinttools.DataSource)
+		if settingsFName != "" {
+			config, err := eprinttools.LoadConfig(settingsFName)
+			if err != nil {
+				fmt.Fprintf(eout, "%s\n", err)
+				os.Exit(1)
+			}
+			var ok bool
+			if ds, ok = config.Repositories[repoID]; !ok {
+				fmt.Fprintf(eout, "repository %q not found in %s\n", repoID, settingsFName)
+				os.Exit(1)
+			}
+			if ds.DSN != "" {
+				// Only connect to the repository being validated
+				config.Repositories = map[string]*eprinttools.DataSource{repoID: ds}
+				if err := eprinttools.OpenConnections(config); err != nil {
+					fmt.Fprintf(eout, "%s\n", err)
+					os.Exit(1)
+				}
+				// NOTE: the tables and column types are read when
+				// connecting, the connection isn't needed after that.
+				eprinttools.CloseConnections(config)
+			}
+		}
+		reports := eprinttools.ValidateEPrints(ds, eprintsList)
+		src, err := json.MarshalIndent(reports, "", "   ")
+		if err != nil {
+			fmt.Fprintf(eout, "%s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(out, "%s\n", src)
+		invalid := 0
+		for _, report := range reports {
+			if !report.Valid {
+				invalid++
+				if !quiet {
+					fmt.Fprintf(eout, "WARNING: %q is not valid, %d problem(s)\n", report.DOI, len(report.Problems))
+				}
+			}
+		}
+		if invalid > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if asSimplified {
 		fmt.Fprintln(out, "[")
 		if eprintsList != nil && eprintsList.EPrint != nil {
@@ -70,6 +70,8 @@ There are two end points that give you information about what repositories are c
 
 Unique ids maybe standards based (e.g. ORCID, DOI, ISSN, ISBN) or internal (e.g. group ids, funder ids)
 
+The DOI, ORCID, ISSN, ISBN, PMID and PMCID end points accept any common form of the identifier and match the forms a record is usually stored in, e.g. a DOI with or without "https://doi.org/", an ORCID with or without hyphens or an ISBN-10 for a record stored with its ISBN-13. Check digits are verified, values that aren't valid identifiers are looked up as given.
+
 - '/{REPO_ID}/doi/{DOI}' with the adoption of EPrints "doi" field in the EPrint table it makes sense to have a quick translation of DOI to EPrint id for a given EPrints repository.
 - '/{REPO_ID}/pmid/{PMID}' with the "pmid" field in the EPrint table, it refers to PubMed is an index of the biomedical literature.
 - '/{REPO_ID}/pmcid/{PMCID}' with the "pmcid" field in the EPrint table, PMCID an Identifier to each full-text paper in PubMed Central Archive
@@ -119,6 +121,8 @@ Unique ids maybe standards based (e.g. ORCID, DOI, ISSN, ISBN) or internal (e.g.
 - '/{REPO_ID}/patent-assignee/{PATENT_ASSIGNEE}' - return a list eprint ids for patent assignee in repository
 - '/{REPO_ID}/year' - return a descending list of years containing record with a date type of "published".
 - '/{REPO_ID}/year/{YEAR}' - return a list of eprintid for a given year contaning date type of "published".
+- '/{REPO_ID}/search?{FIELD}={VALUE}&...' returns a list of eprint ids matching several filters (creator_id, creator_orcid, funder, group, issn, status, type) combined with AND or, with "op=or", OR. "is_public=true" and a publication date range ("from", "until") always apply. A status other than "archive" or "is_public=false" requires the read-private scope.
+- '/{REPO_ID}/openapi.json' returns an OpenAPI 3 description of the end points available for the repository. It is generated from the routes registered for the repository so end points depending on columns (e.g. pmid, patent-number) only appear when the repository has them.
 
 
 ## Change Events
@@ -129,17 +133,100 @@ The follow API end points would facilitate faster updates to our feeds platform
 - '/{REPO_ID}/keys' returns complete list of EPrint ID in the repository
 - '/{REPO_ID}/updated/{TIMESTAMP}/{TIMESTAMP}' returns a list of EPrint IDs updated starting at the first timestamp (timestamps should have a resolution to the minute, e.g. "YYYY-MM-DD HH:MM:SS") through inclusive of the second timestmap (if the second is omitted the timestamp is assumed to be "now")
 - '/{REPO_ID}/deleted/{TIMESTAMP}/{TIMESTAMP}' through the returns a list of EPrint IDs deleted starting at first timestamp through inclusive of the second timestamp, if the second timestamp is omitted it is assumed to be "now"
+- '/{REPO_ID}/changes' returns the created, updated and deleted EPrints ordered by lastmod as an Atom feed, or as a Server-Sent Events stream when "text/event-stream" is requested. Each change reports the eprint id, action and timestamp. Pass the cursor of the last change seen (the feed's "next" link or the event id) as "since" to resume after downtime.
 - '/{REPO_ID}/pubdate/{APROX_DATESTAMP}/{APPOX_DATESTMP}' this query scans the EPrint table for records with publication starts starting with the first approximate date through inclusive of the second approximate date. If the second date is omitted it is assumed to be "today". Approximate dates my be expressed just the year (starting with Jan 1, ending with Dec 31), just the year and month (starting with first day of month ending with the last day) or year, month and day. The end returns zero or more EPrint IDs.
 
+## Pagination
+
+The end points returning lists (e.g. keys, updated, creator-id, year) accept the query parameters "limit", "offset" and "order" ("asc" or "desc"). When any of them are given the list is returned in an object with "total", "limit", "offset", "order", "items" and a "next" link. The "next" link holds an opaque "cursor" parameter which replaces "limit", "offset" and "order" for the following page. The limit can be at most 10000.
+
+~~~
+    /{REPO_ID}/keys?limit=100&order=desc
+~~~
+
 ## Read/Write API
 
-As of __{app_name}__ version 1.0.3 a new set of end points exists for reading (retreiving EPrints XML) and writing (metadata import) of EPrints XML.  The extended API only supports working with EPrints metadata not directly with the documents or files associated with individual records.
+As of __{app_name}__ version 1.0.3 a new set of end points exists for reading (retreiving EPrints XML) and writing (metadata import) of EPrints XML.  Documents and files can be attached to an existing record with the document-upload end point.
 
 The metadata import functionality is enabled per repository. It only supports importing records at this time.  Importing an EPrint XML document, which could containing multiple EPrint metadata records, is implemented purely using SQL statements and not the EPrints Perl API. This allows you (with the right MySQL configuration) to run the extended API on a different server without resorting to Perl.
 
-- '/{REPO_ID}/eprint/{EPRINT_ID}' method GET with a content type of "application/json" (JSON of EPrint XML) or "application/xml" for EPrint XML
+- '/{REPO_ID}/eprint/{EPRINT_ID}' method GET returns the EPrint record, EPrint XML by default, see Content Negotiation for other formats
+- '/{REPO_ID}/record/{EPRINT_ID}' method GET returns the EPrint record, a simplified JSON record by default, see Content Negotiation for other formats
 - '/{REPO_ID}/eprint-import' POST accepts EPrints XML with content type of "application/xml" or JSON of EPrints XML with content type "application/json". To enable this feature add the attribute '"write": true' to the repositories setting in settins.json.
+- '/{REPO_ID}/document-upload/{USER_ID}/{EPRINT_ID}' POST accepts multipart/form-data with one or more "file" parts and attaches them as a new document of an existing EPrint. The files are written into the EPrint's directory below "archive_path" and the size, MIME type and hash are recorded. The "security", "content" and "license" form fields set the document's values. Requires '"write": true' and "archive_path" in the repository's settings.
+- '/{REPO_ID}/eprint-validate' POST checks EPrints XML or JSON the way eprint-import would receive it without writing anything. Each record is checked against the repository's "validation" rules (types, date types and required fields per type) and its column types, a report listing the problems is returned for each record.
+- '/{REPO_ID}/eprint-status/{USER_ID}/{STATUS}/{EPRINT_ID}' PUT or POST moves an EPrint between the inbox, buffer, archive and deletion statuses, omit {EPRINT_ID} and send a JSON array of EPrint IDs to move a batch. Only the transitions offered by the EPrints UI are allowed. The status_changed and lastmod fields are updated, a history entry is recorded for {USER_ID} and the change is logged. When moving to the buffer the body may be a JSON object with "eprint_ids" and the "userid" and "reviewer" the EPrints are assigned to, it is an error if the repository has no column for the assignment. A batch returns 207 if only some were moved. Requires '"write": true'.
+- '/{REPO_ID}/user-create' POST creates a user from a JSON or XML EPrintUser, an optional "password" is stored the way EPrints does. Usernames and emails are validated. Requires '"write": true'.
+- '/{REPO_ID}/user-update/{USERID}' PUT or POST updates a user with the fields of a JSON or XML EPrintUser, fields not included are unchanged. Requires '"write": true'.
+- '/{REPO_ID}/user-deactivate/{USERID}' PUT or POST removes a user's password so they can no longer log in. Requires '"write": true'.
+- '/{REPO_ID}/users-by-type/{USERTYPE}' lists the users with a usertype (user, editor, admin or minuser). Users returned never include a password.
+
+
+## Content Negotiation
+
+The eprint and record end points pick their output format from the "Accept" header. Quality values are honored (e.g. "application/json;q=0.9, application/xml;q=0.5") and a "format" query parameter overrides the header. A request that can't be satisfied returns 406 Not Acceptable. For clients that send a "Content-Type" but no "Accept" header the content type is used. The formats are
+
+- "eprint-xml", "application/xml" (or "text/xml") for EPrints XML
+- "eprint-json", "application/json" for the JSON version of EPrint XML
+- "simplified", "application/vnd.caltechlibrary.simplified+json" for a simplified JSON record
+- "dc", "application/oai_dc+xml" for Dublin Core (oai_dc)
+- "bibtex", "application/x-bibtex" for BibTeX
+- "ris", "application/x-research-info-systems" for RIS
+- "csl-json", "application/vnd.citationstyles.csl+json" for CSL-JSON
+- "endnote", "application/x-endnote+xml" for EndNote XML
+
+~~~
+    /{REPO_ID}/eprint/{EPRINT_ID}?format=bibtex
+~~~
+## Caching
+
+The eprint and record end points send an "ETag" (based on the eprint id, rev_number and format) and a "Last-Modified" header (from lastmod). Requests with a matching "If-None-Match" or an "If-Modified-Since" no earlier than lastmod get a 304 Not Modified response without the record being read. Non-public records are marked "Cache-Control: private".
+
+Setting "cache_size" in the settings file keeps that many rendered responses in memory. A cached response is used until the eprint's rev_number or lastmod changes.
+
+~~~
+    curl -H 'If-None-Match: "1234-5-eprint-xml"' http://localhost:8484/lemurprints/eprint/1234
+~~~
+
+## Monitoring
+
+__ep3apid__ provides end points for checking on the service itself.
 
+- '/healthz' returns "ok" while the service is running (liveness)
+- '/readyz' pings each repository database and the JSON store, returning 200 when all answer and 503 otherwise. The response is a JSON object with a "status" and the result of each check.
+- '/metrics' returns request counts, latency histograms and error counts per route along with database connection pool statistics in the Prometheus text format
+
+These end points don't take a repository id and are answered without credentials so a process supervisor or a Prometheus server can poll them.
+
+~~~
+    curl http://localhost:8484/readyz
+~~~
+
+
+## Authentication
+
+By default __{app_name}__ answers any request that reaches it. Adding "api_tokens" to the settings file, or setting '"basic_auth": true' for a repository, turns on authentication. Each end point then requires a scope for the repository requested.
+
+- read-public, identifier lookups and public records (granted to anonymous requests by default, see "anonymous_scopes")
+- read-private, non-public records, key lists, change events and user information
+- import, '/{REPO_ID}/eprint-import', '/{REPO_ID}/eprint-update', '/{REPO_ID}/eprint-validate', '/{REPO_ID}/document-upload' and '/{REPO_ID}/eprint-status'
+- admin, all of the above plus '/{REPO_ID}/user-create', '/{REPO_ID}/user-update', '/{REPO_ID}/user-deactivate' and '/{REPO_ID}/users-by-type'
+
+API tokens are sent as 'Authorization: Bearer NAME:SECRET'. Only a bcrypt hash of the secret is kept in the settings file, use the '-token' option to generate one. When "basic_auth" is enabled HTTP Basic credentials are checked against the EPrints user table of the repository, "admin" users get the admin scope, "editor" users read-private and import and other users read-public.
+
+~~~
+    {
+        "api_tokens": [
+            {
+                "name": "feeds",
+                "hash": "$2a$10$...",
+                "scopes": { "REPO_ID": [ "read-private" ] }
+            }
+        ],
+        "anonymous_scopes": [ "read-public" ],
+        ...
+    }
+~~~
 
 ## settings.json (configuration)
 
@@ -178,6 +265,9 @@ NOTE: The "default_collection", "default_official_url", "default_rights", "defau
 -license
 : Display software license
 
+-token NAME
+: Generate a secret and hash for a new API token named NAME
+
 -version
 : Display software version
 
@@ -210,6 +300,8 @@ or to load "settings.json" from the current work directory.
 
 	debugLogs bool
 
+	tokenName string
+
 	logFile string
 )
 
@@ -237,6 +329,7 @@ func main() {
 	flag.BoolVar(&showHelp, "help", false, "Display this help message")
 	flag.BoolVar(&showVersion, "version", false, "Display software version")
 	flag.BoolVar(&showLicense, "license", false, "Display software license")
+	flag.StringVar(&tokenName, "token", "", "Generate a secret and hash for a new API token named NAME")
 
 	flag.Parse()
 	args := flag.Args()
@@ -261,6 +354,21 @@ func main() {
 		os.Exit(0)
 	}
 
+	if tokenName != "" {
+		secret, err := eprinttools.GenerateAPISecret()
+		if err == nil {
+			var hash string
+			hash, err = eprinttools.HashAPISecret(secret)
+			if err == nil {
+				fmt.Fprintf(out, "Authorization: Bearer %s:%s\n\n", tokenName, secret)
+				fmt.Fprintf(out, "{\n    \"name\": %q,\n    \"hash\": %q,\n    \"scopes\": { \"REPO_ID\": [ %q ] }\n}\n", tokenName, hash, eprinttools.ScopeReadPublic)
+				os.Exit(0)
+			}
+		}
+		fmt.Fprintf(eout, "failed to generate token, %s\n", err)
+		os.Exit(1)
+	}
+
 	/* Looking settings.json */
 	settings := "settings.json"
 	if len(args) > 0 {
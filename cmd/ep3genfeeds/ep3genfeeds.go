@@ -77,6 +77,20 @@ ep3harvester tool.
 -people
 : render people feeds
 
+-index
+: render search index documents for each repository to
+htdocs/search/REPO_ID/ (solr.json, opensearch.ndjson, lunr.json
+and pagefind-entry.json)
+
+-index-format
+: (string) a comma separated list of index formats to render,
+solr, opensearch, lunr or pagefind (default is all formats)
+
+-citation-format
+: (string) a comma separated list of citation formats rendered
+next to the group and people record lists, bibtex, ris, csl-json
+or endnote (default is all formats, "none" disables them)
+
 -verbose
 : use verbose logging
 
@@ -88,6 +102,18 @@ Harvesting repositories for week month of May, 2022.
     {app_name} harvester-settings.json
 ~~~
 
+Render a Pagefind and LunrJS search index.
+
+~~~
+    {app_name} -index -index-format=pagefind,lunr harvester-settings.json
+~~~
+
+Render the people feeds with only BibTeX and RIS citation files.
+
+~~~
+    {app_name} -people -citation-format=bibtex,ris harvester-settings.json
+~~~
+
 {app_name} {version}
 
 `
@@ -101,6 +127,9 @@ Harvesting repositories for week month of May, 2022.
 	// App Option
 	people bool
 	groups bool
+	index bool
+	indexFormat string
+	citationFormat string
 )
 
 func fmtTxt(src string, appName string, version string) string {
@@ -118,6 +147,9 @@ func main() {
 	flag.BoolVar(&verbose, "verbose", false, "use verbose logging")
 	flag.BoolVar(&people, "people", false, "render people feeds")
 	flag.BoolVar(&groups, "groups", false, "render groups feeds")
+	flag.BoolVar(&index, "index", false, "render search index documents")
+	flag.StringVar(&indexFormat, "index-format", "", "comma separated list of index formats (solr, opensearch, lunr, pagefind)")
+	flag.StringVar(&citationFormat, "citation-format", "", "comma separated list of citation formats (bibtex, ris, csl-json, endnote) or none")
 
 
 	// We're ready to process args
@@ -149,12 +181,32 @@ func main() {
 		settings = args[0]
 	}
 
+	switch citationFormat {
+		case "":
+		case "none":
+			eprinttools.FeedCitationFormats = []string{}
+		default:
+			eprinttools.FeedCitationFormats = strings.Split(citationFormat, ",")
+			for _, name := range eprinttools.FeedCitationFormats {
+				if _, ok := eprinttools.CitationFormats[name]; !ok {
+					fmt.Fprintf(eout, "unsupported citation format %q\n", name)
+					os.Exit(1)
+				}
+			}
+	}
+
 	t0 := time.Now()
 	switch {
 		case people:
 			err = eprinttools.RunGenPeople(settings, verbose)
 		case groups:
 			err = eprinttools.RunGenGroups(settings, verbose)
+		case index:
+			formats := []string{}
+			if indexFormat != "" {
+				formats = strings.Split(indexFormat, ",")
+			}
+			err = eprinttools.RunGenIndex(settings, formats, verbose)
 		default:
 			err = eprinttools.RunGenfeeds(settings, verbose)
 	}
@@ -63,6 +63,15 @@ Each MySQL 8 table has several columns id, src (holding the JSON
 document as a JSON column) and an updated (holding the timestamp
 of when the metadata was harvested).
 
+If START_TIMESTAMP is omitted {app_name} harvests each repository
+from its last checkpoint. Checkpoints are kept in the
+_harvest_checkpoints table of the JSON store and hold the end of the
+last range harvested, the status of the last run and counts of records
+harvested and failed. A checkpoint is only advanced when all the
+records in the range were harvested. A repository without a
+checkpoint is harvested from the beginning. Harvesting with
+-eprintids does not change the checkpoint.
+
 # CONFIGURATION
 
 {app_name} can generate an example settings JSON document. You
@@ -122,6 +131,12 @@ before saving the JSON to the SQL database.
 -verbose
 : use verbose logging
 
+-workers int
+: the number of workers used to read EPrint records concurrently,
+overrides "harvest_workers" in the settings file. Setting
+"max_connections" for a repository limits the database connections
+the workers share.
+
 # EXAMPLES
 
 Harvesting repositories for the month of May, 2022.
@@ -139,6 +154,19 @@ for week month of the month of May, 2022.
         "2022-05-01 00:00:00" "2022-05-31 59:59:59"
 ~~~
 
+Harvesting caltechauthors using eight workers.
+
+~~~
+    {app_name} -repo caltechauthors -workers 8 harvester-settings.json
+~~~
+
+Harvesting all repositories since their last checkpoint (e.g.
+from a cron job).
+
+~~~
+    {app_name} harvester-settings.json
+~~~
+
 {app_name} {version}
 
 `
@@ -158,6 +186,7 @@ for week month of the month of May, 2022.
 	peopleAndGroups     bool
 	useSimplifiedRecord bool
 	verbose             bool
+	workers             int
 )
 
 func fmtTxt(src string, appName string, version string) string {
@@ -181,6 +210,7 @@ func main() {
 	flag.BoolVar(&useSimplifiedRecord, "simple", false, "Crosswalk harvested eprint records storing simplified model")
 	flag.StringVar(&repoName, "repo", "", "Harvest a specific repository id defined in configuration")
 	flag.StringVar(&keyList, "eprintids", "", "Harvest the eprintids indicated in the named file, one eprintid per line")
+	flag.IntVar(&workers, "workers", 0, "number of workers used to read EPrint records concurrently")
 
 	// We're ready to process args
 	flag.Parse()
@@ -236,6 +266,8 @@ func main() {
 		os.Exit(1)
 	}
 
+	eprinttools.HarvestWorkers = workers
+
 	t0 := time.Now()
 	// Handle request to show schema.
 	switch {
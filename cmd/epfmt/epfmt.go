@@ -39,6 +39,7 @@ import (
 const (
 	IsXML = iota
 	IsJSON
+	IsSimplified
 )
 
 var (
@@ -77,6 +78,15 @@ input.
 
 # OPTIONS
 
+-bibtex
+: output BibTeX
+
+-csl
+: output CSL-JSON
+
+-endnote
+: output EndNote XML
+
 -help
 : display help
 
@@ -98,9 +108,17 @@ input.
 -quiet
 : suppress error messages
 
+-ris
+: output RIS
+
 -s, -simple
 : output simplified JSON version of EPrints XML
 
+-verify
+: verify the input survives a round trip through EPrint XML,
+JSON and simplified JSON, lists any fields that differ and
+exits with an error if there are differences
+
 -version
 : display version
 
@@ -127,11 +145,35 @@ Render EPrint JSON as EPrint XML.
     {app_name} -xml < 123.json
 ~~~
 
+Render a simplified JSON record (e.g. from CaltechDATA or
+RDM) as EPrint XML. Simplified JSON input is detected
+automatically, a single record or a JSON array of records
+is accepted.
+
+~~~
+    {app_name} -xml < 123-simple.json
+~~~
+
+Render EPrint XML as BibTeX for a reference manager. The
+'-ris', '-csl' and '-endnote' options work the same way.
+
+~~~
+    {app_name} -bibtex < 123.xml > 123.bib
+~~~
+
+Verify an EPrint XML document round trips without losing
+fields.
+
+~~~
+    {app_name} -verify < 123.xml
+~~~
+
 {app_name} will first parse the XML or JSON 
 presented to it and pretty print the output 
 in the desired format requested. If no 
 format option chosen it will pretty print 
-in the same format as input.
+in the same format as input. Simplified JSON
+input is rendered as EPrint JSON by default.
 
 {app_name} {version}
 
@@ -154,12 +196,61 @@ in the same format as input.
 	asJSON       bool
 	asXML        bool
 	asSimplified bool
+	verify       bool
+	asBibTeX     bool
+	asRIS        bool
+	asCSL        bool
+	asEndNote    bool
 )
 
 func fmtTxt(src string, appName string, version string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(src, "{app_name}", appName), "{version}", version)
 }
 
+// isSimplifiedJSON checks if the JSON source holds a simplified
+// record (or list of records) rather than EPrint JSON.
+func isSimplifiedJSON(src []byte) bool {
+	var m map[string]json.RawMessage
+	if bytes.HasPrefix(src, []byte("[")) {
+		l := []map[string]json.RawMessage{}
+		if err := json.Unmarshal(src, &l); err != nil || len(l) == 0 {
+			return false
+		}
+		m = l[0]
+	} else if err := json.Unmarshal(src, &m); err != nil {
+		return false
+	}
+	_, hasEPrint := m["eprint"]
+	_, hasMetadata := m["metadata"]
+	return hasMetadata && !hasEPrint
+}
+
+// simplifiedToEPrints crosswalks simplified JSON record(s) into
+// an EPrints structure.
+func simplifiedToEPrints(src []byte) (*eprinttools.EPrints, error) {
+	records := []*simplified.Record{}
+	if bytes.HasPrefix(src, []byte("[")) {
+		if err := json.Unmarshal(src, &records); err != nil {
+			return nil, err
+		}
+	} else {
+		rec := new(simplified.Record)
+		if err := json.Unmarshal(src, &rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	eprints := eprinttools.NewEPrints()
+	for _, rec := range records {
+		eprint := new(eprinttools.EPrint)
+		if err := eprinttools.CrosswalkRecordToEPrint(rec, eprint); err != nil {
+			return nil, err
+		}
+		eprints.Append(eprint)
+	}
+	return eprints, nil
+}
+
 func main() {
 	var (
 		inputFmt int
@@ -186,6 +277,11 @@ func main() {
 	flag.BoolVar(&asJSON, "json", false, "output JSON version of EPrint XML")
 	flag.BoolVar(&asSimplified, "s", false, "output simple JSON record version of EPrints XML")
 	flag.BoolVar(&asSimplified, "simple", false, "output simple JSON record version of EPrints XML")
+	flag.BoolVar(&asBibTeX, "bibtex", false, "output BibTeX")
+	flag.BoolVar(&asRIS, "ris", false, "output RIS")
+	flag.BoolVar(&asCSL, "csl", false, "output CSL-JSON")
+	flag.BoolVar(&asEndNote, "endnote", false, "output EndNote XML")
+	flag.BoolVar(&verify, "verify", false, "verify the input round trips through EPrint XML, JSON and simplified JSON")
 
 	// We're ready to process args
 	flag.Parse()
@@ -256,8 +352,12 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Check if JSON or XML
-	if bytes.HasPrefix(src, []byte("{")) {
+	// Check if JSON, simplified JSON or XML
+	if isSimplifiedJSON(src) {
+		// Crosswalk simplified record(s) to EPrints
+		inputFmt = IsSimplified
+		obj, err = simplifiedToEPrints(src)
+	} else if bytes.HasPrefix(src, []byte("{")) {
 		// Unmarshal as JSON
 		inputFmt = IsJSON
 		err = json.Unmarshal(src, &obj)
@@ -271,9 +371,47 @@ func main() {
 		os.Exit(1)
 	}
 
+	if verify {
+		var diffs []*eprinttools.RoundTripDiff
+		if inputFmt == IsXML {
+			diffs, err = eprinttools.VerifyRoundTrip(src)
+		} else {
+			diffs, err = eprinttools.VerifyEPrintsRoundTrip(obj)
+		}
+		if err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		for _, diff := range diffs {
+			fmt.Fprintln(out, diff.String())
+		}
+		if len(diffs) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	for _, e := range obj.EPrint {
 		e.SyntheticFields()
 	}
+	citationFormat := ""
+	switch {
+	case asBibTeX:
+		citationFormat = "bibtex"
+	case asRIS:
+		citationFormat = "ris"
+	case asCSL:
+		citationFormat = "csl-json"
+	case asEndNote:
+		citationFormat = "endnote"
+	}
+	if citationFormat != "" {
+		if err := eprinttools.WriteCitations(out, citationFormat, obj.EPrint); err != nil {
+			fmt.Fprintln(eout, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if asJSON == false && asXML == false {
 		asXML = (inputFmt == IsXML)
 	}
@@ -52,6 +52,28 @@ type Config struct {
 	// Jdb holds the MySQL connector to the jsonstore
 	Jdb *sql.DB `json:"-"`
 
+	// HarvestWorkers is the number of goroutines used to read
+	// EPrint records when harvesting a repository. The default
+	// is one (i.e. harvest serially).
+	HarvestWorkers int `json:"harvest_workers,omitempty"`
+
+	// APITokens are the tokens accepted by ep3apid. Each token grants
+	// scopes (read-public, read-private, import, admin) per repository.
+	APITokens []*APIToken `json:"api_tokens,omitempty"`
+
+	// AnonymousScopes are the scopes granted to requests without
+	// credentials when authentication is enabled. The default is
+	// read-public.
+	AnonymousScopes []string `json:"anonymous_scopes,omitempty"`
+
+	// CacheSize is the number of rendered eprint and record responses
+	// ep3apid keeps in memory. Zero (the default) disables the cache.
+	CacheSize int `json:"cache_size,omitempty"`
+
+	// page, when set, limits the id lists returned by the SQL
+	// queries, see WithPage.
+	page *Page
+
 	// Routes holds the mapping of end points to repository id
 	// instances.
 	Routes map[string]map[string]func(http.ResponseWriter, *http.Request, string, []string) (int, error) `json:"-"`
@@ -66,6 +88,10 @@ type Config struct {
 	// PandocServer is the URL to the Pandoc server
 	// E.g. localhost:8080
 	PandocServer string `json:"pandoc_server,omitempty"`
+
+	// Crosswalk holds additions or overrides to the default EPrint to
+	// Invenio-RDM crosswalk vocabularies.
+	Crosswalk *CrosswalkConfig `json:"crosswalk,omitempty"`
 }
 
 // DataSource can contain one or more types of datasources. E.g.
@@ -112,9 +138,37 @@ type DataSource struct {
 	// the repository presented.
 	TableMap map[string][]string `json:"tables,omitempty"`
 
+	// ColumnTypes holds the MySQL column types of the tables in
+	// TableMap, it is populated when connections are opened.
+	ColumnTypes map[string]map[string]string `json:"-"`
+
+	// Validation holds the rules used to validate EPrint records
+	// before import along with the column types. If nil the
+	// default rules are used.
+	Validation *ValidationRules `json:"validation,omitempty"`
+
 	// PublicOnly is a boolean indicating if the "harvested" content
 	// should be restricted to public records.
 	PublicOnly bool `json:"is_public,omitempty"`
+
+	// MaxConnections limits the number of open connections to the
+	// repository's database, e.g. when harvesting with workers.
+	// Zero means no limit.
+	MaxConnections int `json:"max_connections,omitempty"`
+
+	// BasicAuth enables HTTP Basic authentication in ep3apid against
+	// the repository's EPrints user table.
+	BasicAuth bool `json:"basic_auth,omitempty"`
+
+	// ArchivePath is the EPrints "documents" directory of the archive,
+	// e.g. /usr/share/eprints/archives/REPO_ID/documents. Uploaded
+	// files are written below it in the eprint's directory. Uploads
+	// require write to be true and ArchivePath to be set.
+	ArchivePath string `json:"archive_path,omitempty"`
+
+	// HashType is the hash recorded for uploaded files, "MD5" (the
+	// EPrints default), "SHA-1" or "SHA-256".
+	HashType string `json:"hash_type,omitempty"`
 }
 
 func DefaultConfig() []byte {
@@ -175,6 +229,9 @@ func LoadConfig(fname string) (*Config, error) {
 		if config.Htdocs == "" {
 			config.Htdocs = "htdocs"
 		}
+		if config.Crosswalk != nil {
+			SetCrosswalkConfig(config.Crosswalk)
+		}
 	}
 	return config, nil
 }
@@ -32,12 +32,151 @@ package eprinttools
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	// Caltech Library Packages
 	"github.com/caltechlibrary/simplified"
 )
 
+// CrosswalkConfig holds the vocabulary mappings used by
+// CrosswalkEPrintToRecord. Values set in the "crosswalk" attribute
+// of settings.json are merged with DefaultCrosswalkConfig().
+type CrosswalkConfig struct {
+	// ResourceTypes maps an EPrint type to an Invenio-RDM resource
+	// type. A key of the form "TYPE:SUBTYPE" (e.g.
+	// "monograph:technical_report" or "conference_item:poster") takes
+	// precedence over "TYPE". Subtypes come from monograph_type,
+	// pres_type and thesis_type.
+	ResourceTypes map[string]string `json:"resource_types,omitempty"`
+
+	// RelationTypes maps a related_url type (e.g. "doi", "pub") to an
+	// Invenio-RDM relation type.
+	RelationTypes map[string]string `json:"relation_types,omitempty"`
+
+	// Subjects maps an EPrint subject code to a display label.
+	Subjects map[string]string `json:"subjects,omitempty"`
+
+	// EPrintTypes maps an Invenio-RDM resource type back to an EPrint
+	// type (optionally "TYPE:SUBTYPE"). It is used by
+	// CrosswalkRecordToEPrint.
+	EPrintTypes map[string]string `json:"eprint_types,omitempty"`
+}
+
+// DefaultCrosswalkConfig returns the Caltech Library mapping of
+// EPrint types and related_url types to Invenio-RDM vocabularies.
+func DefaultCrosswalkConfig() *CrosswalkConfig {
+	return &CrosswalkConfig{
+		ResourceTypes: map[string]string{
+			"article":                       "publication-article",
+			"audio":                         "audio",
+			"book":                          "publication-book",
+			"book_section":                  "publication-section",
+			"collection":                    "other",
+			"conference_item":               "publication-conferencepaper",
+			"conference_item:poster":        "poster",
+			"conference_item:speech":        "presentation",
+			"conference_item:keynote":       "presentation",
+			"conference_item:lecture":       "presentation",
+			"conference_item:other":         "presentation",
+			"dataset":                       "dataset",
+			"experiment":                    "dataset",
+			"image":                         "image",
+			"journal_issue":                 "publication-other",
+			"lab_notes":                     "publication-other",
+			"monograph":                     "publication-report",
+			"monograph:documentation":       "publication-softwaredocumentation",
+			"monograph:discussion_paper":    "publication-workingpaper",
+			"monograph:manual":              "publication-technicalnote",
+			"monograph:project_report":      "publication-report",
+			"monograph:technical_report":    "publication-technicalnote",
+			"monograph:working_paper":       "publication-workingpaper",
+			"oral_history":                  "publication-other",
+			"other":                         "other",
+			"patent":                        "publication-patent",
+			"software":                      "software",
+			"teaching_resource":             "lesson",
+			"thesis":                        "publication-thesis",
+			"video":                         "video",
+		},
+		RelationTypes: map[string]string{
+			"arxiv":   "isvariantformof",
+			"doi":     "isidenticalto",
+			"pmc":     "isvariantformof",
+			"pub":     "isvariantformof",
+			"related": "references",
+		},
+		Subjects: map[string]string{},
+		EPrintTypes: map[string]string{
+			"audio":                             "audio",
+			"dataset":                           "dataset",
+			"image":                             "image",
+			"image-figure":                      "image",
+			"image-photo":                       "image",
+			"lesson":                            "teaching_resource",
+			"other":                             "other",
+			"poster":                            "conference_item:poster",
+			"presentation":                      "conference_item:lecture",
+			"publication":                       "other",
+			"publication-article":               "article",
+			"publication-book":                  "book",
+			"publication-conferencepaper":       "conference_item",
+			"publication-conferenceproceeding":  "book",
+			"publication-other":                 "other",
+			"publication-patent":                "patent",
+			"publication-preprint":              "article",
+			"publication-report":                "monograph",
+			"publication-section":               "book_section",
+			"publication-softwaredocumentation": "monograph:documentation",
+			"publication-technicalnote":         "monograph:technical_report",
+			"publication-thesis":                "thesis",
+			"publication-workingpaper":          "monograph:working_paper",
+			"software":                          "software",
+			"video":                             "video",
+		},
+	}
+}
+
+var (
+	// activeCrosswalkConfig is the crosswalk configuration in use,
+	// it is replaced (never changed) by SetCrosswalkConfig.
+	activeCrosswalkConfig = DefaultCrosswalkConfig()
+	crosswalkConfigMutex  sync.RWMutex
+)
+
+// crosswalkConfig returns the active crosswalk configuration. The
+// value returned must not be modified.
+func crosswalkConfig() *CrosswalkConfig {
+	crosswalkConfigMutex.RLock()
+	defer crosswalkConfigMutex.RUnlock()
+	return activeCrosswalkConfig
+}
+
+// SetCrosswalkConfig merges cfg into the default crosswalk
+// configuration and makes it the active one.
+func SetCrosswalkConfig(cfg *CrosswalkConfig) {
+	merged := DefaultCrosswalkConfig()
+	if cfg != nil {
+		for k, v := range cfg.ResourceTypes {
+			merged.ResourceTypes[k] = v
+		}
+		for k, v := range cfg.RelationTypes {
+			merged.RelationTypes[k] = v
+		}
+		for k, v := range cfg.Subjects {
+			merged.Subjects[k] = v
+		}
+		for k, v := range cfg.EPrintTypes {
+			merged.EPrintTypes[k] = v
+		}
+	}
+	crosswalkConfigMutex.Lock()
+	activeCrosswalkConfig = merged
+	crosswalkConfigMutex.Unlock()
+}
+
+// CrosswalkEPrintToRecord maps an EPrint into an Invenio-RDM
+// style simplified.Record.
 func CrosswalkEPrintToRecord(eprint *EPrint, rec *simplified.Record) error {
 	rec.Schema = `local://records/record-v2.0.0.json`
 	rec.ID = fmt.Sprintf("%s:%d", eprint.Collection, eprint.EPrintID)
@@ -55,6 +194,9 @@ func CrosswalkEPrintToRecord(eprint *EPrint, rec *simplified.Record) error {
 	if err := metadataFromEPrint(eprint, rec); err != nil {
 		return err
 	}
+	if err := customFieldsFromEPrint(eprint, rec); err != nil {
+		return err
+	}
 	if err := filesFromEPrint(eprint, rec); err != nil {
 		return err
 	}
@@ -74,7 +216,7 @@ func CrosswalkEPrintToRecord(eprint *EPrint, rec *simplified.Record) error {
 	}
 	*/
 	// Now finish simple record normalization ...
-	if err := mapResourceType(rec); err != nil {
+	if err := mapResourceType(eprint, rec); err != nil {
 		return err
 	}
 	if err := simplifyCreators(rec); err != nil {
@@ -83,8 +225,6 @@ func CrosswalkEPrintToRecord(eprint *EPrint, rec *simplified.Record) error {
 	if err := simplifyContributors(rec); err != nil {
 		return err
 	}
-	// FIXME: Map eprint record types to invenio RDM record types we've
-	// decided on.
 	// FIXME: Funders must have a title, could just copy in the funder
 	// name for now.
 	if err := simplifyFunding(rec); err != nil {
@@ -194,20 +334,49 @@ func simplifyFunding(rec *simplified.Record) error {
 	return nil
 }
 
+// eprintSubtype returns the subtype field relevant to the EPrint's type
+func eprintSubtype(eprint *EPrint) string {
+	switch eprint.Type {
+	case "monograph":
+		return eprint.MonographType
+	case "conference_item":
+		return eprint.PresType
+	case "thesis":
+		return eprint.ThesisType
+	}
+	return ""
+}
+
 // mapResourceType maps the EPrints record types to a predetermined
-// Invenio-RDM record type.
-func mapResourceType(rec *simplified.Record) error {
-	// FIXME: need to load this from a configuration file
-	crosswalkResourceTypes := map[string]string{
-		"article": "publication-article",
-	}
+// Invenio-RDM record type. Types not found in the crosswalk
+// configuration are mapped to "other".
+func mapResourceType(eprint *EPrint, rec *simplified.Record) error {
 	if rec.Metadata.ResourceType != nil {
 		// Should always have an id for a reource_type
 		if id, ok := rec.Metadata.ResourceType["id"].(string); ok {
-			if val, hasID := crosswalkResourceTypes[id]; hasID {
-				rec.Metadata.ResourceType["id"] = val
-				//} else { // FIXME: I don't want to implement a full mapping yet.
-				//	return fmt.Errorf("failed to find id %q in record type crosswalk", id)
+			subtype := eprintSubtype(eprint)
+			val := "other"
+			resourceTypes := crosswalkConfig().ResourceTypes
+			if rdmType, hasID := resourceTypes[id+":"+subtype]; hasID && subtype != "" {
+				val = rdmType
+			} else if rdmType, hasID := resourceTypes[id]; hasID {
+				val = rdmType
+			}
+			rec.Metadata.ResourceType["id"] = val
+			// NOTE: Several EPrint types share an Invenio-RDM type, keep
+			// the EPrint type when it can't be recovered from the
+			// resource type so CrosswalkRecordToEPrint is lossless.
+			// Thesis types are kept in the thesis custom field.
+			eprintType, eprintSubtype := eprintTypeFromResourceType(val)
+			if id != "" && (eprintType != id || (subtype != "" && eprintSubtype != subtype && id != "thesis")) {
+				if rec.CustomFields == nil {
+					rec.CustomFields = map[string]interface{}{}
+				}
+				if subtype != "" && id != "thesis" {
+					rec.CustomFields["eprint:type"] = id + ":" + subtype
+				} else {
+					rec.CustomFields["eprint:type"] = id
+				}
 			}
 		}
 	}
@@ -261,7 +430,7 @@ func externalPIDFromEPrint(eprint *EPrint, rec *simplified.Record) error {
 		rec.ExternalPIDs["doi"] = pid
 	}
 	// Pickup ISSN
-	if eprint.ISBN != "" {
+	if eprint.ISSN != "" {
 		pid := new(simplified.PersistentIdentifier)
 		pid.Identifier = eprint.ISSN
 		pid.Provider = ""
@@ -324,8 +493,9 @@ func recordAccessFromEPrint(eprint *EPrint, rec *simplified.Record) error {
 	return nil
 }
 
-func uriToContributorType(role_uri string) string {
-    roles := map[string]string{
+// contributorRoles maps the LOC relator (and local) URIs used for
+// contributor types in EPrints to Invenio-RDM contributor roles.
+var contributorRoles = map[string]string{
         // Article Author
         "http://coda.library.caltech.edu/ARA": "author_section",
         // Astronaut
@@ -368,19 +538,34 @@ func uriToContributorType(role_uri string) string {
         "http://www.loc.gov/loc.terms/relators/TCH": "teacher",
         // Translator
         "http://www.loc.gov/loc.terms/relators/TRL": "translator",
-    }
-	if val, ok := roles[role_uri]; ok {
+}
+
+func uriToContributorType(role_uri string) string {
+	if val, ok := contributorRoles[role_uri]; ok {
 		return val
 	}
 	return "contributor"
 }
 
+// contributorTypeToURI is the inverse of uriToContributorType
+func contributorTypeToURI(role string) string {
+	for uri, val := range contributorRoles {
+		if val == role {
+			return uri
+		}
+	}
+	return "http://www.loc.gov/loc.terms/relators/CTB"
+}
+
 func creatorFromItem(item *Item, objType string, objRoleSrc string, objIdType string) *simplified.Creator {
 	person := new(simplified.PersonOrOrg)
 	person.Type = objType
 	if item.Name != nil {
 		person.FamilyName = item.Name.Family
 		person.GivenName = item.Name.Given
+		if objType == "organizational" {
+			person.Name = strings.TrimSpace(item.Name.Value)
+		}
 	}
 	if item.ORCID != "" {
 		identifier := new(simplified.Identifier)
@@ -407,6 +592,10 @@ func creatorFromItem(item *Item, objType string, objRoleSrc string, objIdType st
 			creator.Role = &simplified.Role{ ID: contributorType }
 		case "editor":
 			creator.Role = &simplified.Role{ ID: "editor" }
+		case "thesis_advisor", "thesis_committee":
+			creator.Role = &simplified.Role{ ID: objRoleSrc }
+		case "corporate_contributor":
+			creator.Role = &simplified.Role{ ID: "contributor" }
 	}
 	// FIXME: For Creators we skip adding the role and affiliation for now,
 	// it break RDM.
@@ -438,6 +627,38 @@ func mkSimpleIdentifier(scheme, value string) *simplified.Identifier {
 	return identifier
 }
 
+func mkRelatedIdentifier(scheme, value, relationType string) *simplified.Identifier {
+	identifier := mkSimpleIdentifier(scheme, value)
+	identifier.RelationType = &simplified.TypeDetail{ID: relationType}
+	return identifier
+}
+
+// relatedIdentifierFromItem maps a related_url item to an
+// Invenio-RDM related identifier. DOI URLs are reduced to the DOI.
+func relatedIdentifierFromItem(item *Item) *simplified.Identifier {
+	value := strings.TrimSpace(item.URL)
+	if value == "" {
+		return nil
+	}
+	scheme := "url"
+	lowerValue := strings.ToLower(value)
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/"} {
+		if strings.HasPrefix(lowerValue, prefix) {
+			scheme, value = "doi", value[len(prefix):]
+			break
+		}
+	}
+	relationType, ok := crosswalkConfig().RelationTypes[item.Type]
+	if !ok {
+		relationType = "references"
+	}
+	identifier := mkRelatedIdentifier(scheme, value, relationType)
+	if item.Description != "" {
+		identifier.Title = item.Description
+	}
+	return identifier
+}
+
 func funderFromItem(item *Item) *simplified.Funder {
 	funder := new(simplified.Funder)
 	if item.GrantNumber != "" {
@@ -562,11 +783,27 @@ func metadataFromEPrint(eprint *EPrint, rec *simplified.Record) error {
 
 	if (eprint.Subjects != nil) && (eprint.Subjects.Items != nil) {
 		for _, item := range eprint.Subjects.Items {
+			code := strings.TrimSpace(item.Value)
+			if code == "" {
+				continue
+			}
 			subject := new(simplified.Subject)
-			subject.Subject = item.Value
+			subject.ID = code
+			subject.Subject = code
+			if label, ok := crosswalkConfig().Subjects[code]; ok {
+				subject.Subject = label
+			}
 			metadata.Subjects = append(metadata.Subjects, subject)
 		}
 	}
+	// Keywords are free text subjects in Invenio-RDM
+	if eprint.Keywords != "" {
+		for _, keyword := range strings.Split(eprint.Keywords, ";") {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				metadata.Subjects = append(metadata.Subjects, &simplified.Subject{Subject: keyword})
+			}
+		}
+	}
 
 	// Dates are scattered through the primary eprint table.
 	if (eprint.DateType != "published") && (eprint.Date != "") {
@@ -578,6 +815,18 @@ func metadataFromEPrint(eprint *EPrint, rec *simplified.Record) error {
 	if eprint.LastModified != "" {
 		metadata.Dates = append(metadata.Dates, dateTypeFromTimestamp("updated", eprint.LastModified, "Created from EPrint's last_modified field"))
 	}
+	if eprint.ThesisSubmittedDate != "" {
+		metadata.Dates = append(metadata.Dates, dateTypeFromTimestamp("submitted", eprint.ThesisSubmittedDate, "Thesis submitted date"))
+	}
+	if eprint.ThesisDefenseDate != "" {
+		metadata.Dates = append(metadata.Dates, dateTypeFromTimestamp("other", eprint.ThesisDefenseDate, "Thesis defense date"))
+	}
+	if eprint.ThesisApprovedDate != "" {
+		metadata.Dates = append(metadata.Dates, dateTypeFromTimestamp("accepted", eprint.ThesisApprovedDate, "Thesis approved date"))
+	}
+	if eprint.ThesisDegreeDate != "" {
+		metadata.Dates = append(metadata.Dates, dateTypeFromTimestamp("issued", eprint.ThesisDegreeDate, "Thesis degree date"))
+	}
 	/*
 		// status_changed is not a date type in Invenio-RDM, might be mapped
 		// into available object.
@@ -605,15 +854,45 @@ func metadataFromEPrint(eprint *EPrint, rec *simplified.Record) error {
 	if eprint.DOI != "" {
 		metadata.Identifiers = append(metadata.Identifiers, mkSimpleIdentifier("doi", eprint.DOI))
 	}
+	// NOTE: An ISBN identifies a book or monograph, for a book section
+	// or conference item it identifies the containing work.
 	if eprint.ISBN != "" {
-		metadata.Identifiers = append(metadata.Identifiers, mkSimpleIdentifier("isbn", eprint.ISBN))
+		switch eprint.Type {
+		case "book_section", "conference_item":
+			metadata.RelatedIdentifiers = append(metadata.RelatedIdentifiers, mkRelatedIdentifier("isbn", eprint.ISBN, "ispartof"))
+		default:
+			metadata.Identifiers = append(metadata.Identifiers, mkSimpleIdentifier("isbn", eprint.ISBN))
+		}
 	}
+	// NOTE: An ISSN identifies the journal or series the work was
+	// published in.
 	if eprint.ISSN != "" {
-		metadata.Identifiers = append(metadata.Identifiers, mkSimpleIdentifier("issn", eprint.ISSN))
+		metadata.RelatedIdentifiers = append(metadata.RelatedIdentifiers, mkRelatedIdentifier("issn", eprint.ISSN, "ispublishedin"))
+	}
+	if eprint.PMID != "" {
+		metadata.Identifiers = append(metadata.Identifiers, mkSimpleIdentifier("pmid", eprint.PMID))
 	}
 	if eprint.PMCID != "" {
 		metadata.Identifiers = append(metadata.Identifiers, mkSimpleIdentifier("pmcid", eprint.PMCID))
 	}
+	if (eprint.OtherNumberingSystem != nil) && (eprint.OtherNumberingSystem.Items != nil) {
+		for _, item := range eprint.OtherNumberingSystem.Items {
+			if item.ID != "" {
+				identifier := mkSimpleIdentifier("other", item.ID)
+				if item.Name != nil {
+					identifier.Name = strings.TrimSpace(item.Name.Value)
+				}
+				metadata.Identifiers = append(metadata.Identifiers, identifier)
+			}
+		}
+	}
+	if (eprint.RelatedURL != nil) && (eprint.RelatedURL.Items != nil) {
+		for _, item := range eprint.RelatedURL.Items {
+			if identifier := relatedIdentifierFromItem(item); identifier != nil {
+				metadata.RelatedIdentifiers = append(metadata.RelatedIdentifiers, identifier)
+			}
+		}
+	}
 	if (eprint.Funders != nil) && (eprint.Funders.Items != nil) {
 		for _, item := range eprint.Funders.Items {
 			if item.Agency != "" {
@@ -625,6 +904,143 @@ func metadataFromEPrint(eprint *EPrint, rec *simplified.Record) error {
 	return nil
 }
 
+// itemValues returns the trimmed non-empty values in a list of items
+func itemValues(items []*Item) []string {
+	values := []string{}
+	for _, item := range items {
+		if value := strings.TrimSpace(item.Value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// customFieldsFromEPrint maps the thesis, patent, conference (meeting),
+// journal and imprint fields into the record's custom fields. The
+// namespaces follow those used by Invenio-RDM where one exists.
+func customFieldsFromEPrint(eprint *EPrint, rec *simplified.Record) error {
+	customFields := map[string]interface{}{}
+	// Thesis degree, grantor and dates
+	if eprint.Type == "thesis" {
+		thesis := map[string]interface{}{}
+		if eprint.ThesisDegreeGrantor != "" {
+			thesis["university"] = eprint.ThesisDegreeGrantor
+		} else if eprint.Institution != "" {
+			thesis["university"] = eprint.Institution
+		}
+		if eprint.Department != "" {
+			thesis["department"] = eprint.Department
+		}
+		if eprint.ThesisType != "" {
+			thesis["type"] = eprint.ThesisType
+		}
+		if eprint.ThesisDegree != "" {
+			thesis["degree"] = eprint.ThesisDegree
+		}
+		if eprint.ThesisSubmittedDate != "" {
+			thesis["date_submitted"] = eprint.ThesisSubmittedDate
+		}
+		if eprint.ThesisDefenseDate != "" {
+			thesis["date_defended"] = eprint.ThesisDefenseDate
+		}
+		if eprint.ThesisAwards != "" {
+			thesis["awards"] = eprint.ThesisAwards
+		}
+		if (eprint.OptionMajor != nil) && (len(eprint.OptionMajor.Items) > 0) {
+			thesis["option_major"] = itemValues(eprint.OptionMajor.Items)
+		}
+		if (eprint.OptionMinor != nil) && (len(eprint.OptionMinor.Items) > 0) {
+			thesis["option_minor"] = itemValues(eprint.OptionMinor.Items)
+		}
+		if len(thesis) > 0 {
+			customFields["thesis:thesis"] = thesis
+		}
+	}
+	// Patents
+	patent := map[string]interface{}{}
+	if eprint.PatentNumber != "" {
+		patent["number"] = eprint.PatentNumber
+	}
+	if eprint.PatentApplicant != "" {
+		patent["applicant"] = eprint.PatentApplicant
+	}
+	if (eprint.PatentAssignee != nil) && (len(eprint.PatentAssignee.Items) > 0) {
+		patent["assignees"] = itemValues(eprint.PatentAssignee.Items)
+	}
+	if eprint.PatentClassificationText != "" {
+		patent["classification"] = eprint.PatentClassificationText
+	}
+	if (eprint.RelatedPatents != nil) && (len(eprint.RelatedPatents.Items) > 0) {
+		patent["related_patents"] = itemValues(eprint.RelatedPatents.Items)
+	}
+	if len(patent) > 0 {
+		customFields["patent:patent"] = patent
+	}
+	// Conference or other event
+	meeting := map[string]interface{}{}
+	if eprint.EventTitle != "" {
+		meeting["title"] = eprint.EventTitle
+	}
+	if eprint.EventLocation != "" {
+		meeting["place"] = eprint.EventLocation
+	}
+	if eprint.EventDates != "" {
+		meeting["dates"] = eprint.EventDates
+	}
+	if eprint.EventType != "" {
+		meeting["type"] = eprint.EventType
+	}
+	if len(meeting) > 0 {
+		customFields["meeting:meeting"] = meeting
+	}
+	// Journal and imprint (book) details
+	if eprint.Type == "article" || eprint.Type == "journal_issue" {
+		journal := map[string]interface{}{}
+		if eprint.Publication != "" {
+			journal["title"] = eprint.Publication
+		}
+		if eprint.ISSN != "" {
+			journal["issn"] = eprint.ISSN
+		}
+		if eprint.Volume != "" {
+			journal["volume"] = eprint.Volume
+		}
+		if eprint.Number != "" {
+			journal["issue"] = eprint.Number
+		}
+		if eprint.PageRange != "" {
+			journal["pages"] = eprint.PageRange
+		}
+		if len(journal) > 0 {
+			customFields["journal:journal"] = journal
+		}
+	}
+	if eprint.Type == "book_section" || eprint.Type == "conference_item" {
+		imprint := map[string]interface{}{}
+		if eprint.BookTitle != "" {
+			imprint["title"] = eprint.BookTitle
+		}
+		if eprint.ISBN != "" {
+			imprint["isbn"] = eprint.ISBN
+		}
+		if eprint.PlaceOfPub != "" {
+			imprint["place"] = eprint.PlaceOfPub
+		}
+		if eprint.PageRange != "" {
+			imprint["pages"] = eprint.PageRange
+		}
+		if len(imprint) > 0 {
+			customFields["imprint:imprint"] = imprint
+		}
+	}
+	if len(customFields) > 0 {
+		rec.CustomFields = customFields
+	} else {
+		rec.CustomFields = nil
+	}
+	return nil
+}
+
 // filesFromEPrint extracts all the file specific metadata from the
 // EPrint record
 func filesFromEPrint(eprint *EPrint, rec *simplified.Record) error {
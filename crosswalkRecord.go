@@ -0,0 +1,645 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * crosswalkRecord.go implements the reverse of crosswalk.go,
+ * mapping a simplified (Invenio-RDM like) JSON record back into an
+ * EPrint structure. This lets records from CaltechDATA or RDM drive
+ * the EPrints centric feed generation.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// CrosswalkRecordToEPrint maps a simplified.Record into an EPrint.
+// It is the inverse of CrosswalkEPrintToRecord.
+func CrosswalkRecordToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	if rec == nil {
+		return fmt.Errorf("missing record")
+	}
+	if err := eprintIDFromRecord(rec, eprint); err != nil {
+		return err
+	}
+	if err := parentToEPrint(rec, eprint); err != nil {
+		return err
+	}
+	if err := externalPIDToEPrint(rec, eprint); err != nil {
+		return err
+	}
+	if err := metadataToEPrint(rec, eprint); err != nil {
+		return err
+	}
+	if err := customFieldsToEPrint(rec, eprint); err != nil {
+		return err
+	}
+	if err := filesToEPrint(rec, eprint); err != nil {
+		return err
+	}
+	if err := recordAccessToEPrint(rec, eprint); err != nil {
+		return err
+	}
+	if err := tombstoneToEPrint(rec, eprint); err != nil {
+		return err
+	}
+	if err := createdUpdatedToEPrint(rec, eprint); err != nil {
+		return err
+	}
+	return nil
+}
+
+// eprintIDFromRecord splits a record id of the form
+// "COLLECTION:EPRINTID" into the EPrint's collection and eprint id.
+func eprintIDFromRecord(rec *simplified.Record, eprint *EPrint) error {
+	if rec.ID == "" {
+		return nil
+	}
+	if i := strings.LastIndex(rec.ID, ":"); i >= 0 {
+		if id, err := strconv.Atoi(rec.ID[i+1:]); err == nil {
+			eprint.Collection = rec.ID[0:i]
+			eprint.EPrintID = id
+			return nil
+		}
+	}
+	if id, err := strconv.Atoi(rec.ID); err == nil {
+		eprint.EPrintID = id
+	}
+	return nil
+}
+
+// parentToEPrint recovers the owner (userid and reviewer) of a record
+func parentToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	if rec.Parent != nil && rec.Parent.Access != nil && len(rec.Parent.Access.OwnedBy) > 0 {
+		owner := rec.Parent.Access.OwnedBy[0]
+		eprint.UserID = owner.User
+		eprint.Reviewer = owner.DisplayName
+	}
+	return nil
+}
+
+// externalPIDToEPrint maps the record's pids into DOI, ISSN and ISBN
+func externalPIDToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	for scheme, pid := range rec.ExternalPIDs {
+		if pid == nil || pid.Identifier == "" {
+			continue
+		}
+		switch scheme {
+		case "doi":
+			eprint.DOI = pid.Identifier
+		case "issn":
+			eprint.ISSN = pid.Identifier
+		case "isbn":
+			eprint.ISBN = pid.Identifier
+		}
+	}
+	return nil
+}
+
+// eprintTypeFromResourceType maps an Invenio-RDM resource type to an
+// EPrint type and subtype (e.g. monograph_type).
+func eprintTypeFromResourceType(resourceType string) (string, string) {
+	cfg := crosswalkConfig()
+	val, ok := cfg.EPrintTypes[resourceType]
+	if !ok {
+		// Types that where not mapped on the way out are passed
+		// through as is.
+		for eprintType, rdmType := range cfg.ResourceTypes {
+			if rdmType == resourceType && !strings.Contains(eprintType, ":") {
+				return eprintType, ""
+			}
+		}
+		return "other", ""
+	}
+	if i := strings.Index(val, ":"); i >= 0 {
+		return val[0:i], val[i+1:]
+	}
+	return val, ""
+}
+
+// setEPrintSubtype sets the field holding the subtype for an EPrint type
+func setEPrintSubtype(eprint *EPrint, subtype string) {
+	if subtype == "" {
+		return
+	}
+	switch eprint.Type {
+	case "monograph":
+		eprint.MonographType = subtype
+	case "conference_item":
+		eprint.PresType = subtype
+	case "thesis":
+		eprint.ThesisType = subtype
+	}
+}
+
+// itemFromCreator maps a simplified.Creator to an EPrint item
+func itemFromCreator(creator *simplified.Creator) *Item {
+	item := new(Item)
+	item.Name = new(Name)
+	person := creator.PersonOrOrg
+	if person == nil {
+		return item
+	}
+	if person.Type == "organizational" {
+		item.Name.Value = person.Name
+	} else {
+		item.Name.Family = person.FamilyName
+		item.Name.Given = person.GivenName
+	}
+	for _, identifier := range person.Identifiers {
+		switch identifier.Scheme {
+		case "orcid":
+			item.ORCID = identifier.Identifier
+		case "ror":
+			item.ROR = identifier.Identifier
+		default:
+			// NOTE: clpid and the *_id schemes hold the EPrints
+			// person or organization id.
+			if item.ID == "" {
+				item.ID = identifier.Identifier
+			}
+		}
+	}
+	if person.ID != "" && item.ID == "" {
+		item.ID = person.ID
+	}
+	return item
+}
+
+// dateFromDateType returns the date type and date of a simplified.DateType
+func dateFromDateType(dt *simplified.DateType) (string, string) {
+	if dt == nil || dt.Type == nil {
+		return "", ""
+	}
+	return dt.Type.ID, dt.Date
+}
+
+// metadataToEPrint maps the record's metadata into the EPrint
+func metadataToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	metadata := rec.Metadata
+	if metadata == nil {
+		return nil
+	}
+	if metadata.ResourceType != nil {
+		if id, ok := metadata.ResourceType["id"].(string); ok {
+			var subtype string
+			eprint.Type, subtype = eprintTypeFromResourceType(id)
+			setEPrintSubtype(eprint, subtype)
+		}
+	}
+	for _, creator := range metadata.Creators {
+		if creator.PersonOrOrg != nil && creator.PersonOrOrg.Type == "organizational" {
+			if eprint.CorpCreators == nil {
+				eprint.CorpCreators = new(CorpCreatorItemList)
+			}
+			eprint.CorpCreators.Append(itemFromCreator(creator))
+		} else {
+			if eprint.Creators == nil {
+				eprint.Creators = new(CreatorItemList)
+			}
+			eprint.Creators.Append(itemFromCreator(creator))
+		}
+	}
+	for _, contributor := range metadata.Contributors {
+		role := "contributor"
+		if contributor.Role != nil && contributor.Role.ID != "" {
+			role = contributor.Role.ID
+		}
+		item := itemFromCreator(contributor)
+		switch {
+		case contributor.PersonOrOrg != nil && contributor.PersonOrOrg.Type == "organizational":
+			if eprint.CorpContributors == nil {
+				eprint.CorpContributors = new(CorpContributorItemList)
+			}
+			eprint.CorpContributors.Append(item)
+		case role == "editor":
+			if eprint.Editors == nil {
+				eprint.Editors = new(EditorItemList)
+			}
+			eprint.Editors.Append(item)
+		case role == "thesis_advisor":
+			if eprint.ThesisAdvisor == nil {
+				eprint.ThesisAdvisor = new(ThesisAdvisorItemList)
+			}
+			eprint.ThesisAdvisor.Append(item)
+		case role == "thesis_committee":
+			if eprint.ThesisCommittee == nil {
+				eprint.ThesisCommittee = new(ThesisCommitteeItemList)
+			}
+			eprint.ThesisCommittee.Append(item)
+		default:
+			item.Type = contributorTypeToURI(role)
+			if eprint.Contributors == nil {
+				eprint.Contributors = new(ContributorItemList)
+			}
+			eprint.Contributors.Append(item)
+		}
+	}
+	eprint.Title = metadata.Title
+	for _, title := range metadata.AdditionalTitles {
+		if title != nil && title.Title != "" {
+			if eprint.AltTitle == nil {
+				eprint.AltTitle = new(AltTitleItemList)
+			}
+			eprint.AltTitle.Append(&Item{Value: title.Title})
+		}
+	}
+	eprint.Abstract = metadata.Description
+	if metadata.PublicationDate != "" {
+		eprint.Date = metadata.PublicationDate
+		eprint.DateType = "published"
+	}
+	for i, right := range metadata.Rights {
+		if right == nil || right.Description == nil {
+			continue
+		}
+		if description, ok := right.Description["en"]; ok {
+			// NOTE: The first rights statement is from the rights
+			// field, a second one is the copyright statement.
+			if i == 0 {
+				eprint.Rights = description
+			} else {
+				eprint.CopyrightStatement = description
+			}
+		}
+	}
+	keywords := []string{}
+	for _, subject := range metadata.Subjects {
+		if subject == nil {
+			continue
+		}
+		if subject.ID != "" {
+			if eprint.Subjects == nil {
+				eprint.Subjects = new(SubjectItemList)
+			}
+			eprint.Subjects.Append(&Item{Value: subject.ID})
+		} else if subject.Subject != "" {
+			keywords = append(keywords, subject.Subject)
+		}
+	}
+	if len(keywords) > 0 {
+		eprint.Keywords = strings.Join(keywords, "; ")
+	}
+	for _, dt := range metadata.Dates {
+		dtType, date := dateFromDateType(dt)
+		switch dtType {
+		case "pub_date":
+			if eprint.Date == "" {
+				eprint.Date = date
+			}
+		case "submitted":
+			eprint.ThesisSubmittedDate = date
+		case "accepted":
+			eprint.ThesisApprovedDate = date
+		case "issued":
+			eprint.ThesisDegreeDate = date
+		case "other":
+			if dt.Description == "Thesis defense date" {
+				eprint.ThesisDefenseDate = date
+			}
+		}
+	}
+	if strings.HasPrefix(metadata.Version, "v") {
+		if rev, err := strconv.Atoi(strings.TrimPrefix(metadata.Version, "v")); err == nil {
+			eprint.RevNumber = rev
+		}
+	}
+	eprint.Publisher = metadata.Publisher
+	for _, identifier := range metadata.Identifiers {
+		if identifier == nil {
+			continue
+		}
+		switch identifier.Scheme {
+		case "eprintid":
+			if eprint.EPrintID == 0 {
+				eprint.EPrintID, _ = strconv.Atoi(identifier.Identifier)
+			}
+		case "doi":
+			eprint.DOI = identifier.Identifier
+		case "isbn":
+			eprint.ISBN = identifier.Identifier
+		case "issn":
+			eprint.ISSN = identifier.Identifier
+		case "pmid":
+			eprint.PMID = identifier.Identifier
+		case "pmcid":
+			eprint.PMCID = identifier.Identifier
+		case "other":
+			if eprint.OtherNumberingSystem == nil {
+				eprint.OtherNumberingSystem = new(OtherNumberingSystemItemList)
+			}
+			item := &Item{ID: identifier.Identifier}
+			if identifier.Name != "" {
+				item.Name = &Name{Value: identifier.Name}
+			}
+			eprint.OtherNumberingSystem.Append(item)
+		}
+	}
+	for _, identifier := range metadata.RelatedIdentifiers {
+		if identifier == nil {
+			continue
+		}
+		relationType := ""
+		if identifier.RelationType != nil {
+			relationType = identifier.RelationType.ID
+		}
+		switch {
+		case identifier.Scheme == "issn" && relationType == "ispublishedin":
+			eprint.ISSN = identifier.Identifier
+		case identifier.Scheme == "isbn" && relationType == "ispartof":
+			eprint.ISBN = identifier.Identifier
+		default:
+			if item := itemFromRelatedIdentifier(identifier, relationType); item != nil {
+				if eprint.RelatedURL == nil {
+					eprint.RelatedURL = new(RelatedURLItemList)
+				}
+				eprint.RelatedURL.Append(item)
+			}
+		}
+	}
+	for _, funder := range metadata.Funding {
+		if funder == nil {
+			continue
+		}
+		item := new(Item)
+		if funder.Funder != nil {
+			item.Agency = funder.Funder.Name
+		}
+		if funder.Award != nil {
+			item.GrantNumber = funder.Award.Number
+		}
+		if item.Agency != "" || item.GrantNumber != "" {
+			if eprint.Funders == nil {
+				eprint.Funders = new(FunderItemList)
+			}
+			eprint.Funders.Append(item)
+		}
+	}
+	return nil
+}
+
+// itemFromRelatedIdentifier maps a related identifier back into a
+// related_url item.
+func itemFromRelatedIdentifier(identifier *simplified.Identifier, relationType string) *Item {
+	if identifier.Identifier == "" {
+		return nil
+	}
+	item := new(Item)
+	item.Description = identifier.Title
+	switch identifier.Scheme {
+	case "doi":
+		item.URL = "https://doi.org/" + identifier.Identifier
+		item.Type = "doi"
+	case "url":
+		item.URL = identifier.Identifier
+		// Pick the first (sorted) related_url type with this relation.
+		types := []string{}
+		for urlType, val := range crosswalkConfig().RelationTypes {
+			if val == relationType && urlType != "doi" {
+				types = append(types, urlType)
+			}
+		}
+		sort.Strings(types)
+		if len(types) > 0 {
+			item.Type = types[0]
+		}
+	default:
+		return nil
+	}
+	return item
+}
+
+// itemsFromValues builds EPrint items from a custom field list, the
+// list maybe a []string or the []interface{} decoded from JSON.
+func itemsFromValues(val interface{}) []*Item {
+	items := []*Item{}
+	switch values := val.(type) {
+	case []string:
+		for _, v := range values {
+			items = append(items, &Item{Value: v})
+		}
+	case []interface{}:
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				items = append(items, &Item{Value: s})
+			}
+		}
+	}
+	return items
+}
+
+// customFieldString returns a string value from a custom field map
+func customFieldString(m map[string]interface{}, key string) string {
+	if s, ok := m[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+// customFieldsToEPrint maps the EPrint type, thesis, patent, meeting,
+// journal and imprint custom fields back into the EPrint.
+func customFieldsToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	if rec.CustomFields == nil {
+		return nil
+	}
+	if val, ok := rec.CustomFields["eprint:type"].(string); ok && val != "" {
+		if i := strings.Index(val, ":"); i >= 0 {
+			eprint.Type = val[0:i]
+			setEPrintSubtype(eprint, val[i+1:])
+		} else {
+			eprint.Type = val
+		}
+	}
+	if thesis, ok := rec.CustomFields["thesis:thesis"].(map[string]interface{}); ok {
+		eprint.ThesisDegreeGrantor = customFieldString(thesis, "university")
+		eprint.Department = customFieldString(thesis, "department")
+		eprint.ThesisType = customFieldString(thesis, "type")
+		eprint.ThesisDegree = customFieldString(thesis, "degree")
+		if s := customFieldString(thesis, "date_submitted"); s != "" {
+			eprint.ThesisSubmittedDate = s
+		}
+		if s := customFieldString(thesis, "date_defended"); s != "" {
+			eprint.ThesisDefenseDate = s
+		}
+		eprint.ThesisAwards = customFieldString(thesis, "awards")
+		if items := itemsFromValues(thesis["option_major"]); len(items) > 0 {
+			eprint.OptionMajor = &OptionMajorItemList{Items: items}
+		}
+		if items := itemsFromValues(thesis["option_minor"]); len(items) > 0 {
+			eprint.OptionMinor = &OptionMinorItemList{Items: items}
+		}
+	}
+	if patent, ok := rec.CustomFields["patent:patent"].(map[string]interface{}); ok {
+		eprint.PatentNumber = customFieldString(patent, "number")
+		eprint.PatentApplicant = customFieldString(patent, "applicant")
+		eprint.PatentClassificationText = customFieldString(patent, "classification")
+		if items := itemsFromValues(patent["assignees"]); len(items) > 0 {
+			eprint.PatentAssignee = &PatentAssigneeItemList{Items: items}
+		}
+		if items := itemsFromValues(patent["related_patents"]); len(items) > 0 {
+			eprint.RelatedPatents = &RelatedPatentItemList{Items: items}
+		}
+	}
+	if meeting, ok := rec.CustomFields["meeting:meeting"].(map[string]interface{}); ok {
+		eprint.EventTitle = customFieldString(meeting, "title")
+		eprint.EventLocation = customFieldString(meeting, "place")
+		eprint.EventDates = customFieldString(meeting, "dates")
+		eprint.EventType = customFieldString(meeting, "type")
+	}
+	if journal, ok := rec.CustomFields["journal:journal"].(map[string]interface{}); ok {
+		eprint.Publication = customFieldString(journal, "title")
+		if s := customFieldString(journal, "issn"); s != "" {
+			eprint.ISSN = s
+		}
+		eprint.Volume = customFieldString(journal, "volume")
+		eprint.Number = customFieldString(journal, "issue")
+		eprint.PageRange = customFieldString(journal, "pages")
+	}
+	if imprint, ok := rec.CustomFields["imprint:imprint"].(map[string]interface{}); ok {
+		eprint.BookTitle = customFieldString(imprint, "title")
+		if s := customFieldString(imprint, "isbn"); s != "" {
+			eprint.ISBN = s
+		}
+		eprint.PlaceOfPub = customFieldString(imprint, "place")
+		eprint.PageRange = customFieldString(imprint, "pages")
+	}
+	return nil
+}
+
+// filesToEPrint maps the record's file entries to EPrint documents,
+// one document per file.
+func filesToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	if rec.Files == nil || len(rec.Files.Entries) == 0 {
+		return nil
+	}
+	// Use the file order if provided, otherwise sort by name so the
+	// document positions are stable.
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range rec.Files.Order {
+		if _, ok := rec.Files.Entries[name]; ok && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	remaining := []string{}
+	for name := range rec.Files.Entries {
+		if !seen[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	sort.Strings(remaining)
+	names = append(names, remaining...)
+
+	documents := DocumentList{}
+	for i, name := range names {
+		entry := rec.Files.Entries[name]
+		if entry == nil {
+			continue
+		}
+		file := new(File)
+		file.Filename = name
+		file.URL = entry.FileID
+		file.FileSize = entry.Size
+		file.MimeType = entry.MimeType
+		file.DatasetID = "document"
+		if parts := strings.SplitN(entry.CheckSum, ":", 2); len(parts) == 2 {
+			file.HashType = strings.ToUpper(parts[0])
+			file.Hash = parts[1]
+		}
+		doc := new(Document)
+		doc.EPrintID = eprint.EPrintID
+		doc.Pos = i + 1
+		doc.Placement = i + 1
+		doc.MimeType = entry.MimeType
+		doc.Format = entry.MimeType
+		doc.Main = name
+		doc.Security = "public"
+		doc.Files = append(doc.Files, file)
+		documents = append(documents, doc)
+	}
+	if len(documents) > 0 {
+		eprint.Documents = &documents
+	}
+	return nil
+}
+
+// recordAccessToEPrint maps the record access and embargo to the
+// EPrint's status, visibility and document security.
+func recordAccessToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	if rec.RecordAccess == nil {
+		return nil
+	}
+	if rec.RecordAccess.Record == "public" {
+		eprint.EPrintStatus = "archive"
+		eprint.MetadataVisibility = "show"
+	} else {
+		// NOTE: A restricted record hasn't been made public, the
+		// closest EPrints state is the review buffer.
+		eprint.EPrintStatus = "buffer"
+		eprint.MetadataVisibility = "hide"
+	}
+	if embargo := rec.RecordAccess.Embargo; embargo != nil && embargo.Until != "" {
+		if embargo.Reason != "" {
+			eprint.Suggestions = embargo.Reason
+		}
+		if eprint.Documents != nil {
+			for i := 0; i < eprint.Documents.Length(); i++ {
+				doc := eprint.Documents.IndexOf(i)
+				doc.DateEmbargo = embargo.Until
+				if embargo.Active {
+					doc.Security = "internal"
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// tombstoneToEPrint marks a record with a tombstone as deleted
+func tombstoneToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	if rec.Tombstone == nil {
+		return nil
+	}
+	eprint.EPrintStatus = "deletion"
+	if rec.Tombstone.RemovedBy != nil {
+		eprint.Reviewer = rec.Tombstone.RemovedBy.DisplayName
+		eprint.UserID = rec.Tombstone.RemovedBy.User
+	}
+	if rec.Tombstone.Reason != "" {
+		eprint.Suggestions = rec.Tombstone.Reason
+	}
+	return nil
+}
+
+// createdUpdatedToEPrint maps created and updated to the EPrint's
+// datestamp and lastmod.
+func createdUpdatedToEPrint(rec *simplified.Record, eprint *EPrint) error {
+	if !rec.Created.IsZero() {
+		eprint.Datestamp = rec.Created.Format(timestamp)
+	}
+	if !rec.Updated.IsZero() {
+		eprint.LastModified = rec.Updated.Format(timestamp)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+func TestCrosswalkRecordToEPrint(t *testing.T) {
+	for _, name := range []string{
+		"lemurprints-1",
+		"lemurprints-76",
+		"lemurprints-8599",
+		"lemurprints-21235",
+		"lemurprints-92759",
+	} {
+		// Read the original EPrint XML
+		fName := path.Join("srctest", name+".xml")
+		src, err := ioutil.ReadFile(fName)
+		if err != nil {
+			t.Errorf("Failed to read %q, %s", fName, err)
+			t.FailNow()
+		}
+		eprints := NewEPrints()
+		if err := xml.Unmarshal(src, &eprints); err != nil {
+			t.Errorf("Failed to unmarshal %q, %s", fName, err)
+			t.FailNow()
+		}
+		expected := eprints.EPrint[0]
+
+		// Read the crosswalked simplified record
+		fName = path.Join("testdata", "crosswalk", name+".json")
+		src, err = ioutil.ReadFile(fName)
+		if err != nil {
+			t.Errorf("Failed to read %q, %s", fName, err)
+			t.FailNow()
+		}
+		rec := new(simplified.Record)
+		if err := json.Unmarshal(src, &rec); err != nil {
+			t.Errorf("Failed to unmarshal %q, %s", fName, err)
+			t.FailNow()
+		}
+		eprint := new(EPrint)
+		if err := CrosswalkRecordToEPrint(rec, eprint); err != nil {
+			t.Errorf("CrosswalkRecordToEPrint(%q) failed, %s", fName, err)
+			continue
+		}
+		assertStringSame(t, "collection", expected.Collection, eprint.Collection)
+		assertIntSame(t, "eprintid", expected.EPrintID, eprint.EPrintID)
+		assertStringSame(t, "type", expected.Type, eprint.Type)
+		assertStringSame(t, "title", expected.Title, eprint.Title)
+		assertStringSame(t, "doi", expected.DOI, eprint.DOI)
+		assertStringSame(t, "issn", expected.ISSN, eprint.ISSN)
+		assertStringSame(t, "isbn", expected.ISBN, eprint.ISBN)
+		// NOTE: date only datestamps are expanded to a timestamp
+		if !strings.HasPrefix(eprint.Datestamp, expected.Datestamp) {
+			t.Errorf("expected datestamp %q, got %q", expected.Datestamp, eprint.Datestamp)
+		}
+		assertStringSame(t, "lastmod", expected.LastModified, eprint.LastModified)
+		assertStringSame(t, "thesis_degree", expected.ThesisDegree, eprint.ThesisDegree)
+		assertStringSame(t, "event_title", expected.EventTitle, eprint.EventTitle)
+		if expected.Reviewer != "" {
+			assertIntSame(t, "userid", expected.UserID, eprint.UserID)
+		}
+		assertIntSame(t, "rev_number", expected.RevNumber, eprint.RevNumber)
+		if expected.Keywords != "" {
+			assertStringSame(t, "keywords", strings.Join(strings.Fields(expected.Keywords), " "), strings.Join(strings.Fields(eprint.Keywords), " "))
+		}
+		if rec.Files != nil && (eprint.Documents == nil || eprint.Documents.Length() != len(rec.Files.Entries)) {
+			t.Errorf("%s expected %d documents, got %+v", name, len(rec.Files.Entries), eprint.Documents)
+		}
+		if eprint.Creators.Length() != len(rec.Metadata.Creators) {
+			t.Errorf("%s expected %d creators, got %d", name, len(rec.Metadata.Creators), eprint.Creators.Length())
+		}
+
+		// Crosswalking back should give us the same record
+		rec2 := new(simplified.Record)
+		if err := CrosswalkEPrintToRecord(eprint, rec2); err != nil {
+			t.Errorf("CrosswalkEPrintToRecord(%q) failed, %s", name, err)
+			continue
+		}
+		if rec2.Metadata.ResourceType["id"] != rec.Metadata.ResourceType["id"] {
+			t.Errorf("%s expected resource type %q, got %q", name, rec.Metadata.ResourceType["id"], rec2.Metadata.ResourceType["id"])
+		}
+		if len(rec2.Metadata.Identifiers) != len(rec.Metadata.Identifiers) || len(rec2.Metadata.RelatedIdentifiers) != len(rec.Metadata.RelatedIdentifiers) {
+			t.Errorf("%s expected identifiers %+v, %+v, got %+v, %+v", name, rec.Metadata.Identifiers, rec.Metadata.RelatedIdentifiers, rec2.Metadata.Identifiers, rec2.Metadata.RelatedIdentifiers)
+		}
+	}
+}
+
+func TestCrosswalkRecordRoles(t *testing.T) {
+	rec := &simplified.Record{
+		Metadata: &simplified.Metadata{
+			ResourceType: map[string]interface{}{"id": "publication-technicalnote"},
+			Contributors: []*simplified.Creator{
+				{PersonOrOrg: &simplified.PersonOrOrg{Type: "personal", FamilyName: "Doe", GivenName: "Jane"}, Role: &simplified.Role{ID: "editor"}},
+				{PersonOrOrg: &simplified.PersonOrOrg{Type: "personal", FamilyName: "Roe", GivenName: "Ann"}, Role: &simplified.Role{ID: "thesis_advisor"}},
+				{PersonOrOrg: &simplified.PersonOrOrg{Type: "personal", FamilyName: "Poe", GivenName: "Ed"}, Role: &simplified.Role{ID: "translator"}},
+			},
+			Funding: []*simplified.Funder{
+				{Funder: &simplified.FunderIdentifier{Name: "NSF"}, Award: &simplified.AwardIdentifier{Number: "AST-1234"}},
+			},
+		},
+		Tombstone: &simplified.Tombstone{Reason: "duplicate", RemovedBy: &simplified.User{User: 3, DisplayName: "Admin"}},
+	}
+	eprint := new(EPrint)
+	if err := CrosswalkRecordToEPrint(rec, eprint); err != nil {
+		t.Errorf("CrosswalkRecordToEPrint() failed, %s", err)
+		t.FailNow()
+	}
+	assertStringSame(t, "type", "monograph", eprint.Type)
+	assertStringSame(t, "monograph_type", "technical_report", eprint.MonographType)
+	assertStringSame(t, "eprint_status", "deletion", eprint.EPrintStatus)
+	assertStringSame(t, "suggestions", "duplicate", eprint.Suggestions)
+	if eprint.Editors.Length() != 1 || eprint.ThesisAdvisor.Length() != 1 || eprint.Contributors.Length() != 1 {
+		t.Errorf("contributors not mapped by role, %+v", eprint)
+		t.FailNow()
+	}
+	assertStringSame(t, "contributor type", "http://www.loc.gov/loc.terms/relators/TRL", eprint.Contributors.IndexOf(0).Type)
+	if eprint.Funders.Length() != 1 || eprint.Funders.IndexOf(0).GrantNumber != "AST-1234" {
+		t.Errorf("funders not mapped, %+v", eprint.Funders)
+	}
+}
@@ -17,13 +17,14 @@
 package eprinttools
 
 import (
-	//"encoding/json"
-
+	"bytes"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	// Caltech Library Packages
@@ -61,3 +62,143 @@ func TestRecordFromEPrint(t *testing.T) {
 		}
 	}
 }
+
+// TestCrosswalkGolden crosswalks the lemurprints records in srctest
+// and compares the results with testdata/crosswalk. Set the
+// environment variable UPDATE_GOLDEN=true to regenerate them.
+func TestCrosswalkGolden(t *testing.T) {
+	update := os.Getenv("UPDATE_GOLDEN") == "true"
+	for _, name := range []string{
+		"lemurprints-1.xml",
+		"lemurprints-7.xml",
+		"lemurprints-8.xml",
+		"lemurprints-34.xml",
+		"lemurprints-76.xml",
+		"lemurprints-97.xml",
+		"lemurprints-105.xml",
+		"lemurprints-132.xml",
+		"lemurprints-209.xml",
+		"lemurprints-260.xml",
+		"lemurprints-8599.xml",
+		"lemurprints-21235.xml",
+		"lemurprints-92759.xml",
+	} {
+		fName := path.Join("srctest", name)
+		src, err := ioutil.ReadFile(fName)
+		if err != nil {
+			t.Errorf("Failed to read %q, %s", fName, err)
+			t.FailNow()
+		}
+		eprints := NewEPrints()
+		if err := xml.Unmarshal(src, &eprints); err != nil {
+			t.Errorf("Failed to unmarshal %q, %s", fName, err)
+			t.FailNow()
+		}
+		if len(eprints.EPrint) != 1 {
+			t.Errorf("Expected 1 record in %q, got %d", fName, len(eprints.EPrint))
+			continue
+		}
+		rec := new(simplified.Record)
+		if err := CrosswalkEPrintToRecord(eprints.EPrint[0], rec); err != nil {
+			t.Errorf("CrosswalkEPrintToRecord(%q) failed, %s", fName, err)
+			continue
+		}
+		got, err := json.MarshalIndent(rec, "", "    ")
+		if err != nil {
+			t.Errorf("Failed to marshal record for %q, %s", fName, err)
+			continue
+		}
+		gName := path.Join("testdata", "crosswalk", strings.TrimSuffix(name, ".xml")+".json")
+		if update {
+			if err := ioutil.WriteFile(gName, got, 0664); err != nil {
+				t.Errorf("Failed to write %q, %s", gName, err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(gName)
+		if err != nil {
+			t.Errorf("Failed to read %q, %s", gName, err)
+			continue
+		}
+		if !bytes.Equal(bytes.TrimSpace(expected), bytes.TrimSpace(got)) {
+			t.Errorf("%s does not match %s, got\n%s", fName, gName, got)
+		}
+	}
+}
+
+func TestMapResourceType(t *testing.T) {
+	for _, test := range []struct {
+		eprint   *EPrint
+		expected string
+	}{
+		{&EPrint{Type: "article"}, "publication-article"},
+		{&EPrint{Type: "book_section"}, "publication-section"},
+		{&EPrint{Type: "thesis", ThesisType: "phd"}, "publication-thesis"},
+		{&EPrint{Type: "patent"}, "publication-patent"},
+		{&EPrint{Type: "monograph"}, "publication-report"},
+		{&EPrint{Type: "monograph", MonographType: "technical_report"}, "publication-technicalnote"},
+		{&EPrint{Type: "conference_item"}, "publication-conferencepaper"},
+		{&EPrint{Type: "conference_item", PresType: "poster"}, "poster"},
+		{&EPrint{Type: "teaching_resource"}, "lesson"},
+		{&EPrint{Type: "no_such_type"}, "other"},
+	} {
+		rec := new(simplified.Record)
+		rec.Metadata = new(simplified.Metadata)
+		rec.Metadata.ResourceType = map[string]interface{}{"id": test.eprint.Type}
+		if err := mapResourceType(test.eprint, rec); err != nil {
+			t.Errorf("mapResourceType(%q) failed, %s", test.eprint.Type, err)
+			continue
+		}
+		if got := rec.Metadata.ResourceType["id"]; got != test.expected {
+			t.Errorf("expected %q for %+v, got %q", test.expected, test.eprint.Type, got)
+		}
+	}
+	// Configured types override the defaults
+	SetCrosswalkConfig(&CrosswalkConfig{ResourceTypes: map[string]string{"oral_history": "publication-oralhistory"}})
+	defer SetCrosswalkConfig(nil)
+	rec := &simplified.Record{Metadata: &simplified.Metadata{ResourceType: map[string]interface{}{"id": "oral_history"}}}
+	mapResourceType(&EPrint{Type: "oral_history"}, rec)
+	if got := rec.Metadata.ResourceType["id"]; got != "publication-oralhistory" {
+		t.Errorf("expected configured resource type, got %q", got)
+	}
+}
+
+func TestExternalPIDFromEPrint(t *testing.T) {
+	eprint := &EPrint{DOI: "10.1000/xyz", ISSN: "1234-5679"}
+	rec := new(simplified.Record)
+	if err := externalPIDFromEPrint(eprint, rec); err != nil {
+		t.Errorf("externalPIDFromEPrint() failed, %s", err)
+		t.FailNow()
+	}
+	if pid, ok := rec.ExternalPIDs["issn"]; !ok || pid.Identifier != eprint.ISSN {
+		t.Errorf("expected issn %q, got %+v", eprint.ISSN, pid)
+	}
+	if _, ok := rec.ExternalPIDs["isbn"]; ok {
+		t.Errorf("did not expect an isbn")
+	}
+}
+
+func TestCustomFieldsFromEPrint(t *testing.T) {
+	eprint := &EPrint{
+		Type:            "patent",
+		PatentNumber:    "US 1,234,567",
+		PatentApplicant: "Example Institute of Technology",
+		PatentAssignee:  &PatentAssigneeItemList{Items: []*Item{{Value: "Lemur Lab"}}},
+	}
+	rec := new(simplified.Record)
+	if err := customFieldsFromEPrint(eprint, rec); err != nil {
+		t.Errorf("customFieldsFromEPrint() failed, %s", err)
+		t.FailNow()
+	}
+	patent, ok := rec.CustomFields["patent:patent"].(map[string]interface{})
+	if !ok {
+		t.Errorf("expected patent custom field, got %+v", rec.CustomFields)
+		t.FailNow()
+	}
+	if patent["number"] != eprint.PatentNumber || patent["applicant"] != eprint.PatentApplicant {
+		t.Errorf("unexpected patent fields %+v", patent)
+	}
+	if assignees, ok := patent["assignees"].([]string); !ok || len(assignees) != 1 || assignees[0] != "Lemur Lab" {
+		t.Errorf("unexpected patent assignees %+v", patent["assignees"])
+	}
+}
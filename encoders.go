@@ -0,0 +1,379 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * encoders.go holds the registry of output encoders used by the
+ * extended EPrints API and the Accept header negotiation that picks
+ * one of them for a request.
+ */
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// Encoder renders EPrint records in an output format
+type Encoder struct {
+	// Name of the encoder, used by the "format" query parameter
+	Name string
+	// ContentType is the MIME type of the output
+	ContentType string
+	// Aliases are other MIME types and format names accepted
+	// for the encoder, e.g. "text/xml" or "xml"
+	Aliases []string
+	// Encode renders the EPrint records to w
+	Encode func(w io.Writer, eprints []*EPrint) error
+}
+
+var (
+	// encoders holds the registered encoders in order of registration
+	encoders = []*Encoder{}
+
+	// ErrNotAcceptable is returned when no encoder satisfies a request
+	ErrNotAcceptable = fmt.Errorf("not acceptable")
+)
+
+func init() {
+	RegisterEncoder(&Encoder{
+		Name:        "eprint-xml",
+		ContentType: "application/xml",
+		Aliases:     []string{"text/xml", "xml"},
+		Encode:      encodeEPrintXML,
+	})
+	RegisterEncoder(&Encoder{
+		Name:        "eprint-json",
+		ContentType: "application/json",
+		Aliases:     []string{"json"},
+		Encode:      encodeEPrintJSON,
+	})
+	RegisterEncoder(&Encoder{
+		Name:        "simplified",
+		ContentType: "application/vnd.caltechlibrary.simplified+json",
+		Aliases:     []string{"simple"},
+		Encode:      encodeSimplified,
+	})
+	RegisterEncoder(&Encoder{
+		Name:        "dc",
+		ContentType: "application/oai_dc+xml",
+		Aliases:     []string{"oai_dc"},
+		Encode:      encodeDublinCore,
+	})
+	for _, name := range CitationFormatNames() {
+		citationFormat := CitationFormats[name]
+		encoder := &Encoder{
+			Name:        citationFormat.Name,
+			ContentType: citationFormat.ContentType,
+			Encode:      citationFormat.Write,
+		}
+		if name == "csl-json" {
+			encoder.Aliases = []string{"csl"}
+		}
+		RegisterEncoder(encoder)
+	}
+}
+
+// RegisterEncoder adds an encoder to the registry, an encoder with
+// the same name is replaced.
+func RegisterEncoder(encoder *Encoder) {
+	for i, e := range encoders {
+		if e.Name == encoder.Name {
+			encoders[i] = encoder
+			return
+		}
+	}
+	encoders = append(encoders, encoder)
+}
+
+// Encoders returns the registered encoders
+func Encoders() []*Encoder {
+	return append([]*Encoder{}, encoders...)
+}
+
+// matches returns true if s is the encoder's name, MIME type or alias
+func (encoder *Encoder) matches(s string) bool {
+	s = strings.ToLower(s)
+	if s == encoder.Name || s == encoder.ContentType {
+		return true
+	}
+	for _, alias := range encoder.Aliases {
+		if s == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupEncoder returns the encoder with the name, MIME type or
+// alias, or nil if there is none.
+func LookupEncoder(s string) *Encoder {
+	mediaType, _, _ := strings.Cut(s, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for _, encoder := range encoders {
+		if encoder.matches(mediaType) {
+			return encoder
+		}
+	}
+	return nil
+}
+
+//
+// Accept header negotiation
+//
+
+// mediaRange is a media range from an Accept header
+type mediaRange struct {
+	MediaType string
+	Q         float64
+}
+
+// specificity ranks "type/subtype" above "type/*" above "*/*"
+func (mr mediaRange) specificity() int {
+	switch {
+	case mr.MediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mr.MediaType, "/*"):
+		return 1
+	}
+	return 2
+}
+
+// parseAccept parses an Accept header into media ranges ordered
+// by quality value then specificity. Ranges with an invalid quality
+// value are skipped.
+func parseAccept(accept string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mr := mediaRange{MediaType: strings.ToLower(strings.TrimSpace(params[0])), Q: 1.0}
+		if mr.MediaType == "" {
+			continue
+		}
+		if mr.MediaType == "*" {
+			mr.MediaType = "*/*"
+		}
+		valid := true
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+				break
+			}
+			mr.Q = q
+		}
+		if valid {
+			ranges = append(ranges, mr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Q != ranges[j].Q {
+			return ranges[i].Q > ranges[j].Q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// matchEncoder returns the encoder matching a media range
+func matchEncoder(mr mediaRange, defaultEncoder *Encoder, excluded map[string]bool) *Encoder {
+	candidates := append([]*Encoder{defaultEncoder}, encoders...)
+	for _, encoder := range candidates {
+		if encoder == nil || excluded[encoder.Name] {
+			continue
+		}
+		switch mr.specificity() {
+		case 0:
+			return encoder
+		case 1:
+			prefix := strings.TrimSuffix(mr.MediaType, "*")
+			if strings.HasPrefix(encoder.ContentType, prefix) {
+				return encoder
+			}
+			for _, alias := range encoder.Aliases {
+				if strings.HasPrefix(alias, prefix) {
+					return encoder
+				}
+			}
+		default:
+			if encoder.matches(mr.MediaType) {
+				return encoder
+			}
+		}
+	}
+	return nil
+}
+
+// NegotiateEncoder picks the encoder for a request. A "format"
+// query parameter (an encoder name, MIME type or alias) overrides
+// the Accept header. Without either the encoder named by defaultName
+// is used. ErrNotAcceptable is returned if nothing matches.
+func NegotiateEncoder(r *http.Request, defaultName string) (*Encoder, error) {
+	defaultEncoder := LookupEncoder(defaultName)
+	if format := r.URL.Query().Get("format"); format != "" {
+		if encoder := LookupEncoder(format); encoder != nil {
+			return encoder, nil
+		}
+		return nil, ErrNotAcceptable
+	}
+	accept := strings.TrimSpace(r.Header.Get("Accept"))
+	if accept == "" || accept == "*/*" {
+		// NOTE: earlier versions of the API selected the output
+		// with the Content-Type header, it is honored for GET
+		// requests without an Accept header.
+		if encoder := LookupEncoder(r.Header.Get("Content-Type")); encoder != nil {
+			return encoder, nil
+		}
+		if defaultEncoder == nil {
+			return nil, ErrNotAcceptable
+		}
+		return defaultEncoder, nil
+	}
+	ranges := parseAccept(accept)
+	excluded := map[string]bool{}
+	for _, mr := range ranges {
+		if mr.Q == 0 && mr.specificity() == 2 {
+			if encoder := LookupEncoder(mr.MediaType); encoder != nil {
+				excluded[encoder.Name] = true
+			}
+		}
+	}
+	for _, mr := range ranges {
+		if mr.Q == 0 {
+			continue
+		}
+		if encoder := matchEncoder(mr, defaultEncoder, excluded); encoder != nil {
+			return encoder, nil
+		}
+	}
+	return nil, ErrNotAcceptable
+}
+
+// AcceptableTypes returns the MIME types of the registered encoders
+func AcceptableTypes() []string {
+	l := []string{}
+	for _, encoder := range encoders {
+		l = append(l, encoder.ContentType)
+	}
+	return l
+}
+
+//
+// Encoders
+//
+
+// encodeEPrintXML renders eprints as EPrints XML
+func encodeEPrintXML(w io.Writer, eprints []*EPrint) error {
+	obj := NewEPrints()
+	obj.XMLNS = eprintsNamespace
+	for _, eprint := range eprints {
+		obj.Append(eprint)
+	}
+	src, err := xml.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n%s", src)
+	return err
+}
+
+// encodeEPrintJSON renders a single eprint as a JSON object or
+// several as the JSON version of EPrints XML.
+func encodeEPrintJSON(w io.Writer, eprints []*EPrint) error {
+	var obj interface{}
+	if len(eprints) == 1 {
+		obj = eprints[0]
+	} else {
+		l := NewEPrints()
+		for _, eprint := range eprints {
+			l.Append(eprint)
+		}
+		obj = l
+	}
+	src, err := json.MarshalIndent(obj, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// encodeSimplified renders a single eprint as a simplified record
+// or several as a JSON array of records.
+func encodeSimplified(w io.Writer, eprints []*EPrint) error {
+	records := []*simplified.Record{}
+	for _, eprint := range eprints {
+		record := new(simplified.Record)
+		if err := CrosswalkEPrintToRecord(eprint, record); err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	var (
+		src []byte
+		err error
+	)
+	if len(records) == 1 {
+		src, err = json.MarshalIndent(records[0], "", "    ")
+	} else {
+		src, err = json.MarshalIndent(records, "", "    ")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// dcCollection wraps several Dublin Core records
+type dcCollection struct {
+	XMLName xml.Name `xml:"records"`
+	Records []*OAIDC `xml:"oai_dc:dc"`
+}
+
+// encodeDublinCore renders a single eprint as an oai_dc record or
+// several wrapped in a records element.
+func encodeDublinCore(w io.Writer, eprints []*EPrint) error {
+	var obj interface{}
+	if len(eprints) == 1 {
+		obj = eprintToOAIDC(eprints[0])
+	} else {
+		collection := new(dcCollection)
+		for _, eprint := range eprints {
+			collection.Records = append(collection.Records, eprintToOAIDC(eprint))
+		}
+		obj = collection
+	}
+	src, err := xml.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, src)
+	return err
+}
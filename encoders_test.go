@@ -0,0 +1,105 @@
+package eprinttools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http/httptest"
+	"path"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	ranges := parseAccept("text/*;q=0.3, application/json;q=0.9, */*;q=0.1, application/xml, text/html;level=1;q=bad")
+	expected := []string{"application/xml", "application/json", "text/*", "*/*"}
+	if len(ranges) != len(expected) {
+		t.Fatalf("expected %d ranges, got %+v", len(expected), ranges)
+	}
+	for i, mediaType := range expected {
+		if ranges[i].MediaType != mediaType {
+			t.Errorf("expected %q at %d, got %q", mediaType, i, ranges[i].MediaType)
+		}
+	}
+	if ranges := parseAccept("*/*, application/xml"); ranges[0].MediaType != "application/xml" {
+		t.Errorf("expected the more specific range first, got %+v", ranges)
+	}
+}
+
+func TestNegotiateEncoder(t *testing.T) {
+	for _, test := range []struct {
+		target      string
+		accept      string
+		contentType string
+		defaultName string
+		expected    string
+	}{
+		{"/lemurprints/eprint/1", "", "", "eprint-xml", "eprint-xml"},
+		{"/lemurprints/eprint/1", "*/*", "", "eprint-xml", "eprint-xml"},
+		{"/lemurprints/eprint/1", "", "application/json", "eprint-xml", "eprint-json"},
+		{"/lemurprints/eprint/1", "application/json", "", "eprint-xml", "eprint-json"},
+		{"/lemurprints/eprint/1", "text/xml", "", "eprint-json", "eprint-xml"},
+		{"/lemurprints/eprint/1", "application/xml;q=0.5, application/json;q=0.9", "", "eprint-xml", "eprint-json"},
+		{"/lemurprints/eprint/1", "application/x-bibtex", "", "eprint-xml", "bibtex"},
+		{"/lemurprints/eprint/1", "image/png, */*;q=0.1", "", "eprint-xml", "eprint-xml"},
+		{"/lemurprints/eprint/1", "application/json;q=0, application/*", "", "eprint-json", "eprint-xml"},
+		{"/lemurprints/eprint/1?format=ris", "application/json", "", "eprint-xml", "ris"},
+		{"/lemurprints/eprint/1?format=application/oai_dc%2Bxml", "", "", "eprint-xml", "dc"},
+		{"/lemurprints/record/1", "", "", "simplified", "simplified"},
+		{"/lemurprints/record/1?format=csl", "", "", "simplified", "csl-json"},
+		{"/lemurprints/eprint/1", "image/png", "", "eprint-xml", ""},
+		{"/lemurprints/eprint/1?format=marc21", "", "", "eprint-xml", ""},
+	} {
+		r := httptest.NewRequest("GET", test.target, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+		encoder, err := NegotiateEncoder(r, test.defaultName)
+		if test.expected == "" {
+			if err != ErrNotAcceptable {
+				t.Errorf("%s %q expected ErrNotAcceptable, got %+v, %s", test.target, test.accept, encoder, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q failed, %s", test.target, test.accept, err)
+			continue
+		}
+		if encoder.Name != test.expected {
+			t.Errorf("%s %q expected %q, got %q", test.target, test.accept, test.expected, encoder.Name)
+		}
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	fName := path.Join("testdata", "test_eprint1.xml")
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		t.Fatalf("Failed to read %q, %s", fName, err)
+	}
+	eprints := NewEPrints()
+	if err := xml.Unmarshal(src, &eprints); err != nil {
+		t.Fatalf("Failed to unmarshal %q, %s", fName, err)
+	}
+	names := map[string]bool{}
+	for _, encoder := range Encoders() {
+		names[encoder.Name] = true
+		for _, l := range [][]*EPrint{eprints.EPrint, {eprints.EPrint[0], eprints.EPrint[0]}} {
+			buf := new(bytes.Buffer)
+			if err := encoder.Encode(buf, l); err != nil {
+				t.Errorf("%s failed to encode %d records, %s", encoder.Name, len(l), err)
+				continue
+			}
+			if buf.Len() == 0 {
+				t.Errorf("%s encoded nothing", encoder.Name)
+			}
+		}
+	}
+	for _, name := range []string{"eprint-xml", "eprint-json", "simplified", "dc", "bibtex", "ris", "csl-json", "endnote"} {
+		if !names[name] {
+			t.Errorf("expected %q encoder to be registered", name)
+		}
+	}
+}
@@ -21,27 +21,39 @@ package eprinttools
  */
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	// Caltech Library Packages
-	"github.com/caltechlibrary/simplified"
+	// Caltech Packages
+	"github.com/caltechlibrary/eprinttools/identifier"
 )
 
 type EP3API struct {
 	Config *Config
 	Log    *log.Logger
+
+	// metrics holds the request metrics reported by /metrics
+	metrics *apiMetrics
+
+	// cache holds rendered eprint and record responses, it is nil
+	// unless "cache_size" is set
+	cache *responseCache
 }
 
 // Handle parameters that may continue URL
@@ -64,10 +76,13 @@ func expandAproxDate(dt string, roundDown bool) string {
 		}
 	case 7:
 		if roundDown {
-			dt += "-01 00:00:00"
+			dt += "-01"
 		} else {
-			//FIXME: need to handle 28/29/30/31 day mounths
-			dt += "-31"
+			if t, err := time.Parse(`2006-01`, dt); err == nil {
+				dt = t.AddDate(0, 1, -1).Format(datestamp)
+			} else {
+				dt += "-31"
+			}
 		}
 	}
 	return dt
@@ -78,11 +93,14 @@ func expandAproxDate(dt string, roundDown bool) string {
 // response.
 //
 
-func (api *EP3API) packageIntIDs(w http.ResponseWriter, repoID string, values []int, err error) (int, error) {
+func (api *EP3API) packageIntIDs(w http.ResponseWriter, r *http.Request, repoID string, values []int, err error) (int, error) {
 	if err != nil {
 		api.Log.Printf("ERROR: (%s) query error, %s", repoID, err)
 		return 500, fmt.Errorf("internal server error")
 	}
+	if page := requestPage(r); page != nil {
+		return api.packagePage(w, r, repoID, page, values)
+	}
 	src, err := json.MarshalIndent(values, "", "  ")
 	if err != nil {
 		api.Log.Printf("ERROR: marshal error (%q), %s", repoID, err)
@@ -93,11 +111,14 @@ func (api *EP3API) packageIntIDs(w http.ResponseWriter, repoID string, values []
 	return 200, nil
 }
 
-func (api *EP3API) packageStringIDs(w http.ResponseWriter, repoID string, values []string, err error) (int, error) {
+func (api *EP3API) packageStringIDs(w http.ResponseWriter, r *http.Request, repoID string, values []string, err error) (int, error) {
 	if err != nil {
 		api.Log.Printf("ERROR: (%s) query error, %s", repoID, err)
 		return 500, fmt.Errorf("internal server error")
 	}
+	if page := requestPage(r); page != nil {
+		return api.packagePage(w, r, repoID, page, values)
+	}
 	src, err := json.MarshalIndent(values, "", "  ")
 	if err != nil {
 		api.Log.Printf("ERROR: marshal error (%q), %s", repoID, err)
@@ -108,6 +129,76 @@ func (api *EP3API) packageStringIDs(w http.ResponseWriter, repoID string, values
 	return 200, nil
 }
 
+// pageEnvelope wraps a page of an id list
+type pageEnvelope struct {
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit,omitempty"`
+	Offset int         `json:"offset"`
+	Order  string      `json:"order,omitempty"`
+	Next   string      `json:"next,omitempty"`
+	Items  interface{} `json:"items"`
+}
+
+// pageContextKey is used to attach a *Page to a request
+type pageContextKey struct{}
+
+// requestPage returns the *Page attached to the request by
+// routeEndPoints or nil if the request isn't paginated.
+func requestPage(r *http.Request) *Page {
+	if page, ok := r.Context().Value(pageContextKey{}).(*Page); ok {
+		return page
+	}
+	return nil
+}
+
+// config returns the configuration to use for the id list queries
+// of a request, it applies the request's pagination options.
+func (api *EP3API) config(r *http.Request) *Config {
+	if page := requestPage(r); page != nil {
+		return api.Config.WithPage(page)
+	}
+	return api.Config
+}
+
+// packagePage writes a page of an id list along with the total and
+// a link to the next page.
+func (api *EP3API) packagePage(w http.ResponseWriter, r *http.Request, repoID string, page *Page, values interface{}) (int, error) {
+	envelope := &pageEnvelope{
+		Total:  page.Total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+		Order:  page.Order,
+		Items:  values,
+	}
+	if cursor := page.Cursor(); cursor != "" {
+		q := r.URL.Query()
+		for _, key := range []string{"limit", "offset", "order"} {
+			q.Del(key)
+		}
+		q.Set("cursor", cursor)
+		envelope.Next = fmt.Sprintf("%s%s?%s", strings.TrimSuffix(api.Config.BaseURL, "/"), r.URL.Path, q.Encode())
+	}
+	src, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		api.Log.Printf("ERROR: marshal error (%q), %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, "%s", src)
+	return 200, nil
+}
+
+// negotiate picks the encoder for a request, the error is suitable
+// for returning from an end point.
+func (api *EP3API) negotiate(w http.ResponseWriter, r *http.Request, defaultName string) (*Encoder, int, error) {
+	w.Header().Set("Vary", "Accept")
+	encoder, err := NegotiateEncoder(r, defaultName)
+	if err != nil {
+		return nil, 406, fmt.Errorf("not acceptable, supported types are %s", strings.Join(AcceptableTypes(), ", "))
+	}
+	return encoder, 200, nil
+}
+
 func (api *EP3API) packageObject(w http.ResponseWriter, repoID string, obj interface{}, err error) (int, error) {
 	if err != nil {
 		api.Log.Printf("ERROR: (%s) query error, %s", repoID, err)
@@ -229,6 +320,9 @@ func (api *EP3API) repositoryEndPoint(w http.ResponseWriter, r *http.Request, re
 		api.Log.Printf("Database connections not configured for %s", repoID)
 		return 404, fmt.Errorf("not found")
 	}
+	if statusCode, err := api.authorize(w, r, repoID, ScopeReadPublic); err != nil {
+		return statusCode, err
+	}
 	data, err := GetTablesAndColumns(api.Config, repoID)
 	if err != nil {
 		api.Log.Printf("GetTablesAndColumn(%q), %s", repoID, err)
@@ -243,16 +337,16 @@ func (api *EP3API) usernamesEndPoint(w http.ResponseWriter, r *http.Request, rep
 	if strings.HasSuffix(r.URL.Path, "/help") {
 		return api.packageDocument(w, userDocument(repoID))
 	}
-	usernames, err := GetUsernames(api.Config, repoID)
-	return api.packageStringIDs(w, repoID, usernames, err)
+	usernames, err := GetUsernames(api.config(r), repoID)
+	return api.packageStringIDs(w, r, repoID, usernames, err)
 }
 
 func (api *EP3API) lookupUserIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
 	if len(args) != 1 || strings.HasSuffix(r.URL.Path, "/help") {
 		return api.packageDocument(w, userDocument(repoID))
 	}
-	ids, err := GetUserID(api.Config, repoID, args[0])
-	return api.packageIntIDs(w, repoID, ids, err)
+	ids, err := GetUserID(api.config(r), repoID, args[0])
+	return api.packageIntIDs(w, r, repoID, ids, err)
 }
 
 func (api *EP3API) userEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -274,6 +368,188 @@ func (api *EP3API) userEndPoint(w http.ResponseWriter, r *http.Request, repoID s
 	return api.packageObject(w, repoID, user, err)
 }
 
+// unpackageUserPOST reads an EPrintUser from a JSON or XML request
+// body into user, fields missing from the body are left unchanged.
+// A "password" in the body is returned separately, EPrintUser never
+// holds a password.
+func (api *EP3API) unpackageUserPOST(r *http.Request, user *EPrintUser) (string, error) {
+	var credentials struct {
+		Password string `xml:"password" json:"password"`
+	}
+	src, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		api.Log.Printf("Failed to read from r.Body, %s", err)
+		return "", fmt.Errorf("failed to read, %s", err)
+	}
+	contentType := r.Header.Get("Content-Type")
+	switch contentType {
+	case "application/json":
+		if err := jsonDecode(src, user); err != nil {
+			return "", err
+		}
+		if err := jsonDecode(src, &credentials); err != nil {
+			return "", err
+		}
+	case "application/xml":
+		if err := xml.Unmarshal(src, user); err != nil {
+			return "", err
+		}
+		if err := xml.Unmarshal(src, &credentials); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("%s not supported", contentType)
+	}
+	return credentials.Password, nil
+}
+
+// saveUser validates user, checks the username isn't used by another
+// user and writes the user and password (if not empty).
+func (api *EP3API) saveUser(r *http.Request, repoID string, user *EPrintUser, password string) (int, error) {
+	if err := ValidateUser(user); err != nil {
+		return 400, fmt.Errorf("bad request, %s", err)
+	}
+	ids, err := GetUserID(api.Config, repoID, user.Username)
+	if err != nil {
+		api.Log.Printf("ERROR: (%s) %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	for _, id := range ids {
+		if id != user.UserID {
+			return 409, fmt.Errorf("conflict, username %q already exists", user.Username)
+		}
+	}
+	action := "updated"
+	if user.UserID == 0 {
+		action = "created"
+		if _, err := SQLCreateUser(api.Config, repoID, user); err != nil {
+			api.Log.Printf("ERROR: (%s) %s", repoID, err)
+			return 500, fmt.Errorf("internal server error")
+		}
+	} else if err := SQLUpdateUser(api.Config, repoID, user); err != nil {
+		api.Log.Printf("ERROR: (%s) %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	if password != "" {
+		if err := SQLSetUserPassword(api.Config, repoID, user.UserID, password); err != nil {
+			api.Log.Printf("ERROR: (%s) %s", repoID, err)
+			return 500, fmt.Errorf("internal server error")
+		}
+	}
+	api.Log.Printf("%s %s user id %d (%s) in %s", requestName(r), action, user.UserID, user.Username, repoID)
+	return 200, nil
+}
+
+// userWriteAccess returns an error unless the repository allows writes
+func (api *EP3API) userWriteAccess(r *http.Request, repoID string) (int, error) {
+	dataSource, ok := api.Config.Repositories[repoID]
+	if !ok {
+		api.Log.Printf("Data Source not found for %q", repoID)
+		return 404, fmt.Errorf("not found")
+	}
+	if (r.Method != "PUT" && r.Method != "POST") || dataSource.Write == false {
+		api.Log.Printf("writeAccess not enabled for %s for repoID %q", r.Method, repoID)
+		return 405, fmt.Errorf("method not allowed %q", r.Method)
+	}
+	return 200, nil
+}
+
+// User Create End Point creates a user from a JSON or XML EPrintUser.
+// The usertype defaults to "user", an optional "password" is stored
+// as EPrints does. The new user is returned, without the password.
+func (api *EP3API) userCreateEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if r.Method == "GET" || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, userAdminDocument(repoID))
+	}
+	if statusCode, err := api.userWriteAccess(r, repoID); err != nil {
+		return statusCode, err
+	}
+	user := &EPrintUser{Type: "user", Name: new(Name)}
+	password, err := api.unpackageUserPOST(r, user)
+	if err != nil {
+		return 400, fmt.Errorf("bad request, %s failed (%s), %s", r.Method, repoID, err)
+	}
+	user.UserID, user.Joined = 0, ""
+	if statusCode, err := api.saveUser(r, repoID, user, password); err != nil {
+		return statusCode, err
+	}
+	user, err = GetUserBy(api.Config, repoID, `userid`, user.UserID)
+	return api.packageObject(w, repoID, user, err)
+}
+
+// User Update End Point updates the user {USERID} with the fields
+// in a JSON or XML EPrintUser, fields not included are unchanged.
+func (api *EP3API) userUpdateEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if r.Method == "GET" || len(args) != 1 || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, userAdminDocument(repoID))
+	}
+	if statusCode, err := api.userWriteAccess(r, repoID); err != nil {
+		return statusCode, err
+	}
+	userID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 400, fmt.Errorf("bad request, user id %q not valid", args[0])
+	}
+	user, err := GetUserBy(api.Config, repoID, `userid`, userID)
+	if err != nil {
+		api.Log.Printf("ERROR: (%s) %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	if user.UserID == 0 {
+		return 404, fmt.Errorf("not found, user id %d", userID)
+	}
+	password, err := api.unpackageUserPOST(r, user)
+	if err != nil {
+		return 400, fmt.Errorf("bad request, %s failed (%s), %s", r.Method, repoID, err)
+	}
+	if user.Name == nil {
+		user.Name = new(Name)
+	}
+	user.UserID = userID
+	if statusCode, err := api.saveUser(r, repoID, user, password); err != nil {
+		return statusCode, err
+	}
+	user, err = GetUserBy(api.Config, repoID, `userid`, userID)
+	return api.packageObject(w, repoID, user, err)
+}
+
+// User Deactivate End Point removes the password of {USERID} so the
+// user can no longer log in.
+func (api *EP3API) userDeactivateEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if r.Method == "GET" || len(args) != 1 || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, userAdminDocument(repoID))
+	}
+	if statusCode, err := api.userWriteAccess(r, repoID); err != nil {
+		return statusCode, err
+	}
+	userID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 400, fmt.Errorf("bad request, user id %q not valid", args[0])
+	}
+	if err := SQLDeactivateUser(api.Config, repoID, userID); err != nil {
+		if strings.HasPrefix(err.Error(), "not found") {
+			return 404, fmt.Errorf("%s", err)
+		}
+		api.Log.Printf("ERROR: (%s) %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	api.Log.Printf("%s deactivated user id %d in %s", requestName(r), userID, repoID)
+	user, err := GetUserBy(api.Config, repoID, `userid`, userID)
+	return api.packageObject(w, repoID, user, err)
+}
+
+// Users By Type End Point lists the users with a usertype
+func (api *EP3API) usersByTypeEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if len(args) != 1 || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, userAdminDocument(repoID))
+	}
+	if !slices.Contains(UserTypes, args[0]) {
+		return 400, fmt.Errorf("bad request, usertype %q not valid, expected %s", args[0], strings.Join(UserTypes, ", "))
+	}
+	users, err := GetUsersByType(api.Config, repoID, args[0])
+	return api.packageObject(w, repoID, users, err)
+}
+
 //
 // End Point handles (route as defined `/{REPO_ID}/{END-POINT}/{ARGS}`)
 //
@@ -297,11 +573,11 @@ func (api *EP3API) keysEndPoint(w http.ResponseWriter, r *http.Request, repoID s
 	options := r.URL.Query()
 	eprintStatus := options.Get("eprint_status")
 	if eprintStatus != "" {
-		eprintIDs, err := GetAllEPrintIDsWithStatus(api.Config, repoID, eprintStatus)
-		return api.packageIntIDs(w, repoID, eprintIDs, err)
+		eprintIDs, err := GetAllEPrintIDsWithStatus(api.config(r), repoID, eprintStatus)
+		return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 	}
-	eprintIDs, err := GetAllEPrintIDs(api.Config, repoID)
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetAllEPrintIDs(api.config(r), repoID)
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) createdEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -326,8 +602,8 @@ func (api *EP3API) createdEndPoint(w http.ResponseWriter, r *http.Request, repoI
 			return 400, fmt.Errorf("bad request, (end) %s", err)
 		}
 	}
-	eprintIDs, err := GetEPrintIDsInTimestampRange(api.Config, repoID, "datestamp", start.Format(timestamp), end.Format(timestamp))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsInTimestampRange(api.config(r), repoID, "datestamp", start.Format(timestamp), end.Format(timestamp))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) updatedEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -363,11 +639,11 @@ func (api *EP3API) updatedEndPoint(w http.ResponseWriter, r *http.Request, repoI
 		}
 	}
 	if eprintStatus != `` {
-		eprintIDs, err := GetEPrintIDsWithStatusInTimestampRange(api.Config, repoID, eprintStatus, "lastmod", start.Format(timestamp), end.Format(timestamp))
-		return api.packageIntIDs(w, repoID, eprintIDs, err)
+		eprintIDs, err := GetEPrintIDsWithStatusInTimestampRange(api.config(r), repoID, eprintStatus, "lastmod", start.Format(timestamp), end.Format(timestamp))
+		return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 	}
-	eprintIDs, err := GetEPrintIDsInTimestampRange(api.Config, repoID, "lastmod", start.Format(timestamp), end.Format(timestamp))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsInTimestampRange(api.config(r), repoID, "lastmod", start.Format(timestamp), end.Format(timestamp))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) deletedEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -392,8 +668,8 @@ func (api *EP3API) deletedEndPoint(w http.ResponseWriter, r *http.Request, repoI
 			return 400, fmt.Errorf("bad request, (end) %s", err)
 		}
 	}
-	eprintIDs, err := GetEPrintIDsWithStatus(api.Config, repoID, "deletion", start.Format(timestamp), end.Format(timestamp))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsWithStatus(api.config(r), repoID, "deletion", start.Format(timestamp), end.Format(timestamp))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) pubdateEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -426,11 +702,11 @@ func (api *EP3API) pubdateEndPoint(w http.ResponseWriter, r *http.Request, repoI
 		}
 	}
 	if eprintStatus != `` {
-		eprintIDs, err := GetEPrintIDsWithStatusForDateType(api.Config, repoID, eprintStatus, "published", start.Format(datestamp), end.Format(datestamp))
-		return api.packageIntIDs(w, repoID, eprintIDs, err)
+		eprintIDs, err := GetEPrintIDsWithStatusForDateType(api.config(r), repoID, eprintStatus, "published", start.Format(datestamp), end.Format(datestamp))
+		return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 	}
-	eprintIDs, err := GetEPrintIDsForDateType(api.Config, repoID, "published", start.Format(datestamp), end.Format(datestamp))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForDateType(api.config(r), repoID, "published", start.Format(datestamp), end.Format(datestamp))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 //
@@ -442,11 +718,11 @@ func (api *EP3API) creatorIDEndPoint(w http.ResponseWriter, r *http.Request, rep
 		return api.packageDocument(w, creatorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonOrOrgIDs(api.Config, repoID, "creators")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonOrOrgIDs(api.config(r), repoID, "creators")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.Config, repoID, "creators", args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.config(r), repoID, "creators", args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) creatorNameEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -454,8 +730,8 @@ func (api *EP3API) creatorNameEndPoint(w http.ResponseWriter, r *http.Request, r
 		return api.packageDocument(w, creatorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonNames(api.Config, repoID, "creators_name")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonNames(api.config(r), repoID, "creators_name")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
 	family, given := args[0], ``
 	if len(args) == 2 {
@@ -464,8 +740,8 @@ func (api *EP3API) creatorNameEndPoint(w http.ResponseWriter, r *http.Request, r
 	if len(args) > 2 {
 		return 400, fmt.Errorf("bad request")
 	}
-	eprintIDs, err := GetEPrintIDsForPersonName(api.Config, repoID, "creators_name", family, given)
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonName(api.config(r), repoID, "creators_name", family, given)
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) creatorORCIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -473,12 +749,11 @@ func (api *EP3API) creatorORCIDEndPoint(w http.ResponseWriter, r *http.Request,
 		return api.packageDocument(w, creatorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllORCIDs(api.Config, repoID)
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllORCIDs(api.config(r), repoID)
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	//FIXME: Should validate ORCID format ...
-	eprintIDs, err := GetEPrintIDsForORCID(api.Config, repoID, args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForORCIDs(api.config(r), repoID, identifierForms(identifier.ORCID, args[0]))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) editorIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -486,11 +761,11 @@ func (api *EP3API) editorIDEndPoint(w http.ResponseWriter, r *http.Request, repo
 		return api.packageDocument(w, editorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonOrOrgIDs(api.Config, repoID, "editors")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonOrOrgIDs(api.config(r), repoID, "editors")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.Config, repoID, "editors", args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.config(r), repoID, "editors", args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) editorNameEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -498,8 +773,8 @@ func (api *EP3API) editorNameEndPoint(w http.ResponseWriter, r *http.Request, re
 		return api.packageDocument(w, editorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonNames(api.Config, repoID, "editors_name")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonNames(api.config(r), repoID, "editors_name")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
 	family, given := args[0], ``
 	if len(args) == 2 {
@@ -508,8 +783,8 @@ func (api *EP3API) editorNameEndPoint(w http.ResponseWriter, r *http.Request, re
 	if len(args) > 2 {
 		return 400, fmt.Errorf("bad request")
 	}
-	eprintIDs, err := GetEPrintIDsForPersonName(api.Config, repoID, "editors_name", family, given)
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonName(api.config(r), repoID, "editors_name", family, given)
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) contributorIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -517,11 +792,11 @@ func (api *EP3API) contributorIDEndPoint(w http.ResponseWriter, r *http.Request,
 		return api.packageDocument(w, contributorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonOrOrgIDs(api.Config, repoID, "contributors")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonOrOrgIDs(api.config(r), repoID, "contributors")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.Config, repoID, "contributors", args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.config(r), repoID, "contributors", args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) contributorNameEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -529,8 +804,8 @@ func (api *EP3API) contributorNameEndPoint(w http.ResponseWriter, r *http.Reques
 		return api.packageDocument(w, contributorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonNames(api.Config, repoID, "contributors_name")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonNames(api.config(r), repoID, "contributors_name")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
 	family, given := args[0], ``
 	if len(args) == 2 {
@@ -539,8 +814,8 @@ func (api *EP3API) contributorNameEndPoint(w http.ResponseWriter, r *http.Reques
 	if len(args) > 2 {
 		return 400, fmt.Errorf("bad request")
 	}
-	eprintIDs, err := GetEPrintIDsForPersonName(api.Config, repoID, "contributors_name", family, given)
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonName(api.config(r), repoID, "contributors_name", family, given)
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) advisorIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -548,11 +823,11 @@ func (api *EP3API) advisorIDEndPoint(w http.ResponseWriter, r *http.Request, rep
 		return api.packageDocument(w, advisorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonOrOrgIDs(api.Config, repoID, "thesis_advisor")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonOrOrgIDs(api.config(r), repoID, "thesis_advisor")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.Config, repoID, "thesis_advisor", args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.config(r), repoID, "thesis_advisor", args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) advisorNameEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -560,8 +835,8 @@ func (api *EP3API) advisorNameEndPoint(w http.ResponseWriter, r *http.Request, r
 		return api.packageDocument(w, advisorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonNames(api.Config, repoID, "thesis_advisor_name")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonNames(api.config(r), repoID, "thesis_advisor_name")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
 	family, given := args[0], ``
 	if len(args) == 2 {
@@ -570,8 +845,8 @@ func (api *EP3API) advisorNameEndPoint(w http.ResponseWriter, r *http.Request, r
 	if len(args) > 2 {
 		return 400, fmt.Errorf("bad request")
 	}
-	eprintIDs, err := GetEPrintIDsForPersonName(api.Config, repoID, "thesis_advisor_name", family, given)
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonName(api.config(r), repoID, "thesis_advisor_name", family, given)
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) committeeIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -579,11 +854,11 @@ func (api *EP3API) committeeIDEndPoint(w http.ResponseWriter, r *http.Request, r
 		return api.packageDocument(w, committeeDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonOrOrgIDs(api.Config, repoID, "thesis_committee")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonOrOrgIDs(api.config(r), repoID, "thesis_committee")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.Config, repoID, "thesis_committee", args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonOrOrgID(api.config(r), repoID, "thesis_committee", args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) committeeNameEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -591,8 +866,8 @@ func (api *EP3API) committeeNameEndPoint(w http.ResponseWriter, r *http.Request,
 		return api.packageDocument(w, committeeDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllPersonNames(api.Config, repoID, "thesis_committee_name")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllPersonNames(api.config(r), repoID, "thesis_committee_name")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
 	family, given := args[0], ``
 	if len(args) == 2 {
@@ -601,8 +876,8 @@ func (api *EP3API) committeeNameEndPoint(w http.ResponseWriter, r *http.Request,
 	if len(args) > 2 {
 		return 400, fmt.Errorf("bad request")
 	}
-	eprintIDs, err := GetEPrintIDsForPersonName(api.Config, repoID, "thesis_committee_name", family, given)
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForPersonName(api.config(r), repoID, "thesis_committee_name", family, given)
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) corpCreatorIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -610,11 +885,11 @@ func (api *EP3API) corpCreatorIDEndPoint(w http.ResponseWriter, r *http.Request,
 		return api.packageDocument(w, corpCreatorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllItems(api.Config, repoID, "corp_creators_id")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllItems(api.config(r), repoID, "corp_creators_id")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForItem(api.Config, repoID, "corp_creators_id", args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForItem(api.config(r), repoID, "corp_creators_id", args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) corpCreatorNameEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -622,11 +897,11 @@ func (api *EP3API) corpCreatorNameEndPoint(w http.ResponseWriter, r *http.Reques
 		return api.packageDocument(w, corpCreatorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllItems(api.Config, repoID, "corp_creators_name")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllItems(api.config(r), repoID, "corp_creators_name")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForItem(api.Config, repoID, "corp_creators_name", joinArgs(args))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForItem(api.config(r), repoID, "corp_creators_name", joinArgs(args))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) corpCreatorURIEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -634,11 +909,11 @@ func (api *EP3API) corpCreatorURIEndPoint(w http.ResponseWriter, r *http.Request
 		return api.packageDocument(w, corpCreatorDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllItems(api.Config, repoID, "corp_creators_uri")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllItems(api.config(r), repoID, "corp_creators_uri")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForItem(api.Config, repoID, "corp_creators_uri", joinArgs(args))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForItem(api.config(r), repoID, "corp_creators_uri", joinArgs(args))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) groupIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -646,11 +921,11 @@ func (api *EP3API) groupIDEndPoint(w http.ResponseWriter, r *http.Request, repoI
 		return api.packageDocument(w, groupDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllItems(api.Config, repoID, "local_group")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllItems(api.config(r), repoID, "local_group")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForItem(api.Config, repoID, "local_group", args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForItem(api.config(r), repoID, "local_group", args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) funderIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -658,11 +933,11 @@ func (api *EP3API) funderIDEndPoint(w http.ResponseWriter, r *http.Request, repo
 		return api.packageDocument(w, funderDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllItems(api.Config, repoID, "funders_agency")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllItems(api.config(r), repoID, "funders_agency")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForItem(api.Config, repoID, "funders_agency", joinArgs(args))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForItem(api.config(r), repoID, "funders_agency", joinArgs(args))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) grantNumberEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -670,11 +945,11 @@ func (api *EP3API) grantNumberEndPoint(w http.ResponseWriter, r *http.Request, r
 		return api.packageDocument(w, funderDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllItems(api.Config, repoID, "funders_grant_number")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllItems(api.config(r), repoID, "funders_grant_number")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForItem(api.Config, repoID, "funders_grant_number", joinArgs(args))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForItem(api.config(r), repoID, "funders_grant_number", joinArgs(args))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api EP3API) patentAssigneeEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -682,11 +957,11 @@ func (api EP3API) patentAssigneeEndPoint(w http.ResponseWriter, r *http.Request,
 		return api.packageDocument(w, patentAssigneeDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllItems(api.Config, repoID, "patent_assignee")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllItems(api.config(r), repoID, "patent_assignee")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForItem(api.Config, repoID, "patent_assignee", joinArgs(args))
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForItem(api.config(r), repoID, "patent_assignee", joinArgs(args))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api EP3API) yearEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -694,15 +969,29 @@ func (api EP3API) yearEndPoint(w http.ResponseWriter, r *http.Request, repoID st
 		return api.packageDocument(w, yearDocument(repoID))
 	}
 	if len(args) == 0 {
-		years, err := GetAllYears(api.Config, repoID)
-		return api.packageIntIDs(w, repoID, years, err)
+		years, err := GetAllYears(api.config(r), repoID)
+		return api.packageIntIDs(w, r, repoID, years, err)
 	}
 	year, err := strconv.Atoi(args[0])
 	if err != nil {
-		return api.packageIntIDs(w, repoID, []int{}, err)
+		return api.packageIntIDs(w, r, repoID, []int{}, err)
 	}
-	eprintIDs, err := GetEPrintIDsForYear(api.Config, repoID, year)
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForYear(api.config(r), repoID, year)
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
+}
+
+// identifierForms returns the forms of an identifier to look up so
+// that any accepted form matches. The value as given is included so a
+// value stored the way it was entered (e.g. a hyphenated ISBN) still
+// matches. If value isn't a valid identifier it is looked up as given.
+func identifierForms(kind string, value string) []string {
+	if forms, err := identifier.Forms(kind, value); err == nil {
+		if !slices.Contains(forms, value) {
+			forms = append(forms, value)
+		}
+		return forms
+	}
+	return []string{value}
 }
 
 // Unique identifiers (e.g. doi, issn, isbn) end points
@@ -711,13 +1000,12 @@ func (api *EP3API) doiEndPoint(w http.ResponseWriter, r *http.Request, repoID st
 		return api.packageDocument(w, doiDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllUniqueID(api.Config, repoID, "doi")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllUniqueID(api.config(r), repoID, "doi")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
 	doi := joinArgs(args)
-	//FIXME: Should validate DOI format ...
-	eprintIDs, err := GetEPrintIDsForUniqueID(api.Config, repoID, "doi", doi)
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForUniqueIDs(api.config(r), repoID, "doi", identifierForms(identifier.DOI, doi))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) pubmedIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -725,11 +1013,11 @@ func (api *EP3API) pubmedIDEndPoint(w http.ResponseWriter, r *http.Request, repo
 		return api.packageDocument(w, pubmedIDDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllUniqueID(api.Config, repoID, "pmid")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllUniqueID(api.config(r), repoID, "pmid")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForUniqueID(api.Config, repoID, `pmid`, args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForUniqueIDs(api.config(r), repoID, `pmid`, identifierForms(identifier.PMID, args[0]))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) pubmedCentralIDEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -737,11 +1025,11 @@ func (api *EP3API) pubmedCentralIDEndPoint(w http.ResponseWriter, r *http.Reques
 		return api.packageDocument(w, pubmedCentralIDDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllUniqueID(api.Config, repoID, "pmc_id")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllUniqueID(api.config(r), repoID, "pmc_id")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForUniqueID(api.Config, repoID, `pmc_id`, args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForUniqueIDs(api.config(r), repoID, `pmc_id`, identifierForms(identifier.PMCID, args[0]))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) issnEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -749,11 +1037,11 @@ func (api *EP3API) issnEndPoint(w http.ResponseWriter, r *http.Request, repoID s
 		return api.packageDocument(w, issnDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllUniqueID(api.Config, repoID, "issn")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllUniqueID(api.config(r), repoID, "issn")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForUniqueID(api.Config, repoID, `issn`, args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForUniqueIDs(api.config(r), repoID, `issn`, identifierForms(identifier.ISSN, args[0]))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) isbnEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -761,11 +1049,11 @@ func (api *EP3API) isbnEndPoint(w http.ResponseWriter, r *http.Request, repoID s
 		return api.packageDocument(w, isbnDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllUniqueID(api.Config, repoID, "isbn")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllUniqueID(api.config(r), repoID, "isbn")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForUniqueID(api.Config, repoID, `isbn`, args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForUniqueIDs(api.config(r), repoID, `isbn`, identifierForms(identifier.ISBN, args[0]))
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) patentApplicantEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -773,11 +1061,11 @@ func (api *EP3API) patentApplicantEndPoint(w http.ResponseWriter, r *http.Reques
 		return api.packageDocument(w, patentApplicantDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllUniqueID(api.Config, repoID, "patent_applicant")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllUniqueID(api.config(r), repoID, "patent_applicant")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForUniqueID(api.Config, repoID, `patent_applicant`, args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForUniqueID(api.config(r), repoID, `patent_applicant`, args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) patentNumberEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -785,11 +1073,11 @@ func (api *EP3API) patentNumberEndPoint(w http.ResponseWriter, r *http.Request,
 		return api.packageDocument(w, patentNumberDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllUniqueID(api.Config, repoID, "patent_number")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllUniqueID(api.config(r), repoID, "patent_number")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForUniqueID(api.Config, repoID, `patent_number`, args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForUniqueID(api.config(r), repoID, `patent_number`, args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 func (api *EP3API) patentClassificationEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
@@ -797,11 +1085,42 @@ func (api *EP3API) patentClassificationEndPoint(w http.ResponseWriter, r *http.R
 		return api.packageDocument(w, patentClassificationDocument(repoID))
 	}
 	if len(args) == 0 {
-		values, err := GetAllUniqueID(api.Config, repoID, "patent_classification")
-		return api.packageStringIDs(w, repoID, values, err)
+		values, err := GetAllUniqueID(api.config(r), repoID, "patent_classification")
+		return api.packageStringIDs(w, r, repoID, values, err)
 	}
-	eprintIDs, err := GetEPrintIDsForUniqueID(api.Config, repoID, `patent_classification`, args[0])
-	return api.packageIntIDs(w, repoID, eprintIDs, err)
+	eprintIDs, err := GetEPrintIDsForUniqueID(api.config(r), repoID, `patent_classification`, args[0])
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
+}
+
+// Search End Point combines several field filters (e.g. creator_id,
+// group, type, from, until) into a single query returning a list of
+// eprint ids. Filters are combined with AND unless "op=or" is given.
+func (api *EP3API) searchEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if len(args) != 0 || len(r.URL.Query()) == 0 || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, searchDocument(repoID))
+	}
+	ds, ok := api.Config.Repositories[repoID]
+	if !ok {
+		api.Log.Printf("Data Source not found for %q", repoID)
+		return 404, fmt.Errorf("not found")
+	}
+	query, err := ParseSearchQuery(r.URL.Query())
+	if err != nil {
+		return 400, fmt.Errorf("bad request, %s", err)
+	}
+	if !api.canReadPrivate(r, repoID) {
+		// NOTE: the search route only requires read-public, callers
+		// without read-private can't list non-public records.
+		if searchesPrivate(r.URL.Query(), query) {
+			return api.authorize(w, r, repoID, ScopeReadPrivate)
+		}
+		query.IsPublic = true
+	}
+	if _, _, err := searchStatement(ds.TableMap, query); err != nil {
+		return 400, fmt.Errorf("bad request, %s", err)
+	}
+	eprintIDs, err := SearchEPrintIDs(api.config(r), repoID, query)
+	return api.packageIntIDs(w, r, repoID, eprintIDs, err)
 }
 
 // Record End Point is experimental and may not make it to the
@@ -823,32 +1142,22 @@ func (api *EP3API) recordEndPoint(w http.ResponseWriter, r *http.Request, repoID
 	if err != nil {
 		return 400, fmt.Errorf("bad request, eprint id invalid, %s", err)
 	}
-
-	eprint, err := SQLReadEPrint(api.Config, repoID, ds.BaseURL, eprintID)
+	encoder, code, err := api.negotiate(w, r, "simplified")
 	if err != nil {
-		api.Log.Printf("SQLReadEPrint Error: %s\n", err)
-		return 404, fmt.Errorf("not found")
+		return code, err
 	}
-	//FIXME: this should just be a simple JSON from SQL ...
-	simple := new(simplified.Record)
-	if err := CrosswalkEPrintToRecord(eprint, simple); err != nil {
-		return 500, fmt.Errorf("internal server error")
-	}
-	src, err := json.MarshalIndent(simple, "", "    ")
-	return api.packageJSON(w, repoID, src, err)
+	return api.packageEPrint(w, r, repoID, ds.BaseURL, eprintID, encoder)
 }
 
 // EPrint XML End Point is an experimental read end point provided
 // in the extended EPrint API.  It reads EPrint data structures
 // based on SQL calls to the MySQL database for a given EPrints
-// repository. It accepts two content types - "application/xml"
-// which returns EPrints XML or "application/json" which returns
-// a JSON version of the EPrints XML.
+// repository. The output format is negotiated with the Accept header
+// or the "format" query parameter, EPrints XML is the default.
 func (api *EP3API) eprintEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
 	if len(args) == 0 || repoID == "" || strings.HasSuffix(r.URL.Path, "/help") {
 		return api.packageDocument(w, eprintReadWriteDocument(repoID))
 	}
-	contentType := r.Header.Get("Content-Type")
 	if r.Method != "GET" {
 		return 405, fmt.Errorf("method not allowed")
 	}
@@ -859,35 +1168,16 @@ func (api *EP3API) eprintEndPoint(w http.ResponseWriter, r *http.Request, repoID
 	if err != nil {
 		return 400, fmt.Errorf("bad request, eprint id (%s) %q not valid", repoID, eprintID)
 	}
+	encoder, code, err := api.negotiate(w, r, "eprint-xml")
+	if err != nil {
+		return code, err
+	}
 	ds, ok := api.Config.Repositories[repoID]
 	if !ok {
 		api.Log.Printf("Data Source not found for %q", repoID)
 		return 404, fmt.Errorf("not found")
 	}
-	eprint, err := SQLReadEPrint(api.Config, repoID, ds.BaseURL, eprintID)
-	if err != nil {
-		return 404, fmt.Errorf("%s", err)
-
-	}
-	switch contentType {
-	case "application/json":
-		src, err := json.MarshalIndent(eprint, "", "    ")
-		return api.packageJSON(w, repoID, src, err)
-	case "application/xml":
-		eprints := NewEPrints()
-		eprints.XMLNS = `http://eprints.org/ep2/data/2.0`
-		eprints.Append(eprint)
-		src, err := xml.MarshalIndent(eprints, "", "  ")
-		return api.packageXML(w, repoID, src, err)
-	case "":
-		eprints := NewEPrints()
-		eprints.XMLNS = `http://eprints.org/ep2/data/2.0`
-		eprints.Append(eprint)
-		src, err := xml.MarshalIndent(eprints, "", "  ")
-		return api.packageXML(w, repoID, src, err)
-	default:
-		return 415, fmt.Errorf("unsupported media type, %q", contentType)
-	}
+	return api.packageEPrint(w, r, repoID, ds.BaseURL, eprintID, encoder)
 }
 
 // EPrint Import End Point is an experimental write end point provided
@@ -945,12 +1235,370 @@ func (api *EP3API) eprintImportEndPoint(w http.ResponseWriter, r *http.Request,
 		}
 	}
 
-	ids := []int{}
-	ids, err = ImportEPrints(api.Config, repoID, dataSource, eprints)
-	if err != nil {
+	// NOTE: "atomic=true" imports the whole batch in one transaction,
+	// otherwise each record is committed (or rolled back) on its own.
+	atomic := r.URL.Query().Get("atomic") == "true"
+	results, err := ImportEPrints(api.Config, repoID, dataSource, eprints, atomic)
+	if results == nil {
 		return 400, fmt.Errorf("bad request, create EPrint failed, %s", err)
 	}
-	return api.packageIntIDs(w, repoID, ids, err)
+	if err != nil {
+		api.Log.Printf("ERROR: (%s) import error, %s", repoID, err)
+	}
+	return api.packageImportResults(w, repoID, results)
+}
+
+// EPrint Validate End Point checks EPrints XML or JSON the way
+// eprint-import would receive it without writing anything. The
+// data source defaults are applied as they are on import then each
+// record is checked against the validation rules, tables and column
+// types of the repository.
+func (api *EP3API) eprintValidateEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if (r.Method == "GET") || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, eprintValidateDocument(repoID))
+	}
+	dataSource, ok := api.Config.Repositories[repoID]
+	if !ok {
+		api.Log.Printf("Data Source not found for %q", repoID)
+		return 404, fmt.Errorf("not found")
+	}
+	if r.Method != "POST" && r.Method != "PUT" {
+		return 405, fmt.Errorf("method not allowed %q", r.Method)
+	}
+	eprints, err := api.unpackageEPrintsPOST(r)
+	if err != nil {
+		api.Log.Printf("unpackageEPrintsPost error %q", err)
+		return 400, fmt.Errorf("bad request, %s failed (%s), %s", r.Method, repoID, err)
+	}
+	if eprints == nil {
+		eprints = new(EPrints)
+	}
+	for _, eprint := range eprints.EPrint {
+		if eprint.EPrintStatus == "" {
+			eprint.EPrintStatus = `inbox`
+		}
+		applyImportDefaults(dataSource, eprint)
+	}
+	reports := ValidateEPrints(dataSource, eprints)
+	return api.packageObject(w, repoID, reports, nil)
+}
+
+// packageImportResults writes the per-record import (or update)
+// results. The status code is 200 if every record was created or
+// updated, 207 (multi-status) if some were and 400 if none were.
+func (api *EP3API) packageImportResults(w http.ResponseWriter, repoID string, results []*ImportResult) (int, error) {
+	src, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		api.Log.Printf("ERROR: marshal error (%q), %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	created := 0
+	for _, result := range results {
+		if result.Status == `created` || result.Status == `updated` {
+			created++
+		}
+	}
+	statusCode := 200
+	switch {
+	case created == 0 && len(results) > 0:
+		statusCode = 400
+	case created < len(results):
+		statusCode = 207
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	fmt.Fprintf(w, "%s", src)
+	return statusCode, nil
+}
+
+// EPrint Update End Point is an experimental write end point provided
+// in the extended EPrint API. It accepts EPrints XML or the JSON
+// expression of the EPrint XML and replaces the metadata of existing
+// EPrint records. Each EPrint must include its eprintid.
+//
+// This end point requires a PUT or POST method.  It accepts content
+// encoded as either "application/json" or "application/xml".
+//
+// NOTE: this end point has to be enabled in the settings.json file
+// defining the individual repository support. "write" needs to be
+// set to true.
+func (api *EP3API) eprintUpdateEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if (r.Method == "GET") || (len(args) == 0) || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, eprintReadWriteDocument(repoID))
+	}
+	userID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 400, fmt.Errorf("bad request, (%s, missing user id), %s", repoID, err)
+	}
+	writeAccess := false
+	dataSource, ok := api.Config.Repositories[repoID]
+	if ok == true {
+		writeAccess = dataSource.Write
+	} else {
+		api.Log.Printf("Data Source not found for %q", repoID)
+		return 404, fmt.Errorf("not found")
+	}
+	if (r.Method != "PUT" && r.Method != "POST") || writeAccess == false {
+		api.Log.Printf("writeAccess not enabled for %s for repoID %q", r.Method, repoID)
+		return 405, fmt.Errorf("method not allowed %q", r.Method)
+	}
+	eprints, err := api.unpackageEPrintsPOST(r)
+	if err != nil {
+		api.Log.Printf("unpackageEPrintsPost error %q", err)
+		return 400, fmt.Errorf("bad request, %s failed (%s), %s", r.Method, repoID, err)
+	}
+	if _, err := GetUserBy(api.Config, repoID, `userid`, userID); err != nil {
+		api.Log.Printf("Can't find user name from userid %d", userID)
+		return 400, fmt.Errorf("bad request, %s failed (%s), %s", r.Method, repoID, err)
+	}
+	results, err := UpdateEPrints(api.Config, repoID, dataSource, userID, eprints)
+	if results == nil {
+		return 400, fmt.Errorf("bad request, update EPrint failed, %s", err)
+	}
+	if err != nil {
+		api.Log.Printf("ERROR: (%s) update error, %s", repoID, err)
+	}
+	return api.packageImportResults(w, repoID, results)
+}
+
+// unpackageDocumentUpload reads a multipart/form-data request holding
+// one or more "file" parts and the document's fields (format,
+// formatdesc, language, security, license, content, date_embargo and
+// main). The returned function closes the files and removes any
+// temporary files created while parsing the form.
+func (api *EP3API) unpackageDocumentUpload(r *http.Request) (*Document, []*UploadFile, func(), error) {
+	cleanup := func() {}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return nil, nil, cleanup, fmt.Errorf("expected multipart/form-data, %s", err)
+	}
+	opened := []multipart.File{}
+	cleanup = func() {
+		for _, fp := range opened {
+			fp.Close()
+		}
+		r.MultipartForm.RemoveAll()
+	}
+	document := new(Document)
+	document.Format = r.FormValue("format")
+	document.FormatDesc = r.FormValue("formatdesc")
+	document.Language = r.FormValue("language")
+	document.Security = r.FormValue("security")
+	document.License = r.FormValue("license")
+	document.Content = r.FormValue("content")
+	document.DateEmbargo = r.FormValue("date_embargo")
+	document.Main = r.FormValue("main")
+	files := []*UploadFile{}
+	for _, header := range r.MultipartForm.File["file"] {
+		fp, err := header.Open()
+		if err != nil {
+			return nil, nil, cleanup, err
+		}
+		opened = append(opened, fp)
+		files = append(files, &UploadFile{
+			Filename: header.Filename,
+			MimeType: header.Header.Get("Content-Type"),
+			Reader:   fp,
+		})
+	}
+	if len(files) == 0 {
+		return nil, nil, cleanup, fmt.Errorf(`missing "file" in form`)
+	}
+	return document, files, cleanup, nil
+}
+
+// Document Upload End Point attaches a new document and its files
+// to an existing EPrint. It accepts a POST with multipart/form-data.
+//
+// NOTE: this end point has to be enabled in the settings.json file,
+// "write" needs to be true and "archive_path" set for the repository.
+func (api *EP3API) documentUploadEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if (r.Method == "GET") || (len(args) == 0) || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, documentUploadDocument(repoID))
+	}
+	if len(args) != 2 {
+		return 400, fmt.Errorf("bad request, expected user id and eprint id in path")
+	}
+	userID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 400, fmt.Errorf("bad request, (%s, missing user id), %s", repoID, err)
+	}
+	eprintID, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 400, fmt.Errorf("bad request, (%s, missing eprint id), %s", repoID, err)
+	}
+	dataSource, ok := api.Config.Repositories[repoID]
+	if !ok {
+		api.Log.Printf("Data Source not found for %q", repoID)
+		return 404, fmt.Errorf("not found")
+	}
+	if r.Method != "POST" || dataSource.Write == false || dataSource.ArchivePath == "" {
+		api.Log.Printf("uploads not enabled for %s for repoID %q", r.Method, repoID)
+		return 405, fmt.Errorf("method not allowed %q", r.Method)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+	document, files, cleanup, err := api.unpackageDocumentUpload(r)
+	defer cleanup()
+	if err != nil {
+		return 400, fmt.Errorf("bad request, upload failed (%s), %s", repoID, err)
+	}
+	if _, err := GetUserBy(api.Config, repoID, `userid`, userID); err != nil {
+		api.Log.Printf("Can't find user name from userid %d", userID)
+		return 400, fmt.Errorf("bad request, upload failed (%s), %s", repoID, err)
+	}
+	document, err = SQLCreateDocument(api.Config, repoID, dataSource, userID, eprintID, document, files)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "not found") {
+			return 404, fmt.Errorf("not found, upload failed, %s", err)
+		}
+		api.Log.Printf("upload failed for eprint id %d (%s), %s", eprintID, repoID, err)
+		return 400, fmt.Errorf("bad request, upload failed, %s", err)
+	}
+	return api.packageObject(w, repoID, document, nil)
+}
+
+// unpackageStatusChange reads the EPrint IDs to change from the path
+// or, for a batch, a JSON array in the request body. The body may
+// instead be a JSON object with the "eprint_ids" and the assignment
+// ("userid", "reviewer") for EPrints moved to the buffer.
+func (api *EP3API) unpackageStatusChange(r *http.Request, args []string) ([]int, *StatusAssignment, error) {
+	eprintIDs := []int{}
+	for _, arg := range args {
+		eprintID, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("eprint id %q not valid", arg)
+		}
+		eprintIDs = append(eprintIDs, eprintID)
+	}
+	src, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	src = bytes.TrimSpace(src)
+	var assign *StatusAssignment
+	switch {
+	case len(src) == 0:
+	case src[0] == '{':
+		body := struct {
+			EPrintIDs []int `json:"eprint_ids,omitempty"`
+			StatusAssignment
+		}{}
+		decoder := json.NewDecoder(bytes.NewReader(src))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&body); err != nil {
+			return nil, nil, fmt.Errorf("expected eprint_ids, userid or reviewer, %s", err)
+		}
+		if len(args) > 0 && len(body.EPrintIDs) > 0 {
+			return nil, nil, fmt.Errorf("eprint ids given in both the path and the body")
+		}
+		eprintIDs = append(eprintIDs, body.EPrintIDs...)
+		assign = &body.StatusAssignment
+	case len(args) > 0:
+		return nil, nil, fmt.Errorf("eprint ids given in both the path and the body")
+	default:
+		if err := json.Unmarshal(src, &eprintIDs); err != nil {
+			return nil, nil, fmt.Errorf("expected a JSON array of eprint ids, %s", err)
+		}
+	}
+	if len(eprintIDs) == 0 {
+		return nil, nil, fmt.Errorf("missing eprint ids")
+	}
+	return eprintIDs, assign, nil
+}
+
+// packageStatusChanges writes the outcome of a batch of status
+// changes. The status code is 200 if all the EPrints were moved, 207
+// if only some were and 400 if none were.
+func (api *EP3API) packageStatusChanges(w http.ResponseWriter, repoID string, changes []*StatusChange) (int, error) {
+	src, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		api.Log.Printf("ERROR: marshal error (%q), %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	changed := 0
+	for _, change := range changes {
+		if change.Status == `changed` {
+			changed++
+		}
+	}
+	statusCode := 200
+	switch {
+	case changed == 0 && len(changes) > 0:
+		statusCode = 400
+	case changed < len(changes):
+		statusCode = 207
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	fmt.Fprintf(w, "%s", src)
+	return statusCode, nil
+}
+
+// EPrint Status End Point moves one EPrint, or a batch of EPrints,
+// between the inbox, buffer, archive and deletion statuses.
+//
+// NOTE: this end point has to be enabled in the settings.json file,
+// "write" needs to be set to true.
+func (api *EP3API) eprintStatusEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if (r.Method == "GET") || (len(args) < 2) || strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, eprintStatusDocument(repoID))
+	}
+	userID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 400, fmt.Errorf("bad request, (%s, missing user id), %s", repoID, err)
+	}
+	status := args[1]
+	dataSource, ok := api.Config.Repositories[repoID]
+	if !ok {
+		api.Log.Printf("Data Source not found for %q", repoID)
+		return 404, fmt.Errorf("not found")
+	}
+	if (r.Method != "PUT" && r.Method != "POST") || dataSource.Write == false {
+		api.Log.Printf("writeAccess not enabled for %s for repoID %q", r.Method, repoID)
+		return 405, fmt.Errorf("method not allowed %q", r.Method)
+	}
+	eprintIDs, assign, err := api.unpackageStatusChange(r, args[2:])
+	if err != nil {
+		return 400, fmt.Errorf("bad request, (%s) %s", repoID, err)
+	}
+	if _, ok := StatusTransitions[status]; !ok {
+		return 400, fmt.Errorf("bad request, %s", ValidateStatusTransition("", status))
+	}
+	if _, _, err := statusAssignments(dataSource, status, assign); err != nil {
+		return 400, fmt.Errorf("bad request, (%s) %s", repoID, err)
+	}
+	user, err := GetUserBy(api.Config, repoID, `userid`, userID)
+	if err != nil || user.UserID == 0 {
+		api.Log.Printf("Can't find user name from userid %d", userID)
+		return 400, fmt.Errorf("bad request, (%s) user id %d not found", repoID, userID)
+	}
+	if assign != nil && assign.UserID != 0 && assign.UserID != userID {
+		if assignee, err := GetUserBy(api.Config, repoID, `userid`, assign.UserID); err != nil || assignee.UserID == 0 {
+			api.Log.Printf("Can't find assignee from userid %d", assign.UserID)
+			return 400, fmt.Errorf("bad request, (%s) assignee user id %d not found", repoID, assign.UserID)
+		}
+	}
+	who := user.Username
+	if auth := requestAuth(r); auth != nil && auth.Name != "" && auth.Name != who {
+		who = fmt.Sprintf("%s (%s)", who, auth.Name)
+	}
+	changes, err := ChangeEPrintStatus(api.Config, repoID, dataSource, userID, eprintIDs, status, assign)
+	for _, change := range changes {
+		if change.Status == "changed" {
+			api.Log.Printf("%s moved eprint id %d in %s from %s to %s", who, change.EPrintID, repoID, change.From, change.To)
+		} else {
+			api.Log.Printf("%s failed to move eprint id %d in %s to %s, %s", who, change.EPrintID, repoID, change.To, change.Error)
+		}
+	}
+	if err != nil && len(changes) == 1 {
+		switch {
+		case strings.HasPrefix(err.Error(), "not found"):
+			return 404, fmt.Errorf("not found, %s", err)
+		case strings.HasPrefix(err.Error(), "transition not allowed"):
+			return 409, fmt.Errorf("conflict, %s", err)
+		}
+		return 400, fmt.Errorf("bad request, %s", err)
+	}
+	return api.packageStatusChanges(w, repoID, changes)
 }
 
 // The following define the API as a service handling errors,
@@ -961,10 +1609,14 @@ func (api *EP3API) logRequest(r *http.Request, status int, err error) {
 	if err != nil {
 		errStr = fmt.Sprintf("%s", err)
 	}
+	authName := ""
+	if auth := requestAuth(r); auth != nil && auth.Name != "" {
+		authName = fmt.Sprintf(" Auth: %s", auth.Name)
+	}
 	if len(q) > 0 {
-		api.Log.Printf("%s %s RemoteAddr: %s UserAgent: %s Query: %+v Response: %d %s", r.Method, r.URL.Path, r.RemoteAddr, r.UserAgent(), q, status, errStr)
+		api.Log.Printf("%s %s RemoteAddr: %s UserAgent: %s%s Query: %+v Response: %d %s", r.Method, r.URL.Path, r.RemoteAddr, r.UserAgent(), authName, q, status, errStr)
 	} else {
-		api.Log.Printf("%s %s RemoteAddr: %s UserAgent: %s Response: %d %s", r.Method, r.URL.Path, r.RemoteAddr, r.UserAgent(), status, errStr)
+		api.Log.Printf("%s %s RemoteAddr: %s UserAgent: %s%s Response: %d %s", r.Method, r.URL.Path, r.RemoteAddr, r.UserAgent(), authName, status, errStr)
 	}
 }
 
@@ -1001,6 +1653,24 @@ func (api *EP3API) routeEndPoints(w http.ResponseWriter, r *http.Request) (int,
 		if fn, hasRoute := routes[endPoint]; hasRoute == true {
 			// Confirm we have a DB connection
 			if _, hasConnection := api.Config.Connections[repoID]; hasConnection == true {
+				if statusCode, err := api.authorize(w, r, repoID, routeScope(endPoint)); err != nil {
+					return statusCode, err
+				}
+				if ep := LookupEndPoint(endPoint); ep != nil {
+					if strings.HasSuffix(r.URL.Path, "/help") && ep.Doc != "" {
+						return api.packageDocument(w, strings.ReplaceAll(ep.Doc, "{REPO_ID}", repoID))
+					}
+					if !ep.AllowsMethod(r.Method) {
+						return 405, fmt.Errorf("method not allowed, %q", r.Method)
+					}
+				}
+				page, err := ParsePage(r.URL.Query())
+				if err != nil {
+					return 400, fmt.Errorf("bad request, %s", err)
+				}
+				if page != nil {
+					r = r.WithContext(context.WithValue(r.Context(), pageContextKey{}, page))
+				}
 				return fn(w, r, repoID, args)
 			}
 		}
@@ -1013,16 +1683,34 @@ func (api *EP3API) routeHandler(w http.ResponseWriter, r *http.Request) {
 		err        error
 		statusCode int
 	)
-	if r.Method != "GET" && r.Method != "POST" {
+	t0 := time.Now()
+	if r.Method != "GET" && r.Method != "POST" && r.Method != "PUT" {
 		statusCode, err = 405, fmt.Errorf("method not allowed, %q", r.Method)
 		handleError(w, statusCode, err)
+	} else if auth, authErr := api.authenticate(r); authErr != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ep3apid"`)
+		statusCode, err = 401, fmt.Errorf("unauthorized, %s", authErr)
+		handleError(w, statusCode, err)
 	} else {
+		r = withAuth(r, auth)
 		switch {
 		case r.URL.Path == "/version":
 			statusCode, err = api.versionEndPoint(w, r)
 			if err != nil {
 				handleError(w, statusCode, err)
 			}
+		case r.URL.Path == "/healthz":
+			statusCode, err = api.healthzEndPoint(w, r)
+		case r.URL.Path == "/readyz":
+			statusCode, err = api.readyzEndPoint(w, r)
+			if err != nil {
+				handleError(w, statusCode, err)
+			}
+		case r.URL.Path == "/metrics":
+			statusCode, err = api.metricsEndPoint(w, r)
+			if err != nil {
+				handleError(w, statusCode, err)
+			}
 		case r.URL.Path == "/favicon.ico":
 			statusCode, err = 200, nil
 			fmt.Fprintf(w, "")
@@ -1046,6 +1734,7 @@ func (api *EP3API) routeHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	api.observe(r, statusCode, time.Since(t0))
 	api.logRequest(r, statusCode, err)
 }
 
@@ -1072,6 +1761,10 @@ func (api *EP3API) Shutdown(appName string, sigName string) int {
 	if err := CloseConnections(api.Config); err != nil {
 		exitCode = 1
 	}
+	if err := CloseJSONStore(api.Config); err != nil {
+		api.Log.Printf("%s", err)
+		exitCode = 1
+	}
 	api.Log.Printf(`Shutdown completed %s pid: %d exit code: %d `, appName, pid, exitCode)
 	return exitCode
 }
@@ -1087,6 +1780,25 @@ func (api *EP3API) Reload(appName string, sigName string, settings string) error
 	return api.InitExtendedAPI(settings)
 }
 
+// registerRoutes adds the registered end points supported by a
+// repository's tables to api.Config.Routes.
+func (api *EP3API) registerRoutes(repoID string, dataSource *DataSource) {
+	if api.Config.Routes == nil {
+		api.Config.Routes = map[string]map[string]func(http.ResponseWriter, *http.Request, string, []string) (int, error){}
+	}
+	if api.Config.Routes[repoID] == nil {
+		api.Config.Routes[repoID] = map[string]func(http.ResponseWriter, *http.Request, string, []string) (int, error){}
+	}
+	for _, endPoint := range EndPoints() {
+		if endPoint.Supports(dataSource.TableMap) {
+			handler := endPoint.Handler
+			api.Config.Routes[repoID][endPoint.Name] = func(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+				return handler(api, w, r, repoID, args)
+			}
+		}
+	}
+}
+
 func (api *EP3API) InitExtendedAPI(settings string) error {
 	var err error
 	// NOTE: This reads the settings file and creates a global
@@ -1110,6 +1822,12 @@ func (api *EP3API) InitExtendedAPI(settings string) error {
 		}
 		api.Log = log.New(lp, ``, log.LstdFlags)
 	}
+	if api.metrics == nil {
+		api.metrics = newAPIMetrics()
+	}
+	if api.Config.CacheSize > 0 {
+		api.cache = newResponseCache(api.Config.CacheSize)
+	}
 	if api.Config.Hostname == "" {
 		return fmt.Errorf("Hostings hostname for service")
 	}
@@ -1119,48 +1837,6 @@ func (api *EP3API) InitExtendedAPI(settings string) error {
 	if err := OpenConnections(api.Config); err != nil {
 		return fmt.Errorf(`Failed to open database connections, %s`, err)
 	}
-	if api.Config.Routes == nil {
-		api.Config.Routes = map[string]map[string]func(http.ResponseWriter, *http.Request, string, []string) (int, error){}
-	}
-	// This is a map standard endpoints and end point handlers.
-	// NOTE: Eventually this should evolving into a registration
-	// style design pattern based on unique fields in repositories.
-	routes := map[string]func(http.ResponseWriter, *http.Request, string, []string) (int, error){
-		// Standard fields
-		"keys":              api.keysEndPoint,
-		"created":           api.createdEndPoint,
-		"updated":           api.updatedEndPoint,
-		"deleted":           api.deletedEndPoint,
-		"pubdate":           api.pubdateEndPoint,
-		"doi":               api.doiEndPoint,
-		"record":            api.recordEndPoint,
-		"eprint":            api.eprintEndPoint,
-		"eprint-import":     api.eprintImportEndPoint,
-		"creator-id":        api.creatorIDEndPoint,
-		"creator-orcid":     api.creatorORCIDEndPoint,
-		"editor-id":         api.editorIDEndPoint,
-		"contributor-id":    api.contributorIDEndPoint,
-		"advisor-id":        api.advisorIDEndPoint,
-		"committee-id":      api.committeeIDEndPoint,
-		"corp-creator-id":   api.corpCreatorIDEndPoint,
-		"group-id":          api.groupIDEndPoint,
-		"funder-id":         api.funderIDEndPoint,
-		"grant-number":      api.grantNumberEndPoint,
-		"creator-name":      api.creatorNameEndPoint,
-		"editor-name":       api.editorNameEndPoint,
-		"contributor-name":  api.contributorNameEndPoint,
-		"advisor-name":      api.advisorNameEndPoint,
-		"committee-name":    api.committeeNameEndPoint,
-		"corp-creator-name": api.corpCreatorNameEndPoint,
-		"corp-creator-uri":  api.corpCreatorURIEndPoint,
-		"issn":              api.issnEndPoint,
-		"isbn":              api.isbnEndPoint,
-		"year":              api.yearEndPoint,
-		"usernames":         api.usernamesEndPoint,
-		"lookup-userid":     api.lookupUserIDEndPoint,
-		"user":              api.userEndPoint,
-		"is-public":         api.isPublicEndPoint,
-	}
 
 	/* NOTE: We need a DB connection to MySQL for each
 	   EPrints repository supported by the API
@@ -1168,37 +1844,16 @@ func (api *EP3API) InitExtendedAPI(settings string) error {
 	if err := OpenConnections(api.Config); err != nil {
 		return err
 	}
+	// NOTE: the JSON store is optional, it is opened here so /readyz
+	// only needs to ping it.
+	if api.Config.JSONStore != "" {
+		if err := OpenJSONStore(api.Config); err != nil {
+			return err
+		}
+	}
 
 	for repoID, dataSource := range api.Config.Repositories {
-		// Add routes (end points) for the target repository
-		for route, fn := range routes {
-			if api.Config.Routes[repoID] == nil {
-				api.Config.Routes[repoID] = map[string]func(http.ResponseWriter, *http.Request, string, []string) (int, error){}
-			}
-			api.Config.Routes[repoID][route] = fn
-		}
-		// NOTE: make sure each end point is supported by repository
-		// e.g. CaltechTHESIS doens't have "patent_number",
-		// "patent_classification", "parent_assignee", "pmc_id",
-		// or "pmid".
-		if hasColumn(dataSource.TableMap, "eprint", "pmc_id") {
-			api.Config.Routes[repoID]["pmcid"] = api.pubmedCentralIDEndPoint
-		}
-		if hasColumn(dataSource.TableMap, "eprint", "pmid") {
-			api.Config.Routes[repoID]["pmid"] = api.pubmedIDEndPoint
-		}
-		if hasColumn(dataSource.TableMap, "eprint", "patent_applicant") {
-			api.Config.Routes[repoID]["patent-applicant"] = api.patentApplicantEndPoint
-		}
-		if hasColumn(dataSource.TableMap, "eprint", "patent_number") {
-			api.Config.Routes[repoID]["patent-number"] = api.patentNumberEndPoint
-		}
-		if hasColumn(dataSource.TableMap, "eprint", "patent_classification") {
-			api.Config.Routes[repoID]["patent-classification"] = api.patentClassificationEndPoint
-		}
-		if hasColumn(dataSource.TableMap, "eprint_patent_assignee", "patent_assignee") {
-			api.Config.Routes[repoID]["patent-assignee"] = api.patentAssigneeEndPoint
-		}
+		api.registerRoutes(repoID, dataSource)
 	}
 	return nil
 }
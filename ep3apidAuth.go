@@ -0,0 +1,405 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3apidAuth.go implements authentication and per repository
+ * authorization for ep3apid.
+ *
+ * Clients authenticate with an API token (Authorization: Bearer
+ * NAME:SECRET) or, if enabled for the repository, HTTP Basic auth
+ * against the EPrints user table. Each end point requires a scope.
+ * If no tokens are configured and basic auth isn't enabled then
+ * ep3apid behaves as before and every request has every scope.
+ */
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	// 3rd Party Packages
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Scopes used to authorize requests to ep3apid
+const (
+	// ScopeReadPublic allows reading public records and lookups
+	ScopeReadPublic = "read-public"
+	// ScopeReadPrivate allows reading non-public records, key lists
+	// and user information
+	ScopeReadPrivate = "read-private"
+	// ScopeImport allows importing and updating records
+	ScopeImport = "import"
+	// ScopeAdmin allows everything
+	ScopeAdmin = "admin"
+)
+
+// APIToken describes an API token accepted by ep3apid. Clients
+// send the token as "Authorization: Bearer NAME:SECRET".
+type APIToken struct {
+	// Name identifies the token, it is used in the logs
+	Name string `json:"name"`
+
+	// Hash is the bcrypt hash of the token's secret,
+	// see HashAPISecret.
+	Hash string `json:"hash"`
+
+	// Scopes maps a repository id to the scopes granted for
+	// that repository. The repository id "*" applies to all
+	// repositories.
+	Scopes map[string][]string `json:"scopes"`
+}
+
+// apiAuth describes the credentials presented with a request
+type apiAuth struct {
+	// Name of the token or username, empty if anonymous
+	Name string
+	// Token is set if an API token was presented
+	Token *APIToken
+	// Username and Password are set if basic auth was presented
+	Username string
+	Password string
+	// scopes are the scopes granted for scopesRepoID, they are
+	// worked out once per request so basic auth passwords are only
+	// checked once.
+	scopes       []string
+	scopesRepoID string
+}
+
+// authContextKey is used to attach the apiAuth to a request
+type authContextKey struct{}
+
+var (
+	// verifiedSecrets caches tokens that have been checked with
+	// bcrypt by the sha256 of the presented token.
+	verifiedSecrets = map[string]*APIToken{}
+	verifiedMu      sync.RWMutex
+)
+
+// GenerateAPISecret returns a random secret for a new API token.
+func GenerateAPISecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashAPISecret returns the hash of secret to store in the
+// "api_tokens" section of the settings file.
+func HashAPISecret(secret string) (string, error) {
+	src, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(src), nil
+}
+
+// AuthEnabled returns true if API tokens or basic auth are
+// configured.
+func (config *Config) AuthEnabled() bool {
+	if len(config.APITokens) > 0 {
+		return true
+	}
+	for _, ds := range config.Repositories {
+		if ds.BasicAuth {
+			return true
+		}
+	}
+	return false
+}
+
+// hasScope returns true if scopes grant scope. The admin scope
+// grants every scope and read-private grants read-public.
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		switch {
+		case s == scope || s == ScopeAdmin:
+			return true
+		case s == ScopeReadPrivate && scope == ScopeReadPublic:
+			return true
+		}
+	}
+	return false
+}
+
+// userTypeScopes maps an EPrints usertype to scopes
+func userTypeScopes(userType string) []string {
+	switch userType {
+	case "admin":
+		return []string{ScopeAdmin}
+	case "editor":
+		return []string{ScopeReadPrivate, ScopeImport}
+	}
+	return []string{ScopeReadPublic}
+}
+
+// checkEPrintsPassword compares password with the value of the
+// password column in the EPrints user table. EPrints 3.3 stores
+// passwords as "?digest=SHA512&rounds=N&salt=SALT#HASH" where HASH
+// is the digest of SALT + PASSWORD rehashed N times. Legacy crypt(3)
+// passwords are not supported.
+func checkEPrintsPassword(crypted string, password string) bool {
+	if crypted == "" || password == "" || !strings.HasPrefix(crypted, "?") {
+		return false
+	}
+	u, err := url.Parse(crypted)
+	if err != nil {
+		return false
+	}
+	q := u.Query()
+	if strings.ToUpper(q.Get("digest")) != "SHA512" {
+		return false
+	}
+	rounds, err := strconv.Atoi(q.Get("rounds"))
+	if err != nil || rounds < 1 {
+		return false
+	}
+	digest := sha512.Sum512([]byte(q.Get("salt") + password))
+	for i := 1; i < rounds; i++ {
+		digest = sha512.Sum512(digest[:])
+	}
+	expected := u.Fragment
+	for _, got := range []string{
+		hex.EncodeToString(digest[:]),
+		base64.StdEncoding.EncodeToString(digest[:]),
+		base64.RawStdEncoding.EncodeToString(digest[:]),
+		base64.RawURLEncoding.EncodeToString(digest[:]),
+	} {
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(got)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// cryptEPrintsPassword returns password in the format EPrints 3.3
+// stores in the user table (see checkEPrintsPassword).
+func cryptEPrintsPassword(password string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	salt, rounds := hex.EncodeToString(buf), 10000
+	digest := sha512.Sum512([]byte(salt + password))
+	for i := 1; i < rounds; i++ {
+		digest = sha512.Sum512(digest[:])
+	}
+	q := url.Values{}
+	q.Set("digest", "SHA512")
+	q.Set("rounds", strconv.Itoa(rounds))
+	q.Set("salt", salt)
+	return fmt.Sprintf("?%s#%s", q.Encode(), hex.EncodeToString(digest[:])), nil
+}
+
+// getUserCredentials returns the usertype and password hash for
+// username in the EPrints user table.
+func getUserCredentials(config *Config, repoID string, username string) (string, string, error) {
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return "", "", fmt.Errorf("no database connection for %s", repoID)
+	}
+	stmt := `SELECT IFNULL(usertype, '') AS usertype, IFNULL(password, '') AS password FROM user WHERE username = ? LIMIT 1`
+	rows, err := db.Query(stmt, username)
+	if err != nil {
+		return "", "", fmt.Errorf("ERROR: query error (%q), %s", repoID, err)
+	}
+	defer rows.Close()
+	userType, crypted := "", ""
+	for rows.Next() {
+		if err := rows.Scan(&userType, &crypted); err != nil {
+			return "", "", fmt.Errorf("ERROR: scan error (%q), %q, %s", repoID, stmt, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", "", fmt.Errorf("ERROR: rows error (%q), %s", repoID, err)
+	}
+	return userType, crypted, nil
+}
+
+// findAPIToken returns the configured token matching the
+// presented "NAME:SECRET" value or nil.
+func (config *Config) findAPIToken(value string) *APIToken {
+	sum := sha256.Sum256([]byte(value))
+	key := hex.EncodeToString(sum[:])
+	verifiedMu.RLock()
+	token, ok := verifiedSecrets[key]
+	verifiedMu.RUnlock()
+	if ok {
+		// NOTE: the settings may have been reloaded
+		for _, t := range config.APITokens {
+			if t == token {
+				return token
+			}
+		}
+	}
+	name, secret, found := strings.Cut(value, ":")
+	if !found {
+		return nil
+	}
+	for _, t := range config.APITokens {
+		if t.Name == name && bcrypt.CompareHashAndPassword([]byte(t.Hash), []byte(secret)) == nil {
+			verifiedMu.Lock()
+			verifiedSecrets[key] = t
+			verifiedMu.Unlock()
+			return t
+		}
+	}
+	return nil
+}
+
+// authenticate reads the credentials from the request's
+// Authorization header. An unknown API token is an error, basic
+// auth credentials are checked per repository by scopesFor.
+func (api *EP3API) authenticate(r *http.Request) (*apiAuth, error) {
+	auth := new(apiAuth)
+	value := r.Header.Get("Authorization")
+	if value == "" {
+		return auth, nil
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		auth.Name, auth.Username, auth.Password = username, username, password
+		return auth, nil
+	}
+	scheme, credentials, _ := strings.Cut(value, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, fmt.Errorf("unsupported authorization scheme %q", scheme)
+	}
+	token := api.Config.findAPIToken(strings.TrimSpace(credentials))
+	if token == nil {
+		return nil, fmt.Errorf("invalid API token")
+	}
+	auth.Name, auth.Token = token.Name, token
+	return auth, nil
+}
+
+// scopesFor returns the scopes auth grants for repoID.
+func (api *EP3API) scopesFor(auth *apiAuth, repoID string) ([]string, error) {
+	if !api.Config.AuthEnabled() {
+		return []string{ScopeAdmin}, nil
+	}
+	switch {
+	case auth == nil || auth.Name == "":
+		if api.Config.AnonymousScopes != nil {
+			return api.Config.AnonymousScopes, nil
+		}
+		return []string{ScopeReadPublic}, nil
+	case auth.Token != nil:
+		scopes := append([]string{}, auth.Token.Scopes["*"]...)
+		return append(scopes, auth.Token.Scopes[repoID]...), nil
+	}
+	ds, ok := api.Config.Repositories[repoID]
+	if !ok || !ds.BasicAuth {
+		return nil, fmt.Errorf("basic auth not enabled for %s", repoID)
+	}
+	userType, crypted, err := getUserCredentials(api.Config, repoID, auth.Username)
+	if err != nil {
+		api.Log.Printf("%s", err)
+		return nil, fmt.Errorf("invalid username or password")
+	}
+	if !checkEPrintsPassword(crypted, auth.Password) {
+		return nil, fmt.Errorf("invalid username or password")
+	}
+	return userTypeScopes(userType), nil
+}
+
+// requestScopes returns the scopes the request's credentials grant
+// for repoID. They are kept with the apiAuth attached to the request
+// so later checks (e.g. canReadPrivate) don't repeat the work.
+func (api *EP3API) requestScopes(r *http.Request, repoID string) ([]string, error) {
+	auth := requestAuth(r)
+	if auth != nil && auth.scopes != nil && auth.scopesRepoID == repoID {
+		return auth.scopes, nil
+	}
+	scopes, err := api.scopesFor(auth, repoID)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		auth.scopes, auth.scopesRepoID = append([]string{}, scopes...), repoID
+	}
+	return scopes, nil
+}
+
+// requestAuth returns the apiAuth attached to the request by
+// routeHandler.
+func requestAuth(r *http.Request) *apiAuth {
+	if auth, ok := r.Context().Value(authContextKey{}).(*apiAuth); ok {
+		return auth
+	}
+	return nil
+}
+
+// withAuth returns a copy of r with auth attached
+func withAuth(r *http.Request, auth *apiAuth) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authContextKey{}, auth))
+}
+
+// authorize checks the request has scope for repoID. It returns
+// 401 if the credentials are missing or invalid and 403 if they
+// don't grant the scope.
+func (api *EP3API) authorize(w http.ResponseWriter, r *http.Request, repoID string, scope string) (int, error) {
+	auth := requestAuth(r)
+	scopes, err := api.requestScopes(r, repoID)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q`, repoID))
+		return 401, fmt.Errorf("unauthorized, %s", err)
+	}
+	if hasScope(scopes, scope) {
+		return 200, nil
+	}
+	if auth == nil || auth.Name == "" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q`, repoID))
+		return 401, fmt.Errorf("unauthorized, %s scope required", scope)
+	}
+	return 403, fmt.Errorf("forbidden, %s scope required", scope)
+}
+
+// requestName returns the name of the token or user making a
+// request for logging, "anonymous" if there are no credentials.
+func requestName(r *http.Request) string {
+	if auth := requestAuth(r); auth != nil && auth.Name != "" {
+		return auth.Name
+	}
+	return "anonymous"
+}
+
+// canReadPrivate returns true if the request may read non-public
+// records in repoID.
+func (api *EP3API) canReadPrivate(r *http.Request, repoID string) bool {
+	scopes, err := api.requestScopes(r, repoID)
+	return err == nil && hasScope(scopes, ScopeReadPrivate)
+}
+
+// routeScope returns the scope required for an end point
+func routeScope(endPoint string) string {
+	if ep := LookupEndPoint(endPoint); ep != nil {
+		return ep.scope()
+	}
+	return ScopeReadPublic
+}
@@ -0,0 +1,170 @@
+package eprinttools
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHasScope(t *testing.T) {
+	for _, c := range []struct {
+		scopes   []string
+		scope    string
+		expected bool
+	}{
+		{[]string{ScopeReadPublic}, ScopeReadPublic, true},
+		{[]string{ScopeReadPublic}, ScopeReadPrivate, false},
+		{[]string{ScopeReadPrivate}, ScopeReadPublic, true},
+		{[]string{ScopeImport}, ScopeReadPrivate, false},
+		{[]string{ScopeAdmin}, ScopeImport, true},
+		{nil, ScopeReadPublic, false},
+	} {
+		if got := hasScope(c.scopes, c.scope); got != c.expected {
+			t.Errorf("hasScope(%v, %q) expected %t, got %t", c.scopes, c.scope, c.expected, got)
+		}
+	}
+}
+
+func TestCheckEPrintsPassword(t *testing.T) {
+	salt, password := "c0ffee", "lemur-secret"
+	digest := sha512.Sum512([]byte(salt + password))
+	for i := 1; i < 10; i++ {
+		digest = sha512.Sum512(digest[:])
+	}
+	crypted := "?digest=SHA512&rounds=10&salt=" + salt + "#" + hex.EncodeToString(digest[:])
+	if !checkEPrintsPassword(crypted, password) {
+		t.Errorf("expected password to match %q", crypted)
+	}
+	for _, s := range []string{"wrong", ""} {
+		if checkEPrintsPassword(crypted, s) {
+			t.Errorf("expected password %q not to match", s)
+		}
+	}
+	if checkEPrintsPassword("abJnggxhB/yWI", password) {
+		t.Errorf("expected crypt(3) passwords to be rejected")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	secret, err := GenerateAPISecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := HashAPISecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := new(EP3API)
+	api.Log = log.New(ioutil.Discard, "", 0)
+	api.Config = &Config{
+		Repositories: map[string]*DataSource{
+			"lemurprints": {},
+			"otherprints": {},
+		},
+	}
+	// Without tokens or basic auth everything is allowed
+	r := httptest.NewRequest("GET", "/lemurprints/user/1", nil)
+	if status, err := api.authorize(httptest.NewRecorder(), r, "lemurprints", ScopeAdmin); err != nil {
+		t.Errorf("expected authorization without auth configured, got %d %s", status, err)
+	}
+
+	api.Config.APITokens = []*APIToken{
+		{
+			Name:   "feeds",
+			Hash:   hash,
+			Scopes: map[string][]string{"lemurprints": {ScopeReadPrivate}},
+		},
+	}
+	for _, c := range []struct {
+		authorization string
+		repoID        string
+		scope         string
+		expected      int
+	}{
+		{"", "lemurprints", ScopeReadPublic, 200},
+		{"", "lemurprints", ScopeReadPrivate, 401},
+		{"Bearer feeds:" + secret, "lemurprints", ScopeReadPrivate, 200},
+		{"Bearer feeds:" + secret, "lemurprints", ScopeImport, 403},
+		{"Bearer feeds:" + secret, "otherprints", ScopeReadPrivate, 403},
+		{"Basic bW9sbHk6c2VjcmV0", "lemurprints", ScopeReadPublic, 401},
+	} {
+		r := httptest.NewRequest("GET", "/"+c.repoID+"/keys", nil)
+		if c.authorization != "" {
+			r.Header.Set("Authorization", c.authorization)
+		}
+		auth, err := api.authenticate(r)
+		if err != nil {
+			t.Errorf("%q authenticate failed, %s", c.authorization, err)
+			continue
+		}
+		w := httptest.NewRecorder()
+		status, _ := api.authorize(w, withAuth(r, auth), c.repoID, c.scope)
+		if status != c.expected {
+			t.Errorf("%q %s %s expected %d, got %d", c.authorization, c.repoID, c.scope, c.expected, status)
+		}
+		if status == 401 && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("expected WWW-Authenticate header for 401")
+		}
+	}
+	for _, value := range []string{"Bearer feeds:not-the-secret", "Bearer " + secret, "Digest abc"} {
+		r := httptest.NewRequest("GET", "/lemurprints/keys", nil)
+		r.Header.Set("Authorization", value)
+		if _, err := api.authenticate(r); err == nil {
+			t.Errorf("expected %q to fail authentication", value)
+		}
+	}
+
+	// The routeHandler should reject bad tokens before routing
+	w := httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/lemurprints/keys", nil)
+	r.Header.Set("Authorization", "Bearer feeds:nope")
+	api.routeHandler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for bad token, got %d", w.Code)
+	}
+	if routeScope("eprint-import") != ScopeImport || routeScope("doi") != ScopeReadPublic {
+		t.Errorf("unexpected route scopes")
+	}
+}
+
+// TestRequestScopes checks the scopes are only worked out once
+// per request and repository.
+func TestRequestScopes(t *testing.T) {
+	api := new(EP3API)
+	api.Log = log.New(ioutil.Discard, "", 0)
+	token := &APIToken{
+		Name:   "feeds",
+		Scopes: map[string][]string{"lemurprints": {ScopeReadPrivate}},
+	}
+	api.Config = &Config{
+		Repositories: map[string]*DataSource{
+			"lemurprints": {},
+			"otherprints": {},
+		},
+		APITokens: []*APIToken{token},
+	}
+	auth := &apiAuth{Name: token.Name, Token: token}
+	r := withAuth(httptest.NewRequest("GET", "/lemurprints/keys", nil), auth)
+	if status, err := api.authorize(httptest.NewRecorder(), r, "lemurprints", ScopeReadPublic); err != nil {
+		t.Fatalf("expected authorization, got %d %s", status, err)
+	}
+	if auth.scopesRepoID != "lemurprints" || !hasScope(auth.scopes, ScopeReadPrivate) {
+		t.Errorf("expected scopes to be kept with the request, got %q %v", auth.scopesRepoID, auth.scopes)
+	}
+	// Changing the token shows canReadPrivate uses the scopes
+	// from authorize rather than checking the credentials again.
+	token.Scopes = map[string][]string{"otherprints": {ScopeReadPrivate}}
+	if !api.canReadPrivate(r, "lemurprints") {
+		t.Errorf("expected canReadPrivate to use the request scopes")
+	}
+	if !api.canReadPrivate(r, "otherprints") {
+		t.Errorf("expected scopes for another repository to be worked out")
+	}
+	if api.canReadPrivate(httptest.NewRequest("GET", "/lemurprints/keys", nil), "lemurprints") {
+		t.Errorf("expected a request without credentials not to read private records")
+	}
+}
@@ -0,0 +1,199 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3apidCache.go implements HTTP conditional requests (ETag,
+ * Last-Modified) and an optional in-process LRU cache of rendered
+ * eprint and record responses.
+ */
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedResponse is a rendered response and the version it was
+// rendered from
+type cachedResponse struct {
+	key          string
+	etag         string
+	lastModified time.Time
+	contentType  string
+	body         []byte
+}
+
+// responseCache is a least recently used cache of rendered responses.
+// Entries are only returned when the requested ETag and lastmod still
+// match so a modified eprint is re-rendered.
+type responseCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// newResponseCache returns a cache holding at most size responses
+func newResponseCache(size int) *responseCache {
+	return &responseCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// get returns the cached response for key if it was rendered from
+// the same version. A stale entry is removed.
+func (cache *responseCache) get(key string, etag string, lastModified time.Time) (*cachedResponse, bool) {
+	if cache == nil {
+		return nil, false
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	elem, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cachedResponse)
+	if entry.etag != etag || !entry.lastModified.Equal(lastModified) {
+		cache.order.Remove(elem)
+		delete(cache.entries, key)
+		return nil, false
+	}
+	cache.order.MoveToFront(elem)
+	return entry, true
+}
+
+// put adds or replaces a response, evicting the least recently
+// used entries when the cache is full
+func (cache *responseCache) put(entry *cachedResponse) {
+	if cache == nil || cache.size <= 0 {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if elem, ok := cache.entries[entry.key]; ok {
+		elem.Value = entry
+		cache.order.MoveToFront(elem)
+		return
+	}
+	cache.entries[entry.key] = cache.order.PushFront(entry)
+	for cache.order.Len() > cache.size {
+		elem := cache.order.Back()
+		cache.order.Remove(elem)
+		delete(cache.entries, elem.Value.(*cachedResponse).key)
+	}
+}
+
+// len returns the number of cached responses
+func (cache *responseCache) len() int {
+	if cache == nil {
+		return 0
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.order.Len()
+}
+
+// etagMatches reports if an If-None-Match header value matches etag.
+// The weak comparison is used as required for If-None-Match.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified evaluates the If-None-Match and If-Modified-Since
+// headers of a request. If-Modified-Since is ignored when
+// If-None-Match is present.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// packageEPrint answers the eprint and record end points. The eprint's
+// version is read first so conditional requests and cached responses
+// are answered without calling SQLReadEPrint.
+func (api *EP3API) packageEPrint(w http.ResponseWriter, r *http.Request, repoID string, baseURL string, eprintID int, encoder *Encoder) (int, error) {
+	version, err := SQLReadEPrintVersion(api.Config, repoID, eprintID)
+	if err != nil {
+		api.Log.Printf("SQLReadEPrintVersion Error: %s\n", err)
+		return 404, fmt.Errorf("not found")
+	}
+	if !version.IsPublic() && !api.canReadPrivate(r, repoID) {
+		return 404, fmt.Errorf("not found")
+	}
+	etag := version.ETag(encoder.Name)
+	w.Header().Set("ETag", etag)
+	if !version.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", version.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if !version.IsPublic() {
+		w.Header().Set("Cache-Control", "private")
+	}
+	if notModified(r, etag, version.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified, nil
+	}
+	key := fmt.Sprintf("%s/%d/%s", repoID, eprintID, encoder.Name)
+	if entry, ok := api.cache.get(key, etag, version.LastModified); ok {
+		w.Header().Set("Content-Type", entry.contentType)
+		w.Write(entry.body)
+		return 200, nil
+	}
+	eprint, err := SQLReadEPrint(api.Config, repoID, baseURL, eprintID)
+	if err != nil {
+		api.Log.Printf("SQLReadEPrint Error: %s\n", err)
+		return 404, fmt.Errorf("not found")
+	}
+	buf := new(bytes.Buffer)
+	if err := encoder.Encode(buf, []*EPrint{eprint}); err != nil {
+		api.Log.Printf("ERROR: %s error (%q), %s", encoder.Name, repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	api.cache.put(&cachedResponse{
+		key:          key,
+		etag:         etag,
+		lastModified: version.LastModified,
+		contentType:  encoder.ContentType,
+		body:         buf.Bytes(),
+	})
+	w.Header().Set("Content-Type", encoder.ContentType)
+	w.Write(buf.Bytes())
+	return 200, nil
+}
@@ -0,0 +1,106 @@
+package eprinttools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEPrintVersion(t *testing.T) {
+	version := &EPrintVersion{
+		EPrintID:           1234,
+		RevNumber:          5,
+		EPrintStatus:       "archive",
+		MetadataVisibility: "show",
+	}
+	if etag := version.ETag("eprint-xml"); etag != `"1234-5-eprint-xml"` {
+		t.Errorf("unexpected etag %s", etag)
+	}
+	if !version.IsPublic() {
+		t.Errorf("expected version to be public")
+	}
+	version.EPrintStatus = "buffer"
+	if version.IsPublic() {
+		t.Errorf("expected version to be private")
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := `"1234-5-eprint-xml"`
+	lastModified := time.Date(2021, time.March, 4, 10, 30, 15, 0, time.UTC)
+	request := func(method string, headers map[string]string) *http.Request {
+		r := httptest.NewRequest(method, "/lemurprints/eprint/1234", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+	for i, test := range []struct {
+		method   string
+		headers  map[string]string
+		expected bool
+	}{
+		{"GET", nil, false},
+		{"GET", map[string]string{"If-None-Match": etag}, true},
+		{"GET", map[string]string{"If-None-Match": `"1234-4-eprint-xml", ` + etag}, true},
+		{"GET", map[string]string{"If-None-Match": "W/" + etag}, true},
+		{"GET", map[string]string{"If-None-Match": "*"}, true},
+		{"GET", map[string]string{"If-None-Match": `"1234-4-eprint-xml"`}, false},
+		{"POST", map[string]string{"If-None-Match": etag}, false},
+		{"GET", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, true},
+		{"GET", map[string]string{"If-Modified-Since": lastModified.Add(time.Hour).Format(http.TimeFormat)}, true},
+		{"GET", map[string]string{"If-Modified-Since": lastModified.Add(-time.Second).Format(http.TimeFormat)}, false},
+		{"GET", map[string]string{"If-Modified-Since": "yesterday"}, false},
+		// If-None-Match takes precedence over If-Modified-Since
+		{"GET", map[string]string{
+			"If-None-Match":     `"1234-4-eprint-xml"`,
+			"If-Modified-Since": lastModified.Format(http.TimeFormat),
+		}, false},
+	} {
+		if got := notModified(request(test.method, test.headers), etag, lastModified); got != test.expected {
+			t.Errorf("(%d) %s %v expected %t, got %t", i, test.method, test.headers, test.expected, got)
+		}
+	}
+	// Without a lastmod If-Modified-Since can't be evaluated
+	r := request("GET", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)})
+	if notModified(r, etag, time.Time{}) {
+		t.Errorf("expected modified when lastmod is unknown")
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	lastModified := time.Date(2021, time.March, 4, 10, 30, 15, 0, time.UTC)
+	cache := newResponseCache(2)
+	for _, key := range []string{"lemurprints/1/eprint-xml", "lemurprints/2/eprint-xml"} {
+		cache.put(&cachedResponse{key: key, etag: `"1"`, lastModified: lastModified, contentType: "application/xml", body: []byte(key)})
+	}
+	if _, ok := cache.get("lemurprints/1/eprint-xml", `"1"`, lastModified); !ok {
+		t.Errorf("expected lemurprints/1/eprint-xml to be cached")
+	}
+	// lemurprints/2 is now the least recently used and is evicted
+	cache.put(&cachedResponse{key: "lemurprints/3/eprint-xml", etag: `"1"`, lastModified: lastModified})
+	if cache.len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.len())
+	}
+	if _, ok := cache.get("lemurprints/2/eprint-xml", `"1"`, lastModified); ok {
+		t.Errorf("expected lemurprints/2/eprint-xml to be evicted")
+	}
+	entry, ok := cache.get("lemurprints/1/eprint-xml", `"1"`, lastModified)
+	if !ok || string(entry.body) != "lemurprints/1/eprint-xml" {
+		t.Errorf("expected lemurprints/1/eprint-xml, got %+v", entry)
+	}
+	// A changed lastmod invalidates the entry
+	if _, ok := cache.get("lemurprints/1/eprint-xml", `"1"`, lastModified.Add(time.Second)); ok {
+		t.Errorf("expected stale entry to be invalidated")
+	}
+	if _, ok := cache.get("lemurprints/1/eprint-xml", `"1"`, lastModified); ok {
+		t.Errorf("expected stale entry to be removed")
+	}
+	// A nil cache is disabled
+	var disabled *responseCache
+	disabled.put(&cachedResponse{key: "x"})
+	if _, ok := disabled.get("x", "", time.Time{}); ok || disabled.len() != 0 {
+		t.Errorf("expected disabled cache to be empty")
+	}
+}
@@ -0,0 +1,401 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3apidChanges.go implements a change feed of created, updated and
+ * deleted eprints. The feed is available as Atom and as a Server-Sent
+ * Events stream. Each change carries a cursor a consumer can use to
+ * resume the feed after downtime.
+ */
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change actions reported by the change feed
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+
+	// DefaultChangeLimit is the number of changes returned when
+	// the request doesn't include a limit
+	DefaultChangeLimit = 100
+)
+
+var (
+	// ChangeFeedPollInterval is how often the Server-Sent Events
+	// stream checks for new changes once it has caught up
+	ChangeFeedPollInterval = 30 * time.Second
+)
+
+// Change describes the latest change of an eprint
+type Change struct {
+	EPrintID  int       `json:"eprintid"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+	// Cursor resumes the feed after this change
+	Cursor string `json:"cursor"`
+}
+
+// ChangeCursor is a position in the change feed, changes are ordered
+// by lastmod and eprint id.
+type ChangeCursor struct {
+	Timestamp time.Time
+	EPrintID  int
+}
+
+// String returns the cursor as an opaque token
+func (cursor *ChangeCursor) String() string {
+	src := fmt.Sprintf("%s/%d", cursor.Timestamp.UTC().Format(time.RFC3339), cursor.EPrintID)
+	return base64.RawURLEncoding.EncodeToString([]byte(src))
+}
+
+// Before returns true if change comes before or at the cursor
+// position, i.e. the consumer has already seen it.
+func (cursor *ChangeCursor) Before(change *Change) bool {
+	if change.Timestamp.Equal(cursor.Timestamp) {
+		return change.EPrintID > cursor.EPrintID
+	}
+	return change.Timestamp.After(cursor.Timestamp)
+}
+
+// ParseChangeCursor reads a cursor token. To start a feed at a
+// point in time a timestamp ("YYYY-MM-DD HH:MM:SS" or RFC 3339)
+// is accepted too. An empty string starts at the beginning.
+func ParseChangeCursor(s string) (*ChangeCursor, error) {
+	cursor := new(ChangeCursor)
+	if s == "" {
+		return cursor, nil
+	}
+	for _, layout := range []string{timestamp, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			cursor.Timestamp = t.UTC()
+			return cursor, nil
+		}
+	}
+	src, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("cursor not valid")
+	}
+	parts := strings.SplitN(string(src), "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("cursor not valid")
+	}
+	if cursor.Timestamp, err = time.Parse(time.RFC3339, parts[0]); err != nil {
+		return nil, fmt.Errorf("cursor not valid")
+	}
+	if cursor.EPrintID, err = strconv.Atoi(parts[1]); err != nil {
+		return nil, fmt.Errorf("cursor not valid")
+	}
+	return cursor, nil
+}
+
+// newChange returns the change described by an eprint's version.
+// An eprint not modified since it was created is reported as
+// "created", an eprint with the status "deletion" as "deleted".
+func newChange(version *EPrintVersion) *Change {
+	change := &Change{
+		EPrintID:  version.EPrintID,
+		Action:    ChangeUpdated,
+		Timestamp: version.LastModified,
+	}
+	switch {
+	case version.EPrintStatus == "deletion":
+		change.Action = ChangeDeleted
+	case !version.Datestamp.IsZero() && !version.LastModified.After(version.Datestamp):
+		change.Action = ChangeCreated
+	}
+	change.Cursor = (&ChangeCursor{Timestamp: change.Timestamp, EPrintID: change.EPrintID}).String()
+	return change
+}
+
+// changesLastmod is the lastmod of an eprint as a timestamp string.
+const changesLastmod = `CONCAT(lastmod_year, '-', LPAD(IFNULL(lastmod_month, 1), 2, '0'), '-', LPAD(IFNULL(lastmod_day, 1), 2, '0'), ' ', LPAD(IFNULL(lastmod_hour, 0), 2, '0'), ':', LPAD(IFNULL(lastmod_minute, 0), 2, '0'), ':', LPAD(IFNULL(lastmod_second, 0), 2, '0'))`
+
+// changesStatement returns the SQL statement and parameters listing
+// the eprints changed after cursor, see GetChanges.
+func changesStatement(status string, cursor *ChangeCursor, limit int) (string, []interface{}) {
+	start := cursor.Timestamp.UTC().Format(timestamp)
+	params := []interface{}{start, start, cursor.EPrintID}
+	stmt := `SELECT ` + eprintVersionColumns + `
+FROM eprint
+WHERE lastmod_year IS NOT NULL
+AND (` + changesLastmod + ` > ? OR (` + changesLastmod + ` = ? AND eprintid > ?))`
+	if status != "" {
+		stmt += `
+AND eprint_status IN (?, 'deletion')`
+		params = append(params, status)
+	}
+	stmt += `
+ORDER BY ` + changesLastmod + ` ASC, eprintid ASC`
+	if limit > 0 {
+		stmt += `
+LIMIT ?`
+		params = append(params, limit)
+	}
+	return stmt, params
+}
+
+// GetChanges returns up to limit changes after cursor ordered by
+// lastmod and eprint id. If status is not empty only eprints with
+// that status, and deletions, are reported. A zero limit returns all
+// the changes.
+//
+// NOTE: only the latest change of an eprint is known, an eprint
+// modified again after the cursor is reported once at its new
+// position.
+func GetChanges(config *Config, repoID string, status string, cursor *ChangeCursor, limit int) ([]*Change, error) {
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return nil, fmt.Errorf("no database connection for %s", repoID)
+	}
+	stmt, params := changesStatement(status, cursor, limit)
+	rows, err := db.Query(stmt, params...)
+	if err != nil {
+		return nil, fmt.Errorf("SQL error, %q, %s", stmt, err)
+	}
+	defer rows.Close()
+	changes := []*Change{}
+	for rows.Next() {
+		version, err := scanEPrintVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("SQL error, %q, %s", stmt, err)
+		}
+		changes = append(changes, newChange(version))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("SQL error, %q, %s", stmt, err)
+	}
+	return changes, nil
+}
+
+// AtomFeed is an Atom (RFC 4287) feed of changes
+type AtomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Author  *AtomPerson  `xml:"author,omitempty"`
+	Links   []*AtomLink  `xml:"link"`
+	Entries []*AtomEntry `xml:"entry"`
+}
+
+// AtomPerson is an Atom author
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+// AtomLink is an Atom link
+type AtomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+// AtomCategory is an Atom category, the change feed uses it for
+// the action
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// AtomEntry is a change in an Atom feed
+type AtomEntry struct {
+	ID       string        `xml:"id"`
+	Title    string        `xml:"title"`
+	Updated  string        `xml:"updated"`
+	Links    []*AtomLink   `xml:"link"`
+	Category *AtomCategory `xml:"category"`
+}
+
+// changesFeed returns the Atom feed for changes. apiURL is the URL
+// of the change feed end point, query holds the request's parameters.
+func changesFeed(apiURL string, eprintURL string, repoID string, query url.Values, cursor *ChangeCursor, changes []*Change) *AtomFeed {
+	updated := cursor.Timestamp
+	if len(changes) > 0 {
+		updated = changes[len(changes)-1].Timestamp
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	link := func(since string) string {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Del("since")
+		if since != "" {
+			q.Set("since", since)
+		}
+		if len(q) == 0 {
+			return apiURL
+		}
+		return apiURL + "?" + q.Encode()
+	}
+	feed := &AtomFeed{
+		ID:      apiURL,
+		Title:   fmt.Sprintf("%s changes", repoID),
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  &AtomPerson{Name: repoID},
+		Links:   []*AtomLink{{Rel: "self", Type: "application/atom+xml", Href: link(query.Get("since"))}},
+	}
+	// The next link resumes after the last change, when there
+	// are no changes it is the same as the self link.
+	next := query.Get("since")
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Cursor
+	}
+	feed.Links = append(feed.Links, &AtomLink{Rel: "next", Type: "application/atom+xml", Href: link(next)})
+	for _, change := range changes {
+		feed.Entries = append(feed.Entries, &AtomEntry{
+			ID:      fmt.Sprintf("%s/%d#%s", eprintURL, change.EPrintID, change.Cursor),
+			Title:   fmt.Sprintf("eprint %d %s", change.EPrintID, change.Action),
+			Updated: change.Timestamp.UTC().Format(time.RFC3339),
+			Links: []*AtomLink{
+				{Rel: "alternate", Type: "application/xml", Href: fmt.Sprintf("%s/%d", eprintURL, change.EPrintID)},
+			},
+			Category: &AtomCategory{Term: change.Action},
+		})
+	}
+	return feed
+}
+
+// writeChangeEvent writes a change as a Server-Sent Event. The
+// event's id is the change's cursor so a reconnecting EventSource
+// resumes with the "Last-Event-ID" header.
+func writeChangeEvent(w io.Writer, change *Change) error {
+	src, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", change.Cursor, change.Action, src)
+	return err
+}
+
+// changesEndPoint returns the change feed of a repository as Atom
+// or, when requested, as a Server-Sent Events stream.
+func (api *EP3API) changesEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, changesDocument(repoID))
+	}
+	if len(args) > 0 {
+		return 400, fmt.Errorf("bad request")
+	}
+	if _, ok := api.Config.Repositories[repoID]; !ok {
+		api.Log.Printf("Data Source not found for %q", repoID)
+		return 404, fmt.Errorf("not found")
+	}
+	query := r.URL.Query()
+	since := query.Get("since")
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		since = lastEventID
+	}
+	cursor, err := ParseChangeCursor(since)
+	if err != nil {
+		return 400, fmt.Errorf("bad request, %s", err)
+	}
+	limit := DefaultChangeLimit
+	if s := query.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			return 400, fmt.Errorf("bad request, limit must be between 1 and %d", MaxPageLimit)
+		}
+	}
+	status := query.Get("eprint_status")
+	format := query.Get("format")
+	if format == "" {
+		format = "atom"
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			format = "sse"
+		}
+	}
+	switch format {
+	case "atom":
+		// NOTE: the change feed does its own paging so the
+		// unpaged configuration is used.
+		changes, err := GetChanges(api.Config, repoID, status, cursor, limit)
+		if err != nil {
+			api.Log.Printf("ERROR: (%s) change feed, %s", repoID, err)
+			return 500, fmt.Errorf("internal server error")
+		}
+		apiURL := fmt.Sprintf("%s/%s/changes", strings.TrimSuffix(api.Config.BaseURL, "/"), repoID)
+		eprintURL := fmt.Sprintf("%s/%s/eprint", strings.TrimSuffix(api.Config.BaseURL, "/"), repoID)
+		src, err := xml.MarshalIndent(changesFeed(apiURL, eprintURL, repoID, query, cursor, changes), "", "  ")
+		if err != nil {
+			api.Log.Printf("ERROR: (%s) marshal error, %s", repoID, err)
+			return 500, fmt.Errorf("internal server error")
+		}
+		w.Header().Set("Content-Type", "application/atom+xml")
+		fmt.Fprintf(w, "%s%s\n", xml.Header, src)
+		return 200, nil
+	case "sse":
+		return api.streamChanges(w, r, repoID, status, cursor, limit)
+	}
+	return 400, fmt.Errorf("bad request, format must be atom or sse")
+}
+
+// streamChanges writes changes as Server-Sent Events. Once the
+// stream has caught up it checks for new changes every
+// ChangeFeedPollInterval until the client disconnects.
+func (api *EP3API) streamChanges(w http.ResponseWriter, r *http.Request, repoID string, status string, cursor *ChangeCursor, limit int) (int, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return 500, fmt.Errorf("internal server error, streaming not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", ChangeFeedPollInterval.Milliseconds())
+	flusher.Flush()
+	for r.Context().Err() == nil {
+		changes, err := GetChanges(api.Config, repoID, status, cursor, limit)
+		if err != nil {
+			// NOTE: the headers are sent, end the stream and let
+			// the client reconnect.
+			api.Log.Printf("ERROR: (%s) change feed, %s", repoID, err)
+			return http.StatusOK, nil
+		}
+		for _, change := range changes {
+			if err := writeChangeEvent(w, change); err != nil {
+				return http.StatusOK, nil
+			}
+			cursor = &ChangeCursor{Timestamp: change.Timestamp, EPrintID: change.EPrintID}
+		}
+		if len(changes) == limit {
+			flusher.Flush()
+			continue
+		}
+		// A comment keeps proxies from closing an idle stream
+		fmt.Fprint(w, ": waiting\n\n")
+		flusher.Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(ChangeFeedPollInterval):
+		}
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,145 @@
+package eprinttools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangeCursor(t *testing.T) {
+	ts := time.Date(2021, time.March, 4, 10, 30, 15, 0, time.UTC)
+	cursor := &ChangeCursor{Timestamp: ts, EPrintID: 1234}
+	token := cursor.String()
+	got, err := ParseChangeCursor(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Timestamp.Equal(ts) || got.EPrintID != 1234 {
+		t.Errorf("expected %s/1234, got %+v", ts, got)
+	}
+	for _, s := range []string{"2021-03-04 10:30:15", "2021-03-04T10:30:15Z"} {
+		got, err := ParseChangeCursor(s)
+		if err != nil {
+			t.Errorf("%q, %s", s, err)
+		} else if !got.Timestamp.Equal(ts) || got.EPrintID != 0 {
+			t.Errorf("%q, expected %s, got %+v", s, ts, got)
+		}
+	}
+	if got, err := ParseChangeCursor(""); err != nil || !got.Timestamp.IsZero() {
+		t.Errorf("expected an empty cursor, got %+v, %v", got, err)
+	}
+	if _, err := ParseChangeCursor("not-a-cursor!"); err == nil {
+		t.Errorf("expected an error for an invalid cursor")
+	}
+	for _, test := range []struct {
+		change   *Change
+		expected bool
+	}{
+		{&Change{EPrintID: 1, Timestamp: ts.Add(time.Second)}, true},
+		{&Change{EPrintID: 1235, Timestamp: ts}, true},
+		{&Change{EPrintID: 1234, Timestamp: ts}, false},
+		{&Change{EPrintID: 1233, Timestamp: ts}, false},
+		{&Change{EPrintID: 9999, Timestamp: ts.Add(-time.Second)}, false},
+	} {
+		if got := cursor.Before(test.change); got != test.expected {
+			t.Errorf("%d %s expected %t, got %t", test.change.EPrintID, test.change.Timestamp, test.expected, got)
+		}
+	}
+}
+
+func TestNewChange(t *testing.T) {
+	created := time.Date(2021, time.March, 4, 10, 30, 15, 0, time.UTC)
+	for _, test := range []struct {
+		version  *EPrintVersion
+		expected string
+	}{
+		{&EPrintVersion{EPrintID: 1, EPrintStatus: "archive", Datestamp: created, LastModified: created}, ChangeCreated},
+		{&EPrintVersion{EPrintID: 2, EPrintStatus: "archive", Datestamp: created, LastModified: created.Add(time.Hour)}, ChangeUpdated},
+		{&EPrintVersion{EPrintID: 3, EPrintStatus: "buffer", LastModified: created}, ChangeUpdated},
+		{&EPrintVersion{EPrintID: 4, EPrintStatus: "deletion", Datestamp: created, LastModified: created}, ChangeDeleted},
+	} {
+		change := newChange(test.version)
+		if change.Action != test.expected {
+			t.Errorf("%d expected %s, got %s", test.version.EPrintID, test.expected, change.Action)
+		}
+		if !change.Timestamp.Equal(test.version.LastModified) {
+			t.Errorf("%d expected timestamp %s, got %s", test.version.EPrintID, test.version.LastModified, change.Timestamp)
+		}
+		cursor, err := ParseChangeCursor(change.Cursor)
+		if err != nil || cursor.EPrintID != change.EPrintID || cursor.Before(change) {
+			t.Errorf("%d expected cursor at the change, got %+v, %v", change.EPrintID, cursor, err)
+		}
+	}
+}
+
+func TestChangesStatement(t *testing.T) {
+	cursor := &ChangeCursor{Timestamp: time.Date(2021, time.March, 4, 10, 30, 15, 0, time.UTC), EPrintID: 7}
+	stmt, params := changesStatement("", cursor, 0)
+	if strings.Contains(stmt, "LIMIT") || strings.Contains(stmt, "eprint_status IN") {
+		t.Errorf("expected no limit or status filter, got %s", stmt)
+	}
+	if !strings.Contains(stmt, "eprintid ASC") {
+		t.Errorf("expected eprintid tie-breaker, got %s", stmt)
+	}
+	if len(params) != 3 || params[0] != "2021-03-04 10:30:15" || params[2] != 7 {
+		t.Errorf("unexpected params %+v", params)
+	}
+	stmt, params = changesStatement("archive", cursor, 25)
+	if !strings.Contains(stmt, "eprint_status IN (?, 'deletion')") || !strings.HasSuffix(stmt, "LIMIT ?") {
+		t.Errorf("expected status filter and limit, got %s", stmt)
+	}
+	if strings.Count(stmt, "?") != len(params) || params[3] != "archive" || params[4] != 25 {
+		t.Errorf("unexpected params %+v", params)
+	}
+}
+
+func TestChangesFeed(t *testing.T) {
+	ts := time.Date(2021, time.March, 4, 10, 30, 15, 0, time.UTC)
+	changes := []*Change{
+		newChange(&EPrintVersion{EPrintID: 1, EPrintStatus: "archive", Datestamp: ts, LastModified: ts}),
+		newChange(&EPrintVersion{EPrintID: 2, EPrintStatus: "deletion", Datestamp: ts, LastModified: ts.Add(time.Minute)}),
+	}
+	query := url.Values{"since": []string{"2021-01-01 00:00:00"}, "limit": []string{"2"}}
+	cursor, _ := ParseChangeCursor(query.Get("since"))
+	feed := changesFeed("http://localhost:8484/lemurprints/changes", "http://localhost:8484/lemurprints/eprint", "lemurprints", query, cursor, changes)
+	src, err := xml.Marshal(feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		`<updated>2021-03-04T10:31:15Z</updated>`,
+		`<title>eprint 1 created</title>`,
+		`<category term="deleted"></category>`,
+		`href="http://localhost:8484/lemurprints/eprint/2"`,
+		`<link rel="next" type="application/atom+xml" href="http://localhost:8484/lemurprints/changes?limit=2&amp;since=` + changes[1].Cursor + `"></link>`,
+	} {
+		if !bytes.Contains(src, []byte(expected)) {
+			t.Errorf("expected %s in\n%s", expected, src)
+		}
+	}
+	// Without changes the next link repeats the request
+	feed = changesFeed("http://localhost:8484/lemurprints/changes", "http://localhost:8484/lemurprints/eprint", "lemurprints", query, cursor, nil)
+	if feed.Links[0].Href != feed.Links[1].Href || len(feed.Entries) != 0 {
+		t.Errorf("expected self and next links to match, got %s, %s", feed.Links[0].Href, feed.Links[1].Href)
+	}
+}
+
+func TestWriteChangeEvent(t *testing.T) {
+	ts := time.Date(2021, time.March, 4, 10, 30, 15, 0, time.UTC)
+	change := newChange(&EPrintVersion{EPrintID: 1234, EPrintStatus: "archive", Datestamp: ts, LastModified: ts.Add(time.Hour)})
+	buf := new(bytes.Buffer)
+	if err := writeChangeEvent(buf, change); err != nil {
+		t.Fatal(err)
+	}
+	expected := "id: " + change.Cursor + "\nevent: updated\ndata: {\"eprintid\":1234,\"action\":\"updated\",\"timestamp\":\"2021-03-04T11:30:15Z\",\"cursor\":\"" + change.Cursor + "\"}\n\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	if strings.Count(buf.String(), "\n\n") != 1 {
+		t.Errorf("expected a single event")
+	}
+}
@@ -2,6 +2,7 @@ package eprinttools
 
 import (
 	"fmt"
+	"strings"
 )
 
 //
@@ -42,6 +43,8 @@ Unique ID to EPrint ID
 
 Unique ids maybe standards based (e.g. ORCID, DOI, ISSN, ISBN) or internal (e.g. group ids, funder ids)
 
+The DOI, ORCID, ISSN, ISBN, PMID and PMCID end points accept any common form of the identifier and match the forms a record is usually stored in, e.g. a DOI with or without "https://doi.org/", an ORCID with or without hyphens or an ISBN-10 for a record stored with its ISBN-13. Check digits are verified, values that aren't valid identifiers are looked up as given.
+
 - '/{REPO_ID}/doi/{DOI}' with the adoption of EPrints "doi" field in the EPrint table it makes sense to have a quick translation of DOI to EPrint id for a given EPrints repository. 
 - '/{REPO_ID}/pmid/{PMID}' with the "pmid" field in the EPrint table, it refers to PubMed is an index of the biomedical literature.
 - '/{REPO_ID}/pmcid/{PMCID}' with the "pmcid" field in the EPrint table, PMCID an Identifier to each full-text paper in PubMed Central Archive
@@ -91,6 +94,8 @@ Unique ids maybe standards based (e.g. ORCID, DOI, ISSN, ISBN) or internal (e.g.
 - '/{REPO_ID}/patent-assignee/{PATENT_ASSIGNEE}' - return a list eprint ids for patent assignee in repository
 - '/{REPO_ID}/year' - return a descending list of years containing record with a date type of "published".
 - '/{REPO_ID}/year/{YEAR}' - return a list of eprintid for a given year contaning date type of "published".
+- '/{REPO_ID}/search?{FIELD}={VALUE}&...' returns a list of eprint ids matching several filters (creator_id, creator_orcid, funder, group, issn, status, type) combined with AND or, with "op=or", OR. "is_public=true" and a publication date range ("from", "until") always apply. A status other than "archive" or "is_public=false" requires the read-private scope.
+- '/{REPO_ID}/openapi.json' returns an OpenAPI 3 description of the end points available for the repository. It is generated from the routes registered for the repository so end points depending on columns (e.g. pmid, patent-number) only appear when the repository has them.
 
 
 Change Events
@@ -101,18 +106,114 @@ The follow API end points would facilitate faster updates to our feeds platform
 - '/{REPO_ID}/keys' returns complete list of EPrint ID in the repository
 - '/{REPO_ID}/updated/{TIMESTAMP}/{TIMESTAMP}' returns a list of EPrint IDs updated starting at the first timestamp (timestamps should have a resolution to the minute, e.g. "YYYY-MM-DD HH:MM:SS") through inclusive of the second timestmap (if the second is omitted the timestamp is assumed to be "now")
 - '/{REPO_ID}/deleted/{TIMESTAMP}/{TIMESTAMP}' through the returns a list of EPrint IDs deleted starting at first timestamp through inclusive of the second timestamp, if the second timestamp is omitted it is assumed to be "now"
+- '/{REPO_ID}/changes' returns the created, updated and deleted EPrints ordered by lastmod as an Atom feed, or as a Server-Sent Events stream when "text/event-stream" is requested. Each change reports the eprint id, action and timestamp. Pass the cursor of the last change seen (the feed's "next" link or the event id) as "since" to resume after downtime.
 - '/{REPO_ID}/pubdate/{APROX_DATESTAMP}/{APPOX_DATESTMP}' this query scans the EPrint table for records with publication starts starting with the first approximate date through inclusive of the second approximate date. If the second date is omitted it is assumed to be "today". Approximate dates my be expressed just the year (starting with Jan 1, ending with Dec 31), just the year and month (starting with first day of month ending with the last day) or year, month and day. The end returns zero or more EPrint IDs.
 
+Pagination
+----------
+
+The end points returning lists (e.g. keys, updated, creator-id, year) accept the query parameters "limit", "offset" and "order" ("asc" or "desc"). When any of them are given the list is returned in an object with "total", "limit", "offset", "order", "items" and a "next" link. The "next" link holds an opaque "cursor" parameter which replaces "limit", "offset" and "order" for the following page. The limit can be at most 10000.
+
+` + "```" + `
+    /{REPO_ID}/keys?limit=100&order=desc
+` + "```" + `
+
 Read/Write API
 --------------
 
-As of __ep3apid__ version 1.0.3 a new set of end points exists for reading (retreiving EPrints XML) and writing (metadata import) of EPrints XML.  The extended API only supports working with EPrints metadata not directly with the documents or files associated with individual records.
+As of __ep3apid__ version 1.0.3 a new set of end points exists for reading (retreiving EPrints XML) and writing (metadata import) of EPrints XML.  Documents and files can be attached to an existing record with the document-upload end point.
 
 The metadata import functionality is enabled per repository. It only supports importing records at this time.  Importing an EPrint XML document, which could containing multiple EPrint metadata records, is implemented purely using SQL statements and not the EPrints Perl API. This allows you (with the right MySQL configuration) to run the extended API on a different server without resorting to Perl.
 
-- '/{REPO_ID}/eprint/{EPRINT_ID}' method GET with a content type of "application/json" (JSON of EPrint XML) or "application/xml" for EPrint XML
+- '/{REPO_ID}/eprint/{EPRINT_ID}' method GET returns the EPrint record, EPrint XML by default, see Content Negotiation for other formats
+- '/{REPO_ID}/record/{EPRINT_ID}' method GET returns the EPrint record, a simplified JSON record by default, see Content Negotiation for other formats
 - '/{REPO_ID}/eprint-import/{USER_ID}' POST accepts EPrints XML with content type of "application/xml" or JSON of EPrints XML with content type "application/json". To enable this feature add the attribute '"write": true' to the repositories setting in settins.json. The {USER_ID} is required and this is used to assign the imported eprint to a specific buffer.
+- '/{REPO_ID}/eprint-update/{USER_ID}' PUT or POST accepts EPrints XML or JSON of EPrints XML for existing records (eprintid required). Each record is replaced in its own transaction, the rev_number is incremented and a history entry is recorded for {USER_ID}. The eprint_status can't be changed, use eprint-status. A JSON list reports the outcome ("updated" or "failed") for each record, the status code is 207 if only some were updated. Requires '"write": true'.
+- '/{REPO_ID}/document-upload/{USER_ID}/{EPRINT_ID}' POST accepts multipart/form-data with one or more "file" parts and attaches them as a new document of an existing EPrint. The files are written into the EPrint's directory below "archive_path" and the size, MIME type and hash are recorded. The "security", "content" and "license" form fields set the document's values. Requires '"write": true' and "archive_path" in the repository's settings.
+- '/{REPO_ID}/eprint-validate' POST checks EPrints XML or JSON the way eprint-import would receive it without writing anything. Each record is checked against the repository's "validation" rules (types, date types and required fields per type) and its column types, a report listing the problems is returned for each record.
+- '/{REPO_ID}/eprint-status/{USER_ID}/{STATUS}/{EPRINT_ID}' PUT or POST moves an EPrint between the inbox, buffer, archive and deletion statuses, omit {EPRINT_ID} and send a JSON array of EPrint IDs to move a batch. Only the transitions offered by the EPrints UI are allowed. The status_changed and lastmod fields are updated, a history entry is recorded for {USER_ID} and the change is logged. When moving to the buffer the body may be a JSON object with "eprint_ids" and the "userid" and "reviewer" the EPrints are assigned to, it is an error if the repository has no column for the assignment. A batch returns 207 if only some were moved. Requires '"write": true'.
+- '/{REPO_ID}/user-create' POST creates a user from a JSON or XML EPrintUser, an optional "password" is stored the way EPrints does. Usernames and emails are validated. Requires '"write": true'.
+- '/{REPO_ID}/user-update/{USERID}' PUT or POST updates a user with the fields of a JSON or XML EPrintUser, fields not included are unchanged. Requires '"write": true'.
+- '/{REPO_ID}/user-deactivate/{USERID}' PUT or POST removes a user's password so they can no longer log in. Requires '"write": true'.
+- '/{REPO_ID}/users-by-type/{USERTYPE}' lists the users with a usertype (user, editor, admin or minuser). Users returned never include a password.
+
+Content Negotiation
+-------------------
+
+The eprint and record end points pick their output format from the "Accept" header. Quality values are honored (e.g. "application/json;q=0.9, application/xml;q=0.5") and a "format" query parameter overrides the header. A request that can't be satisfied returns 406 Not Acceptable. For clients that send a "Content-Type" but no "Accept" header the content type is used. The formats are
+
+- "eprint-xml", "application/xml" (or "text/xml") for EPrints XML
+- "eprint-json", "application/json" for the JSON version of EPrint XML
+- "simplified", "application/vnd.caltechlibrary.simplified+json" for a simplified JSON record
+- "dc", "application/oai_dc+xml" for Dublin Core (oai_dc)
+- "bibtex", "application/x-bibtex" for BibTeX
+- "ris", "application/x-research-info-systems" for RIS
+- "csl-json", "application/vnd.citationstyles.csl+json" for CSL-JSON
+- "endnote", "application/x-endnote+xml" for EndNote XML
+
+` + "```" + `
+    /{REPO_ID}/eprint/{EPRINT_ID}?format=bibtex
+` + "```" + `
+
+Caching
+-------
+
+The eprint and record end points send an "ETag" (based on the eprint id, rev_number and format) and a "Last-Modified" header (from lastmod). Requests with a matching "If-None-Match" or an "If-Modified-Since" no earlier than lastmod get a 304 Not Modified response without the record being read. Non-public records are marked "Cache-Control: private".
+
+Setting "cache_size" in the settings file keeps that many rendered responses in memory. A cached response is used until the eprint's rev_number or lastmod changes.
+
+` + "```" + `
+    curl -H 'If-None-Match: "1234-5-eprint-xml"' http://localhost:8484/lemurprints/eprint/1234
+` + "```" + `
+
+OAI-PMH
+-------
+
+Each repository has an OAI-PMH 2.0 data provider. It supports the verbs Identify, ListMetadataFormats, ListSets, ListIdentifiers, ListRecords and GetRecord. Metadata is available as "oai_dc" (unqualified Dublin Core) or "eprint" (EPrints XML). Sets are derived from collection, type and local_group, e.g. "type:article". Only public records are disseminated.
+
+- '/{REPO_ID}/oai?verb={VERB}' accepts GET or POST requests following the OAI-PMH 2.0 protocol
 
+Monitoring
+----------
+
+__ep3apid__ provides end points for checking on the service itself.
+
+- '/healthz' returns "ok" while the service is running (liveness)
+- '/readyz' pings each repository database and the JSON store, returning 200 when all answer and 503 otherwise. The response is a JSON object with a "status" and the result of each check.
+- '/metrics' returns request counts, latency histograms and error counts per route along with database connection pool statistics in the Prometheus text format
+
+These end points don't take a repository id and are answered without credentials so a process supervisor or a Prometheus server can poll them.
+
+` + "```" + `
+    curl http://localhost:8484/readyz
+` + "```" + `
+
+
+Authentication
+--------------
+
+By default __ep3apid__ answers any request that reaches it. Adding "api_tokens" to the settings file, or setting '"basic_auth": true' for a repository, turns on authentication. Each end point then requires a scope for the repository requested.
+
+- read-public, identifier lookups and public records (granted to anonymous requests by default, see "anonymous_scopes")
+- read-private, non-public records, key lists, change events and user information
+- import, '/{REPO_ID}/eprint-import', '/{REPO_ID}/eprint-update', '/{REPO_ID}/eprint-validate', '/{REPO_ID}/document-upload' and '/{REPO_ID}/eprint-status'
+- admin, all of the above plus '/{REPO_ID}/user-create', '/{REPO_ID}/user-update', '/{REPO_ID}/user-deactivate' and '/{REPO_ID}/users-by-type'
+
+API tokens are sent as 'Authorization: Bearer NAME:SECRET'. Only a bcrypt hash of the secret is kept in the settings file, use the '-token' option to generate one. When "basic_auth" is enabled HTTP Basic credentials are checked against the EPrints user table of the repository, "admin" users get the admin scope, "editor" users read-private and import and other users read-public.
+
+` + "```" + `
+    {
+        "api_tokens": [
+            {
+                "name": "feeds",
+                "hash": "$2a$10$...",
+                "scopes": { "REPO_ID": [ "read-private" ] }
+            }
+        ],
+        "anonymous_scopes": [ "read-public" ],
+        ...
+    }
+` + "```" + `
 
 settings.json (configuration)
 -----------------------------
@@ -161,6 +262,8 @@ Options
     	Display this help message
   -license
     	Display software license
+  -token string
+    	Generate a secret and hash for a new API token named NAME
   -version
     	Display software version
 ` + "```" + `
@@ -283,12 +386,24 @@ func deletedDocument(repoID string) string {
 	return fmt.Sprintf(`'/%s/deleted/{TIMESTAMP}/{TIMESTAMP}' through the returns a list of EPrint IDs deleted starting at first timestamp through inclusive of the second timestamp, if the second timestamp is omitted it is assumed to be "now"`, repoID)
 }
 
+func documentUploadDocument(repoID string) string {
+	return fmt.Sprintf(`'/%s/document-upload/{USER_ID}/{EPRINT_ID}' POST accepts multipart/form-data with one or more "file" parts and attaches them as a new document of the EPrint. The files are written into the EPrint's directory below the repository's "archive_path" and the document and file rows are created with the file size, MIME type and hash ("hash_type" in settings, MD5 by default). The form fields "security" (public, validuser or staffonly, default public), "content", "license", "format", "formatdesc", "language", "date_embargo" and "main" set the document's values. The EPrint's rev_number and lastmod are updated and a history entry is recorded for {USER_ID}. Requires '"write": true' and "archive_path". The new document is returned as JSON.`, repoID)
+}
+
+func eprintStatusDocument(repoID string) string {
+	return fmt.Sprintf(`'/%s/eprint-status/{USER_ID}/{STATUS}/{EPRINT_ID}' PUT or POST moves an EPrint to {STATUS} (inbox, buffer, archive or deletion). To move a batch omit {EPRINT_ID} and send a JSON array of EPrint IDs. The transitions allowed are those of the EPrints UI, inbox to buffer or archive, buffer to inbox or archive, archive to buffer or deletion and deletion to archive. The status_changed and lastmod fields are updated, a history entry is recorded for {USER_ID} and the change is logged. When moving to the buffer the EPrints may be assigned by sending a JSON object, e.g. {"eprint_ids": [1, 2], "userid": 3, "reviewer": "jdoe"}, the "userid" is written to the userid column and the "reviewer" to the reviewer column. Assigning an EPrint moved to another status, or when the repository lacks the column, is a bad request. A JSON list reports the outcome ("changed" or "failed") for each EPrint, 200 if all were moved, 207 if some were and 400 if none were. Requires '"write": true'.`, repoID)
+}
+
+func changesDocument(repoID string) string {
+	return fmt.Sprintf(`'/%s/changes' returns the created, updated and deleted EPrints ordered by lastmod as an Atom feed. Each entry has the eprint id, the action (created, updated or deleted) and a timestamp. The "since" parameter takes a cursor from a previous change (the feed's "next" link) or a timestamp and returns the changes after it. "limit" sets the number of changes returned (default 100) and "eprint_status" restricts the feed to a status (deletions are always reported). Requesting "text/event-stream" (or "format=sse") returns a Server-Sent Events stream that stays open and reports new changes as they happen, the event id is the cursor so a reconnecting client resumes with the "Last-Event-ID" header.`, repoID)
+}
+
 func pubdateDocument(repoID string) string {
 	return fmt.Sprintf(`'/%s/pubdate/{APROX_DATESTAMP}/{APPOX_DATESTAMP}' this query scans the EPrint table for records with publication starts starting with the first approximate date through inclusive of the second approximate date. If the second date is omitted it is assumed to be "today". Approximate dates my be expressed just the year (starting with Jan 1, ending with Dec 31), just the year and month (starting with first day of month ending with the last day) or year, month and day. The end returns zero or more EPrint IDs.`, repoID)
 }
 
 func doiDocument(repoID string) string {
-	return fmt.Sprintf(`'/%s/doi/{DOI}' with the adoption of EPrints "doi" field in the EPrint table it makes sense to have a quick translation of DOI to EPrint id for a given EPrints repository.`, repoID)
+	return fmt.Sprintf(`'/%s/doi/{DOI}' with the adoption of EPrints "doi" field in the EPrint table it makes sense to have a quick translation of DOI to EPrint id for a given EPrints repository. The DOI may include a "https://doi.org/" or "doi:" prefix.`, repoID)
 }
 
 func creatorDocument(repoID string) string {
@@ -298,7 +413,7 @@ func creatorDocument(repoID string) string {
 - '/%s/creator-name' returns a list of creator names (family, given) in repository
 - '/%s/creator-name/{FAMILY}/{GIVEN}' returns a list of EPrint ID for the given creator using their family and given names
 - '/%s/creator-orcid' return a list of "orcid" associated with creators in repository 
-- '/%s/creator-orcid/{ORCID}' scans the "orcid" field associated with creators and returns a list of EPrint ID
+- '/%s/creator-orcid/{ORCID}' scans the "orcid" field associated with creators and returns a list of EPrint ID, the ORCID may be hyphenated or not or an orcid.org URL
 `, repoID, repoID, repoID, repoID, repoID, repoID)
 }
 
@@ -363,27 +478,27 @@ func funderDocument(repoID string) string {
 
 func pubmedIDDocument(repoID string) string {
 	return fmt.Sprintf(`
-- '/%s/pmid/{PMID}' with the "pmid" field in the EPrint table, it refers to PubMed is an index of the biomedical literature.
+- '/%s/pmid/{PMID}' with the "pmid" field in the EPrint table, it refers to PubMed is an index of the biomedical literature. A "PMID:" prefix or PubMed URL is accepted.
 `, repoID)
 }
 
 func pubmedCentralIDDocument(repoID string) string {
 	return fmt.Sprintf(`
-- '/%s/pmcid/{PMCID}' with the "pmcid" field in the EPrint table, PMCID an Identifier to each full-text paper in PubMed Central Archive
+- '/%s/pmcid/{PMCID}' with the "pmcid" field in the EPrint table, PMCID an Identifier to each full-text paper in PubMed Central Archive. The "PMC" prefix is optional.
 `, repoID)
 }
 
 func issnDocument(repoID string) string {
 	return fmt.Sprintf(`
 - '/%s/issn' - returns a list of ISSN in repository
-- '/%s/issn/{ISSN}' - returns a list eprint id for ISSN in repository
+- '/%s/issn/{ISSN}' - returns a list eprint id for ISSN in repository, the ISSN may be written with or without the hyphen
 `, repoID, repoID)
 }
 
 func isbnDocument(repoID string) string {
 	return fmt.Sprintf(`
 - '/%s/isbn' - returns a list of ISBN in repository
-- '/%s/isbn/{ISBN}' - returns a list eprint id for ISBN in repository
+- '/%s/isbn/{ISBN}' - returns a list eprint id for ISBN in repository, an ISBN-10 matches the same ISBN-13, a stored ISBN matches without hyphens or hyphenated as given
 `, repoID, repoID)
 }
 
@@ -422,6 +537,14 @@ func yearDocument(repoID string) string {
 `, repoID, repoID)
 }
 
+func searchDocument(repoID string) string {
+	return fmt.Sprintf(`Search
+------
+
+- '/%s/search?{FIELD}={VALUE}&...' returns a list of eprint ids matching several filters at once. The fields are %s. A field may be repeated. Filters are combined with AND, add "op=or" to combine them with OR. "is_public=true" and a publication date range, "from" and "until" as approximate dates (e.g. 2020, 2020-05, 2020-05-21), always apply. Fields not supported by the repository are rejected. Requests without the read-private scope only return public records, searching them with "is_public=false" or a status other than "archive" is unauthorized.
+`, repoID, strings.Join(SearchFields(), ", "))
+}
+
 func recordDocument(repoID string) string {
 	return fmt.Sprintf(`Simplified Record
 -----------------
@@ -430,6 +553,9 @@ This version of the API includes a simplified JSON record view. The
 JSON represents the JSON model used in DataCite and InvenioRDMs.
 
 - '/%s/record/{EPRINT_ID}' returns a complex JSON object representing the EPrint record identified by {EPRINT_ID}.
+
+Other formats (e.g. EPrint XML, Dublin Core or BibTeX) can be requested
+with the Accept header or a "format" query parameter, e.g. "?format=dc".
 `, repoID)
 }
 
@@ -451,13 +577,37 @@ GET:
 
 - '/%s/eprint/{EPRINT_ID}' will retrieve an existing EPrint record as EPrint XML by building up an eprint record via SQL queries.
 
+The output format is negotiated with the Accept header or a "format"
+query parameter, e.g. "?format=eprint-json" or "?format=bibtex".
+
 POST:
 
 - '/%s/eprint-import/{USER_ID}' POST accepts EPrints XML with content type of "application/xml" or JSON of EPrints XML with content type "application/json". To enable this feature add the attribute '"write": true' to the repositories setting in settins.json. The {USER_ID} is required and this is used to assign the imported eprint to a specific buffer.
 
+PUT or POST:
+
+- '/%s/eprint-update/{USER_ID}' accepts EPrints XML with content type of "application/xml" or JSON of EPrints XML with content type "application/json". Each EPrint must include its eprintid. The eprint row and its item lists are replaced, the rev_number is incremented, lastmod is updated and a history entry is recorded for {USER_ID}. An EPrint's eprint_status is not changed by an update, a record with a different eprint_status fails and is moved with '/%s/eprint-status' instead. Each record is committed on its own and the response lists the eprint_id, status ("updated" or "failed") and error of each record, 200 if all were updated, 207 if some were and 400 if none were. Like eprint-import this requires '"write": true' in the repository settings.
+
 EPrints XML can contiain more than one EPrint record so multiple EPrint metadata records can be created with one post.
 
-`, repoID, repoID)
+Each record is created in its own transaction so a failure leaves no
+partial record behind. Add "?atomic=true" to the eprint-import URL to
+import the whole post in a single transaction, if any record fails
+none are created. The response is a JSON list with one result per
+record in the order submitted, e.g.
+
+~~~
+    [
+      { "eprint_id": 1201, "status": "created" },
+      { "eprint_id": 0, "status": "failed", "error": "..." }
+    ]
+~~~
+
+The status is "created", "failed" or "rolled back". The HTTP status
+code is 200 if all records were created, 207 if some were and 400 if
+none were.
+
+`, repoID, repoID, repoID, repoID)
 }
 
 func userDocument(repoID string) string {
@@ -467,3 +617,64 @@ func userDocument(repoID string) string {
 - '/%s/user/{userid|username}' - get a user object for user id or username in repository
 `, repoID, repoID, repoID)
 }
+
+func eprintValidateDocument(repoID string) string {
+	return fmt.Sprintf(`'/%s/eprint-validate' POST (or PUT) checks EPrints XML ("application/xml") or JSON ("application/json") before it is imported, nothing is written. The repository's defaults are applied as they are by eprint-import, then each record is checked for
+
+- a type and date_type allowed by the repository's "validation" rules
+- an eprint_status of inbox, buffer, archive or deletion
+- dates in the form YYYY, YYYY-MM or YYYY-MM-DD
+- the fields "required" for the record's type
+- values that fit the MySQL column types, e.g. strings longer than a varchar column
+
+A JSON list is returned with a report for each record giving its position, "valid" and a list of "problems" (field, code and message). The codes are "required", "not_allowed", "too_long", "out_of_range", "bad_format" and "unknown_field".
+
+The rules are set per repository in settings.json,
+
+`+"```"+`
+"validation": {
+    "types": [ "article", "book", "book_section", "thesis" ],
+    "date_types": [ "published", "submitted", "completed" ],
+    "required": {
+        "*": [ "title", "type" ],
+        "article": [ "creators", "publication" ]
+    }
+}
+`+"```"+`
+
+Without "validation" the standard EPrints types and date types are used and title and type are required.
+`, repoID)
+}
+
+func userAdminDocument(repoID string) string {
+	return fmt.Sprintf(`
+- '/%s/user-create' POST creates a user from an EPrintUser in JSON ("application/json") or XML ("application/xml"). The usertype defaults to "user", an optional "password" is stored the way EPrints does.
+- '/%s/user-update/{userid}' PUT or POST updates a user with the fields of an EPrintUser (JSON or XML), fields not included are unchanged. A "password" sets a new password.
+- '/%s/user-deactivate/{userid}' PUT or POST removes the user's password so they can no longer log in, setting a new password reactivates them.
+- '/%s/users-by-type/{usertype}' returns the users with a usertype (user, editor, admin or minuser).
+
+Usernames may contain letters, digits and . _ @ + -, emails must be a plain address (e.g. "jane@example.edu"). These end points require the admin scope and, except users-by-type, '"write": true'. The user returned never includes the password.
+`, repoID, repoID, repoID, repoID)
+}
+
+func oaiDocument(repoID string) string {
+	return fmt.Sprintf(`
+OAI-PMH
+-------
+
+The extended API includes an OAI-PMH 2.0 data provider for each
+repository. Only public records (eprint_status "archive" and
+metadata_visibility "show") are disseminated, records with an
+eprint_status of "deletion" are reported as deleted.
+
+- '/%s/oai?verb=Identify' - describe the repository
+- '/%s/oai?verb=ListMetadataFormats' - list the supported formats, "oai_dc" and "eprint" (EPrints XML)
+- '/%s/oai?verb=ListSets' - list the sets derived from collection, type and local_group (e.g. "type:article")
+- '/%s/oai?verb=ListIdentifiers&metadataPrefix={PREFIX}' - list record headers, supports from, until and set
+- '/%s/oai?verb=ListRecords&metadataPrefix={PREFIX}' - list records, supports from, until and set
+- '/%s/oai?verb=GetRecord&identifier={IDENTIFIER}&metadataPrefix={PREFIX}' - get a single record
+
+List responses are returned %d records at a time, use the
+resumptionToken returned to retrieve the next batch.
+`, repoID, repoID, repoID, repoID, repoID, repoID, oaiPageSize)
+}
@@ -0,0 +1,334 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3apidMetrics.go implements the health, readiness and metrics
+ * end points of ep3apid. Metrics are written in the Prometheus text
+ * exposition format.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// latencyBuckets are the upper bounds, in seconds, of the request
+	// latency histogram buckets
+	latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	// ReadyTimeout limits how long /readyz waits on each database ping
+	ReadyTimeout = 2 * time.Second
+)
+
+// routeLabels identify the requests counted together
+type routeLabels struct {
+	Repository string
+	Route      string
+	Method     string
+	Code       int
+}
+
+// latencyHistogram accumulates request durations
+type latencyHistogram struct {
+	Buckets []uint64
+	Sum     float64
+	Count   uint64
+}
+
+// apiMetrics holds the request metrics of ep3apid
+type apiMetrics struct {
+	mu        sync.Mutex
+	started   time.Time
+	requests  map[routeLabels]uint64
+	latencies map[routeLabels]*latencyHistogram
+	errors    map[int]uint64
+}
+
+// newAPIMetrics returns an empty set of metrics
+func newAPIMetrics() *apiMetrics {
+	return &apiMetrics{
+		started:   time.Now(),
+		requests:  map[routeLabels]uint64{},
+		latencies: map[routeLabels]*latencyHistogram{},
+		errors:    map[int]uint64{},
+	}
+}
+
+// observe records a request
+func (m *apiMetrics) observe(repoID string, route string, method string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[routeLabels{repoID, route, method, code}]++
+	key := routeLabels{Repository: repoID, Route: route}
+	h, ok := m.latencies[key]
+	if !ok {
+		h = &latencyHistogram{Buckets: make([]uint64, len(latencyBuckets))}
+		m.latencies[key] = h
+	}
+	seconds := d.Seconds()
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			h.Buckets[i]++
+		}
+	}
+	h.Sum += seconds
+	h.Count++
+	if code >= 400 {
+		m.errors[code]++
+	}
+}
+
+// promLabels formats Prometheus labels, pairs are name then value
+func promLabels(pairs ...string) string {
+	l := []string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		l = append(l, fmt.Sprintf(`%s="%s"`, pairs[i], value))
+	}
+	if len(l) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(l, ",") + "}"
+}
+
+// promFloat formats a value for the Prometheus text format
+func promFloat(f float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%f", f), "0"), ".")
+}
+
+// sortedLabels returns the keys of a label map in a stable order
+func sortedLabels[T any](m map[routeLabels]T) []routeLabels {
+	keys := []routeLabels{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Repository != b.Repository {
+			return a.Repository < b.Repository
+		}
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Code < b.Code
+	})
+	return keys
+}
+
+// write renders the metrics and the connection pool statistics
+func (m *apiMetrics) write(w io.Writer, connections map[string]*sql.DB) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP ep3apid_start_time_seconds Start time of the process since unix epoch in seconds.\n")
+	fmt.Fprintf(w, "# TYPE ep3apid_start_time_seconds gauge\n")
+	fmt.Fprintf(w, "ep3apid_start_time_seconds %d\n", m.started.Unix())
+
+	fmt.Fprintf(w, "# HELP ep3apid_requests_total Requests handled by repository, route, method and status code.\n")
+	fmt.Fprintf(w, "# TYPE ep3apid_requests_total counter\n")
+	for _, key := range sortedLabels(m.requests) {
+		fmt.Fprintf(w, "ep3apid_requests_total%s %d\n", promLabels("repository", key.Repository, "route", key.Route, "method", key.Method, "code", fmt.Sprintf("%d", key.Code)), m.requests[key])
+	}
+
+	fmt.Fprintf(w, "# HELP ep3apid_request_duration_seconds Request latency by repository and route.\n")
+	fmt.Fprintf(w, "# TYPE ep3apid_request_duration_seconds histogram\n")
+	for _, key := range sortedLabels(m.latencies) {
+		h := m.latencies[key]
+		for i, le := range latencyBuckets {
+			fmt.Fprintf(w, "ep3apid_request_duration_seconds_bucket%s %d\n", promLabels("repository", key.Repository, "route", key.Route, "le", promFloat(le)), h.Buckets[i])
+		}
+		fmt.Fprintf(w, "ep3apid_request_duration_seconds_bucket%s %d\n", promLabels("repository", key.Repository, "route", key.Route, "le", "+Inf"), h.Count)
+		fmt.Fprintf(w, "ep3apid_request_duration_seconds_sum%s %s\n", promLabels("repository", key.Repository, "route", key.Route), promFloat(h.Sum))
+		fmt.Fprintf(w, "ep3apid_request_duration_seconds_count%s %d\n", promLabels("repository", key.Repository, "route", key.Route), h.Count)
+	}
+
+	fmt.Fprintf(w, "# HELP ep3apid_errors_total Requests answered with an error by status code.\n")
+	fmt.Fprintf(w, "# TYPE ep3apid_errors_total counter\n")
+	codes := []int{}
+	for code := range m.errors {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "ep3apid_errors_total%s %d\n", promLabels("code", fmt.Sprintf("%d", code)), m.errors[code])
+	}
+
+	repoIDs := []string{}
+	for repoID := range connections {
+		repoIDs = append(repoIDs, repoID)
+	}
+	sort.Strings(repoIDs)
+	stats := map[string]sql.DBStats{}
+	for _, repoID := range repoIDs {
+		if db := connections[repoID]; db != nil {
+			stats[repoID] = db.Stats()
+		}
+	}
+	for _, gauge := range []struct {
+		name  string
+		help  string
+		kind  string
+		value func(sql.DBStats) string
+	}{
+		{"ep3apid_db_max_open_connections", "Maximum number of open connections to the database.", "gauge", func(s sql.DBStats) string { return fmt.Sprintf("%d", s.MaxOpenConnections) }},
+		{"ep3apid_db_open_connections", "The number of established connections both in use and idle.", "gauge", func(s sql.DBStats) string { return fmt.Sprintf("%d", s.OpenConnections) }},
+		{"ep3apid_db_in_use_connections", "The number of connections currently in use.", "gauge", func(s sql.DBStats) string { return fmt.Sprintf("%d", s.InUse) }},
+		{"ep3apid_db_idle_connections", "The number of idle connections.", "gauge", func(s sql.DBStats) string { return fmt.Sprintf("%d", s.Idle) }},
+		{"ep3apid_db_wait_count_total", "The total number of connections waited for.", "counter", func(s sql.DBStats) string { return fmt.Sprintf("%d", s.WaitCount) }},
+		{"ep3apid_db_wait_duration_seconds_total", "The total time blocked waiting for a new connection.", "counter", func(s sql.DBStats) string { return promFloat(s.WaitDuration.Seconds()) }},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n", gauge.name, gauge.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", gauge.name, gauge.kind)
+		for _, repoID := range repoIDs {
+			if s, ok := stats[repoID]; ok {
+				fmt.Fprintf(w, "%s%s %s\n", gauge.name, promLabels("repository", repoID), gauge.value(s))
+			}
+		}
+	}
+}
+
+// requestRoute returns the repository and route labels for a
+// request. Paths not matching a registered route are labeled
+// "other" so the number of label values stays bounded.
+func (api *EP3API) requestRoute(r *http.Request) (string, string) {
+	parts := []string{}
+	for _, part := range strings.Split(r.URL.Path, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", "/"
+	}
+	switch parts[0] {
+	case "version", "favicon.ico", "repositories", "healthz", "readyz", "metrics":
+		return "", "/" + parts[0]
+	case "repository":
+		if len(parts) > 1 && api.Config != nil && api.Config.Repositories[parts[1]] != nil {
+			return parts[1], "/repository"
+		}
+		return "", "/repository"
+	}
+	if api.Config == nil {
+		return "", "other"
+	}
+	routes, ok := api.Config.Routes[parts[0]]
+	if !ok {
+		return "", "other"
+	}
+	if len(parts) == 1 {
+		return parts[0], "/"
+	}
+	if _, ok := routes[parts[1]]; !ok {
+		return parts[0], "other"
+	}
+	return parts[0], parts[1]
+}
+
+// observe records the request in the API metrics
+func (api *EP3API) observe(r *http.Request, statusCode int, d time.Duration) {
+	if api.metrics == nil {
+		return
+	}
+	repoID, route := api.requestRoute(r)
+	api.metrics.observe(repoID, route, r.Method, statusCode, d)
+}
+
+// healthzEndPoint reports the service is running
+func (api *EP3API) healthzEndPoint(w http.ResponseWriter, r *http.Request) (int, error) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+	return 200, nil
+}
+
+// readyzEndPoint pings each repository database and the jsonstore
+// (when configured), it returns 503 if any of them fail.
+func (api *EP3API) readyzEndPoint(w http.ResponseWriter, r *http.Request) (int, error) {
+	checks := map[string]string{}
+	ready := true
+	ping := func(name string, db *sql.DB) {
+		if db == nil {
+			checks[name], ready = "not connected", false
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), ReadyTimeout)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			api.Log.Printf("readyz: %s ping failed, %s", name, err)
+			checks[name], ready = "unavailable", false
+			return
+		}
+		checks[name] = "ok"
+	}
+	if api.Config == nil || len(api.Config.Connections) == 0 {
+		ready = false
+	} else {
+		for repoID, db := range api.Config.Connections {
+			ping(repoID, db)
+		}
+		// NOTE: the JSON store is opened by InitExtendedAPI, it is
+		// only pinged here.
+		if api.Config.JSONStore != "" {
+			ping("jsonstore", api.Config.Jdb)
+		}
+	}
+	status, statusCode := "ok", 200
+	if !ready {
+		status, statusCode = "unavailable", 503
+	}
+	src, err := json.MarshalIndent(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	}, "", "  ")
+	if err != nil {
+		return 500, fmt.Errorf("internal server error")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	fmt.Fprintf(w, "%s", src)
+	return statusCode, nil
+}
+
+// metricsEndPoint writes the metrics in Prometheus text format
+func (api *EP3API) metricsEndPoint(w http.ResponseWriter, r *http.Request) (int, error) {
+	if api.metrics == nil {
+		return 404, fmt.Errorf("not found")
+	}
+	var connections map[string]*sql.DB
+	if api.Config != nil {
+		connections = api.Config.Connections
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	api.metrics.write(w, connections)
+	return 200, nil
+}
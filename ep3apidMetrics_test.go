@@ -0,0 +1,146 @@
+package eprinttools
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	// NOTE: sql.Open doesn't connect, the stats are for an idle pool
+	db, err := sql.Open("mysql", "lemur:secret@tcp(127.0.0.1:1)/lemurprints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(4)
+	m := newAPIMetrics()
+	m.observe("lemurprints", "keys", "GET", 200, 3*time.Millisecond)
+	m.observe("lemurprints", "keys", "GET", 200, 300*time.Millisecond)
+	m.observe("lemurprints", "eprint", "GET", 404, time.Millisecond)
+	buf := new(bytes.Buffer)
+	m.write(buf, map[string]*sql.DB{"lemurprints": db})
+	src := buf.String()
+	for _, expected := range []string{
+		"# TYPE ep3apid_requests_total counter\n",
+		`ep3apid_requests_total{repository="lemurprints",route="keys",method="GET",code="200"} 2` + "\n",
+		`ep3apid_requests_total{repository="lemurprints",route="eprint",method="GET",code="404"} 1` + "\n",
+		"# TYPE ep3apid_request_duration_seconds histogram\n",
+		`ep3apid_request_duration_seconds_bucket{repository="lemurprints",route="keys",le="0.005"} 1` + "\n",
+		`ep3apid_request_duration_seconds_bucket{repository="lemurprints",route="keys",le="0.25"} 1` + "\n",
+		`ep3apid_request_duration_seconds_bucket{repository="lemurprints",route="keys",le="0.5"} 2` + "\n",
+		`ep3apid_request_duration_seconds_bucket{repository="lemurprints",route="keys",le="+Inf"} 2` + "\n",
+		`ep3apid_request_duration_seconds_count{repository="lemurprints",route="keys"} 2` + "\n",
+		`ep3apid_errors_total{code="404"} 1` + "\n",
+		`ep3apid_db_max_open_connections{repository="lemurprints"} 4` + "\n",
+		`ep3apid_db_open_connections{repository="lemurprints"} 0` + "\n",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("expected %q in\n%s", expected, src)
+		}
+	}
+	if s := promLabels("route", `a"b\c`); s != `{route="a\"b\\c"}` {
+		t.Errorf("unexpected label escaping %s", s)
+	}
+	if s := promFloat(0.025); s != "0.025" {
+		t.Errorf("expected 0.025, got %s", s)
+	}
+	if s := promFloat(10); s != "10" {
+		t.Errorf("expected 10, got %s", s)
+	}
+}
+
+func metricsTestEP3API() *EP3API {
+	api := &EP3API{
+		Config: &Config{
+			Repositories: map[string]*DataSource{
+				"lemurprints": {TableMap: map[string][]string{"eprint": {"eprintid"}}},
+			},
+		},
+		Log:     log.New(io.Discard, "", 0),
+		metrics: newAPIMetrics(),
+	}
+	api.registerRoutes("lemurprints", api.Config.Repositories["lemurprints"])
+	return api
+}
+
+func TestRequestRoute(t *testing.T) {
+	api := metricsTestEP3API()
+	for target, expected := range map[string][2]string{
+		"/":                           {"", "/"},
+		"/healthz":                    {"", "/healthz"},
+		"/repository/lemurprints":     {"lemurprints", "/repository"},
+		"/lemurprints/keys":           {"lemurprints", "keys"},
+		"/lemurprints/creator-id/Doe": {"lemurprints", "creator-id"},
+		"/lemurprints/not-a-route":    {"lemurprints", "other"},
+		"/wp-admin/index.php":         {"", "other"},
+	} {
+		repoID, route := api.requestRoute(httptest.NewRequest("GET", target, nil))
+		if repoID != expected[0] || route != expected[1] {
+			t.Errorf("%s expected %v, got %q %q", target, expected, repoID, route)
+		}
+	}
+}
+
+func TestHealthEndPoints(t *testing.T) {
+	api := metricsTestEP3API()
+	request := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.routeHandler(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+	if w := request("/healthz"); w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "ok" {
+		t.Errorf("expected healthz ok, got %d %q", w.Code, w.Body.String())
+	}
+	// Without database connections the service isn't ready
+	if w := request("/readyz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d %s", w.Code, w.Body.String())
+	}
+	db, err := sql.Open("mysql", "lemur:secret@tcp(127.0.0.1:1)/lemurprints?timeout=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	api.Config.Connections = map[string]*sql.DB{"lemurprints": db}
+	if w := request("/readyz"); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"lemurprints": "unavailable"`) {
+		t.Errorf("expected lemurprints unavailable, got %d %s", w.Code, w.Body.String())
+	}
+	w := request("/metrics")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected metrics, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, expected := range []string{
+		`ep3apid_requests_total{route="/healthz",method="GET",code="200"} 1`,
+		`ep3apid_requests_total{route="/readyz",method="GET",code="503"} 2`,
+		`ep3apid_errors_total{code="503"} 2`,
+		`ep3apid_db_open_connections{repository="lemurprints"}`,
+	} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("expected %q in\n%s", expected, w.Body.String())
+		}
+	}
+
+	// readyz only pings the JSON store, it doesn't open it
+	api.Config.JSONStore = "lemur:secret@tcp(127.0.0.1:1)/collections?timeout=1s"
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := request("/readyz"); !strings.Contains(w.Body.String(), `"jsonstore": "not connected"`) {
+				t.Errorf("expected jsonstore not connected, got %d %s", w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	if api.Config.Jdb != nil {
+		t.Errorf("expected readyz not to open the JSON store")
+	}
+}
@@ -0,0 +1,368 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3apidRoutes.go holds the registry of end points supported by
+ * the extended EPrints API. Each end point declares the columns it
+ * needs, its methods, scope, documentation and handler. Repositories
+ * get the end points their tables support when the API starts.
+ */
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// EndPointHandler handles a request to an end point. The args are
+// the path parts following "/{REPO_ID}/{END_POINT}".
+type EndPointHandler func(api *EP3API, w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error)
+
+// EndPointPath describes a path handled by an end point
+type EndPointPath struct {
+	// Path holds the path parameters following the end point name,
+	// e.g. "/{CREATOR_ID}", it is empty for the end point itself
+	Path string
+	// Summary describes the path
+	Summary string
+	// Response is one of "ids", "strings", "object", "bool",
+	// "encoded", "results" or "xml"
+	Response string
+}
+
+// EndPointParameter describes a query parameter of an end point
+type EndPointParameter struct {
+	Name        string
+	Description string
+}
+
+// EndPoint describes an end point of the extended EPrints API
+type EndPoint struct {
+	// Name of the end point, e.g. "creator-id"
+	Name string
+	// Requires lists the columns a repository needs for the end
+	// point as "table.column", e.g. "eprint.pmid"
+	Requires []string
+	// Methods accepted, GET if empty
+	Methods []string
+	// Scope required to use the end point, ScopeReadPublic if empty
+	Scope string
+	// Doc is returned for "/{REPO_ID}/{END_POINT}/help", "{REPO_ID}"
+	// is replaced by the repository id
+	Doc string
+	// Paths describes the paths handled for the OpenAPI document
+	Paths []*EndPointPath
+	// Query describes the query parameters for the OpenAPI document
+	Query []*EndPointParameter
+	// Handler responds to requests
+	Handler EndPointHandler
+}
+
+var (
+	// endPoints holds the registered end points by name
+	endPoints = map[string]*EndPoint{}
+)
+
+func init() {
+	formatParameter := []*EndPointParameter{{"format", "the output format, overrides the Accept header"}}
+	for _, endPoint := range []*EndPoint{
+		{Name: "keys", Scope: ScopeReadPrivate, Doc: keysDocument("{REPO_ID}"), Handler: (*EP3API).keysEndPoint,
+			Paths: []*EndPointPath{{"", "list the EPrint IDs in the repository", "ids"}}},
+		{Name: "created", Scope: ScopeReadPrivate, Doc: createdDocument("{REPO_ID}"), Handler: (*EP3API).createdEndPoint,
+			Paths: timestampPaths("created")},
+		{Name: "updated", Scope: ScopeReadPrivate, Doc: updatedDocument("{REPO_ID}"), Handler: (*EP3API).updatedEndPoint,
+			Paths: timestampPaths("updated")},
+		{Name: "deleted", Scope: ScopeReadPrivate, Doc: deletedDocument("{REPO_ID}"), Handler: (*EP3API).deletedEndPoint,
+			Paths: timestampPaths("deleted")},
+		{Name: "changes", Scope: ScopeReadPrivate, Doc: changesDocument("{REPO_ID}"), Handler: (*EP3API).changesEndPoint,
+			Paths: []*EndPointPath{{"", "the created, updated and deleted EPrints as Atom or Server-Sent Events", "changes"}},
+			Query: []*EndPointParameter{
+				{"since", "a cursor from a previous change or a timestamp"},
+				{"limit", "the number of changes returned"},
+				{"eprint_status", "only report EPrints with this status, and deletions"},
+				{"format", "atom (default) or sse"},
+			}},
+		{Name: "pubdate", Doc: pubdateDocument("{REPO_ID}"), Handler: (*EP3API).pubdateEndPoint,
+			Paths: []*EndPointPath{{"/{START}", "list the EPrint IDs published from an approximate date (e.g. 2020, 2020-05) until today", "ids"}, {"/{START}/{END}", "list the EPrint IDs published between two approximate dates", "ids"}}},
+		{Name: "doi", Doc: doiDocument("{REPO_ID}"), Handler: (*EP3API).doiEndPoint,
+			Paths: LookupPaths("DOI", "DOI")},
+		{Name: "record", Doc: recordDocument("{REPO_ID}"), Handler: (*EP3API).recordEndPoint, Query: formatParameter,
+			Paths: []*EndPointPath{{"/{EPRINT_ID}", "get an EPrint record, a simplified JSON record by default", "encoded"}}},
+		{Name: "eprint", Doc: eprintReadWriteDocument("{REPO_ID}"), Handler: (*EP3API).eprintEndPoint, Query: formatParameter,
+			Paths: []*EndPointPath{{"/{EPRINT_ID}", "get an EPrint record, EPrint XML by default", "encoded"}}},
+		{Name: "eprint-import", Methods: []string{"POST"}, Scope: ScopeImport, Doc: eprintReadWriteDocument("{REPO_ID}"), Handler: (*EP3API).eprintImportEndPoint,
+			Paths: []*EndPointPath{{"/{USER_ID}", "import EPrints XML or EPrint JSON as new records", "results"}},
+			Query: []*EndPointParameter{{"atomic", "if true import all the records in a single transaction"}}},
+		{Name: "eprint-update", Methods: []string{"PUT", "POST"}, Scope: ScopeImport, Doc: eprintReadWriteDocument("{REPO_ID}"), Handler: (*EP3API).eprintUpdateEndPoint,
+			Paths: []*EndPointPath{{"/{USER_ID}", "replace existing records with EPrints XML or EPrint JSON", "results"}}},
+		{Name: "document-upload", Methods: []string{"POST"}, Scope: ScopeImport, Doc: documentUploadDocument("{REPO_ID}"), Handler: (*EP3API).documentUploadEndPoint,
+			Paths: []*EndPointPath{{"/{USER_ID}/{EPRINT_ID}", "attach files as a new document of an EPrint (multipart/form-data)", "object"}}},
+		{Name: "eprint-status", Methods: []string{"PUT", "POST"}, Scope: ScopeImport, Doc: eprintStatusDocument("{REPO_ID}"), Handler: (*EP3API).eprintStatusEndPoint,
+			Paths: []*EndPointPath{
+				{"/{USER_ID}/{STATUS}", "move a batch of EPrints (a JSON array of EPrint IDs or an object with eprint_ids, userid and reviewer) to a status", "results"},
+				{"/{USER_ID}/{STATUS}/{EPRINT_ID}", "move an EPrint to a status (inbox, buffer, archive or deletion)", "results"},
+			}},
+		{Name: "creator-id", Doc: creatorDocument("{REPO_ID}"), Handler: (*EP3API).creatorIDEndPoint,
+			Paths: LookupPaths("creator id", "CREATOR_ID")},
+		{Name: "creator-orcid", Doc: creatorDocument("{REPO_ID}"), Handler: (*EP3API).creatorORCIDEndPoint,
+			Paths: LookupPaths("creator ORCID", "ORCID")},
+		{Name: "creator-name", Doc: creatorDocument("{REPO_ID}"), Handler: (*EP3API).creatorNameEndPoint,
+			Paths: namePaths("creator")},
+		{Name: "editor-id", Doc: editorDocument("{REPO_ID}"), Handler: (*EP3API).editorIDEndPoint,
+			Paths: LookupPaths("editor id", "EDITOR_ID")},
+		{Name: "editor-name", Doc: editorDocument("{REPO_ID}"), Handler: (*EP3API).editorNameEndPoint,
+			Paths: namePaths("editor")},
+		{Name: "contributor-id", Doc: contributorDocument("{REPO_ID}"), Handler: (*EP3API).contributorIDEndPoint,
+			Paths: LookupPaths("contributor id", "CONTRIBUTOR_ID")},
+		{Name: "contributor-name", Doc: contributorDocument("{REPO_ID}"), Handler: (*EP3API).contributorNameEndPoint,
+			Paths: namePaths("contributor")},
+		{Name: "advisor-id", Doc: advisorDocument("{REPO_ID}"), Handler: (*EP3API).advisorIDEndPoint,
+			Paths: LookupPaths("advisor id", "ADVISOR_ID")},
+		{Name: "advisor-name", Doc: advisorDocument("{REPO_ID}"), Handler: (*EP3API).advisorNameEndPoint,
+			Paths: namePaths("advisor")},
+		{Name: "committee-id", Doc: committeeDocument("{REPO_ID}"), Handler: (*EP3API).committeeIDEndPoint,
+			Paths: LookupPaths("committee id", "COMMITTEE_ID")},
+		{Name: "committee-name", Doc: committeeDocument("{REPO_ID}"), Handler: (*EP3API).committeeNameEndPoint,
+			Paths: namePaths("committee member")},
+		{Name: "corp-creator-id", Doc: corpCreatorDocument("{REPO_ID}"), Handler: (*EP3API).corpCreatorIDEndPoint,
+			Paths: LookupPaths("corporate creator id", "CORP_CREATOR_ID")},
+		{Name: "corp-creator-name", Doc: corpCreatorDocument("{REPO_ID}"), Handler: (*EP3API).corpCreatorNameEndPoint,
+			Paths: LookupPaths("corporate creator name", "CORP_CREATOR_NAME")},
+		{Name: "corp-creator-uri", Doc: corpCreatorDocument("{REPO_ID}"), Handler: (*EP3API).corpCreatorURIEndPoint,
+			Paths: LookupPaths("corporate creator URI", "CORP_CREATOR_URI")},
+		{Name: "group-id", Doc: groupDocument("{REPO_ID}"), Handler: (*EP3API).groupIDEndPoint,
+			Paths: LookupPaths("group id", "GROUP_ID")},
+		{Name: "funder-id", Doc: funderDocument("{REPO_ID}"), Handler: (*EP3API).funderIDEndPoint,
+			Paths: LookupPaths("funder id", "FUNDER_ID")},
+		{Name: "grant-number", Doc: funderDocument("{REPO_ID}"), Handler: (*EP3API).grantNumberEndPoint,
+			Paths: LookupPaths("grant number", "GRANT_NUMBER")},
+		{Name: "issn", Doc: issnDocument("{REPO_ID}"), Handler: (*EP3API).issnEndPoint,
+			Paths: LookupPaths("ISSN", "ISSN")},
+		{Name: "isbn", Doc: isbnDocument("{REPO_ID}"), Handler: (*EP3API).isbnEndPoint,
+			Paths: LookupPaths("ISBN", "ISBN")},
+		{Name: "year", Doc: yearDocument("{REPO_ID}"), Handler: (*EP3API).yearEndPoint,
+			Paths: []*EndPointPath{{"", "list the years with published records, most recent first", "ids"}, {"/{YEAR}", "list the EPrint IDs published in a year", "ids"}}},
+		{Name: "usernames", Scope: ScopeReadPrivate, Doc: userDocument("{REPO_ID}"), Handler: (*EP3API).usernamesEndPoint,
+			Paths: []*EndPointPath{{"", "list the usernames in the repository", "strings"}}},
+		{Name: "lookup-userid", Scope: ScopeReadPrivate, Doc: userDocument("{REPO_ID}"), Handler: (*EP3API).lookupUserIDEndPoint,
+			Paths: []*EndPointPath{{"/{USERNAME}", "list the user ids for a username", "ids"}}},
+		{Name: "user", Scope: ScopeReadPrivate, Doc: userDocument("{REPO_ID}"), Handler: (*EP3API).userEndPoint,
+			Paths: []*EndPointPath{{"/{USER}", "get a user by user id or username", "object"}}},
+		{Name: "eprint-validate", Methods: []string{"POST", "PUT"}, Scope: ScopeImport, Doc: eprintValidateDocument("{REPO_ID}"), Handler: (*EP3API).eprintValidateEndPoint,
+			Paths: []*EndPointPath{{"", "check EPrints XML or JSON before it is imported, nothing is written", "results"}}},
+		{Name: "user-create", Methods: []string{"POST"}, Scope: ScopeAdmin, Doc: userAdminDocument("{REPO_ID}"), Handler: (*EP3API).userCreateEndPoint,
+			Paths: []*EndPointPath{{"", "create a user from an EPrintUser (JSON or XML)", "object"}}},
+		{Name: "user-update", Methods: []string{"PUT", "POST"}, Scope: ScopeAdmin, Doc: userAdminDocument("{REPO_ID}"), Handler: (*EP3API).userUpdateEndPoint,
+			Paths: []*EndPointPath{{"/{USERID}", "update a user with the fields of an EPrintUser (JSON or XML)", "object"}}},
+		{Name: "user-deactivate", Methods: []string{"PUT", "POST"}, Scope: ScopeAdmin, Doc: userAdminDocument("{REPO_ID}"), Handler: (*EP3API).userDeactivateEndPoint,
+			Paths: []*EndPointPath{{"/{USERID}", "remove a user's password so they can no longer log in", "object"}}},
+		{Name: "users-by-type", Scope: ScopeAdmin, Doc: userAdminDocument("{REPO_ID}"), Handler: (*EP3API).usersByTypeEndPoint,
+			Paths: []*EndPointPath{{"/{USERTYPE}", "list the users with a usertype", "results"}}},
+		{Name: "is-public", Doc: isPublicDocument("{REPO_ID}"), Handler: (*EP3API).isPublicEndPoint,
+			Paths: []*EndPointPath{{"/{EPRINT_ID}", "check if an EPrint record is public", "bool"}}},
+		{Name: "oai", Methods: []string{"GET", "POST"}, Doc: oaiDocument("{REPO_ID}"), Handler: (*EP3API).oaiEndPoint,
+			Paths: []*EndPointPath{{"", "OAI-PMH 2.0 data provider", "xml"}},
+			Query: []*EndPointParameter{{"verb", "the OAI-PMH verb"}, {"metadataPrefix", "oai_dc or eprint"}, {"identifier", "an OAI identifier"}, {"from", "harvest from this date"}, {"until", "harvest until this date"}, {"set", "a set spec, e.g. type:article"}, {"resumptionToken", "continue a list request"}}},
+		{Name: "search", Doc: searchDocument("{REPO_ID}"), Handler: (*EP3API).searchEndPoint,
+			Paths: []*EndPointPath{{"", "list the EPrint IDs matching several filters", "ids"}}},
+		{Name: "openapi.json", Doc: "- '/{REPO_ID}/openapi.json' returns an OpenAPI 3 description of the end points available for the repository\n", Handler: (*EP3API).openAPIEndPoint,
+			Paths: []*EndPointPath{{"", "this OpenAPI document", "object"}}},
+
+		// NOTE: these end points depend on columns not every
+		// repository has, e.g. CaltechTHESIS doens't have
+		// "patent_number", "patent_classification",
+		// "parent_assignee", "pmc_id", or "pmid".
+		{Name: "pmcid", Requires: []string{"eprint.pmc_id"}, Doc: pubmedCentralIDDocument("{REPO_ID}"), Handler: (*EP3API).pubmedCentralIDEndPoint,
+			Paths: LookupPaths("PubMed Central ID", "PMCID")},
+		{Name: "pmid", Requires: []string{"eprint.pmid"}, Doc: pubmedIDDocument("{REPO_ID}"), Handler: (*EP3API).pubmedIDEndPoint,
+			Paths: LookupPaths("PubMed ID", "PMID")},
+		{Name: "patent-applicant", Requires: []string{"eprint.patent_applicant"}, Doc: patentApplicantDocument("{REPO_ID}"), Handler: (*EP3API).patentApplicantEndPoint,
+			Paths: LookupPaths("patent applicant", "PATENT_APPLICANT")},
+		{Name: "patent-number", Requires: []string{"eprint.patent_number"}, Doc: patentNumberDocument("{REPO_ID}"), Handler: (*EP3API).patentNumberEndPoint,
+			Paths: LookupPaths("patent number", "PATENT_NUMBER")},
+		{Name: "patent-classification", Requires: []string{"eprint.patent_classification"}, Doc: patentClassificationDocument("{REPO_ID}"), Handler: (*EP3API).patentClassificationEndPoint,
+			Paths: LookupPaths("patent classification", "PATENT_CLASSIFICATION")},
+		{Name: "patent-assignee", Requires: []string{"eprint_patent_assignee.patent_assignee"}, Doc: patentAssigneeDocument("{REPO_ID}"), Handler: (*EP3API).patentAssigneeEndPoint,
+			Paths: LookupPaths("patent assignee", "PATENT_ASSIGNEE")},
+	} {
+		if err := RegisterEndPoint(endPoint); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterEndPoint adds an end point to the extended API. It is
+// enabled for each repository with the required columns when the
+// API is initialized, so it must be called before InitExtendedAPI.
+//
+// ```
+//
+//	err := eprinttools.RegisterEndPoint(eprinttools.ItemEndPoint(
+//	    "option-major", "option_major", "thesis option (major)"))
+//
+// ```
+func RegisterEndPoint(endPoint *EndPoint) error {
+	if endPoint == nil || endPoint.Name == "" || strings.Contains(endPoint.Name, "/") {
+		return fmt.Errorf("end point name missing or invalid")
+	}
+	if endPoint.Handler == nil {
+		return fmt.Errorf("end point %q missing handler", endPoint.Name)
+	}
+	if _, exists := endPoints[endPoint.Name]; exists {
+		return fmt.Errorf("end point %q already registered", endPoint.Name)
+	}
+	for _, requires := range endPoint.Requires {
+		if table, column, ok := strings.Cut(requires, "."); !ok || table == "" || column == "" {
+			return fmt.Errorf("end point %q, required column %q should be table.column", endPoint.Name, requires)
+		}
+	}
+	for _, method := range endPoint.Methods {
+		if method != "GET" && method != "POST" && method != "PUT" {
+			return fmt.Errorf("end point %q, method %q not supported", endPoint.Name, method)
+		}
+	}
+	endPoints[endPoint.Name] = endPoint
+	return nil
+}
+
+// LookupEndPoint returns the registered end point or nil
+func LookupEndPoint(name string) *EndPoint {
+	return endPoints[name]
+}
+
+// EndPoints returns the registered end points sorted by name
+func EndPoints() []*EndPoint {
+	l := []*EndPoint{}
+	for _, endPoint := range endPoints {
+		l = append(l, endPoint)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+// Supports returns true if the table map has the columns required
+// by the end point.
+func (endPoint *EndPoint) Supports(tableMap map[string][]string) bool {
+	for _, requires := range endPoint.Requires {
+		table, column, _ := strings.Cut(requires, ".")
+		if !hasColumn(tableMap, table, column) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsMethod returns true if the end point accepts the HTTP method
+func (endPoint *EndPoint) AllowsMethod(method string) bool {
+	if len(endPoint.Methods) == 0 {
+		return method == "GET"
+	}
+	for _, m := range endPoint.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// scope returns the scope required by the end point
+func (endPoint *EndPoint) scope() string {
+	if endPoint.Scope == "" {
+		return ScopeReadPublic
+	}
+	return endPoint.Scope
+}
+
+//
+// Helpers for describing and building end points
+//
+
+// LookupPaths describes an end point listing the values of a field
+// or the EPrint IDs having a value.
+func LookupPaths(label string, param string) []*EndPointPath {
+	return []*EndPointPath{
+		{"", fmt.Sprintf("list the %s values in the repository", label), "strings"},
+		{fmt.Sprintf("/{%s}", param), fmt.Sprintf("list the EPrint IDs for a %s", label), "ids"},
+	}
+}
+
+// namePaths describes the paths of an end point using person names
+func namePaths(role string) []*EndPointPath {
+	return []*EndPointPath{
+		{"", fmt.Sprintf("list the %s names (family, given) in the repository", role), "strings"},
+		{"/{FAMILY}", fmt.Sprintf("list the EPrint IDs for a %s family name", role), "ids"},
+		{"/{FAMILY}/{GIVEN}", fmt.Sprintf("list the EPrint IDs for a %s family and given name", role), "ids"},
+	}
+}
+
+// timestampPaths describes the paths of an end point using a
+// timestamp range.
+func timestampPaths(event string) []*EndPointPath {
+	return []*EndPointPath{
+		{"/{START}", fmt.Sprintf("list the EPrint IDs %s since a timestamp (YYYY-MM-DD HH:MM:SS)", event), "ids"},
+		{"/{START}/{END}", fmt.Sprintf("list the EPrint IDs %s between two timestamps", event), "ids"},
+	}
+}
+
+// lookupDoc returns the help text for a lookup end point
+func lookupDoc(name string, label string) string {
+	return fmt.Sprintf(`- '/{REPO_ID}/%s' - returns a list of %s values in the repository
+- '/{REPO_ID}/%s/{VALUE}' - returns a list of eprint ids with the %s
+`, name, label, name, label)
+}
+
+// ColumnEndPoint returns an end point listing the values of a column
+// in the eprint table or the EPrint IDs with a value, e.g.
+// ColumnEndPoint("thesis-type", "thesis_type", "thesis type").
+func ColumnEndPoint(name string, column string, label string) *EndPoint {
+	return &EndPoint{
+		Name:     name,
+		Requires: []string{"eprint." + column},
+		Doc:      lookupDoc(name, label),
+		Paths:    LookupPaths(label, strings.ToUpper(column)),
+		Handler: func(api *EP3API, w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+			if len(args) == 0 {
+				values, err := GetAllUniqueID(api.config(r), repoID, column)
+				return api.packageStringIDs(w, r, repoID, values, err)
+			}
+			eprintIDs, err := GetEPrintIDsForUniqueID(api.config(r), repoID, column, joinArgs(args))
+			return api.packageIntIDs(w, r, repoID, eprintIDs, err)
+		},
+	}
+}
+
+// ItemEndPoint returns an end point listing the values of an item
+// table (e.g. eprint_option_major) or the EPrint IDs with a value,
+// e.g. ItemEndPoint("option-major", "option_major", "option (major)").
+func ItemEndPoint(name string, field string, label string) *EndPoint {
+	return &EndPoint{
+		Name:     name,
+		Requires: []string{fmt.Sprintf("eprint_%s.%s", field, field)},
+		Doc:      lookupDoc(name, label),
+		Paths:    LookupPaths(label, strings.ToUpper(field)),
+		Handler: func(api *EP3API, w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+			if len(args) == 0 {
+				values, err := GetAllItems(api.config(r), repoID, field)
+				return api.packageStringIDs(w, r, repoID, values, err)
+			}
+			eprintIDs, err := GetEPrintIDsForItem(api.config(r), repoID, field, joinArgs(args))
+			return api.packageIntIDs(w, r, repoID, eprintIDs, err)
+		},
+	}
+}
@@ -0,0 +1,118 @@
+package eprinttools
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegisterEndPoint(t *testing.T) {
+	handler := func(api *EP3API, w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+		return 200, nil
+	}
+	for _, endPoint := range []*EndPoint{
+		nil,
+		{Handler: handler},
+		{Name: "a/b", Handler: handler},
+		{Name: "lemurs"},
+		{Name: "lemurs", Handler: handler, Requires: []string{"lemur"}},
+		{Name: "lemurs", Handler: handler, Methods: []string{"DELETE"}},
+		{Name: "keys", Handler: handler},
+	} {
+		if err := RegisterEndPoint(endPoint); err == nil {
+			t.Errorf("expected error registering %+v", endPoint)
+		}
+	}
+	if LookupEndPoint("lemurs") != nil {
+		t.Errorf("expected lemurs not to be registered")
+	}
+	for _, endPoint := range EndPoints() {
+		if endPoint.Handler == nil || endPoint.Doc == "" {
+			t.Errorf("end point %q missing handler or doc", endPoint.Name)
+		}
+	}
+}
+
+func TestEndPointMethodsAndScopes(t *testing.T) {
+	for name, expected := range map[string][]string{
+		"keys":          {"GET"},
+		"oai":           {"GET", "POST"},
+		"eprint-update": {"PUT", "POST"},
+	} {
+		endPoint := LookupEndPoint(name)
+		for _, method := range []string{"GET", "PUT", "POST"} {
+			allowed := false
+			for _, m := range expected {
+				allowed = allowed || m == method
+			}
+			if endPoint.AllowsMethod(method) != allowed {
+				t.Errorf("%s %s expected %t", name, method, allowed)
+			}
+		}
+	}
+	if routeScope("keys") != ScopeReadPrivate || routeScope("eprint-update") != ScopeImport || routeScope("thesis-type") != ScopeReadPublic {
+		t.Errorf("unexpected end point scopes")
+	}
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	// A downstream end point for a Caltech local field
+	if err := RegisterEndPoint(ColumnEndPoint("thesis-type", "thesis_type", "thesis type")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterEndPoint(ItemEndPoint("option-major", "option_major", "option (major)")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		delete(endPoints, "thesis-type")
+		delete(endPoints, "option-major")
+	})
+	api := &EP3API{
+		Config: &Config{
+			Repositories: map[string]*DataSource{
+				"thesis": {TableMap: map[string][]string{
+					"eprint":              {"eprintid", "thesis_type"},
+					"eprint_option_major": {"eprintid", "pos", "option_major"},
+				}},
+				"authors": {TableMap: map[string][]string{
+					"eprint":                 {"eprintid", "pmid", "pmc_id", "patent_number"},
+					"eprint_patent_assignee": {"eprintid", "pos", "patent_assignee"},
+				}},
+			},
+		},
+		Log: log.Default(),
+	}
+	for repoID, dataSource := range api.Config.Repositories {
+		api.registerRoutes(repoID, dataSource)
+	}
+	for repoID, expected := range map[string]map[string]bool{
+		"thesis":  {"keys": true, "thesis-type": true, "option-major": true, "pmid": false, "patent-number": false, "patent-assignee": false},
+		"authors": {"keys": true, "thesis-type": false, "option-major": false, "pmid": true, "pmcid": true, "patent-number": true, "patent-assignee": true, "patent-applicant": false},
+	} {
+		for route, ok := range expected {
+			if _, has := api.Config.Routes[repoID][route]; has != ok {
+				t.Errorf("%s %s expected %t", repoID, route, ok)
+			}
+		}
+	}
+	// The downstream end point is described in the OpenAPI document
+	doc, err := OpenAPIDocument(api.Config, "thesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.Paths["/thesis/thesis-type/{THESIS_TYPE}"]; !ok {
+		t.Errorf("expected thesis-type in OpenAPI document")
+	}
+	// Help is served from the registered doc string
+	endPoint := LookupEndPoint("thesis-type")
+	if !strings.Contains(endPoint.Doc, "'/{REPO_ID}/thesis-type/{VALUE}'") {
+		t.Errorf("unexpected doc %q", endPoint.Doc)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/thesis/openapi.json", nil)
+	if code, err := api.Config.Routes["thesis"]["openapi.json"](w, r, "thesis", []string{}); code != 200 || err != nil {
+		t.Errorf("expected wrapped handler to return 200, got %d, %s", code, err)
+	}
+}
@@ -2,9 +2,11 @@ package eprinttools
 
 import (
 	"bytes"
+	"database/sql"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
@@ -232,3 +234,86 @@ func TestExtendedAPI(t *testing.T) {
 	}()
 	runClientForTest(t, api, appName, settings)
 }
+
+func TestIdentifierForms(t *testing.T) {
+	forms := identifierForms("isbn", "0-306-40615-2")
+	if len(forms) != 3 || forms[0] != "9780306406157" || forms[1] != "0306406152" || forms[2] != "0-306-40615-2" {
+		t.Errorf("unexpected ISBN forms %q", forms)
+	}
+	forms = identifierForms("orcid", "https://orcid.org/0000000218250097")
+	if len(forms) == 0 || forms[0] != "0000-0002-1825-0097" {
+		t.Errorf("unexpected ORCID forms %q", forms)
+	}
+	// Values that aren't valid are looked up as given
+	forms = identifierForms("issn", "0378-5954")
+	if len(forms) != 1 || forms[0] != "0378-5954" {
+		t.Errorf("expected the value as given, got %q", forms)
+	}
+}
+
+func TestPackageImportResults(t *testing.T) {
+	api := metricsTestEP3API()
+	for _, test := range []struct {
+		statuses []string
+		expected int
+	}{
+		{[]string{"created", "created"}, 200},
+		{[]string{"updated"}, 200},
+		{[]string{"updated", "failed"}, 207},
+		{[]string{"created", "rolled back"}, 207},
+		{[]string{"failed", "failed"}, 400},
+	} {
+		results := []*ImportResult{}
+		for i, status := range test.statuses {
+			results = append(results, &ImportResult{EPrintID: i + 1, Status: status})
+		}
+		w := httptest.NewRecorder()
+		if status, err := api.packageImportResults(w, "lemurprints", results); status != test.expected || err != nil || w.Code != test.expected {
+			t.Errorf("%v expected %d, got %d (%d) %v", test.statuses, test.expected, status, w.Code, err)
+		}
+	}
+}
+
+func TestPackageStatusChanges(t *testing.T) {
+	api := metricsTestEP3API()
+	for _, test := range []struct {
+		statuses []string
+		expected int
+	}{
+		{[]string{"changed", "changed"}, 200},
+		{[]string{"changed", "failed"}, 207},
+		{[]string{"failed", "failed"}, 400},
+	} {
+		changes := []*StatusChange{}
+		for i, status := range test.statuses {
+			changes = append(changes, &StatusChange{EPrintID: i + 1, To: "archive", Status: status})
+		}
+		w := httptest.NewRecorder()
+		if status, err := api.packageStatusChanges(w, "lemurprints", changes); status != test.expected || err != nil || w.Code != test.expected {
+			t.Errorf("%v expected %d, got %d (%d) %v", test.statuses, test.expected, status, w.Code, err)
+		}
+	}
+}
+
+func TestUpdateEPrintsResults(t *testing.T) {
+	db, err := sql.Open("mysql", "lemur:secret@tcp(127.0.0.1:1)/lemurprints?timeout=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	config := &Config{Connections: map[string]*sql.DB{"lemurprints": db}}
+	ds := &DataSource{TableMap: map[string][]string{"eprint": {"eprintid", "title"}}}
+	eprints := new(EPrints)
+	eprints.Append(&EPrint{Title: "No eprint id"})
+	eprints.Append(&EPrint{EPrintID: 7, Title: "Database unavailable"})
+	results, err := UpdateEPrints(config, "lemurprints", ds, 1, eprints)
+	if err == nil || len(results) != 2 {
+		t.Fatalf("expected a result for each record and an error, got %+v, %v", results, err)
+	}
+	for i, result := range results {
+		if result.Status != "failed" || result.Error == "" {
+			t.Errorf("results[%d] expected to fail, got %+v", i, result)
+		}
+	}
+	assertIntSame(t, "results[1].EPrintID", 7, results[1].EPrintID)
+}
@@ -5,6 +5,7 @@ package eprinttools
 //
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -14,7 +15,8 @@ import (
 	"github.com/caltechlibrary/eprinttools/cleaner"
 	"github.com/caltechlibrary/pairtree"
 
-	_ "github.com/go-sql-driver/mysql"
+	// Aliasing mysql driver to get to MySQLError
+	mysqlDriver "github.com/go-sql-driver/mysql"
 )
 
 const (
@@ -39,8 +41,12 @@ func OpenConnections(config *Config) error {
 		if err != nil {
 			return fmt.Errorf("could not open MySQL connection for %s, %s", repoID, err)
 		}
+		if dataSource.MaxConnections > 0 {
+			db.SetMaxOpenConns(dataSource.MaxConnections)
+			db.SetMaxIdleConns(dataSource.MaxConnections)
+		}
 		config.Connections[repoID] = db
-		dataSource.TableMap, err = eprintTablesAndColumns(db, repoID)
+		dataSource.TableMap, dataSource.ColumnTypes, err = eprintTablesColumnsAndTypes(db, repoID)
 		if err != nil {
 			return fmt.Errorf("failed to map table and columns for %q, %s", repoID, err)
 		}
@@ -71,8 +77,12 @@ func CloseConnections(config *Config) error {
 // sqlQueryInts takes a repostory ID, a SQL statement and returns
 // intergers retrieved.
 func sqlQueryInts(config *Config, repoID string, stmt string) ([]int, error) {
+	stmt, args, err := config.paginate(repoID, stmt, nil)
+	if err != nil {
+		return nil, err
+	}
 	if db, ok := config.Connections[repoID]; ok {
-		rows, err := db.Query(stmt)
+		rows, err := db.Query(stmt, args...)
 		if err != nil {
 			return nil, fmt.Errorf("ERROR: query error (%q), %s", repoID, err)
 		}
@@ -98,6 +108,10 @@ func sqlQueryInts(config *Config, repoID string, stmt string) ([]int, error) {
 // sqlQueryIntIDs takes a repostory ID, a SQL statement and applies
 // the args returning a list of integer id or error.
 func sqlQueryIntIDs(config *Config, repoID string, stmt string, args ...interface{}) ([]int, error) {
+	stmt, args, err := config.paginate(repoID, stmt, args)
+	if err != nil {
+		return nil, err
+	}
 	if db, ok := config.Connections[repoID]; ok {
 		rows, err := db.Query(stmt, args...)
 		if err != nil {
@@ -128,6 +142,10 @@ func sqlQueryIntIDs(config *Config, repoID string, stmt string, args ...interfac
 // sqlQueryStringIDs takes a repostory ID, a SQL statement and applies
 // the args returning a list of string type id or error.
 func sqlQueryStringIDs(config *Config, repoID string, stmt string, args ...interface{}) ([]string, error) {
+	stmt, args, err := config.paginate(repoID, stmt, args)
+	if err != nil {
+		return nil, err
+	}
 	if db, ok := config.Connections[repoID]; ok {
 		rows, err := db.Query(stmt, args...)
 		if err != nil {
@@ -482,6 +500,22 @@ func GetEPrintIDsForUniqueID(config *Config, repoID string, field string, value
 	return sqlQueryIntIDs(config, repoID, stmt, value)
 }
 
+// GetEPrintIDsForUniqueIDs returns a list of EPrint IDs where a
+// unique id field (e.g. doi) matches any of values. Each form of an
+// identifier is passed in values so the field's index is used.
+func GetEPrintIDsForUniqueIDs(config *Config, repoID string, field string, values []string) ([]int, error) {
+	if len(values) == 0 {
+		return []int{}, nil
+	}
+	args := []interface{}{}
+	for _, value := range values {
+		args = append(args, value)
+	}
+	stmt := fmt.Sprintf(`SELECT eprintid FROM eprint WHERE %s IN (%s)
+ORDER BY date_year DESC, date_month DESC, date_day DESC`, field, strings.Join(qmList(len(values)), `, `))
+	return sqlQueryIntIDs(config, repoID, stmt, args...)
+}
+
 // GetAllPersonOrOrgIDs return a list of creator ids or error
 func GetAllPersonOrOrgIDs(config *Config, repoID string, field string) ([]string, error) {
 	stmt := fmt.Sprintf(`SELECT %s_id FROM eprint_%s_id
@@ -518,6 +552,24 @@ ORDER BY date_year DESC, date_month DESC, date_day DESC
 `, orcid)
 }
 
+// GetEPrintIDsForORCIDs returns a list of EPrint IDs where a creator
+// ORCID matches any of orcids.
+func GetEPrintIDsForORCIDs(config *Config, repoID string, orcids []string) ([]int, error) {
+	if len(orcids) == 0 {
+		return []int{}, nil
+	}
+	args := []interface{}{}
+	for _, orcid := range orcids {
+		args = append(args, orcid)
+	}
+	return sqlQueryIntIDs(config, repoID, fmt.Sprintf(`SELECT eprint.eprintid AS eprintid
+FROM eprint_creators_orcid JOIN eprint ON (eprint_creators_orcid.eprintid = eprint.eprintid)
+WHERE creators_orcid IN (%s)
+GROUP BY eprint.eprintid
+ORDER BY date_year DESC, date_month DESC, date_day DESC
+`, strings.Join(qmList(len(args)), `, `)), args...)
+}
+
 // GetAllItems returns a list of simple items (e.g. local_group)
 func GetAllItems(config *Config, repoID string, field string) ([]string, error) {
 	stmt := fmt.Sprintf(`SELECT %s
@@ -589,11 +641,20 @@ func GetEPrintIDsForYear(config *Config, repoID string, year int) ([]int, error)
 // eprintTablesAndColumns takes a DB connection and repoID then builds a map[string][]string{}
 // structure representing the tables and their columns available in a EPrints Repository
 func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, error) {
+	data, _, err := eprintTablesColumnsAndTypes(db, repoID)
+	return data, err
+}
+
+// eprintTablesColumnsAndTypes works like eprintTablesAndColumns and
+// also returns the MySQL column types (e.g. "varchar(255)", "int(11)")
+// of each table's columns.
+func eprintTablesColumnsAndTypes(db *sql.DB, repoID string) (map[string][]string, map[string]map[string]string, error) {
 	data := map[string][]string{}
+	types := map[string]map[string]string{}
 	stmt := `SHOW TABLES LIKE "eprint%"`
 	rows, err := db.Query(stmt)
 	if err != nil {
-		return nil, fmt.Errorf("SQL(%q), %s", repoID, err)
+		return nil, nil, fmt.Errorf("SQL(%q), %s", repoID, err)
 	}
 	tables := []string{}
 	for rows.Next() {
@@ -608,10 +669,11 @@ func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, err
 
 	for _, tableName := range tables {
 		data[tableName] = []string{}
+		types[tableName] = map[string]string{}
 		stmt := fmt.Sprintf(`SHOW COLUMNS IN %s`, tableName)
 		cRows, err := db.Query(stmt)
 		if err != nil {
-			return nil, fmt.Errorf("SQL(%q), %s", repoID, err)
+			return nil, nil, fmt.Errorf("SQL(%q), %s", repoID, err)
 		}
 		columns := []string{}
 		var (
@@ -621,9 +683,10 @@ func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, err
 		for cRows.Next() {
 			//colName, f1, f2, f3, f4, f5 = &"", &"", &"", &"", nil, &""
 			if err := cRows.Scan(&colName, &f1, &f2, &f3, &f4, &f5); err != nil {
-				return nil, fmt.Errorf("cRows.Scan() error: %s", err)
+				return nil, nil, fmt.Errorf("cRows.Scan() error: %s", err)
 			} else {
 				columns = append(columns, colName)
+				types[tableName][colName] = f1
 			}
 		}
 		data[tableName] = columns
@@ -633,7 +696,7 @@ func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, err
 	stmt = `SHOW TABLES LIKE "document%"`
 	rows, err = db.Query(stmt)
 	if err != nil {
-		return nil, fmt.Errorf("SQL(%q), %s", repoID, err)
+		return nil, nil, fmt.Errorf("SQL(%q), %s", repoID, err)
 	}
 	tables = []string{}
 	for rows.Next() {
@@ -648,10 +711,11 @@ func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, err
 
 	for _, tableName := range tables {
 		data[tableName] = []string{}
+		types[tableName] = map[string]string{}
 		stmt := fmt.Sprintf(`SHOW COLUMNS IN %s`, tableName)
 		cRows, err := db.Query(stmt)
 		if err != nil {
-			return nil, fmt.Errorf("SQL(%q), %s", repoID, err)
+			return nil, nil, fmt.Errorf("SQL(%q), %s", repoID, err)
 		}
 		columns := []string{}
 		var (
@@ -661,9 +725,10 @@ func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, err
 		for cRows.Next() {
 			//colName, f1, f2, f3, f4, f5 = &"", &"", &"", &"", nil, &""
 			if err := cRows.Scan(&colName, &f1, &f2, &f3, &f4, &f5); err != nil {
-				return nil, fmt.Errorf("cRows.Scan() error: %s", err)
+				return nil, nil, fmt.Errorf("cRows.Scan() error: %s", err)
 			} else {
 				columns = append(columns, colName)
+				types[tableName][colName] = f1
 			}
 		}
 		data[tableName] = columns
@@ -673,7 +738,7 @@ func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, err
 	stmt = `SHOW TABLES LIKE "file%"`
 	rows, err = db.Query(stmt)
 	if err != nil {
-		return nil, fmt.Errorf("SQL(%q), %s", repoID, err)
+		return nil, nil, fmt.Errorf("SQL(%q), %s", repoID, err)
 	}
 	tables = []string{}
 	for rows.Next() {
@@ -688,10 +753,11 @@ func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, err
 
 	for _, tableName := range tables {
 		data[tableName] = []string{}
+		types[tableName] = map[string]string{}
 		stmt := fmt.Sprintf(`SHOW COLUMNS IN %s`, tableName)
 		cRows, err := db.Query(stmt)
 		if err != nil {
-			return nil, fmt.Errorf("SQL(%q), %s", repoID, err)
+			return nil, nil, fmt.Errorf("SQL(%q), %s", repoID, err)
 		}
 		columns := []string{}
 		var (
@@ -701,15 +767,16 @@ func eprintTablesAndColumns(db *sql.DB, repoID string) (map[string][]string, err
 		for cRows.Next() {
 			//colName, f1, f2, f3, f4, f5 = &"", &"", &"", &"", nil, &""
 			if err := cRows.Scan(&colName, &f1, &f2, &f3, &f4, &f5); err != nil {
-				return nil, fmt.Errorf("cRows.Scan() error: %s", err)
+				return nil, nil, fmt.Errorf("cRows.Scan() error: %s", err)
 			} else {
 				columns = append(columns, colName)
+				types[tableName][colName] = f1
 			}
 		}
 		data[tableName] = columns
 		cRows.Close()
 	}
-	return data, nil
+	return data, types, nil
 }
 
 /*
@@ -1312,7 +1379,7 @@ func fileToColumnsAndValues(eprintID int, file *File, columns []string, ifNull b
 /*
  * Document and files models
  */
-func documentIDToFiles(repoID string, baseURL string, eprintID int, documentID int, pos int, db *sql.DB, tables map[string][]string) []*File {
+func documentIDToFiles(repoID string, baseURL string, eprintID int, documentID int, pos int, db sqlQueryer, tables map[string][]string) []*File {
 	//FIXME: Need to figure out if I need to pay attention to
 	// file_copies_plugin and file_copies_sourceid tables. This appear
 	// to be related to the storage manager.  They don't appear to
@@ -1357,7 +1424,7 @@ func documentIDToFiles(repoID string, baseURL string, eprintID int, documentID i
 	return nil
 }
 
-func documentIDToRelation(repoID string, baseURL string, documentID int, pos int, db *sql.DB, tables map[string][]string) *RelationItemList {
+func documentIDToRelation(repoID string, baseURL string, documentID int, pos int, db sqlQueryer, tables map[string][]string) *RelationItemList {
 	typeTable := "document_relation_type"
 	_, okTypeTable := tables[typeTable]
 	uriTable := "document_relation_uri"
@@ -1394,7 +1461,7 @@ func documentIDToRelation(repoID string, baseURL string, documentID int, pos int
 	return nil
 }
 
-func userIDToName(repoID string, userID int, db *sql.DB) string {
+func userIDToName(repoID string, userID int, db sqlQueryer) string {
 	name := ""
 	if userID > 0 {
 		stmt := `SELECT TRIM(CONCAT_WS(' ', IFNULL(name_honourific, ''), IFNULL(name_given, ''), IFNULL(name_family, ''), IFNULL(name_lineage, ''))) AS name FROM user WHERE userid = ?`
@@ -1402,6 +1469,7 @@ func userIDToName(repoID string, userID int, db *sql.DB) string {
 		rows, err := db.Query(stmt, userID)
 		if err != nil {
 			log.Printf(`Query failed "user" for %d in %q, %q,  %s`, userID, repoID, stmt, err)
+			return ""
 		}
 		defer rows.Close()
 		for rows.Next() {
@@ -1413,7 +1481,7 @@ func userIDToName(repoID string, userID int, db *sql.DB) string {
 	return ""
 }
 
-func eprintIDToDocumentList(repoID string, baseURL string, eprintID int, db *sql.DB, tables map[string][]string) *DocumentList {
+func eprintIDToDocumentList(repoID string, baseURL string, eprintID int, db sqlQueryer, tables map[string][]string) *DocumentList {
 	tableName := "document"
 	columns, ok := tables[tableName]
 	if ok {
@@ -1578,7 +1646,7 @@ func resizeItemList(pos int, itemList ItemsInterface) {
 /*
  * PersonItemList model
  */
-func eprintIDToPersonItemList(db *sql.DB, tables map[string][]string, repoID string, eprintID int, tablePrefix string, itemList ItemsInterface) int {
+func eprintIDToPersonItemList(db sqlQueryer, tables map[string][]string, repoID string, eprintID int, tablePrefix string, itemList ItemsInterface) int {
 	var (
 		pos                                       int
 		value, honourific, given, family, lineage string
@@ -1644,7 +1712,7 @@ func eprintIDToPersonItemList(db *sql.DB, tables map[string][]string, repoID str
 	return itemList.Length()
 }
 
-func eprintIDToCreators(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *CreatorItemList {
+func eprintIDToCreators(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *CreatorItemList {
 	tablePrefix := `eprint_creators`
 	itemList := new(CreatorItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1653,7 +1721,7 @@ func eprintIDToCreators(repoID string, eprintID int, db *sql.DB, tables map[stri
 	return nil
 }
 
-func eprintIDToEditors(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *EditorItemList {
+func eprintIDToEditors(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *EditorItemList {
 	tablePrefix := `eprint_editors`
 	itemList := new(EditorItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1662,7 +1730,7 @@ func eprintIDToEditors(repoID string, eprintID int, db *sql.DB, tables map[strin
 	return nil
 }
 
-func eprintIDToContributors(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ContributorItemList {
+func eprintIDToContributors(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ContributorItemList {
 	tablePrefix := `eprint_contributors`
 	itemList := new(ContributorItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1671,7 +1739,7 @@ func eprintIDToContributors(repoID string, eprintID int, db *sql.DB, tables map[
 	return nil
 }
 
-func eprintIDToExhibitors(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ExhibitorItemList {
+func eprintIDToExhibitors(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ExhibitorItemList {
 	tablePrefix := `eprint_exhibitors`
 	itemList := new(ExhibitorItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1680,7 +1748,7 @@ func eprintIDToExhibitors(repoID string, eprintID int, db *sql.DB, tables map[st
 	return nil
 }
 
-func eprintIDToProducers(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ProducerItemList {
+func eprintIDToProducers(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ProducerItemList {
 	tablePrefix := `eprint_producers`
 	itemList := new(ProducerItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1689,7 +1757,7 @@ func eprintIDToProducers(repoID string, eprintID int, db *sql.DB, tables map[str
 	return nil
 }
 
-func eprintIDToConductors(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ConductorItemList {
+func eprintIDToConductors(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ConductorItemList {
 	tablePrefix := `eprint_conductors`
 	itemList := new(ConductorItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1698,7 +1766,7 @@ func eprintIDToConductors(repoID string, eprintID int, db *sql.DB, tables map[st
 	return nil
 }
 
-func eprintIDToLyricists(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *LyricistItemList {
+func eprintIDToLyricists(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *LyricistItemList {
 	tablePrefix := `eprint_lyricists`
 	itemList := new(LyricistItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1707,7 +1775,7 @@ func eprintIDToLyricists(repoID string, eprintID int, db *sql.DB, tables map[str
 	return nil
 }
 
-func eprintIDToThesisAdvisors(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ThesisAdvisorItemList {
+func eprintIDToThesisAdvisors(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ThesisAdvisorItemList {
 	tablePrefix := `eprint_thesis_advisor`
 	itemList := new(ThesisAdvisorItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1716,7 +1784,7 @@ func eprintIDToThesisAdvisors(repoID string, eprintID int, db *sql.DB, tables ma
 	return nil
 }
 
-func eprintIDToThesisCommittee(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ThesisCommitteeItemList {
+func eprintIDToThesisCommittee(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ThesisCommitteeItemList {
 	tablePrefix := `eprint_thesis_committee`
 	itemList := new(ThesisCommitteeItemList)
 	if count := eprintIDToPersonItemList(db, tables, repoID, eprintID, tablePrefix, itemList); count > 0 {
@@ -1729,7 +1797,7 @@ func eprintIDToThesisCommittee(repoID string, eprintID int, db *sql.DB, tables m
  * SimpleItemList model
  */
 
-func eprintIDToSimpleItemList(db *sql.DB, tables map[string][]string, repoID string, eprintID int, tableName string, itemList ItemsInterface) int {
+func eprintIDToSimpleItemList(db sqlQueryer, tables map[string][]string, repoID string, eprintID int, tableName string, itemList ItemsInterface) int {
 	columnName := strings.TrimPrefix(tableName, `eprint_`)
 	var (
 		pos   int
@@ -1766,7 +1834,7 @@ func eprintIDToSimpleItemList(db *sql.DB, tables map[string][]string, repoID str
 	return itemList.Length()
 }
 
-func eprintIDToLocalGroup(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *LocalGroupItemList {
+func eprintIDToLocalGroup(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *LocalGroupItemList {
 	tableName := `eprint_local_group`
 	itemList := new(LocalGroupItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1775,7 +1843,7 @@ func eprintIDToLocalGroup(repoID string, eprintID int, db *sql.DB, tables map[st
 	return nil
 }
 
-func eprintIDToReferenceText(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ReferenceTextItemList {
+func eprintIDToReferenceText(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ReferenceTextItemList {
 	tableName := `eprint_referencetext`
 	itemList := new(ReferenceTextItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1784,7 +1852,7 @@ func eprintIDToReferenceText(repoID string, eprintID int, db *sql.DB, tables map
 	return nil
 }
 
-func eprintIDToProjects(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ProjectItemList {
+func eprintIDToProjects(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ProjectItemList {
 	tableName := `eprint_projects`
 	itemList := new(ProjectItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1793,7 +1861,7 @@ func eprintIDToProjects(repoID string, eprintID int, db *sql.DB, tables map[stri
 	return nil
 }
 
-func eprintIDToSubjects(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *SubjectItemList {
+func eprintIDToSubjects(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *SubjectItemList {
 	tableName := `eprint_subjects`
 	itemList := new(SubjectItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1802,7 +1870,7 @@ func eprintIDToSubjects(repoID string, eprintID int, db *sql.DB, tables map[stri
 	return nil
 }
 
-func eprintIDToAccompaniment(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *AccompanimentItemList {
+func eprintIDToAccompaniment(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *AccompanimentItemList {
 	tableName := `eprint_accompaniment`
 	itemList := new(AccompanimentItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1811,7 +1879,7 @@ func eprintIDToAccompaniment(repoID string, eprintID int, db *sql.DB, tables map
 	return nil
 }
 
-func eprintIDToSkillAreas(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *SkillAreaItemList {
+func eprintIDToSkillAreas(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *SkillAreaItemList {
 	tableName := `eprint_skill_areas`
 	itemList := new(SkillAreaItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1820,7 +1888,7 @@ func eprintIDToSkillAreas(repoID string, eprintID int, db *sql.DB, tables map[st
 	return nil
 }
 
-func eprintIDToCopyrightHolders(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *CopyrightHolderItemList {
+func eprintIDToCopyrightHolders(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *CopyrightHolderItemList {
 	tableName := `eprint_copyright_holders`
 	itemList := new(CopyrightHolderItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1829,7 +1897,7 @@ func eprintIDToCopyrightHolders(repoID string, eprintID int, db *sql.DB, tables
 	return nil
 }
 
-func eprintIDToReference(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ReferenceItemList {
+func eprintIDToReference(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ReferenceItemList {
 	tableName := `eprint_reference`
 	itemList := new(ReferenceItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1838,7 +1906,7 @@ func eprintIDToReference(repoID string, eprintID int, db *sql.DB, tables map[str
 	return nil
 }
 
-func eprintIDToAltTitle(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *AltTitleItemList {
+func eprintIDToAltTitle(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *AltTitleItemList {
 	tableName := `eprint_alt_title`
 	itemList := new(AltTitleItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1847,7 +1915,7 @@ func eprintIDToAltTitle(repoID string, eprintID int, db *sql.DB, tables map[stri
 	return nil
 }
 
-func eprintIDToPatentAssignee(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *PatentAssigneeItemList {
+func eprintIDToPatentAssignee(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *PatentAssigneeItemList {
 	tableName := `eprint_patent_assignee`
 	itemList := new(PatentAssigneeItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1856,7 +1924,7 @@ func eprintIDToPatentAssignee(repoID string, eprintID int, db *sql.DB, tables ma
 	return nil
 }
 
-func eprintIDToRelatedPatents(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *RelatedPatentItemList {
+func eprintIDToRelatedPatents(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *RelatedPatentItemList {
 	tableName := `eprint_related_patents`
 	itemList := new(RelatedPatentItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1865,7 +1933,7 @@ func eprintIDToRelatedPatents(repoID string, eprintID int, db *sql.DB, tables ma
 	return nil
 }
 
-func eprintIDToDivisions(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *DivisionItemList {
+func eprintIDToDivisions(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *DivisionItemList {
 	tableName := `eprint_divisions`
 	itemList := new(DivisionItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1874,7 +1942,7 @@ func eprintIDToDivisions(repoID string, eprintID int, db *sql.DB, tables map[str
 	return nil
 }
 
-func eprintIDToOptionMajor(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *OptionMajorItemList {
+func eprintIDToOptionMajor(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *OptionMajorItemList {
 	tableName := `eprint_option_major`
 	itemList := new(OptionMajorItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1883,7 +1951,7 @@ func eprintIDToOptionMajor(repoID string, eprintID int, db *sql.DB, tables map[s
 	return nil
 }
 
-func eprintIDToOptionMinor(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *OptionMinorItemList {
+func eprintIDToOptionMinor(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *OptionMinorItemList {
 	tableName := `eprint_option_minor`
 	itemList := new(OptionMinorItemList)
 	if count := eprintIDToSimpleItemList(db, tables, repoID, eprintID, tableName, itemList); count > 0 {
@@ -1896,7 +1964,7 @@ func eprintIDToOptionMinor(repoID string, eprintID int, db *sql.DB, tables map[s
  * Hetrogenous models
  */
 
-func eprintIDToConfCreators(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ConfCreatorItemList {
+func eprintIDToConfCreators(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ConfCreatorItemList {
 	var (
 		pos   int
 		value string
@@ -1977,7 +2045,7 @@ FROM %s WHERE eprintid = ? ORDER BY eprintid, pos`, columnName, columnName, tabl
 	return nil
 }
 
-func eprintIDToCorpCreators(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *CorpCreatorItemList {
+func eprintIDToCorpCreators(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *CorpCreatorItemList {
 	var (
 		pos   int
 		value string
@@ -2055,7 +2123,7 @@ FROM %s WHERE eprintid = ? ORDER BY eprintid, pos`, columnName, columnName, tabl
 	return nil
 }
 
-func eprintIDToCorpContributors(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *CorpContributorItemList {
+func eprintIDToCorpContributors(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *CorpContributorItemList {
 	var (
 		pos   int
 		value string
@@ -2133,7 +2201,7 @@ FROM %s WHERE eprintid = ? ORDER BY eprintid, pos`, columnName, columnName, tabl
 	return nil
 }
 
-func eprintIDToFunders(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *FunderItemList {
+func eprintIDToFunders(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *FunderItemList {
 	var (
 		pos   int
 		value string
@@ -2209,7 +2277,7 @@ func eprintIDToFunders(repoID string, eprintID int, db *sql.DB, tables map[strin
 	return nil
 }
 
-func eprintIDToRelatedURL(repoID string, baseURL string, eprintID int, db *sql.DB, tables map[string][]string) *RelatedURLItemList {
+func eprintIDToRelatedURL(repoID string, baseURL string, eprintID int, db sqlQueryer, tables map[string][]string) *RelatedURLItemList {
 	tablesAndColumns := map[string]string{
 		"eprint_related_url_url":         "related_url_url",
 		"eprint_related_url_type":        "related_url_type",
@@ -2258,7 +2326,7 @@ func eprintIDToRelatedURL(repoID string, baseURL string, eprintID int, db *sql.D
 	return nil
 }
 
-func eprintIDToOtherNumberingSystem(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *OtherNumberingSystemItemList {
+func eprintIDToOtherNumberingSystem(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *OtherNumberingSystemItemList {
 	tableNames := []string{`eprint_other_numbering_system_name`, `eprint_other_numbering_system_id`}
 	ok := true
 	for _, tableName := range tableNames {
@@ -2304,7 +2372,7 @@ SELECT %s.pos AS pos, IFNULL(other_numbering_system_name, '') AS name, IFNULL(ot
 	return nil
 }
 
-func eprintIDToItemIssues(repoID string, eprintID int, db *sql.DB, tables map[string][]string) *ItemIssueItemList {
+func eprintIDToItemIssues(repoID string, eprintID int, db sqlQueryer, tables map[string][]string) *ItemIssueItemList {
 	tableName := `eprint_item_issues_timestamp`
 	_, ok := tables[tableName]
 	if ok {
@@ -2395,6 +2463,123 @@ FROM %s WHERE eprintid = ? ORDER BY eprintid, pos`, tableName)
 	return nil
 }
 
+// normalizeEPrintColumns sets the fields inferred from the eprint
+// table columns (e.g. timestamps from their year, month, day parts).
+func normalizeEPrintColumns(baseURL string, eprint *EPrint) {
+	// Normalize fields inferred from MySQL database tables.
+	eprint.ID = fmt.Sprintf(`%s/id/eprint/%d`, baseURL, eprint.EPrintID)
+	eprint.LastModified = makeTimestamp(eprint.LastModifiedYear, eprint.LastModifiedMonth, eprint.LastModifiedDay, eprint.LastModifiedHour, eprint.LastModifiedMinute, eprint.LastModifiedSecond)
+	// NOTE: EPrint XML uses a datestamp for output but tracks a timestamp.
+	eprint.Datestamp = makeTimestamp(eprint.DatestampYear, eprint.DatestampMonth, eprint.DatestampDay, eprint.DatestampHour, eprint.DatestampMinute, eprint.DatestampSecond)
+	eprint.StatusChanged = makeTimestamp(eprint.StatusChangedYear, eprint.StatusChangedMonth, eprint.StatusChangedDay, eprint.StatusChangedHour, eprint.StatusChangedMinute, eprint.StatusChangedSecond)
+	eprint.Date = makeApproxDate(eprint.DateYear, eprint.DateMonth, eprint.DateDay)
+
+	// Used in CaltechTHESIS
+	eprint.ThesisSubmittedDate = makeDatestamp(eprint.ThesisSubmittedDateYear, eprint.ThesisSubmittedDateMonth, eprint.ThesisSubmittedDateDay)
+	eprint.ThesisDefenseDate = makeDatestamp(eprint.ThesisDefenseDateYear, eprint.ThesisDefenseDateMonth, eprint.ThesisDefenseDateDay)
+	eprint.ThesisApprovedDate = makeDatestamp(eprint.ThesisApprovedDateYear, eprint.ThesisApprovedDateMonth, eprint.ThesisApprovedDateDay)
+	eprint.ThesisPublicDate = makeDatestamp(eprint.ThesisPublicDateYear, eprint.ThesisPublicDateMonth, eprint.ThesisPublicDateDay)
+	eprint.ThesisDegreeDate = makeDatestamp(eprint.ThesisDegreeDateYear, eprint.ThesisDegreeDateMonth, eprint.ThesisDegreeDateDay)
+	eprint.GradOfficeApprovalDate = makeDatestamp(eprint.GradOfficeApprovalDateYear, eprint.GradOfficeApprovalDateMonth, eprint.GradOfficeApprovalDateDay)
+}
+
+// readEPrintSideTables populates eprint from the tables related to
+// the eprint table (e.g. eprint_creators_name, document, file) using
+// the queryer db.
+func readEPrintSideTables(repoID string, baseURL string, eprint *EPrint, db sqlQueryer, tables map[string][]string) {
+	// FIXME: Add Depository info (eprint.userid -> user* tables)
+	//   deposited on, deposited by
+	if eprint.UserID > 0 {
+		eprint.DepositedBy = userIDToName(repoID, eprint.UserID, db)
+		eprint.DepositedOn = makeTimestamp(eprint.DatestampYear, eprint.DatestampMonth, eprint.DatestampDay, eprint.DatestampHour, eprint.DatestampMinute, eprint.DatestampSecond)
+	}
+
+	// CreatorsItemList
+	eprint.Creators = eprintIDToCreators(repoID, eprint.EPrintID, db, tables)
+	// EditorsItemList
+	eprint.Editors = eprintIDToEditors(repoID, eprint.EPrintID, db, tables)
+	// ContributorsItemList
+	eprint.Contributors = eprintIDToContributors(repoID, eprint.EPrintID, db, tables)
+
+	// CorpCreators
+	eprint.CorpCreators = eprintIDToCorpCreators(repoID, eprint.EPrintID, db, tables)
+	// CorpContributors
+	eprint.CorpContributors = eprintIDToCorpContributors(repoID, eprint.EPrintID, db, tables)
+
+	// LocalGroupItemList (SimpleItemList)
+	eprint.LocalGroup = eprintIDToLocalGroup(repoID, eprint.EPrintID, db, tables)
+	// FundersItemList (custom)
+	eprint.Funders = eprintIDToFunders(repoID, eprint.EPrintID, db, tables)
+	// Documents (*DocumentList)
+	eprint.Documents = eprintIDToDocumentList(repoID, baseURL, eprint.EPrintID, db, tables)
+	// RelatedURLs List
+	eprint.RelatedURL = eprintIDToRelatedURL(repoID, baseURL, eprint.EPrintID, db, tables)
+	// ReferenceText (item list)
+	eprint.ReferenceText = eprintIDToReferenceText(repoID, eprint.EPrintID, db, tables)
+	// Projects
+	eprint.Projects = eprintIDToProjects(repoID, eprint.EPrintID, db, tables)
+	// OtherNumberingSystem (item list)
+	eprint.OtherNumberingSystem = eprintIDToOtherNumberingSystem(repoID, eprint.EPrintID, db, tables)
+	// Subjects List
+	eprint.Subjects = eprintIDToSubjects(repoID, eprint.EPrintID, db, tables)
+	// ItemIssues
+	eprint.ItemIssues = eprintIDToItemIssues(repoID, eprint.EPrintID, db, tables)
+
+	// Exhibitors
+	eprint.Exhibitors = eprintIDToExhibitors(repoID, eprint.EPrintID, db, tables)
+	// Producers
+	eprint.Producers = eprintIDToProducers(repoID, eprint.EPrintID, db, tables)
+	// Conductors
+	eprint.Conductors = eprintIDToConductors(repoID, eprint.EPrintID, db, tables)
+
+	// Lyricists
+	eprint.Lyricists = eprintIDToLyricists(repoID, eprint.EPrintID, db, tables)
+
+	// Accompaniment
+	eprint.Accompaniment = eprintIDToAccompaniment(repoID, eprint.EPrintID, db, tables)
+	// SkillAreas
+	eprint.SkillAreas = eprintIDToSkillAreas(repoID, eprint.EPrintID, db, tables)
+	// CopyrightHolders
+	eprint.CopyrightHolders = eprintIDToCopyrightHolders(repoID, eprint.EPrintID, db, tables)
+	// Reference
+	eprint.Reference = eprintIDToReference(repoID, eprint.EPrintID, db, tables)
+
+	// ConfCreators
+	eprint.ConfCreators = eprintIDToConfCreators(repoID, eprint.EPrintID, db, tables)
+	// AltTitle
+	eprint.AltTitle = eprintIDToAltTitle(repoID, eprint.EPrintID, db, tables)
+	// PatentAssignee
+	eprint.PatentAssignee = eprintIDToPatentAssignee(repoID, eprint.EPrintID, db, tables)
+	// RelatedPatents
+	eprint.RelatedPatents = eprintIDToRelatedPatents(repoID, eprint.EPrintID, db, tables)
+	// Divisions
+	eprint.Divisions = eprintIDToDivisions(repoID, eprint.EPrintID, db, tables)
+	// ThesisAdvisor
+	eprint.ThesisAdvisor = eprintIDToThesisAdvisors(repoID, eprint.EPrintID, db, tables)
+	// ThesisCommittee
+	eprint.ThesisCommittee = eprintIDToThesisCommittee(repoID, eprint.EPrintID, db, tables)
+
+	// OptionMajor
+	eprint.OptionMajor = eprintIDToOptionMajor(repoID, eprint.EPrintID, db, tables)
+	// OptionMinor
+	eprint.OptionMinor = eprintIDToOptionMinor(repoID, eprint.EPrintID, db, tables)
+
+	/*************************************************************
+	    NOTE: These are notes about possible original implementation
+	    errors or elements that did not survive the upgrade to
+	    EPrints 3.3.16
+
+	    eprint.LearningLevels (not an item list in EPrints) using LearningLevelText
+	    GScholar, skipping not an item list, a 2010 plugin for EPRints 3.2.
+	    eprint.GScholar = eprintIDToGScholar(repoID, eprint.EPrintID, db, tables)
+	    Shelves, a plugin, not replicating, not an item list
+	    eprint.Shelves = eprintIDToSchelves(repoID, eprint.EPrintID, db, tables)
+	    eprint.PatentClassification is not not an item list, using eprint.PatentClassificationText
+	    eprint.OtherURL appears to be an extraneous
+	    eprint.CorpContributors apears to be an extraneous
+	*************************************************************/
+}
+
 // SQLReadEPrint expects a repository map and EPrint ID
 // and will generate a series of SELECT statements populating
 // a new EPrint struct or return an error (e.g. "not found" if eprint id is not in repository)
@@ -2454,113 +2639,8 @@ func SQLReadEPrint(config *Config, repoID string, baseURL string, eprintID int)
 	rows.Close()
 	// NOTE: need to handle zero rows returned!
 	if cnt > 0 {
-		// Normalize fields inferred from MySQL database tables.
-		eprint.ID = fmt.Sprintf(`%s/id/eprint/%d`, baseURL, eprint.EPrintID)
-		eprint.LastModified = makeTimestamp(eprint.LastModifiedYear, eprint.LastModifiedMonth, eprint.LastModifiedDay, eprint.LastModifiedHour, eprint.LastModifiedMinute, eprint.LastModifiedSecond)
-		// NOTE: EPrint XML uses a datestamp for output but tracks a timestamp.
-		eprint.Datestamp = makeTimestamp(eprint.DatestampYear, eprint.DatestampMonth, eprint.DatestampDay, eprint.DatestampHour, eprint.DatestampMinute, eprint.DatestampSecond)
-		eprint.StatusChanged = makeTimestamp(eprint.StatusChangedYear, eprint.StatusChangedMonth, eprint.StatusChangedDay, eprint.StatusChangedHour, eprint.StatusChangedMinute, eprint.StatusChangedSecond)
-		eprint.Date = makeApproxDate(eprint.DateYear, eprint.DateMonth, eprint.DateDay)
-
-		// FIXME: Add Depository info (eprint.userid -> user* tables)
-		//   deposited on, deposited by
-		if eprint.UserID > 0 {
-			eprint.DepositedBy = userIDToName(repoID, eprint.UserID, db)
-			eprint.DepositedOn = makeTimestamp(eprint.DatestampYear, eprint.DatestampMonth, eprint.DatestampDay, eprint.DatestampHour, eprint.DatestampMinute, eprint.DatestampSecond)
-		}
-
-		// Used in CaltechTHESIS
-		eprint.ThesisSubmittedDate = makeDatestamp(eprint.ThesisSubmittedDateYear, eprint.ThesisSubmittedDateMonth, eprint.ThesisSubmittedDateDay)
-		eprint.ThesisDefenseDate = makeDatestamp(eprint.ThesisDefenseDateYear, eprint.ThesisDefenseDateMonth, eprint.ThesisDefenseDateDay)
-		eprint.ThesisApprovedDate = makeDatestamp(eprint.ThesisApprovedDateYear, eprint.ThesisApprovedDateMonth, eprint.ThesisApprovedDateDay)
-		eprint.ThesisPublicDate = makeDatestamp(eprint.ThesisPublicDateYear, eprint.ThesisPublicDateMonth, eprint.ThesisPublicDateDay)
-		eprint.ThesisDegreeDate = makeDatestamp(eprint.ThesisDegreeDateYear, eprint.ThesisDegreeDateMonth, eprint.ThesisDegreeDateDay)
-		eprint.GradOfficeApprovalDate = makeDatestamp(eprint.GradOfficeApprovalDateYear, eprint.GradOfficeApprovalDateMonth, eprint.GradOfficeApprovalDateDay)
-
-		// CreatorsItemList
-		eprint.Creators = eprintIDToCreators(repoID, eprintID, db, tables)
-		// EditorsItemList
-		eprint.Editors = eprintIDToEditors(repoID, eprintID, db, tables)
-		// ContributorsItemList
-		eprint.Contributors = eprintIDToContributors(repoID, eprintID, db, tables)
-
-		// CorpCreators
-		eprint.CorpCreators = eprintIDToCorpCreators(repoID, eprintID, db, tables)
-		// CorpContributors
-		eprint.CorpContributors = eprintIDToCorpContributors(repoID, eprintID, db, tables)
-
-		// LocalGroupItemList (SimpleItemList)
-		eprint.LocalGroup = eprintIDToLocalGroup(repoID, eprintID, db, tables)
-		// FundersItemList (custom)
-		eprint.Funders = eprintIDToFunders(repoID, eprintID, db, tables)
-		// Documents (*DocumentList)
-		eprint.Documents = eprintIDToDocumentList(repoID, baseURL, eprintID, db, tables)
-		// RelatedURLs List
-		eprint.RelatedURL = eprintIDToRelatedURL(repoID, baseURL, eprintID, db, tables)
-		// ReferenceText (item list)
-		eprint.ReferenceText = eprintIDToReferenceText(repoID, eprintID, db, tables)
-		// Projects
-		eprint.Projects = eprintIDToProjects(repoID, eprintID, db, tables)
-		// OtherNumberingSystem (item list)
-		eprint.OtherNumberingSystem = eprintIDToOtherNumberingSystem(repoID, eprintID, db, tables)
-		// Subjects List
-		eprint.Subjects = eprintIDToSubjects(repoID, eprintID, db, tables)
-		// ItemIssues
-		eprint.ItemIssues = eprintIDToItemIssues(repoID, eprintID, db, tables)
-
-		// Exhibitors
-		eprint.Exhibitors = eprintIDToExhibitors(repoID, eprintID, db, tables)
-		// Producers
-		eprint.Producers = eprintIDToProducers(repoID, eprintID, db, tables)
-		// Conductors
-		eprint.Conductors = eprintIDToConductors(repoID, eprintID, db, tables)
-
-		// Lyricists
-		eprint.Lyricists = eprintIDToLyricists(repoID, eprintID, db, tables)
-
-		// Accompaniment
-		eprint.Accompaniment = eprintIDToAccompaniment(repoID, eprintID, db, tables)
-		// SkillAreas
-		eprint.SkillAreas = eprintIDToSkillAreas(repoID, eprintID, db, tables)
-		// CopyrightHolders
-		eprint.CopyrightHolders = eprintIDToCopyrightHolders(repoID, eprintID, db, tables)
-		// Reference
-		eprint.Reference = eprintIDToReference(repoID, eprintID, db, tables)
-
-		// ConfCreators
-		eprint.ConfCreators = eprintIDToConfCreators(repoID, eprintID, db, tables)
-		// AltTitle
-		eprint.AltTitle = eprintIDToAltTitle(repoID, eprintID, db, tables)
-		// PatentAssignee
-		eprint.PatentAssignee = eprintIDToPatentAssignee(repoID, eprintID, db, tables)
-		// RelatedPatents
-		eprint.RelatedPatents = eprintIDToRelatedPatents(repoID, eprintID, db, tables)
-		// Divisions
-		eprint.Divisions = eprintIDToDivisions(repoID, eprintID, db, tables)
-		// ThesisAdvisor
-		eprint.ThesisAdvisor = eprintIDToThesisAdvisors(repoID, eprintID, db, tables)
-		// ThesisCommittee
-		eprint.ThesisCommittee = eprintIDToThesisCommittee(repoID, eprintID, db, tables)
-
-		// OptionMajor
-		eprint.OptionMajor = eprintIDToOptionMajor(repoID, eprintID, db, tables)
-		// OptionMinor
-		eprint.OptionMinor = eprintIDToOptionMinor(repoID, eprintID, db, tables)
-
-		/*************************************************************
-		    NOTE: These are notes about possible original implementation
-		    errors or elements that did not survive the upgrade to
-		    EPrints 3.3.16
-
-		    eprint.LearningLevels (not an item list in EPrints) using LearningLevelText
-		    GScholar, skipping not an item list, a 2010 plugin for EPRints 3.2.
-		    eprint.GScholar = eprintIDToGScholar(repoID, eprintID, db, tables)
-		    Shelves, a plugin, not replicating, not an item list
-		    eprint.Shelves = eprintIDToSchelves(repoID, eprintID, db, tables)
-		    eprint.PatentClassification is not not an item list, using eprint.PatentClassificationText
-		    eprint.OtherURL appears to be an extraneous
-		    eprint.CorpContributors apears to be an extraneous
-		*************************************************************/
+		normalizeEPrintColumns(baseURL, eprint)
+		readEPrintSideTables(repoID, baseURL, eprint, &dbQueryer{db}, tables)
 	} else {
 		return nil, fmt.Errorf("not found")
 	}
@@ -2568,6 +2648,82 @@ func SQLReadEPrint(config *Config, repoID string, baseURL string, eprintID int)
 	return eprint, nil
 }
 
+// EPrintVersion holds the columns of an eprint row that change when
+// the record is modified. It is used by ep3apid to answer conditional
+// requests without assembling the whole EPrint.
+type EPrintVersion struct {
+	EPrintID           int
+	RevNumber          int
+	EPrintStatus       string
+	MetadataVisibility string
+	// Datestamp and LastModified are the datestamp (created) and
+	// lastmod timestamps (UTC), they are the zero time when the
+	// fields are not set.
+	Datestamp    time.Time
+	LastModified time.Time
+}
+
+// IsPublic returns true if the version's EPrint is public (see EPrint.IsPublic)
+func (version *EPrintVersion) IsPublic() bool {
+	return (version.EPrintStatus == "archive") && (version.MetadataVisibility == "show")
+}
+
+// ETag returns a strong entity tag for a representation of the
+// eprint, e.g. `"1234-5-eprint-xml"`. It changes whenever the
+// rev_number does.
+func (version *EPrintVersion) ETag(representation string) string {
+	return fmt.Sprintf(`"%d-%d-%s"`, version.EPrintID, version.RevNumber, representation)
+}
+
+// SQLReadEPrintVersion reads the rev_number, status, visibility,
+// datestamp and lastmod of an eprint or returns an error. The error
+// wraps sql.ErrNoRows when the eprint is "not found".
+func SQLReadEPrintVersion(config *Config, repoID string, eprintID int) (*EPrintVersion, error) {
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return nil, fmt.Errorf("no database connection for %s", repoID)
+	}
+	stmt := `SELECT ` + eprintVersionColumns + ` FROM eprint WHERE eprintid = ? LIMIT 1`
+	version, err := scanEPrintVersion(db.QueryRow(stmt, eprintID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("not found, %d not in %q, %w", eprintID, repoID, err)
+		}
+		return nil, fmt.Errorf("SQL error, %q, %s", stmt, err)
+	}
+	return version, nil
+}
+
+// eprintVersionColumns are the eprint table columns read by
+// scanEPrintVersion.
+const eprintVersionColumns = `eprintid, IFNULL(rev_number, 0), IFNULL(eprint_status, ''), IFNULL(metadata_visibility, ''),
+IFNULL(datestamp_year, 0), IFNULL(datestamp_month, 1), IFNULL(datestamp_day, 1),
+IFNULL(datestamp_hour, 0), IFNULL(datestamp_minute, 0), IFNULL(datestamp_second, 0),
+IFNULL(lastmod_year, 0), IFNULL(lastmod_month, 1), IFNULL(lastmod_day, 1),
+IFNULL(lastmod_hour, 0), IFNULL(lastmod_minute, 0), IFNULL(lastmod_second, 0)`
+
+// scanEPrintVersion scans a row selected with eprintVersionColumns
+// into an EPrintVersion. row is a *sql.Row or *sql.Rows.
+func scanEPrintVersion(row interface{ Scan(dest ...interface{}) error }) (*EPrintVersion, error) {
+	var (
+		dYear, dMonth, dDay, dHour, dMin, dSec int
+		lYear, lMonth, lDay, lHour, lMin, lSec int
+	)
+	version := new(EPrintVersion)
+	if err := row.Scan(&version.EPrintID, &version.RevNumber, &version.EPrintStatus, &version.MetadataVisibility,
+		&dYear, &dMonth, &dDay, &dHour, &dMin, &dSec,
+		&lYear, &lMonth, &lDay, &lHour, &lMin, &lSec); err != nil {
+		return nil, err
+	}
+	if dYear > 0 {
+		version.Datestamp = time.Date(dYear, time.Month(dMonth), dDay, dHour, dMin, dSec, 0, time.UTC)
+	}
+	if lYear > 0 {
+		version.LastModified = time.Date(lYear, time.Month(lMonth), lDay, lHour, lMin, lSec, 0, time.UTC)
+	}
+	return version, nil
+}
+
 // qmList generates an array of string where each element holds "?".
 func qmList(length int) []string {
 	list := []string{}
@@ -2577,371 +2733,478 @@ func qmList(length int) []string {
 	return list
 }
 
+// sqlExecer is satisfied by both *sql.DB and *sql.Tx so that
+// item lists can be written inside or outside a transaction.
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 // insertItemList takes an repoID, table name, list of columns and
 // an EPrint datastructure then generates and executes a series of
 // INSERT statement to create an Item List for the given table.
-func insertItemList(db *sql.DB, repoID string, tableName string, columns []string, eprint *EPrint) error {
-	var (
-		itemList ItemsInterface
-	)
+func insertItemList(db sqlExecer, repoID string, tableName string, columns []string, eprint *EPrint) error {
 	eprintid := eprint.EPrintID
+	itemList, err := eprintItemList(eprint, tableName, columns)
+	if err != nil {
+		return err
+	}
+	// Clear the list, then insert
+	stmt := fmt.Sprintf(`DELETE FROM %s WHERE eprintid = ?`, tableName)
+	_, err = db.Exec(stmt, eprint.EPrintID)
+	if err != nil {
+		return fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+	}
+	for pos := 0; pos < itemList.Length(); pos++ {
+		item := itemList.IndexOf(pos)
+		item.Pos = pos
+		columnsSQL, values, err := itemToColumnsAndValues(tableName, eprintid, pos, item, columns)
+		if err != nil {
+			return err
+		}
+		stmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, tableName, strings.Join(columnsSQL, `, `), strings.Join(qmList(len(columnsSQL)), `, `))
+		_, err = db.Exec(stmt, values...)
+		if err != nil {
+			return fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+		}
+	}
+	return nil
+}
+
+// eprintItemList returns the item list of an EPrint stored in
+// tableName. A nil list is returned for tables without one
+// (e.g. eprint_keyword).
+func eprintItemList(eprint *EPrint, tableName string, columns []string) (ItemsInterface, error) {
 	switch {
 	case strings.HasPrefix(tableName, `eprint_creators_`):
-		itemList = eprint.Creators
+		return eprint.Creators, nil
 	case strings.HasPrefix(tableName, `eprint_editors_`):
-		itemList = eprint.Editors
+		return eprint.Editors, nil
 	case strings.HasPrefix(tableName, `eprint_contributors_`):
-		itemList = eprint.Contributors
+		return eprint.Contributors, nil
 	case strings.HasPrefix(tableName, `eprint_corp_creators`):
-		itemList = eprint.CorpCreators
+		return eprint.CorpCreators, nil
 	case strings.HasPrefix(tableName, `eprint_corp_contributors_`):
-		itemList = eprint.CorpContributors
+		return eprint.CorpContributors, nil
 	case strings.HasPrefix(tableName, `eprint_thesis_advisor_`):
-		itemList = eprint.ThesisAdvisor
+		return eprint.ThesisAdvisor, nil
 	case strings.HasPrefix(tableName, `eprint_thesis_committee_`):
-		itemList = eprint.ThesisCommittee
+		return eprint.ThesisCommittee, nil
 	case strings.HasPrefix(tableName, `eprint_item_issues_`):
-		itemList = eprint.ItemIssues
+		return eprint.ItemIssues, nil
 	case strings.HasPrefix(tableName, `eprint_alt_title`):
-		itemList = eprint.AltTitle
+		return eprint.AltTitle, nil
 	case strings.HasPrefix(tableName, `eprint_conductors`):
-		itemList = eprint.Conductors
+		return eprint.Conductors, nil
 	case strings.HasPrefix(tableName, `eprint_conf_creators_`):
-		itemList = eprint.ConfCreators
+		return eprint.ConfCreators, nil
 	case strings.HasPrefix(tableName, `eprint_exhibitors_`):
-		itemList = eprint.Exhibitors
+		return eprint.Exhibitors, nil
 	case strings.HasPrefix(tableName, `eprint_producers_`):
-		itemList = eprint.Producers
+		return eprint.Producers, nil
 	case strings.HasPrefix(tableName, `eprint_lyricists_`):
-		itemList = eprint.Lyricists
+		return eprint.Lyricists, nil
 	case strings.HasPrefix(tableName, `eprint_accompaniment`):
-		itemList = eprint.Accompaniment
+		return eprint.Accompaniment, nil
 	case strings.HasPrefix(tableName, `eprint_subjec`):
-		itemList = eprint.Subjects
+		return eprint.Subjects, nil
 	case strings.HasPrefix(tableName, `eprint_local_`):
-		itemList = eprint.LocalGroup
+		return eprint.LocalGroup, nil
 	case strings.HasPrefix(tableName, `eprint_div`):
-		itemList = eprint.Divisions
+		return eprint.Divisions, nil
 	case strings.HasPrefix(tableName, `eprint_option_maj`):
-		itemList = eprint.OptionMajor
+		return eprint.OptionMajor, nil
 	case strings.HasPrefix(tableName, `eprint_option_min`):
-		itemList = eprint.OptionMinor
+		return eprint.OptionMinor, nil
 	case strings.HasPrefix(tableName, `eprint_funders_`):
-		itemList = eprint.Funders
+		return eprint.Funders, nil
 	case strings.HasPrefix(tableName, `eprint_funders`):
 		// Ignore, eprint_funders is empty in CaltechAUTHORS ...
-		itemList = new(FunderItemList)
+		return new(FunderItemList), nil
 	case strings.HasPrefix(tableName, `eprint_other_numbering_system`):
-		itemList = eprint.OtherNumberingSystem
+		return eprint.OtherNumberingSystem, nil
 	case strings.HasPrefix(tableName, `eprint_projects`):
-		itemList = eprint.Projects
+		return eprint.Projects, nil
 	case strings.HasPrefix(tableName, `eprint_referencetext`):
-		itemList = eprint.ReferenceText
+		return eprint.ReferenceText, nil
 	case strings.HasPrefix(tableName, `eprint_related_url`):
-		itemList = eprint.RelatedURL
+		return eprint.RelatedURL, nil
 	case strings.HasPrefix(tableName, `eprint_skill_areas`):
-		itemList = eprint.SkillAreas
+		return eprint.SkillAreas, nil
 	case strings.HasPrefix(tableName, `eprint_patent_assignee`):
-		itemList = eprint.PatentAssignee
+		return eprint.PatentAssignee, nil
 	case strings.HasPrefix(tableName, `eprint_related_patents`):
-		itemList = eprint.RelatedPatents
+		return eprint.RelatedPatents, nil
 	case strings.HasPrefix(tableName, `eprint_referencetext`):
-		itemList = eprint.ReferenceText
+		return eprint.ReferenceText, nil
 	case strings.HasPrefix(tableName, `eprint_accompaniment`):
-		itemList = eprint.Accompaniment
+		return eprint.Accompaniment, nil
 	case strings.HasPrefix(tableName, `eprint_reference`):
-		itemList = eprint.Reference
+		return eprint.Reference, nil
 	case strings.HasPrefix(tableName, `eprint_copyright_holders`):
-		itemList = eprint.CopyrightHolders
+		return eprint.CopyrightHolders, nil
 	case strings.HasPrefix(tableName, `eprint_related_patent`):
-		itemList = eprint.RelatedPatents
+		return eprint.RelatedPatents, nil
 	case strings.HasPrefix(tableName, `eprint_parent_assign`):
-		itemList = eprint.PatentAssignee
+		return eprint.PatentAssignee, nil
 	case strings.HasPrefix(tableName, `eprint_skill`):
-		itemList = eprint.SkillAreas
+		return eprint.SkillAreas, nil
 	case strings.HasPrefix(tableName, `eprint_relation`):
 		// NOTE: This is not the same as document_relation_*, it is a separate item list item list
 		// it has the same structure with a uri and type. Our eprint implementations use a Relation
-		itemList = eprint.Relation
+		return eprint.Relation, nil
 	case strings.HasPrefix(tableName, `eprint_keyword`):
 		// NOTE: this we appear to use the longtext of key in our eprint table. Not sure if this
 		// is new or old structure. It is posssible that our longtext for keywords is a legacy structure.
 		// itemList = eprint.Keyword
+		return nil, nil
 	default:
-		return fmt.Errorf(`do not understand table %q, columns %s`, tableName, strings.Join(columns, `, `))
+		return nil, fmt.Errorf(`do not understand table %q, columns %s`, tableName, strings.Join(columns, `, `))
 	}
-	// Clear the list, then insert
-	stmt := fmt.Sprintf(`DELETE FROM %s WHERE eprintid = ?`, tableName)
-	_, err := db.Exec(stmt, eprint.EPrintID)
+}
+
+// itemToColumnsAndValues maps the columns of an item list table to
+// the values of item at pos for eprintid.
+func itemToColumnsAndValues(tableName string, eprintid int, pos int, item *Item, columns []string) ([]string, []interface{}, error) {
+	values := []interface{}{}
+	columnsSQL := []string{}
+	for _, col := range columns {
+		switch {
+		case col == `eprintid`:
+			values = append(values, eprintid)
+			columnsSQL = append(columnsSQL, col)
+		case col == `pos`:
+			values = append(values, pos)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_id`):
+			values = append(values, item.ID)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_type`):
+			values = append(values, item.Type)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_family`):
+			values = append(values, item.Name.Family)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_given`):
+			values = append(values, item.Name.Given)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_honourific`):
+			values = append(values, item.Name.Honourific)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_lineage`):
+			values = append(values, item.Name.Lineage)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_name`):
+			values = append(values, item.Name.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_show_email`):
+			// NOTE: _show_email needs to be tested before _email
+			values = append(values, item.ShowEMail)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_email`):
+			// NOTE: _show_email needs to be tested before _email
+			values = append(values, item.EMail)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_role`):
+			values = append(values, item.Role)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_url`):
+			values = append(values, item.URL)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `description`):
+			values = append(values, item.Description)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_agency`):
+			values = append(values, item.Agency)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_grant_number`):
+			values = append(values, item.GrantNumber)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_uri`):
+			values = append(values, item.URI)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_orcid`):
+			values = append(values, item.ORCID)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_ror`):
+			values = append(values, item.ROR)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_timestamp`):
+			values = append(values, item.Timestamp)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_status`):
+			values = append(values, item.Status)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_reported_by`):
+			values = append(values, item.ReportedBy)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_resolved_by`):
+			values = append(values, item.ResolvedBy)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_comment`):
+			values = append(values, item.ResolvedBy)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_group`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_subjects`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_major`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_minor`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `_holders`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `divisions`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `subjects`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `referencetext`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `accompaniment`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `related_patents`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `patent_assignee`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `skill_areas`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		case strings.HasSuffix(col, `alt_title`):
+			values = append(values, item.Value)
+			columnsSQL = append(columnsSQL, col)
+		default:
+			return nil, nil, fmt.Errorf("do not understand column %s.%s\n", tableName, col)
+		}
+	}
+	return columnsSQL, values, nil
+}
+
+// maxIDRetries is the number of times we retry allocating an eprintid
+// when a concurrent request has claimed the id first.
+const maxIDRetries = 5
+
+// isDuplicateKey returns true if err is a MySQL duplicate key error
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	return false
+}
+
+// allocateEPrintID reserves the next eprintid inside a transaction.
+// The locking read holds the end of the eprint index until the
+// transaction completes so concurrent imports can't be given
+// the same id. A row with the new eprintid is inserted and the id
+// returned.
+func allocateEPrintID(tx *sql.Tx) (int, error) {
+	stmt := `SELECT eprintid FROM eprint ORDER BY eprintid DESC LIMIT 1 FOR UPDATE`
+	id := 0
+	if err := tx.QueryRow(stmt).Scan(&id); err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+	}
+	id++
+	stmt = `INSERT INTO eprint (eprintid) VALUES (?)`
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// sqlCreateEPrint does the work of SQLCreateEPrint inside a
+// transaction. The caller is responsible for commit or rollback.
+func sqlCreateEPrint(tx *sql.Tx, repoID string, ds *DataSource, eprint *EPrint) (int, error) {
+	tableName := `eprint`
+	columns, ok := ds.TableMap[tableName]
+	if !ok {
+		return 0, fmt.Errorf(`eprint table not found for %s`, repoID)
+	}
+	id, err := allocateEPrintID(tx)
 	if err != nil {
-		return fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+		return 0, err
 	}
-	for pos := 0; pos < itemList.Length(); pos++ {
-		item := itemList.IndexOf(pos)
-		item.Pos = pos
-		values := []interface{}{}
-		columnsSQL := []string{}
-		for _, col := range columns {
-			switch {
-			case col == `eprintid`:
-				values = append(values, eprintid)
-				columnsSQL = append(columnsSQL, col)
-			case col == `pos`:
-				values = append(values, pos)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_id`):
-				values = append(values, item.ID)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_type`):
-				values = append(values, item.Type)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_family`):
-				values = append(values, item.Name.Family)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_given`):
-				values = append(values, item.Name.Given)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_honourific`):
-				values = append(values, item.Name.Honourific)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_lineage`):
-				values = append(values, item.Name.Lineage)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_name`):
-				values = append(values, item.Name.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_show_email`):
-				// NOTE: _show_email needs to be tested before _email
-				values = append(values, item.ShowEMail)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_email`):
-				// NOTE: _show_email needs to be tested before _email
-				values = append(values, item.EMail)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_role`):
-				values = append(values, item.Role)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_url`):
-				values = append(values, item.URL)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `description`):
-				values = append(values, item.Description)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_agency`):
-				values = append(values, item.Agency)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_grant_number`):
-				values = append(values, item.GrantNumber)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_uri`):
-				values = append(values, item.URI)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_orcid`):
-				values = append(values, item.ORCID)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_ror`):
-				values = append(values, item.ROR)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_timestamp`):
-				values = append(values, item.Timestamp)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_status`):
-				values = append(values, item.Status)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_reported_by`):
-				values = append(values, item.ReportedBy)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_resolved_by`):
-				values = append(values, item.ResolvedBy)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_comment`):
-				values = append(values, item.ResolvedBy)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_group`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_subjects`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_major`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_minor`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `_holders`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `divisions`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `subjects`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `referencetext`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `accompaniment`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `related_patents`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `patent_assignee`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `skill_areas`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			case strings.HasSuffix(col, `alt_title`):
-				values = append(values, item.Value)
-				columnsSQL = append(columnsSQL, col)
-			default:
-				return fmt.Errorf("do not understand column %s.%s\n", tableName, col)
+	eprint.EPrintID = id
+	eprint.Dir = makeDirValue(eprint.EPrintID)
+	// FIXME: decide if the is automatic or if this should be
+	// passed in with the data structure.
+	// Generate minimal date and time stamps
+	now := time.Now()
+	if eprint.Datestamp == "" {
+		eprint.Datestamp = now.Format(timestamp)
+		eprint.DatestampYear = now.Year()
+		eprint.DatestampMonth = int(now.Month())
+		eprint.DatestampDay = now.Day()
+		eprint.DatestampHour = now.Hour()
+		eprint.DatestampMinute = now.Minute()
+		eprint.DatestampSecond = now.Second()
+	} else if dt, err := time.Parse(datestamp, eprint.Datestamp); err == nil {
+		eprint.DatestampYear = dt.Year()
+		eprint.DatestampMonth = int(dt.Month())
+		eprint.DatestampDay = dt.Day()
+	} else if dt, err := time.Parse(timestamp, eprint.Datestamp); err == nil {
+		eprint.DatestampYear = dt.Year()
+		eprint.DatestampMonth = int(dt.Month())
+		eprint.DatestampDay = dt.Day()
+		eprint.DatestampHour = dt.Hour()
+		eprint.DatestampMinute = dt.Minute()
+		eprint.DatestampSecond = dt.Second()
+	}
+
+	eprint.LastModified = now.Format(timestamp)
+	eprint.LastModifiedYear = now.Year()
+	eprint.LastModifiedMonth = int(now.Month())
+	eprint.LastModifiedDay = now.Day()
+	eprint.LastModifiedHour = now.Hour()
+	eprint.LastModifiedMinute = now.Minute()
+	eprint.LastModifiedSecond = now.Second()
+
+	eprint.StatusChanged = now.Format(timestamp)
+	eprint.StatusChangedYear = now.Year()
+	eprint.StatusChangedMonth = int(now.Month())
+	eprint.StatusChangedDay = now.Day()
+	eprint.StatusChangedHour = now.Hour()
+	eprint.StatusChangedMinute = now.Minute()
+	eprint.StatusChangedSecond = now.Second()
+
+	if eprint.Date != "" {
+		eprint.DateYear, eprint.DateMonth, eprint.DateDay = approxYMD(eprint.Date)
+	}
+	if eprint.ThesisSubmittedDate != "" {
+		eprint.ThesisSubmittedDateYear, eprint.ThesisSubmittedDateMonth, eprint.ThesisSubmittedDateDay = approxYMD(eprint.ThesisSubmittedDate)
+	}
+	if eprint.ThesisDefenseDate != "" {
+		eprint.ThesisDefenseDateYear, eprint.ThesisDefenseDateMonth, eprint.ThesisDefenseDateDay = approxYMD(eprint.ThesisDefenseDate)
+	}
+	if eprint.ThesisApprovedDate != "" {
+		eprint.ThesisApprovedDateYear, eprint.ThesisApprovedDateMonth, eprint.ThesisApprovedDateDay = approxYMD(eprint.ThesisApprovedDate)
+	}
+	if eprint.ThesisPublicDate != "" {
+		eprint.ThesisPublicDateYear, eprint.ThesisPublicDateMonth, eprint.ThesisPublicDateDay = approxYMD(eprint.ThesisPublicDate)
+	}
+	if eprint.GradOfficeApprovalDate != "" {
+		eprint.GradOfficeApprovalDateYear, eprint.GradOfficeApprovalDateMonth, eprint.GradOfficeApprovalDateDay = approxYMD(eprint.GradOfficeApprovalDate)
+	}
+
+	// Step two, write the rest of the date into the main table.
+	columnsSQL, values := eprintToColumnsAndValues(eprint, columns, false)
+	stmt := fmt.Sprintf(`REPLACE INTO %s (%s) VALUES (%s)`,
+		tableName,
+		strings.Join(columnsSQL, `, `),
+		strings.Join(qmList(len(columnsSQL)), `, `))
+	if _, err := tx.Exec(stmt, values...); err != nil {
+		return 0, fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+	}
+	for tableName, columns := range ds.TableMap {
+		// Handle the remaining tables, i.e. skip eprint table.
+		switch {
+		case tableName == `eprint`:
+			// Skip eprint table, we've already processed it
+		case tableName == `eprint_keyword`:
+			// Skip eprint_keyword, our EPrints use keywords (longtext) in eprint table.
+		case strings.HasPrefix(tableName, `document`):
+			//log.Printf(`FIXME %s columns: %s`, tableName, strings.Join(columns, `, `))
+		case strings.HasPrefix(tableName, `file`):
+			//log.Printf(`FIXME %s columns: %s`, tableName, strings.Join(columns, `, `))
+		default:
+			// Insert new rows in associated table
+			if err := insertItemList(tx, repoID, tableName, columns, eprint); err != nil {
+				return 0, fmt.Errorf(`failed to insert eprintid %d in table %s for %s, %s`, eprint.EPrintID, tableName, repoID, err)
 			}
 		}
-		stmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, tableName, strings.Join(columnsSQL, `, `), strings.Join(qmList(len(columnsSQL)), `, `))
-		_, err := db.Exec(stmt, values...)
-		if err != nil {
-			return fmt.Errorf(`SQL error, %q, %s`, stmt, err)
-		}
 	}
-	return nil
+	return eprint.EPrintID, nil
 }
 
 // SQLCreateEPrint will read a EPrint structure and
 // generate SQL INSERT, REPLACE and DELETE statements
 // suitable for creating a new EPrint record in the repository.
+//
+// The record is written inside a transaction, if any statement
+// fails the transaction is rolled back and no partial record is
+// left behind. If a concurrent request claims the same eprintid
+// the create is retried with a new id.
 func SQLCreateEPrint(config *Config, repoID string, ds *DataSource, eprint *EPrint) (int, error) {
-	var (
-		err error
-	)
 	db, ok := config.Connections[repoID]
 	if !ok {
 		return 0, fmt.Errorf(`no database connection for %s`, repoID)
 	}
-	// If eprint id is zero generate a sequence of INSERT statements
-	// for the record. Others use generate the appropriate History
-	// records and then delete insert the new record.
-	tableName := `eprint`
-
-	if columns, ok := ds.TableMap[tableName]; ok {
-		// Generate an empty row and capture the id created.
-		stmt := `INSERT INTO eprint (eprintid) (SELECT (IFNULL((SELECT eprintid FROM eprint ORDER BY eprintid DESC LIMIT 1), 0) + 1) AS eprintid)`
-		_, err := db.Exec(stmt)
+	var err error
+	for i := 0; i < maxIDRetries; i++ {
+		var (
+			tx *sql.Tx
+			id int
+		)
+		tx, err = db.Begin()
 		if err != nil {
-			return 0, fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+			return 0, fmt.Errorf(`SQL error, failed to start transaction, %s`, err)
 		}
-		stmt = `SELECT eprintid FROM eprint ORDER BY eprintid DESC LIMIT 1`
-		rows, err := db.Query(stmt)
+		id, err = sqlCreateEPrint(tx, repoID, ds, eprint)
 		if err != nil {
-			return 0, fmt.Errorf(`SQL error, %q, %s`, stmt, err)
-		}
-		id := 0
-		for rows.Next() {
-			if err := rows.Scan(&id); err != nil {
-				return 0, fmt.Errorf(`could not calculate the new eprintid value, %s`, err)
+			tx.Rollback()
+			eprint.EPrintID = 0
+			if isDuplicateKey(err) {
+				continue
 			}
+			return 0, err
 		}
-		rows.Close()
-		if err != nil {
-			return 0, fmt.Errorf(`SQL failed to get insert id, %s`, err)
+		if err = tx.Commit(); err != nil {
+			eprint.EPrintID = 0
+			return 0, fmt.Errorf(`SQL error, failed to commit eprint for %s, %s`, repoID, err)
 		}
-		eprint.EPrintID = int(id)
-		eprint.Dir = makeDirValue(eprint.EPrintID)
-		// FIXME: decide if the is automatic or if this should be
-		// passed in with the data structure.
-		// Generate minimal date and time stamps
-		now := time.Now()
-		if eprint.Datestamp == "" {
-			eprint.Datestamp = now.Format(timestamp)
-			eprint.DatestampYear = now.Year()
-			eprint.DatestampMonth = int(now.Month())
-			eprint.DatestampDay = now.Day()
-			eprint.DatestampHour = now.Hour()
-			eprint.DatestampMinute = now.Minute()
-			eprint.DatestampSecond = now.Second()
-		} else if dt, err := time.Parse(datestamp, eprint.Datestamp); err == nil {
-			eprint.DatestampYear = dt.Year()
-			eprint.DatestampMonth = int(dt.Month())
-			eprint.DatestampDay = dt.Day()
-		} else if dt, err := time.Parse(timestamp, eprint.Datestamp); err == nil {
-			eprint.DatestampYear = dt.Year()
-			eprint.DatestampMonth = int(dt.Month())
-			eprint.DatestampDay = dt.Day()
-			eprint.DatestampHour = dt.Hour()
-			eprint.DatestampMinute = dt.Minute()
-			eprint.DatestampSecond = dt.Second()
-		}
-
-		eprint.LastModified = now.Format(timestamp)
-		eprint.LastModifiedYear = now.Year()
-		eprint.LastModifiedMonth = int(now.Month())
-		eprint.LastModifiedDay = now.Day()
-		eprint.LastModifiedHour = now.Hour()
-		eprint.LastModifiedMinute = now.Minute()
-		eprint.LastModifiedSecond = now.Second()
-
-		eprint.StatusChanged = now.Format(timestamp)
-		eprint.StatusChangedYear = now.Year()
-		eprint.StatusChangedMonth = int(now.Month())
-		eprint.StatusChangedDay = now.Day()
-		eprint.StatusChangedHour = now.Hour()
-		eprint.StatusChangedMinute = now.Minute()
-		eprint.StatusChangedSecond = now.Second()
+		return id, nil
+	}
+	return 0, fmt.Errorf(`failed to allocate an eprintid for %s, %s`, repoID, err)
+}
 
-		if eprint.Date != "" {
-			eprint.DateYear, eprint.DateMonth, eprint.DateDay = approxYMD(eprint.Date)
-		}
-		if eprint.ThesisSubmittedDate != "" {
-			eprint.ThesisSubmittedDateYear, eprint.ThesisSubmittedDateMonth, eprint.ThesisSubmittedDateDay = approxYMD(eprint.ThesisSubmittedDate)
-		}
-		if eprint.ThesisDefenseDate != "" {
-			eprint.ThesisDefenseDateYear, eprint.ThesisDefenseDateMonth, eprint.ThesisDefenseDateDay = approxYMD(eprint.ThesisDefenseDate)
-		}
-		if eprint.ThesisApprovedDate != "" {
-			eprint.ThesisApprovedDateYear, eprint.ThesisApprovedDateMonth, eprint.ThesisApprovedDateDay = approxYMD(eprint.ThesisApprovedDate)
-		}
-		if eprint.ThesisPublicDate != "" {
-			eprint.ThesisPublicDateYear, eprint.ThesisPublicDateMonth, eprint.ThesisPublicDateDay = approxYMD(eprint.ThesisPublicDate)
-		}
-		if eprint.GradOfficeApprovalDate != "" {
-			eprint.GradOfficeApprovalDateYear, eprint.GradOfficeApprovalDateMonth, eprint.GradOfficeApprovalDateDay = approxYMD(eprint.GradOfficeApprovalDate)
-		}
+// ImportResult reports the outcome of importing a single
+// EPrint record.
+type ImportResult struct {
+	// EPrintID is the id assigned (or updated), zero if the record
+	// wasn't created
+	EPrintID int `json:"eprint_id" xml:"eprint_id"`
+	// Status is one of "created", "updated", "failed" or "rolled back"
+	Status string `json:"status" xml:"status"`
+	// Error holds the reason a record failed
+	Error string `json:"error,omitempty" xml:"error,omitempty"`
+}
 
-		// Step two, write the rest of the date into the main table.
-		columnsSQL, values := eprintToColumnsAndValues(eprint, columns, false)
-		stmt = fmt.Sprintf(`REPLACE INTO %s (%s) VALUES (%s)`,
-			tableName,
-			strings.Join(columnsSQL, `, `),
-			strings.Join(qmList(len(columnsSQL)), `, `))
-		_, err = db.Exec(stmt, values...)
-		if err != nil {
-			return 0, fmt.Errorf(`SQL error, %q, %s`, stmt, err)
-		}
+// applyImportDefaults applies a data source's default values to
+// an EPrint before it is imported.
+func applyImportDefaults(ds *DataSource, eprint *EPrint) {
+	if eprint.Collection == "" && ds.DefaultCollection != "" {
+		eprint.Collection = DefaultCollection
 	}
-	if eprint.EPrintID != 0 {
-		for tableName, columns := range ds.TableMap {
-			// Handle the remaining tables, i.e. skip eprint table.
-			switch {
-			case tableName == `eprint`:
-				// Skip eprint table, we've already processed it
-			case tableName == `eprint_keyword`:
-				// Skip eprint_keyword, our EPrints use keywords (longtext) in eprint table.
-			case strings.HasPrefix(tableName, `document`):
-				//log.Printf(`FIXME %s columns: %s`, tableName, strings.Join(columns, `, `))
-			case strings.HasPrefix(tableName, `file`):
-				//log.Printf(`FIXME %s columns: %s`, tableName, strings.Join(columns, `, `))
-			default:
-				// Insert new rows in associated table
-				if err := insertItemList(db, repoID, tableName, columns, eprint); err != nil {
-					return eprint.EPrintID, fmt.Errorf(`failed to insert eprintid %d in table %s for %s, %s`, eprint.EPrintID, tableName, repoID, err)
-				}
-			}
+	if eprint.IDNumber == "" && ds.DefaultOfficialURL != "" {
+		eprint.IDNumber = GenerateIDNumber(eprint)
+	}
+	if eprint.OfficialURL == "" && ds.DefaultOfficialURL != "" {
+		eprint.OfficialURL = GenerateOfficialURL(eprint)
+	}
+	if eprint.Rights == "" && ds.DefaultRights != "" {
+		eprint.Rights = ds.DefaultRights
+	}
+	if eprint.Refereed == "" && eprint.Type == "article" &&
+		ds.DefaultRefereed != "" {
+		eprint.Refereed = ds.DefaultRefereed
+	}
+	if eprint.EPrintStatus == "" && ds.DefaultStatus != "" {
+		eprint.EPrintStatus = ds.DefaultStatus
+	}
+	if eprint.Abstract != "" && ds.StripTags {
+		if cleaner.HasEncodedElements([]byte(eprint.Abstract)) {
+			eprint.Abstract = string(cleaner.StripTags([]byte(eprint.Abstract)))
 		}
-		return eprint.EPrintID, nil
 	}
-	return 0, err
 }
 
 // ImportEPrints take an repository id, eprints structure.
@@ -2952,61 +3215,356 @@ func SQLCreateEPrint(config *Config, repoID string, ds *DataSource, eprint *EPri
 // applly any rule sets and eprint status, SQLCreateEPRint is
 // responsible for datestamps and timestamps.
 //
-// ImportEPrints returns a list of EPrint IDs created
-// if successful and an error if something goes wrong.
-func ImportEPrints(config *Config, repoID string, ds *DataSource, eprints *EPrints) ([]int, error) {
-	var importErrors error
-	ids := []int{}
-
+// Each record is created in its own transaction. If allOrNothing
+// is true then the whole batch is written in a single transaction
+// and any failure rolls back every record.
+//
+// ImportEPrints returns a result for each EPrint (in the order
+// they were submitted) and an error if any record failed.
+func ImportEPrints(config *Config, repoID string, ds *DataSource, eprints *EPrints, allOrNothing bool) ([]*ImportResult, error) {
 	if config.Connections == nil {
 		return nil, fmt.Errorf(`no databases are not configured`)
 	}
-	_, ok := config.Connections[repoID]
+	db, ok := config.Connections[repoID]
 	if !ok {
 		return nil, fmt.Errorf(`%s database connection not configured`, repoID)
 	}
 
-	// Check to make sure updates are allowed if non-Zero
-	// eprint ids present.
-	for _, eprint := range eprints.EPrint {
+	results := make([]*ImportResult, len(eprints.EPrint))
+	failed := 0
+	for i, eprint := range eprints.EPrint {
+		results[i] = &ImportResult{Status: `failed`}
+		// Check to make sure we're not trying to update existing records
 		if eprint.EPrintID != 0 {
-			return nil, fmt.Errorf("create failed eprint id %d in %s", eprint.EPrintID, repoID)
+			results[i].Error = fmt.Sprintf("create failed eprint id %d in %s", eprint.EPrintID, repoID)
+			failed++
+			continue
 		}
-		if eprint.Collection == "" && ds.DefaultCollection != "" {
-			eprint.Collection = DefaultCollection
+		applyImportDefaults(ds, eprint)
+	}
+	if allOrNothing {
+		if failed > 0 {
+			for _, result := range results {
+				if result.Error == "" {
+					result.Status = `rolled back`
+				}
+			}
+			return results, fmt.Errorf("%d of %d records failed to import", failed, len(results))
 		}
-		if eprint.IDNumber == "" && ds.DefaultOfficialURL != "" {
-			eprint.IDNumber = GenerateIDNumber(eprint)
+		err := importEPrintsTx(db, repoID, ds, eprints, results)
+		if err != nil {
+			return results, err
 		}
-		if eprint.OfficialURL == "" && ds.DefaultOfficialURL != "" {
-			eprint.OfficialURL = GenerateOfficialURL(eprint)
+		return results, nil
+	}
+	for i, eprint := range eprints.EPrint {
+		if results[i].Error != "" {
+			continue
 		}
-		if eprint.Rights == "" && ds.DefaultRights != "" {
-			eprint.Rights = ds.DefaultRights
+		id, err := SQLCreateEPrint(config, repoID, ds, eprint)
+		if err != nil {
+			results[i].Error = err.Error()
+			failed++
+			continue
 		}
-		if eprint.Refereed == "" && eprint.Type == "article" &&
-			ds.DefaultRefereed != "" {
-			eprint.Refereed = ds.DefaultRefereed
+		results[i].EPrintID, results[i].Status = id, `created`
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d records failed to import", failed, len(results))
+	}
+	return results, nil
+}
+
+// importEPrintsTx creates all the eprints in a single transaction
+// populating results. If a record fails the transaction is rolled
+// back and the other records are marked "rolled back".
+func importEPrintsTx(db *sql.DB, repoID string, ds *DataSource, eprints *EPrints, results []*ImportResult) error {
+	var err error
+	for retry := 0; retry < maxIDRetries; retry++ {
+		var tx *sql.Tx
+		tx, err = db.Begin()
+		if err != nil {
+			return fmt.Errorf(`SQL error, failed to start transaction, %s`, err)
 		}
-		if eprint.EPrintStatus == "" && ds.DefaultStatus != "" {
-			eprint.EPrintStatus = ds.DefaultStatus
+		failedAt, commitFailed := -1, false
+		for i, eprint := range eprints.EPrint {
+			var id int
+			id, err = sqlCreateEPrint(tx, repoID, ds, eprint)
+			if err != nil {
+				failedAt = i
+				break
+			}
+			results[i].EPrintID, results[i].Status = id, `created`
 		}
-		if eprint.Abstract != "" && ds.StripTags {
-			if cleaner.HasEncodedElements([]byte(eprint.Abstract)) {
-				eprint.Abstract = string(cleaner.StripTags([]byte(eprint.Abstract)))
+		if failedAt < 0 {
+			if err = tx.Commit(); err == nil {
+				return nil
+			}
+			err = fmt.Errorf(`SQL error, failed to commit import, %s`, err)
+			commitFailed = true
+		} else {
+			tx.Rollback()
+		}
+		for i, eprint := range eprints.EPrint {
+			eprint.EPrintID = 0
+			results[i].EPrintID, results[i].Status, results[i].Error = 0, `rolled back`, ""
+			switch {
+			case commitFailed:
+				// NOTE: no single record failed, each reports why
+				// the batch was rolled back.
+				results[i].Error = err.Error()
+			case i == failedAt:
+				results[i].Status, results[i].Error = `failed`, err.Error()
 			}
 		}
+		if !isDuplicateKey(err) {
+			break
+		}
 	}
-	for _, eprint := range eprints.EPrint {
-		id, err := SQLCreateEPrint(config, repoID, ds, eprint)
+	return fmt.Errorf("import rolled back for %s, %s", repoID, err)
+}
+
+// setTimestampParts parses a timestamp (or datestamp) string and
+// returns the year, month, day, hour, minute and second parts.
+// If the string can't be parsed ok is false.
+func setTimestampParts(src string) (int, int, int, int, int, int, bool) {
+	if dt, err := time.Parse(timestamp, src); err == nil {
+		return dt.Year(), int(dt.Month()), dt.Day(), dt.Hour(), dt.Minute(), dt.Second(), true
+	}
+	if dt, err := time.Parse(datestamp, src); err == nil {
+		return dt.Year(), int(dt.Month()), dt.Day(), 0, 0, 0, true
+	}
+	return 0, 0, 0, 0, 0, 0, false
+}
+
+// SQLUpdateEPrint will replace an existing EPrint record. The eprint
+// row and the item list tables are replaced inside a transaction, the
+// rev_number is incremented, lastmod is set to now and a "modify"
+// history entry is recorded for userID. Returns an error if the eprint
+// does not exist, its eprint_status would change or the transaction
+// fails.
+//
+// NOTE: EPrints also keeps a copy of each revision in the eprint's
+// "revisions" directory on disk. SQLUpdateEPrint only updates the
+// database, the revision XML is not written.
+func SQLUpdateEPrint(config *Config, repoID string, ds *DataSource, userID int, eprint *EPrint) error {
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return fmt.Errorf(`no database connection for %s`, repoID)
+	}
+	if eprint.EPrintID <= 0 {
+		return fmt.Errorf(`update failed, missing eprint id in %s`, repoID)
+	}
+	columns, ok := ds.TableMap[`eprint`]
+	if !ok {
+		return fmt.Errorf(`update failed, eprint table not found in %s`, repoID)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf(`SQL error, failed to start transaction, %s`, err)
+	}
+	// rollback is used to return an error after aborting the transaction.
+	rollback := func(err error) error {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf(`%s, rollback failed %s`, err, rbErr)
+		}
+		return err
+	}
+
+	// Lock the existing row and retrieve the values we need to preserve.
+	stmt := `SELECT IFNULL(rev_number, 0), IFNULL(eprint_status, ''), IFNULL(dir, ''), IFNULL(userid, 0),
+IFNULL(datestamp_year, 0), IFNULL(datestamp_month, 0), IFNULL(datestamp_day, 0),
+IFNULL(datestamp_hour, 0), IFNULL(datestamp_minute, 0), IFNULL(datestamp_second, 0),
+IFNULL(status_changed_year, 0), IFNULL(status_changed_month, 0), IFNULL(status_changed_day, 0),
+IFNULL(status_changed_hour, 0), IFNULL(status_changed_minute, 0), IFNULL(status_changed_second, 0)
+FROM eprint WHERE eprintid = ? FOR UPDATE`
+	var (
+		revNumber, ownerID                     int
+		status, dir                            string
+		dYear, dMonth, dDay, dHour, dMin, dSec int
+		sYear, sMonth, sDay, sHour, sMin, sSec int
+	)
+	row := tx.QueryRow(stmt, eprint.EPrintID)
+	if err := row.Scan(&revNumber, &status, &dir, &ownerID,
+		&dYear, &dMonth, &dDay, &dHour, &dMin, &dSec,
+		&sYear, &sMonth, &sDay, &sHour, &sMin, &sSec); err != nil {
+		if err == sql.ErrNoRows {
+			return rollback(fmt.Errorf(`not found, eprint id %d in %s`, eprint.EPrintID, repoID))
+		}
+		return rollback(fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+	}
+	// The status is changed with SQLChangeEPrintStatus so the
+	// transition is validated and recorded.
+	if eprint.EPrintStatus != "" && eprint.EPrintStatus != status {
+		return rollback(fmt.Errorf(`status change not allowed, use eprint-status to move eprint id %d from %q to %q`, eprint.EPrintID, status, eprint.EPrintStatus))
+	}
+
+	now := time.Now()
+	eprint.RevNumber = revNumber + 1
+	if eprint.Dir == "" {
+		eprint.Dir = dir
+		if eprint.Dir == "" {
+			eprint.Dir = makeDirValue(eprint.EPrintID)
+		}
+	}
+	if eprint.UserID == 0 {
+		eprint.UserID = ownerID
+	}
+	if eprint.EPrintStatus == "" {
+		eprint.EPrintStatus = status
+	}
+	// The creation datestamp is preserved unless provided
+	if eprint.Datestamp == "" && dYear > 0 {
+		eprint.Datestamp = makeTimestamp(dYear, dMonth, dDay, dHour, dMin, dSec)
+	}
+	if y, m, d, h, mi, sec, ok := setTimestampParts(eprint.Datestamp); ok {
+		eprint.DatestampYear, eprint.DatestampMonth, eprint.DatestampDay = y, m, d
+		eprint.DatestampHour, eprint.DatestampMinute, eprint.DatestampSecond = h, mi, sec
+	}
+
+	eprint.LastModified = now.Format(timestamp)
+	eprint.LastModifiedYear = now.Year()
+	eprint.LastModifiedMonth = int(now.Month())
+	eprint.LastModifiedDay = now.Day()
+	eprint.LastModifiedHour = now.Hour()
+	eprint.LastModifiedMinute = now.Minute()
+	eprint.LastModifiedSecond = now.Second()
+
+	// status_changed is preserved unless it was never set
+	if sYear == 0 {
+		eprint.StatusChanged = now.Format(timestamp)
+	} else {
+		eprint.StatusChanged = makeTimestamp(sYear, sMonth, sDay, sHour, sMin, sSec)
+	}
+	if y, m, d, h, mi, sec, ok := setTimestampParts(eprint.StatusChanged); ok {
+		eprint.StatusChangedYear, eprint.StatusChangedMonth, eprint.StatusChangedDay = y, m, d
+		eprint.StatusChangedHour, eprint.StatusChangedMinute, eprint.StatusChangedSecond = h, mi, sec
+	}
+
+	if eprint.Date != "" {
+		eprint.DateYear, eprint.DateMonth, eprint.DateDay = approxYMD(eprint.Date)
+	}
+	if eprint.ThesisSubmittedDate != "" {
+		eprint.ThesisSubmittedDateYear, eprint.ThesisSubmittedDateMonth, eprint.ThesisSubmittedDateDay = approxYMD(eprint.ThesisSubmittedDate)
+	}
+	if eprint.ThesisDefenseDate != "" {
+		eprint.ThesisDefenseDateYear, eprint.ThesisDefenseDateMonth, eprint.ThesisDefenseDateDay = approxYMD(eprint.ThesisDefenseDate)
+	}
+	if eprint.ThesisApprovedDate != "" {
+		eprint.ThesisApprovedDateYear, eprint.ThesisApprovedDateMonth, eprint.ThesisApprovedDateDay = approxYMD(eprint.ThesisApprovedDate)
+	}
+	if eprint.ThesisPublicDate != "" {
+		eprint.ThesisPublicDateYear, eprint.ThesisPublicDateMonth, eprint.ThesisPublicDateDay = approxYMD(eprint.ThesisPublicDate)
+	}
+	if eprint.GradOfficeApprovalDate != "" {
+		eprint.GradOfficeApprovalDateYear, eprint.GradOfficeApprovalDateMonth, eprint.GradOfficeApprovalDateDay = approxYMD(eprint.GradOfficeApprovalDate)
+	}
+
+	// Replace the eprint row
+	columnsSQL, values := eprintToColumnsAndValues(eprint, columns, false)
+	stmt = fmt.Sprintf(`REPLACE INTO eprint (%s) VALUES (%s)`,
+		strings.Join(columnsSQL, `, `),
+		strings.Join(qmList(len(columnsSQL)), `, `))
+	if _, err := tx.Exec(stmt, values...); err != nil {
+		return rollback(fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+	}
+
+	// Replace the item lists
+	for tableName, columns := range ds.TableMap {
+		switch {
+		case tableName == `eprint`:
+			// Skip eprint table, we've already processed it
+		case tableName == `eprint_keyword`:
+			// Skip eprint_keyword, our EPrints use keywords (longtext) in eprint table.
+		case strings.HasPrefix(tableName, `document`):
+			// Skip, documents are not replaced by a metadata update
+		case strings.HasPrefix(tableName, `file`):
+			// Skip, files are not replaced by a metadata update
+		default:
+			if err := insertItemList(tx, repoID, tableName, columns, eprint); err != nil {
+				return rollback(fmt.Errorf(`failed to replace eprintid %d in table %s for %s, %s`, eprint.EPrintID, tableName, repoID, err))
+			}
+		}
+	}
+
+	// Record the change in the history table so EPrints' history view
+	// reflects the update.
+	if err := insertHistory(tx, userID, eprint.EPrintID, eprint.RevNumber, "modify", now); err != nil {
+		return rollback(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(`SQL error, failed to commit update for eprint id %d in %s, %s`, eprint.EPrintID, repoID, err)
+	}
+	return nil
+}
+
+// insertHistory adds a row to the history table for an eprint
+// revision, action is an EPrints history action (e.g. "modify").
+// The historyid is allocated with a locking read (see nextID) so
+// concurrent changes wait rather than claim the same id, if the id
+// is taken anyway the insert is retried.
+func insertHistory(tx *sql.Tx, userID int, eprintID int, revision int, action string, now time.Time) error {
+	stmt := `INSERT INTO history (historyid, userid, datasetid, objectid, revision,
+timestamp_year, timestamp_month, timestamp_day, timestamp_hour, timestamp_minute, timestamp_second,
+action) VALUES (?, ?, 'eprint', ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	var err error
+	for i := 0; i < maxIDRetries; i++ {
+		var historyID int
+		historyID, err = nextID(tx, `history`, `historyid`)
 		if err != nil {
-			if importErrors == nil {
-				importErrors = err
-			} else {
-				importErrors = fmt.Errorf("%s; %s", importErrors, err)
+			return err
+		}
+		_, err = tx.Exec(stmt, historyID, userID, eprintID, revision,
+			now.Year(), int(now.Month()), now.Day(), now.Hour(), now.Minute(), now.Second(), action)
+		if err == nil {
+			return nil
+		}
+		if !isDuplicateKey(err) {
+			return fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+		}
+	}
+	return fmt.Errorf(`failed to allocate a historyid for eprint id %d, %s`, eprintID, err)
+}
+
+// UpdateEPrints takes a repository id, data source, user id and
+// EPrints structure. Each EPrint must have an existing EPrint ID. It
+// uses SQLUpdateEPrint to replace each record, each record is
+// committed (or rolled back) on its own.
+//
+// UpdateEPrints returns a result for each EPrint (in the order they
+// were submitted) with the status "updated" or "failed" and an error
+// if any record failed.
+func UpdateEPrints(config *Config, repoID string, ds *DataSource, userID int, eprints *EPrints) ([]*ImportResult, error) {
+	if config.Connections == nil {
+		return nil, fmt.Errorf(`no databases are not configured`)
+	}
+	_, ok := config.Connections[repoID]
+	if !ok {
+		return nil, fmt.Errorf(`%s database connection not configured`, repoID)
+	}
+	results := make([]*ImportResult, len(eprints.EPrint))
+	failed := 0
+	for i, eprint := range eprints.EPrint {
+		results[i] = &ImportResult{EPrintID: eprint.EPrintID, Status: `failed`}
+		if eprint.EPrintID == 0 {
+			results[i].Error = fmt.Sprintf("update failed, missing eprint id in %s", repoID)
+			failed++
+			continue
+		}
+		if eprint.Abstract != "" && ds.StripTags {
+			if cleaner.HasEncodedElements([]byte(eprint.Abstract)) {
+				eprint.Abstract = string(cleaner.StripTags([]byte(eprint.Abstract)))
 			}
 		}
-		ids = append(ids, id)
+		if err := SQLUpdateEPrint(config, repoID, ds, userID, eprint); err != nil {
+			results[i].Error = err.Error()
+			failed++
+			continue
+		}
+		results[i].Status = `updated`
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d records failed to update", failed, len(results))
 	}
-	return ids, importErrors
+	return results, nil
 }
@@ -0,0 +1,416 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3sqlBatch.go implements reading many EPrint records at once.
+ * Rather than querying each table once per record the queries used
+ * by SQLReadEPrint are rewritten to select the rows for a batch of
+ * records (i.e. WHERE eprintid IN (...)) and the results are
+ * assembled in memory.
+ */
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxBatchKeys limits the number of keys in a single IN clause
+	maxBatchKeys = 1000
+)
+
+var (
+	// batchKeyRE matches the key condition in the statements used
+	// to read the tables related to an EPrint record.
+	batchKeyRE = regexp.MustCompile(`([A-Za-z_]+\.)?\b(eprintid|docid|objectid|userid) = \?`)
+)
+
+// sqlRows is satisfied by *sql.Rows and batchRows
+type sqlRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Close() error
+	Err() error
+}
+
+// sqlQueryer is used to read the tables related to an EPrint
+// record. It is satisfied by dbQueryer and batchQueryer.
+type sqlQueryer interface {
+	Query(query string, args ...interface{}) (sqlRows, error)
+}
+
+// dbQueryer queries the database directly
+type dbQueryer struct {
+	db *sql.DB
+}
+
+// Query runs the query against the database
+func (q *dbQueryer) Query(query string, args ...interface{}) (sqlRows, error) {
+	rows, err := q.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// batchQueryer answers the single key queries used by SQLReadEPrint
+// by running each distinct query once for all the keys in the batch
+// and caching the rows by key.
+type batchQueryer struct {
+	db *sql.DB
+	// keys maps a key column (e.g. eprintid, docid) to the keys in
+	// the batch
+	keys map[string][]interface{}
+	// keySet holds the keys as strings for membership checks
+	keySet map[string]map[string]bool
+	// cache maps a query to rows by key
+	cache map[string]map[string][][]interface{}
+	// passThrough holds queries that could not be batched
+	passThrough map[string]bool
+}
+
+// newBatchQueryer returns a batchQueryer for db
+func newBatchQueryer(db *sql.DB) *batchQueryer {
+	return &batchQueryer{
+		db:          db,
+		keys:        map[string][]interface{}{},
+		keySet:      map[string]map[string]bool{},
+		cache:       map[string]map[string][][]interface{}{},
+		passThrough: map[string]bool{},
+	}
+}
+
+// setKeys sets the batch keys for a key column
+func (q *batchQueryer) setKeys(column string, keys []interface{}) {
+	q.keys[column] = []interface{}{}
+	q.keySet[column] = map[string]bool{}
+	for _, key := range keys {
+		s := keyString(key)
+		if !q.keySet[column][s] {
+			q.keySet[column][s] = true
+			q.keys[column] = append(q.keys[column], key)
+		}
+	}
+}
+
+// keyString normalizes a key value so keys from query arguments
+// and scanned rows compare equal.
+func keyString(key interface{}) string {
+	switch k := key.(type) {
+	case []byte:
+		return string(k)
+	case string:
+		return k
+	case int:
+		return strconv.Itoa(k)
+	case int64:
+		return strconv.FormatInt(k, 10)
+	}
+	return fmt.Sprintf("%v", key)
+}
+
+// batchStatement rewrites a single key query into one for n keys.
+// It returns the rewritten query and the key column. The key is
+// selected as the first column of the rewritten query. If the query
+// can't be batched ok is false.
+func batchStatement(stmt string, n int) (string, string, bool) {
+	if strings.Count(stmt, "?") != 1 {
+		return "", "", false
+	}
+	m := batchKeyRE.FindStringSubmatchIndex(stmt)
+	if m == nil {
+		return "", "", false
+	}
+	column := stmt[m[4]:m[5]]
+	expr := stmt[m[0]:m[5]]
+	i := strings.Index(stmt, "SELECT ")
+	if i < 0 || i > m[0] {
+		return "", "", false
+	}
+	i += len("SELECT ")
+	src := stmt[0:i] + expr + " AS batch_key, " + stmt[i:m[0]] + expr + " IN (" + strings.Join(qmList(n), ", ") + ")" + stmt[m[1]:]
+	return strings.TrimSuffix(strings.TrimSpace(src), " LIMIT 1"), column, true
+}
+
+// load runs stmt for all the keys of column caching the rows by key
+func (q *batchQueryer) load(stmt string, column string) error {
+	cache := map[string][][]interface{}{}
+	keys := q.keys[column]
+	for start := 0; start < len(keys); start += maxBatchKeys {
+		end := start + maxBatchKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batchStmt, _, _ := batchStatement(stmt, end-start)
+		rows, err := q.db.Query(batchStmt, keys[start:end]...)
+		if err != nil {
+			return err
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		for rows.Next() {
+			values := make([]interface{}, len(columns))
+			ptrs := make([]interface{}, len(columns))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				return err
+			}
+			key := keyString(values[0])
+			cache[key] = append(cache[key], values[1:])
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	q.cache[stmt] = cache
+	return nil
+}
+
+// Query returns the cached rows for the key in args. Queries which
+// can't be batched are passed through to the database.
+func (q *batchQueryer) Query(stmt string, args ...interface{}) (sqlRows, error) {
+	if len(args) != 1 || q.passThrough[stmt] {
+		return (&dbQueryer{q.db}).Query(stmt, args...)
+	}
+	cache, ok := q.cache[stmt]
+	if !ok {
+		_, column, ok := batchStatement(stmt, 1)
+		if !ok || !q.keySet[column][keyString(args[0])] {
+			return (&dbQueryer{q.db}).Query(stmt, args...)
+		}
+		if err := q.load(stmt, column); err != nil {
+			// NOTE: Fallback to querying each key
+			q.passThrough[stmt] = true
+			return (&dbQueryer{q.db}).Query(stmt, args...)
+		}
+		cache = q.cache[stmt]
+	} else {
+		_, column, _ := batchStatement(stmt, 1)
+		if !q.keySet[column][keyString(args[0])] {
+			return (&dbQueryer{q.db}).Query(stmt, args...)
+		}
+	}
+	return &batchRows{rows: cache[keyString(args[0])], i: -1}, nil
+}
+
+// batchRows iterates over cached rows like *sql.Rows
+type batchRows struct {
+	rows [][]interface{}
+	i    int
+}
+
+// Next advances to the next row
+func (r *batchRows) Next() bool {
+	r.i++
+	return r.i < len(r.rows)
+}
+
+// Scan copies the columns of the current row into dest
+func (r *batchRows) Scan(dest ...interface{}) error {
+	if r.i < 0 || r.i >= len(r.rows) {
+		return fmt.Errorf("sql: Scan called without calling Next")
+	}
+	row := r.rows[r.i]
+	if len(dest) != len(row) {
+		return fmt.Errorf("sql: expected %d destination arguments in Scan, not %d", len(row), len(dest))
+	}
+	for i, val := range row {
+		if err := assignValue(dest[i], val); err != nil {
+			return fmt.Errorf("sql: Scan error on column index %d, %s", i, err)
+		}
+	}
+	return nil
+}
+
+// Close is a no-op, the rows are in memory
+func (r *batchRows) Close() error {
+	return nil
+}
+
+// Err always returns nil, errors are returned by Query
+func (r *batchRows) Err() error {
+	return nil
+}
+
+// assignValue copies a value scanned by database/sql into one of
+// the destination types used by the EPrint scans.
+func assignValue(dest interface{}, src interface{}) error {
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+	if d, ok := dest.(*interface{}); ok {
+		*d = src
+		return nil
+	}
+	var s string
+	switch v := src.(type) {
+	case nil:
+		if d, ok := dest.(*string); ok {
+			*d = ""
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %T is unsupported", dest)
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case time.Time:
+		s = v.Format(MySQLTimestamp)
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+	switch d := dest.(type) {
+	case *string:
+		*d = s
+	case *[]byte:
+		*d = []byte(s)
+	case *int:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("converting %q to int, %s", s, err)
+		}
+		*d = int(i)
+	case *int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("converting %q to int64, %s", s, err)
+		}
+		*d = i
+	case *float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("converting %q to float64, %s", s, err)
+		}
+		*d = f
+	case *bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("converting %q to bool, %s", s, err)
+		}
+		*d = b
+	default:
+		return fmt.Errorf("unsupported Scan, storing %T into %T", src, dest)
+	}
+	return nil
+}
+
+// SQLReadEPrints reads the EPrint records for ids. Each table is
+// queried once per batch of ids rather than once per record as in
+// SQLReadEPrint. Records are returned in the order of ids, ids not
+// found in the repository are skipped. The rows for all the ids are
+// held in memory while the records are assembled so large lists of
+// ids should be read in batches (e.g. of a few hundred ids).
+func SQLReadEPrints(config *Config, repoID string, baseURL string, ids []int) ([]*EPrint, error) {
+	ds, ok := config.Repositories[repoID]
+	if !ok {
+		return nil, fmt.Errorf("not found, %q not defined", repoID)
+	}
+	tables := ds.TableMap
+	columns, ok := tables["eprint"]
+	if !ok {
+		return nil, fmt.Errorf("not found, %q eprint table not defined", repoID)
+	}
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return nil, fmt.Errorf("no database connection for %s", repoID)
+	}
+	eprints := []*EPrint{}
+	keys := []interface{}{}
+	for _, id := range ids {
+		if id > 0 {
+			keys = append(keys, id)
+		}
+	}
+	if len(keys) == 0 {
+		return eprints, nil
+	}
+	q := newBatchQueryer(db)
+	q.setKeys("eprintid", keys)
+
+	columnSQL, _ := eprintToColumnsAndValues(new(EPrint), columns, true)
+	stmt := fmt.Sprintf(`SELECT %s FROM eprint WHERE eprintid = ?`, strings.Join(columnSQL, `, `))
+	if err := q.load(stmt, "eprintid"); err != nil {
+		return nil, fmt.Errorf(`ERROR: query error (%q, %q), %s`, repoID, stmt, err)
+	}
+	userIDs := []interface{}{}
+	for _, key := range keys {
+		eprintID := key.(int)
+		eprint := new(EPrint)
+		eprint.EPrintID = eprintID
+		_, values := eprintToColumnsAndValues(eprint, columns, true)
+		rows, err := q.Query(stmt, eprintID)
+		if err != nil {
+			return nil, fmt.Errorf(`ERROR: query error (%q, %q), %s`, repoID, stmt, err)
+		}
+		cnt := 0
+		for rows.Next() {
+			if err := rows.Scan(values...); err != nil {
+				return nil, fmt.Errorf(`%s.eprint eprintid = %d, %s`, repoID, eprintID, err)
+			}
+			cnt++
+		}
+		rows.Close()
+		if cnt > 0 {
+			normalizeEPrintColumns(baseURL, eprint)
+			eprints = append(eprints, eprint)
+			if eprint.UserID > 0 {
+				userIDs = append(userIDs, eprint.UserID)
+			}
+		}
+	}
+
+	// NOTE: Documents, files and depositors are keyed by docid,
+	// objectid and userid, collect those keys so they are also read
+	// once for the batch.
+	q.setKeys("userid", userIDs)
+	if _, ok := tables["document"]; ok {
+		docIDs := []interface{}{}
+		for start := 0; start < len(keys); start += maxBatchKeys {
+			end := start + maxBatchKeys
+			if end > len(keys) {
+				end = len(keys)
+			}
+			stmt := fmt.Sprintf(`SELECT docid FROM document WHERE eprintid IN (%s)`, strings.Join(qmList(end-start), ", "))
+			ids, err := sqlQueryIntIDs(config, repoID, stmt, keys[start:end]...)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				docIDs = append(docIDs, id)
+			}
+		}
+		q.setKeys("docid", docIDs)
+		q.setKeys("objectid", docIDs)
+	}
+	for _, eprint := range eprints {
+		readEPrintSideTables(repoID, baseURL, eprint, q, tables)
+	}
+	return eprints, nil
+}
@@ -0,0 +1,163 @@
+package eprinttools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBatchStatement(t *testing.T) {
+	for stmt, expected := range map[string]string{
+		`SELECT pos, IFNULL(title, "") AS title FROM eprint_title WHERE eprintid = ? ORDER BY eprintid, pos`: `SELECT eprintid AS batch_key, pos, IFNULL(title, "") AS title FROM eprint_title WHERE eprintid IN (?, ?, ?) ORDER BY eprintid, pos`,
+		`SELECT pos, relation_type FROM document_relation_type WHERE (document_relation_type.docid = ?)`:     `SELECT document_relation_type.docid AS batch_key, pos, relation_type FROM document_relation_type WHERE (document_relation_type.docid IN (?, ?, ?))`,
+		`SELECT name_given FROM user WHERE userid = ? LIMIT 1`:                                               `SELECT userid AS batch_key, name_given FROM user WHERE userid IN (?, ?, ?)`,
+	} {
+		got, _, ok := batchStatement(stmt, 3)
+		if !ok {
+			t.Errorf("expected to batch %q", stmt)
+			continue
+		}
+		assertStringSame(t, stmt, expected, got)
+	}
+	for _, stmt := range []string{
+		`SELECT eprintid FROM eprint`,
+		`SELECT eprintid FROM eprint WHERE eprintid = ? AND userid = ?`,
+		`SELECT eprintid FROM eprint WHERE doi = ?`,
+	} {
+		if _, _, ok := batchStatement(stmt, 3); ok {
+			t.Errorf("expected %q not to be batched", stmt)
+		}
+	}
+}
+
+func TestBatchRows(t *testing.T) {
+	rows := &batchRows{rows: [][]interface{}{
+		{int64(1), []byte("Lemurs"), nil, []byte("2.5"), []byte("1")},
+		{int64(2), "Tails", []byte("x"), float64(3), int64(0)},
+	}, i: -1}
+	var (
+		pos   int
+		title string
+		note  string
+		score float64
+		flag  bool
+	)
+	cnt := 0
+	for rows.Next() {
+		if err := rows.Scan(&pos, &title, &note, &score, &flag); err != nil {
+			t.Fatalf("row %d, %s", cnt, err)
+		}
+		cnt++
+	}
+	assertIntSame(t, "rows", 2, cnt)
+	assertIntSame(t, "pos", 2, pos)
+	assertStringSame(t, "title", "Tails", title)
+	assertStringSame(t, "note", "x", note)
+	assertFloat64Same(t, "score", 3, score)
+	assertBoolSame(t, "flag", false, flag)
+
+	rows = &batchRows{rows: [][]interface{}{{nil}}, i: -1}
+	rows.Next()
+	if err := rows.Scan(&pos); err == nil {
+		t.Errorf("expected error scanning NULL into int")
+	}
+	if err := rows.Scan(&pos, &title); err == nil {
+		t.Errorf("expected error for wrong number of columns")
+	}
+}
+
+func TestSQLReadEPrints(t *testing.T) {
+	fName := `test-settings.json`
+	repoID := `lemurprints`
+	config, err := LoadConfig(fName)
+	if err != nil {
+		t.Skipf("Cailed to reload %q, %s", fName, err)
+		t.SkipNow()
+	}
+	ds, ok := config.Repositories[repoID]
+	if ds == nil || ok == false {
+		t.Skipf(`%s not available for testing`, repoID)
+		t.SkipNow()
+	}
+	baseURL := ds.BaseURL
+	assertOpenConnection(t, config, repoID)
+	defer assertCloseConnection(t, config, repoID)
+
+	ids, err := GetAllEPrintIDs(config, repoID)
+	if err != nil {
+		t.Fatalf("GetAllEPrintIDs(config, %q) failed, %s", repoID, err)
+	}
+	if len(ids) > 50 {
+		ids = ids[0:50]
+	}
+	// Include an impossible record, it should be skipped.
+	eprints, err := SQLReadEPrints(config, repoID, baseURL, append(ids, 123456790))
+	if err != nil {
+		t.Fatalf("SQLReadEPrints(config, %q, %q, ids) failed, %s", repoID, baseURL, err)
+	}
+	assertIntSame(t, "number of eprints", len(ids), len(eprints))
+	for i, eprint := range eprints {
+		expected, err := SQLReadEPrint(config, repoID, baseURL, ids[i])
+		if err != nil {
+			t.Errorf("SQLReadEPrint(config, %q, %q, %d) failed, %s", repoID, baseURL, ids[i], err)
+			continue
+		}
+		src1, _ := json.MarshalIndent(expected, "", "  ")
+		src2, _ := json.MarshalIndent(eprint, "", "  ")
+		if string(src1) != string(src2) {
+			t.Errorf("eprint %d, batch read differs\n%s\n%s", ids[i], src1, src2)
+		}
+	}
+}
+
+// benchmarkSetup returns a config with open connections and up to
+// n eprint ids for repoID. It skips the benchmark if the test
+// repository isn't available.
+func benchmarkSetup(b *testing.B, repoID string, n int) (*Config, string, []int) {
+	fName := `test-settings.json`
+	config, err := LoadConfig(fName)
+	if err != nil {
+		b.Skipf("Cailed to reload %q, %s", fName, err)
+	}
+	ds, ok := config.Repositories[repoID]
+	if ds == nil || ok == false {
+		b.Skipf(`%s not available for testing`, repoID)
+	}
+	if err := OpenConnections(config); err != nil {
+		b.Skipf(`could not open connections, %s`, err)
+	}
+	ids, err := GetAllEPrintIDs(config, repoID)
+	if err != nil || len(ids) == 0 {
+		b.Skipf(`no eprint ids available for %s, %v`, repoID, err)
+	}
+	if len(ids) > n {
+		ids = ids[0:n]
+	}
+	return config, ds.BaseURL, ids
+}
+
+func BenchmarkSQLReadEPrint(b *testing.B) {
+	repoID := `lemurprints`
+	config, baseURL, ids := benchmarkSetup(b, repoID, 100)
+	defer CloseConnections(config)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			if _, err := SQLReadEPrint(config, repoID, baseURL, id); err != nil && !strings.Contains(err.Error(), "not found") {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSQLReadEPrints(b *testing.B) {
+	repoID := `lemurprints`
+	config, baseURL, ids := benchmarkSetup(b, repoID, 100)
+	defer CloseConnections(config)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := SQLReadEPrints(config, repoID, baseURL, ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
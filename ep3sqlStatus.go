@@ -0,0 +1,201 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3sqlStatus.go implements moving EPrints between the inbox,
+ * buffer, archive and deletion datasets (i.e. eprint_status) using
+ * SQL. The transitions follow those offered by the EPrints UI.
+ */
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// StatusTransitions maps an eprint_status to the statuses an
+	// EPrint can be moved to from it
+	StatusTransitions = map[string][]string{
+		"inbox":    {"buffer", "archive"},
+		"buffer":   {"inbox", "archive"},
+		"archive":  {"buffer", "deletion"},
+		"deletion": {"archive"},
+	}
+)
+
+// StatusChange reports the outcome of moving an EPrint
+type StatusChange struct {
+	EPrintID int    `json:"eprint_id" xml:"eprint_id"`
+	From     string `json:"from,omitempty" xml:"from,omitempty"`
+	To       string `json:"to" xml:"to"`
+	// Status is "changed" or "failed"
+	Status string `json:"status" xml:"status"`
+	// Error holds the reason a change failed
+	Error string `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// StatusAssignment names who an EPrint is assigned to when it is
+// moved to the buffer. UserID is written to the eprint's userid
+// column and Reviewer to the reviewer column.
+type StatusAssignment struct {
+	UserID   int    `json:"userid,omitempty" xml:"userid,omitempty"`
+	Reviewer string `json:"reviewer,omitempty" xml:"reviewer,omitempty"`
+}
+
+// statusAssignments returns the column assignments and values
+// setting assign when moving an EPrint to status. It is an error to
+// assign an EPrint unless moving it to the buffer or if the
+// repository doesn't have the column to hold the assignment.
+func statusAssignments(ds *DataSource, status string, assign *StatusAssignment) ([]string, []interface{}, error) {
+	assignments, values := []string{}, []interface{}{}
+	if assign == nil || (assign.UserID == 0 && assign.Reviewer == ``) {
+		return assignments, values, nil
+	}
+	if status != `buffer` {
+		return nil, nil, fmt.Errorf(`assignment not allowed, EPrints are only assigned when moved to the buffer`)
+	}
+	var tableMap map[string][]string
+	if ds != nil {
+		tableMap = ds.TableMap
+	}
+	if assign.UserID != 0 {
+		if !hasColumn(tableMap, `eprint`, `userid`) {
+			return nil, nil, fmt.Errorf(`assignment not allowed, eprint table has no userid column`)
+		}
+		assignments = append(assignments, `userid = ?`)
+		values = append(values, assign.UserID)
+	}
+	if assign.Reviewer != `` {
+		if !hasColumn(tableMap, `eprint`, `reviewer`) {
+			return nil, nil, fmt.Errorf(`assignment not allowed, eprint table has no reviewer column`)
+		}
+		assignments = append(assignments, `reviewer = ?`)
+		values = append(values, assign.Reviewer)
+	}
+	return assignments, values, nil
+}
+
+// ValidateStatusTransition returns an error if an EPrint can't be
+// moved from one eprint_status to another.
+func ValidateStatusTransition(from string, to string) error {
+	if _, ok := StatusTransitions[to]; !ok {
+		statuses := []string{}
+		for status := range StatusTransitions {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		return fmt.Errorf("status %q not valid, expected %s", to, strings.Join(statuses, ", "))
+	}
+	if !slices.Contains(StatusTransitions[from], to) {
+		return fmt.Errorf("transition not allowed, %q to %q", from, to)
+	}
+	return nil
+}
+
+// SQLChangeEPrintStatus moves an EPrint to status. The transition
+// is validated, status_changed and lastmod are set to now, the
+// rev_number is incremented and a history entry (e.g.
+// "move_buffer_to_archive") is recorded for userID. When moving to
+// the buffer assign (if not nil) sets the userid and reviewer of the
+// EPrint. Returns the previous status or an error.
+func SQLChangeEPrintStatus(config *Config, repoID string, ds *DataSource, userID int, eprintID int, status string, assign *StatusAssignment) (string, error) {
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return "", fmt.Errorf(`no database connection for %s`, repoID)
+	}
+	assignColumns, assignValues, err := statusAssignments(ds, status, assign)
+	if err != nil {
+		return "", err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return "", fmt.Errorf(`SQL error, failed to start transaction, %s`, err)
+	}
+	// rollback is used to return an error after aborting the transaction.
+	rollback := func(from string, err error) (string, error) {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return from, fmt.Errorf(`%s, rollback failed %s`, err, rbErr)
+		}
+		return from, err
+	}
+	stmt := `SELECT IFNULL(eprint_status, ''), IFNULL(rev_number, 0) FROM eprint WHERE eprintid = ? FOR UPDATE`
+	var (
+		from      string
+		revNumber int
+	)
+	if err := tx.QueryRow(stmt, eprintID).Scan(&from, &revNumber); err != nil {
+		if err == sql.ErrNoRows {
+			return rollback("", fmt.Errorf(`not found, eprint id %d in %s`, eprintID, repoID))
+		}
+		return rollback("", fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+	}
+	if err := ValidateStatusTransition(from, status); err != nil {
+		return rollback(from, err)
+	}
+	now := time.Now()
+	revNumber++
+	assignments := []string{`eprint_status = ?`, `rev_number = ?`}
+	values := []interface{}{status, revNumber}
+	for _, field := range []string{`status_changed`, `lastmod`} {
+		for _, part := range []string{`year`, `month`, `day`, `hour`, `minute`, `second`} {
+			assignments = append(assignments, fmt.Sprintf(`%s_%s = ?`, field, part))
+		}
+		values = append(values, now.Year(), int(now.Month()), now.Day(), now.Hour(), now.Minute(), now.Second())
+	}
+	assignments = append(assignments, assignColumns...)
+	values = append(values, assignValues...)
+	stmt = fmt.Sprintf(`UPDATE eprint SET %s WHERE eprintid = ?`, strings.Join(assignments, `, `))
+	if _, err := tx.Exec(stmt, append(values, eprintID)...); err != nil {
+		return rollback(from, fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+	}
+	if err := insertHistory(tx, userID, eprintID, revNumber, fmt.Sprintf(`move_%s_to_%s`, from, status), now); err != nil {
+		return rollback(from, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return from, fmt.Errorf(`SQL error, failed to commit status change for eprint id %d in %s, %s`, eprintID, repoID, err)
+	}
+	return from, nil
+}
+
+// ChangeEPrintStatus moves a list of EPrints to status using
+// SQLChangeEPrintStatus. Each EPrint is moved in its own transaction
+// so one failure doesn't stop the others. It returns a StatusChange
+// for each EPrint and an error combining any failures.
+func ChangeEPrintStatus(config *Config, repoID string, ds *DataSource, userID int, eprintIDs []int, status string, assign *StatusAssignment) ([]*StatusChange, error) {
+	var changeErrors error
+	changes := []*StatusChange{}
+	for _, eprintID := range eprintIDs {
+		change := &StatusChange{EPrintID: eprintID, To: status, Status: "changed"}
+		from, err := SQLChangeEPrintStatus(config, repoID, ds, userID, eprintID, status, assign)
+		change.From = from
+		if err != nil {
+			change.Status, change.Error = "failed", err.Error()
+			if changeErrors == nil {
+				changeErrors = err
+			} else {
+				changeErrors = fmt.Errorf("%s; %s", changeErrors, err)
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, changeErrors
+}
@@ -0,0 +1,182 @@
+package eprinttools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateStatusTransition(t *testing.T) {
+	for _, test := range []struct {
+		from, to string
+		ok       bool
+	}{
+		{"inbox", "buffer", true},
+		{"inbox", "archive", true},
+		{"buffer", "archive", true},
+		{"buffer", "inbox", true},
+		{"archive", "buffer", true},
+		{"archive", "deletion", true},
+		{"deletion", "archive", true},
+		{"inbox", "deletion", false},
+		{"buffer", "deletion", false},
+		{"deletion", "inbox", false},
+		{"archive", "archive", false},
+		{"", "archive", false},
+		{"archive", "published", false},
+	} {
+		err := ValidateStatusTransition(test.from, test.to)
+		if test.ok && err != nil {
+			t.Errorf("%s to %s, unexpected error %s", test.from, test.to, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s to %s, expected an error", test.from, test.to)
+		}
+	}
+	if err := ValidateStatusTransition("archive", "published"); err == nil || !strings.Contains(err.Error(), "not valid") {
+		t.Errorf("expected an invalid status error, got %v", err)
+	}
+}
+
+func TestStatusAssignments(t *testing.T) {
+	ds := &DataSource{
+		TableMap: map[string][]string{
+			"eprint": {"eprintid", "eprint_status", "userid", "reviewer"},
+		},
+	}
+	// Assigning to the buffer writes userid and reviewer
+	assignments, values, err := statusAssignments(ds, `buffer`, &StatusAssignment{UserID: 7, Reviewer: `jane`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStringSame(t, `assignments`, `userid = ?, reviewer = ?`, strings.Join(assignments, `, `))
+	if len(values) != 2 || values[0] != 7 || values[1] != `jane` {
+		t.Errorf("unexpected values %v", values)
+	}
+	assignments, _, err = statusAssignments(ds, `buffer`, &StatusAssignment{UserID: 7})
+	if err != nil || len(assignments) != 1 || assignments[0] != `userid = ?` {
+		t.Errorf("expected only userid to be assigned, got %v, %v", assignments, err)
+	}
+	// Nothing to assign
+	for _, assign := range []*StatusAssignment{nil, new(StatusAssignment)} {
+		if assignments, _, err := statusAssignments(ds, `archive`, assign); err != nil || len(assignments) != 0 {
+			t.Errorf("expected no assignments, got %v, %v", assignments, err)
+		}
+	}
+	// Assignments are only made when moving to the buffer
+	if _, _, err := statusAssignments(ds, `archive`, &StatusAssignment{UserID: 7}); err == nil {
+		t.Errorf("expected an error assigning an EPrint moved to the archive")
+	}
+	// A missing column is an error rather than being skipped
+	ds.TableMap["eprint"] = []string{"eprintid", "eprint_status", "userid"}
+	if _, _, err := statusAssignments(ds, `buffer`, &StatusAssignment{UserID: 7, Reviewer: `jane`}); err == nil || !strings.Contains(err.Error(), "reviewer") {
+		t.Errorf("expected a missing reviewer column error, got %v", err)
+	}
+	ds.TableMap["eprint"] = []string{"eprintid", "eprint_status", "reviewer"}
+	if _, _, err := statusAssignments(ds, `buffer`, &StatusAssignment{UserID: 7}); err == nil || !strings.Contains(err.Error(), "userid") {
+		t.Errorf("expected a missing userid column error, got %v", err)
+	}
+}
+
+func TestUnpackageStatusChange(t *testing.T) {
+	api := metricsTestEP3API()
+	request := func(src string) *http.Request {
+		return httptest.NewRequest("POST", "/lemurprints/eprint-status/1/buffer", strings.NewReader(src))
+	}
+	eprintIDs, assign, err := api.unpackageStatusChange(request(``), []string{"3"})
+	if err != nil || len(eprintIDs) != 1 || eprintIDs[0] != 3 || assign != nil {
+		t.Errorf("expected eprint id 3 without an assignment, got %v, %+v, %v", eprintIDs, assign, err)
+	}
+	eprintIDs, _, err = api.unpackageStatusChange(request(`[3, 4]`), nil)
+	if err != nil || len(eprintIDs) != 2 {
+		t.Errorf("expected a batch of two, got %v, %v", eprintIDs, err)
+	}
+	eprintIDs, assign, err = api.unpackageStatusChange(request(`{"eprint_ids": [3, 4], "userid": 7, "reviewer": "jane"}`), nil)
+	if err != nil || len(eprintIDs) != 2 || assign == nil || assign.UserID != 7 || assign.Reviewer != `jane` {
+		t.Errorf("expected a batch assigned to 7 (jane), got %v, %+v, %v", eprintIDs, assign, err)
+	}
+	eprintIDs, assign, err = api.unpackageStatusChange(request(`{"userid": 7}`), []string{"3"})
+	if err != nil || len(eprintIDs) != 1 || assign == nil || assign.UserID != 7 {
+		t.Errorf("expected eprint id 3 assigned to 7, got %v, %+v, %v", eprintIDs, assign, err)
+	}
+	for _, src := range []string{`{"eprint_ids": [3], "assignee": 7}`, `{"reviewer": "jane"}`, `[]`, `"3"`} {
+		if _, _, err := api.unpackageStatusChange(request(src), nil); err == nil {
+			t.Errorf("%s, expected an error", src)
+		}
+	}
+	if _, _, err := api.unpackageStatusChange(request(`[4]`), []string{"3"}); err == nil {
+		t.Errorf("expected an error for eprint ids in the path and body")
+	}
+
+	// The end point rejects an assignment the repository can't hold
+	api.Config.Repositories["lemurprints"].Write = true
+	w := httptest.NewRecorder()
+	r := request(`{"userid": 7, "reviewer": "jane"}`)
+	if status, err := api.eprintStatusEndPoint(w, r, "lemurprints", []string{"1", "buffer", "3"}); status != 400 || err == nil {
+		t.Errorf("expected 400 for missing userid and reviewer columns, got %d %v", status, err)
+	}
+}
+
+func TestSQLChangeEPrintStatus(t *testing.T) {
+	fName := `test-settings.json`
+	repoID := `lemurprints`
+	config, err := LoadConfig(fName)
+	if err != nil {
+		t.Skipf("Failed to reload %q, %s", fName, err)
+	}
+	ds, ok := config.Repositories[repoID]
+	if ds == nil || ok == false || ds.Write == false {
+		t.Skipf(`%s not available for testing`, repoID)
+		t.SkipNow()
+	}
+	assertOpenConnection(t, config, repoID)
+	defer assertCloseConnection(t, config, repoID)
+
+	userID := os.Getuid()
+	eprint := new(EPrint)
+	eprint.Title = `TestSQLChangeEPrintStatus()`
+	eprint.EPrintStatus = `inbox`
+	eprint.UserID = userID
+	eprint.Type = `article`
+	id, err := SQLCreateEPrint(config, repoID, ds, eprint)
+	if err != nil || id == 0 {
+		t.Errorf("%s, failed to create test eprint, %s", repoID, err)
+		t.FailNow()
+	}
+	for _, status := range []string{`buffer`, `archive`, `deletion`} {
+		original, err := SQLReadEPrintVersion(config, repoID, id)
+		if err != nil {
+			t.Fatalf("%s, %s", repoID, err)
+		}
+		var assign *StatusAssignment
+		if status == `buffer` {
+			assign = &StatusAssignment{UserID: userID}
+			if hasColumn(ds.TableMap, `eprint`, `reviewer`) {
+				assign.Reviewer = `TestSQLChangeEPrintStatus`
+			}
+		}
+		from, err := SQLChangeEPrintStatus(config, repoID, ds, userID, id, status, assign)
+		if err != nil {
+			t.Fatalf("%s, %s to %s failed, %s", repoID, from, status, err)
+		}
+		assertStringSame(t, `from`, original.EPrintStatus, from)
+		updated, err := SQLReadEPrintVersion(config, repoID, id)
+		if err != nil {
+			t.Fatalf("%s, %s", repoID, err)
+		}
+		assertStringSame(t, `EPrintStatus`, status, updated.EPrintStatus)
+		assertIntSame(t, `RevNumber`, original.RevNumber+1, updated.RevNumber)
+	}
+	// deletion can't go back to the inbox
+	if from, err := SQLChangeEPrintStatus(config, repoID, ds, userID, id, `inbox`, nil); err == nil {
+		t.Errorf("expected %s to inbox to fail", from)
+	}
+	changes, err := ChangeEPrintStatus(config, repoID, ds, userID, []int{id, 123456790}, `archive`, nil)
+	if err == nil || len(changes) != 2 {
+		t.Fatalf("expected a failure for the missing eprint, got %v, %+v", err, changes)
+	}
+	assertStringSame(t, `changes[0].Status`, `changed`, changes[0].Status)
+	assertStringSame(t, `changes[1].Status`, `failed`, changes[1].Status)
+}
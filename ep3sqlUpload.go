@@ -0,0 +1,414 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3sqlUpload.go implements attaching documents and files to an
+ * existing EPrint. The files are written into the eprint's directory
+ * in the repository's archive and the document and file rows are
+ * created with SQL, the EPrints Perl API isn't used.
+ */
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// DocumentSecurity holds the security values EPrints accepts
+	// for a document
+	DocumentSecurity = []string{"public", "validuser", "staffonly"}
+
+	// MaxUploadSize is the largest request accepted by the
+	// document-upload end point (1 GiB)
+	MaxUploadSize int64 = 1 << 30
+)
+
+// UploadFile is a file to be attached to a new document
+type UploadFile struct {
+	// Filename is the name the file is stored under, it must not
+	// include a path.
+	Filename string
+	// MimeType is optional, when empty it is taken from the file
+	// extension or the content.
+	MimeType string
+	// Reader holds the file's content
+	Reader io.Reader
+}
+
+// storedFile describes a file written by writeDocumentFile
+type storedFile struct {
+	Name     string
+	Path     string
+	Size     int64
+	MimeType string
+	Hash     string
+	HashType string
+}
+
+// newHash returns the hash for a EPrints hash_type, "MD5" (the
+// default), "SHA-1" or "SHA-256".
+func newHash(hashType string) (hash.Hash, string, error) {
+	switch strings.ToUpper(hashType) {
+	case "", "MD5":
+		return md5.New(), "MD5", nil
+	case "SHA-1", "SHA1", "SHA":
+		return sha1.New(), "SHA-1", nil
+	case "SHA-256", "SHA256":
+		return sha256.New(), "SHA-256", nil
+	}
+	return nil, "", fmt.Errorf("hash type %q not supported", hashType)
+}
+
+// uploadFilename checks a file name is safe to write in the
+// document directory and returns it without any leading path.
+func uploadFilename(name string) (string, error) {
+	// NOTE: browsers on Windows may send the full path
+	name = path.Base(strings.ReplaceAll(name, `\`, `/`))
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || name == "/" || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("file name %q not valid", name)
+	}
+	return name, nil
+}
+
+// documentDir returns the directory of the document at pos for
+// an eprint dir (e.g. "disk0/00/00/12/34") below archivePath.
+func documentDir(archivePath string, dir string, pos int) (string, error) {
+	if archivePath == "" {
+		return "", fmt.Errorf("archive path not configured")
+	}
+	dir = filepath.Clean(filepath.FromSlash(dir))
+	if dir == "." || filepath.IsAbs(dir) || strings.HasPrefix(dir, "..") {
+		return "", fmt.Errorf("eprint directory %q not valid", dir)
+	}
+	return filepath.Join(archivePath, dir, fmt.Sprintf("%02d", pos)), nil
+}
+
+// missingDirs returns the directories from dir up to, but not
+// including, root that don't exist yet. The deepest directory is
+// listed first so they can be removed in order.
+func missingDirs(root string, dir string) []string {
+	dirs := []string{}
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// detectMimeType returns the MIME type of a file. A specific type
+// given with the upload is used, otherwise the file extension and
+// then the leading bytes of the file are checked.
+func detectMimeType(name string, mimeType string, head []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if mimeType := mime.TypeByExtension(filepath.Ext(name)); mimeType != "" {
+		if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+			return mediaType
+		}
+	}
+	if len(head) > 0 {
+		mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// documentFormat maps a MIME type to an EPrints document format
+// (e.g. "text", "image", "archive").
+func documentFormat(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(mimeType, "text/"),
+		mimeType == "application/pdf",
+		mimeType == "application/msword",
+		mimeType == "application/rtf",
+		strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument"),
+		strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument"):
+		return "text"
+	case mimeType == "application/zip",
+		mimeType == "application/gzip",
+		mimeType == "application/x-tar",
+		mimeType == "application/x-gzip":
+		return "archive"
+	}
+	return "other"
+}
+
+// writeDocumentFile writes a file into docDir and returns its size,
+// MIME type and hash. The content is written to a temporary file
+// which is renamed once complete so a failed upload doesn't leave
+// a partial file behind.
+func writeDocumentFile(docDir string, upload *UploadFile, hashType string) (*storedFile, error) {
+	name, err := uploadFilename(upload.Filename)
+	if err != nil {
+		return nil, err
+	}
+	h, hashType, err := newHash(hashType)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(docDir, 0775); err != nil {
+		return nil, err
+	}
+	target := filepath.Join(docDir, name)
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("%s already exists", name)
+	}
+	tmp, err := os.CreateTemp(docDir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: the first 512 bytes are kept for detecting the MIME type
+	head := &headBuffer{max: 512}
+	size, err := io.Copy(io.MultiWriter(tmp, h, head), upload.Reader)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to write %s, %s", name, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0664); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &storedFile{
+		Name:     name,
+		Path:     target,
+		Size:     size,
+		MimeType: detectMimeType(name, upload.MimeType, head.buf),
+		Hash:     hex.EncodeToString(h.Sum(nil)),
+		HashType: hashType,
+	}, nil
+}
+
+// headBuffer is an io.Writer keeping the first max bytes written
+type headBuffer struct {
+	max int
+	buf []byte
+}
+
+func (head *headBuffer) Write(p []byte) (int, error) {
+	if n := head.max - len(head.buf); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		head.buf = append(head.buf, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// nextID returns one more than the largest value of column in
+// table. The locking read holds the index until the transaction
+// completes (see allocateEPrintID).
+func nextID(tx *sql.Tx, table string, column string) (int, error) {
+	stmt := fmt.Sprintf(`SELECT IFNULL(MAX(%s), 0) FROM %s FOR UPDATE`, column, table)
+	id := 0
+	if err := tx.QueryRow(stmt).Scan(&id); err != nil {
+		return 0, fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+	}
+	return id + 1, nil
+}
+
+// SQLCreateDocument attaches a new document holding files to an
+// existing eprint. The files are written below the repository's
+// "archive_path" in the eprint's directory (see makeDirValue), the
+// document and file rows are created, the eprint's rev_number and
+// lastmod are updated and a history entry is recorded for userID.
+// The document's Format, FormatDesc, Language, Security, License,
+// Content and DateEmbargo are used if set. The first file is the
+// document's main file unless document.Main names another.
+//
+// The rows are written inside a transaction. If it fails the files
+// written are removed.
+func SQLCreateDocument(config *Config, repoID string, ds *DataSource, userID int, eprintID int, document *Document, files []*UploadFile) (*Document, error) {
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return nil, fmt.Errorf(`no database connection for %s`, repoID)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf(`no files to upload for eprint id %d in %s`, eprintID, repoID)
+	}
+	if document.Security == "" {
+		document.Security = "public"
+	}
+	if !slices.Contains(DocumentSecurity, document.Security) {
+		return nil, fmt.Errorf(`security %q not valid, expected %s`, document.Security, strings.Join(DocumentSecurity, ", "))
+	}
+	docColumns, ok := ds.TableMap[`document`]
+	if !ok {
+		return nil, fmt.Errorf(`document table not found in %s`, repoID)
+	}
+	fileColumns, ok := ds.TableMap[`file`]
+	if !ok {
+		return nil, fmt.Errorf(`file table not found in %s`, repoID)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf(`SQL error, failed to start transaction, %s`, err)
+	}
+	written, created := []string{}, []string{}
+	// removeWritten removes the files and directories written
+	removeWritten := func() {
+		for _, name := range written {
+			os.Remove(name)
+		}
+		for _, name := range created {
+			os.Remove(name)
+		}
+	}
+	// rollback removes the files written and aborts the transaction
+	rollback := func(err error) (*Document, error) {
+		removeWritten()
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return nil, fmt.Errorf(`%s, rollback failed %s`, err, rbErr)
+		}
+		return nil, err
+	}
+
+	stmt := `SELECT IFNULL(rev_number, 0), IFNULL(dir, '') FROM eprint WHERE eprintid = ? FOR UPDATE`
+	var (
+		revNumber int
+		dir       string
+	)
+	if err := tx.QueryRow(stmt, eprintID).Scan(&revNumber, &dir); err != nil {
+		if err == sql.ErrNoRows {
+			return rollback(fmt.Errorf(`not found, eprint id %d in %s`, eprintID, repoID))
+		}
+		return rollback(fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+	}
+	if dir == "" {
+		dir = makeDirValue(eprintID)
+	}
+	stmt = `SELECT IFNULL(MAX(pos), 0) FROM document WHERE eprintid = ? FOR UPDATE`
+	if err := tx.QueryRow(stmt, eprintID).Scan(&document.Pos); err != nil {
+		return rollback(fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+	}
+	document.Pos++
+	if document.DocID, err = nextID(tx, `document`, `docid`); err != nil {
+		return rollback(err)
+	}
+	docDir, err := documentDir(ds.ArchivePath, dir, document.Pos)
+	if err != nil {
+		return rollback(err)
+	}
+	created = missingDirs(ds.ArchivePath, docDir)
+
+	now := time.Now()
+	document.ID = fmt.Sprintf("%s/id/document/%d", ds.BaseURL, document.DocID)
+	document.EPrintID = eprintID
+	document.RevNumber = 1
+	document.Placement = document.Pos
+	document.Files = []*File{}
+	for _, upload := range files {
+		stored, err := writeDocumentFile(docDir, upload, ds.HashType)
+		if err != nil {
+			return rollback(err)
+		}
+		written = append(written, stored.Path)
+		file := new(File)
+		if file.FileID, err = nextID(tx, `file`, `fileid`); err != nil {
+			return rollback(err)
+		}
+		file.ID = fmt.Sprintf("%s/id/file/%d", ds.BaseURL, file.FileID)
+		file.DatasetID = "document"
+		file.ObjectID = document.DocID
+		file.Filename = stored.Name
+		file.MimeType = stored.MimeType
+		file.Hash = stored.Hash
+		file.HashType = stored.HashType
+		file.FileSize = int(stored.Size)
+		file.MTime = now.Format(timestamp)
+		file.MTimeYear, file.MTimeMonth, file.MTimeDay = now.Year(), int(now.Month()), now.Day()
+		file.MTimeHour, file.MTimeMinute, file.MTimeSecond = now.Hour(), now.Minute(), now.Second()
+		file.URL = fmt.Sprintf("%s/%d/%d/%s", ds.BaseURL, eprintID, document.Pos, file.Filename)
+		columnsSQL, values := fileToColumnsAndValues(eprintID, file, fileColumns, false)
+		stmt := fmt.Sprintf(`INSERT INTO file (%s) VALUES (%s)`, strings.Join(columnsSQL, `, `), strings.Join(qmList(len(columnsSQL)), `, `))
+		if _, err := tx.Exec(stmt, values...); err != nil {
+			return rollback(fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+		}
+		document.Files = append(document.Files, file)
+	}
+	main := document.Files[0]
+	for _, file := range document.Files {
+		if file.Filename == document.Main {
+			main = file
+		}
+	}
+	document.Main = main.Filename
+	document.MimeType = main.MimeType
+	if document.Format == "" {
+		document.Format = documentFormat(main.MimeType)
+	}
+	if document.DateEmbargo != "" {
+		document.DateEmbargoYear, document.DateEmbargoMonth, document.DateEmbargoDay = approxYMD(document.DateEmbargo)
+	}
+	columnsSQL, values := documentToColumnsAndValues(eprintID, document, docColumns, false)
+	stmt = fmt.Sprintf(`INSERT INTO document (%s) VALUES (%s)`, strings.Join(columnsSQL, `, `), strings.Join(qmList(len(columnsSQL)), `, `))
+	if _, err := tx.Exec(stmt, values...); err != nil {
+		return rollback(fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+	}
+
+	// The eprint has changed, update the revision and lastmod
+	revNumber++
+	stmt = `UPDATE eprint SET rev_number = ?,
+lastmod_year = ?, lastmod_month = ?, lastmod_day = ?,
+lastmod_hour = ?, lastmod_minute = ?, lastmod_second = ?
+WHERE eprintid = ?`
+	if _, err := tx.Exec(stmt, revNumber,
+		now.Year(), int(now.Month()), now.Day(), now.Hour(), now.Minute(), now.Second(),
+		eprintID); err != nil {
+		return rollback(fmt.Errorf(`SQL error, %q, %s`, stmt, err))
+	}
+	if err := insertHistory(tx, userID, eprintID, revNumber, "modify", now); err != nil {
+		return rollback(err)
+	}
+	if err := tx.Commit(); err != nil {
+		removeWritten()
+		return nil, fmt.Errorf(`SQL error, failed to commit document for eprint id %d in %s, %s`, eprintID, repoID, err)
+	}
+	return document, nil
+}
@@ -0,0 +1,190 @@
+package eprinttools
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadFilename(t *testing.T) {
+	for name, expected := range map[string]string{
+		"article.pdf":                "article.pdf",
+		"/tmp/article.pdf":           "article.pdf",
+		`C:\Users\lemur\article.pdf`: "article.pdf",
+		"../../../etc/passwd":        "passwd",
+		" figure 1.png ":             "figure 1.png",
+	} {
+		got, err := uploadFilename(name)
+		if err != nil || got != expected {
+			t.Errorf("%q expected %q, got %q, %v", name, expected, got, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "/", ".htaccess"} {
+		if got, err := uploadFilename(name); err == nil {
+			t.Errorf("%q expected an error, got %q", name, got)
+		}
+	}
+}
+
+func TestDocumentDir(t *testing.T) {
+	archive := t.TempDir()
+	got, err := documentDir(archive, makeDirValue(1234), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(archive, "disk0", "00", "00", "12", "34", "01"); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	for _, dir := range []string{"", "../disk0", "/disk0/00"} {
+		if got, err := documentDir(archive, dir, 1); err == nil {
+			t.Errorf("%q expected an error, got %q", dir, got)
+		}
+	}
+	if _, err := documentDir("", makeDirValue(1234), 1); err == nil {
+		t.Errorf("expected an error without an archive path")
+	}
+}
+
+func TestMissingDirs(t *testing.T) {
+	archive := t.TempDir()
+	docDir, err := documentDir(archive, makeDirValue(1234), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(archive, "disk0", "00", "00"), 0775); err != nil {
+		t.Fatal(err)
+	}
+	dirs := missingDirs(archive, docDir)
+	expected := []string{docDir, filepath.Dir(docDir), filepath.Join(archive, "disk0", "00", "00", "12")}
+	if strings.Join(dirs, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %+v, got %+v", expected, dirs)
+	}
+	if err := os.MkdirAll(docDir, 0775); err != nil {
+		t.Fatal(err)
+	}
+	if dirs := missingDirs(archive, docDir); len(dirs) != 0 {
+		t.Errorf("expected no missing directories, got %+v", dirs)
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	for _, test := range []struct {
+		name, mimeType string
+		head           []byte
+		expected       string
+		format         string
+	}{
+		{"article.pdf", "application/pdf", nil, "application/pdf", "text"},
+		{"article.pdf", "application/octet-stream", nil, "application/pdf", "text"},
+		{"figure.png", "", nil, "image/png", "image"},
+		{"notes", "", []byte("plain text notes"), "text/plain", "text"},
+		{"data", "", []byte{0x00, 0x01, 0x02}, "application/octet-stream", "other"},
+		{"data.zip", "application/zip; charset=binary", nil, "application/zip", "archive"},
+	} {
+		got := detectMimeType(test.name, test.mimeType, test.head)
+		if got != test.expected {
+			t.Errorf("%s %q expected %q, got %q", test.name, test.mimeType, test.expected, got)
+		}
+		if format := documentFormat(got); format != test.format {
+			t.Errorf("%s expected format %q, got %q", got, test.format, format)
+		}
+	}
+}
+
+func TestWriteDocumentFile(t *testing.T) {
+	docDir, err := documentDir(t.TempDir(), makeDirValue(1234), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := &UploadFile{Filename: "hello.txt", Reader: strings.NewReader("hello world")}
+	stored, err := writeDocumentFile(docDir, upload, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Path != filepath.Join(docDir, "hello.txt") || stored.Size != 11 {
+		t.Errorf("unexpected stored file %+v", stored)
+	}
+	if stored.MimeType != "text/plain" {
+		t.Errorf("expected text/plain, got %q", stored.MimeType)
+	}
+	if stored.HashType != "MD5" || stored.Hash != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+		t.Errorf("unexpected hash %s %s", stored.HashType, stored.Hash)
+	}
+	if src, err := os.ReadFile(stored.Path); err != nil || string(src) != "hello world" {
+		t.Errorf("expected file content, got %q, %v", src, err)
+	}
+	// An existing file isn't replaced
+	upload = &UploadFile{Filename: "hello.txt", Reader: strings.NewReader("goodbye")}
+	if _, err := writeDocumentFile(docDir, upload, ""); err == nil {
+		t.Errorf("expected an error writing over hello.txt")
+	}
+	upload = &UploadFile{Filename: "hello.dat", MimeType: "text/plain", Reader: strings.NewReader("hello world")}
+	stored, err = writeDocumentFile(docDir, upload, "sha-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.HashType != "SHA-256" || stored.Hash != "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9" {
+		t.Errorf("unexpected hash %s %s", stored.HashType, stored.Hash)
+	}
+	if _, err := writeDocumentFile(docDir, &UploadFile{Filename: "x.txt", Reader: strings.NewReader("x")}, "CRC32"); err == nil {
+		t.Errorf("expected an error for an unsupported hash type")
+	}
+	// No temporary files are left behind
+	entries, err := os.ReadDir(docDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		for _, entry := range entries {
+			t.Logf("found %s", entry.Name())
+		}
+		t.Errorf("expected 2 files in %s, got %d", docDir, len(entries))
+	}
+}
+
+func TestUnpackageDocumentUpload(t *testing.T) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for k, v := range map[string]string{"security": "staffonly", "content": "accepted", "license": "cc_by", "main": "article.pdf"} {
+		mw.WriteField(k, v)
+	}
+	for _, name := range []string{"article.pdf", "figure.png"} {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(name))
+	}
+	mw.Close()
+	r := httptest.NewRequest("POST", "/lemurprints/document-upload/1/1234", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	api := new(EP3API)
+	document, files, cleanup, err := api.unpackageDocumentUpload(r)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.Security != "staffonly" || document.Content != "accepted" || document.License != "cc_by" || document.Main != "article.pdf" {
+		t.Errorf("unexpected document %+v", document)
+	}
+	if len(files) != 2 || files[0].Filename != "article.pdf" || files[1].Filename != "figure.png" {
+		t.Fatalf("unexpected files %+v", files)
+	}
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(files[1].Reader)
+	if buf.String() != "figure.png" {
+		t.Errorf("unexpected file content %q", buf.String())
+	}
+
+	r = httptest.NewRequest("POST", "/lemurprints/document-upload/1/1234", strings.NewReader("<eprints/>"))
+	r.Header.Set("Content-Type", "application/xml")
+	_, _, cleanup, err = api.unpackageDocumentUpload(r)
+	defer cleanup()
+	if err == nil {
+		t.Errorf("expected an error for a request that isn't multipart/form-data")
+	}
+}
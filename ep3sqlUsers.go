@@ -0,0 +1,120 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * ep3sqlUsers.go implements validating, listing and deactivating
+ * EPrints users along with setting their passwords. Creating and
+ * updating users is done with SQLCreateUser and SQLUpdateUser.
+ */
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// UserTypes are the EPrints usertype values accepted when
+	// creating or updating a user
+	UserTypes = []string{"user", "editor", "admin", "minuser"}
+
+	// usernameRE matches the usernames accepted for new users
+	usernameRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@+-]*$`)
+)
+
+// ValidateUser checks the username, email and usertype of a user
+// before it is written to the user table.
+func ValidateUser(user *EPrintUser) error {
+	if user.Username == "" {
+		return fmt.Errorf("missing username")
+	}
+	if len(user.Username) > 255 || !usernameRE.MatchString(user.Username) {
+		return fmt.Errorf("username %q not valid, use letters, digits and . _ @ + -", user.Username)
+	}
+	if user.EMail != "" {
+		addr, err := mail.ParseAddress(user.EMail)
+		if err != nil || addr.Address != user.EMail {
+			return fmt.Errorf("email %q not valid", user.EMail)
+		}
+	}
+	if !slices.Contains(UserTypes, user.Type) {
+		return fmt.Errorf("usertype %q not valid, expected %s", user.Type, strings.Join(UserTypes, ", "))
+	}
+	return nil
+}
+
+// GetUsersByType returns the users with a usertype (e.g. "editor")
+// ordered by userid.
+func GetUsersByType(config *Config, repoID string, userType string) ([]*EPrintUser, error) {
+	userIDs, err := sqlQueryIntIDs(config, repoID, `SELECT userid FROM user WHERE usertype = ? ORDER BY userid`, userType)
+	if err != nil {
+		return nil, err
+	}
+	users := []*EPrintUser{}
+	for _, userID := range userIDs {
+		user, err := GetUserBy(config, repoID, `userid`, userID)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// SQLSetUserPassword sets the password of a user. The password is
+// stored the way EPrints 3.3 does, a salted and repeated SHA512
+// digest.
+func SQLSetUserPassword(config *Config, repoID string, userID int, password string) error {
+	if password == "" {
+		return fmt.Errorf("missing password")
+	}
+	crypted, err := cryptEPrintsPassword(password)
+	if err != nil {
+		return err
+	}
+	return sqlUpdateUserPassword(config, repoID, userID, crypted)
+}
+
+// SQLDeactivateUser removes the password of a user so they can no
+// longer log in to EPrints or ep3apid. The user's record and
+// deposits are kept, setting a new password reactivates the account.
+func SQLDeactivateUser(config *Config, repoID string, userID int) error {
+	return sqlUpdateUserPassword(config, repoID, userID, nil)
+}
+
+// sqlUpdateUserPassword writes the password column of a user
+func sqlUpdateUserPassword(config *Config, repoID string, userID int, crypted interface{}) error {
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return fmt.Errorf(`no database connection for %s`, repoID)
+	}
+	stmt := `UPDATE user SET password = ? WHERE userid = ?`
+	result, err := db.Exec(stmt, crypted, userID)
+	if err != nil {
+		return fmt.Errorf(`SQL error, %q, %s`, stmt, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		// NOTE: MySQL reports zero rows when the value is unchanged
+		if ids, err := sqlQueryIntIDs(config, repoID, `SELECT userid FROM user WHERE userid = ?`, userID); err == nil && len(ids) == 0 {
+			return fmt.Errorf(`not found, user id %d in %s`, userID, repoID)
+		}
+	}
+	return nil
+}
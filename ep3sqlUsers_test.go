@@ -0,0 +1,151 @@
+package eprinttools
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateUser(t *testing.T) {
+	for _, test := range []struct {
+		user *EPrintUser
+		ok   bool
+	}{
+		{&EPrintUser{Username: "jane", Type: "user"}, true},
+		{&EPrintUser{Username: "jane.doe@example.edu", Type: "editor", EMail: "jane.doe@example.edu"}, true},
+		{&EPrintUser{Username: "j_doe-2", Type: "admin"}, true},
+		{&EPrintUser{Username: "", Type: "user"}, false},
+		{&EPrintUser{Username: "jane doe", Type: "user"}, false},
+		{&EPrintUser{Username: "-jane", Type: "user"}, false},
+		{&EPrintUser{Username: "jane'; DROP TABLE user", Type: "user"}, false},
+		{&EPrintUser{Username: strings.Repeat("j", 256), Type: "user"}, false},
+		{&EPrintUser{Username: "jane", Type: "user", EMail: "not an email"}, false},
+		{&EPrintUser{Username: "jane", Type: "user", EMail: "Jane Doe <jane@example.edu>"}, false},
+		{&EPrintUser{Username: "jane", Type: "superuser"}, false},
+		{&EPrintUser{Username: "jane", Type: ""}, false},
+	} {
+		err := ValidateUser(test.user)
+		if test.ok && err != nil {
+			t.Errorf("%q, unexpected error %s", test.user.Username, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%q (%q, %q), expected an error", test.user.Username, test.user.EMail, test.user.Type)
+		}
+	}
+}
+
+func TestCryptEPrintsPassword(t *testing.T) {
+	crypted, err := cryptEPrintsPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(crypted, "?digest=SHA512&rounds=10000&salt=") {
+		t.Errorf("unexpected crypted password %q", crypted)
+	}
+	if !checkEPrintsPassword(crypted, "secret") {
+		t.Errorf("expected %q to match", crypted)
+	}
+	if checkEPrintsPassword(crypted, "Secret") {
+		t.Errorf("expected %q not to match", crypted)
+	}
+	other, _ := cryptEPrintsPassword("secret")
+	if other == crypted {
+		t.Errorf("expected a new salt for each password")
+	}
+}
+
+func TestUnpackageUserPOST(t *testing.T) {
+	api := metricsTestEP3API()
+	src := `{"username":"jane","type":"editor","email":"jane@example.edu","password":"secret"}`
+	r := httptest.NewRequest("POST", "/lemurprints/user-create", strings.NewReader(src))
+	r.Header.Set("Content-Type", "application/json")
+	user := &EPrintUser{Type: "user", Dept: "Library"}
+	password, err := api.unpackageUserPOST(r, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStringSame(t, "password", "secret", password)
+	assertStringSame(t, "username", "jane", user.Username)
+	assertStringSame(t, "type", "editor", user.Type)
+	assertStringSame(t, "dept", "Library", user.Dept)
+
+	src = `<user><username>jane</username><email>jane@example.edu</email></user>`
+	r = httptest.NewRequest("POST", "/lemurprints/user-update/1", strings.NewReader(src))
+	r.Header.Set("Content-Type", "application/xml")
+	user = &EPrintUser{UserID: 1, Username: "jdoe", Type: "user"}
+	password, err = api.unpackageUserPOST(r, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStringSame(t, "password", "", password)
+	assertStringSame(t, "username", "jane", user.Username)
+	assertStringSame(t, "email", "jane@example.edu", user.EMail)
+	assertIntSame(t, "userid", 1, user.UserID)
+
+	r = httptest.NewRequest("POST", "/lemurprints/user-create", strings.NewReader("username=jane"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := api.unpackageUserPOST(r, new(EPrintUser)); err == nil {
+		t.Errorf("expected an error for an unsupported content type")
+	}
+}
+
+func TestEPrintUserHasNoPassword(t *testing.T) {
+	user := &EPrintUser{UserID: 1, Username: "jane", Type: "user"}
+	src, _ := json.Marshal(user)
+	if strings.Contains(string(src), "password") {
+		t.Errorf("JSON should not include a password, %s", src)
+	}
+	src, _ = xml.Marshal(user)
+	if strings.Contains(string(src), "password") {
+		t.Errorf("XML should not include a password, %s", src)
+	}
+}
+
+func TestSQLUserAdmin(t *testing.T) {
+	fName := `test-settings.json`
+	repoID := `lemurprints`
+	config, err := LoadConfig(fName)
+	if err != nil {
+		t.Skipf("Failed to reload %q, %s", fName, err)
+	}
+	ds, ok := config.Repositories[repoID]
+	if ds == nil || ok == false || ds.Write == false {
+		t.Skipf(`%s not available for testing`, repoID)
+		t.SkipNow()
+	}
+	assertOpenConnection(t, config, repoID)
+	defer assertCloseConnection(t, config, repoID)
+
+	user := &EPrintUser{
+		Username: `test-user-admin-` + time.Now().Format(`20060102150405`),
+		Type:     `minuser`,
+		EMail:    `test@example.edu`,
+	}
+	userID, err := SQLCreateUser(config, repoID, user)
+	if err != nil || userID == 0 {
+		t.Fatalf("%s, failed to create test user, %s", repoID, err)
+	}
+	if err := SQLSetUserPassword(config, repoID, userID, `secret`); err != nil {
+		t.Fatalf("%s, %s", repoID, err)
+	}
+	users, err := GetUsersByType(config, repoID, `minuser`)
+	if err != nil {
+		t.Fatalf("%s, %s", repoID, err)
+	}
+	found := false
+	for _, u := range users {
+		found = found || u.UserID == userID
+	}
+	if !found {
+		t.Errorf("%s, expected user %d in minuser list", repoID, userID)
+	}
+	if err := SQLDeactivateUser(config, repoID, userID); err != nil {
+		t.Errorf("%s, %s", repoID, err)
+	}
+	if err := SQLDeactivateUser(config, repoID, -1); err == nil || !strings.HasPrefix(err.Error(), "not found") {
+		t.Errorf("%s, expected not found, got %v", repoID, err)
+	}
+}
@@ -1197,6 +1197,91 @@ generated in TestSQLCreateEPrint() in ep3sql_test.go.`
 	assertEPrintSame(t, eprint, eprintCopy)
 }
 
+func TestSQLUpdateEPrint(t *testing.T) {
+	fName := `test-settings.json`
+	repoID := `lemurprints`
+	config, err := LoadConfig(fName)
+	if err != nil {
+		t.Skipf("Cailed to reload %q, %s", fName, err)
+	}
+	ds, ok := config.Repositories[repoID]
+	if ds == nil || ok == false || ds.Write == false {
+		t.Skipf(`%s not available for testing`, repoID)
+		t.SkipNow()
+	}
+	baseURL := ds.BaseURL
+	assertOpenConnection(t, config, repoID)
+	defer assertCloseConnection(t, config, repoID)
+
+	userID := os.Getuid()
+	eprint := new(EPrint)
+	eprint.Title = `TestSQLUpdateEPrint()`
+	eprint.EPrintStatus = `buffer`
+	eprint.UserID = userID
+	eprint.Type = `article`
+	eprint.Creators = new(CreatorItemList)
+	item := new(Item)
+	item.Name = new(Name)
+	item.Name.Family = `Doe`
+	item.Name.Given = `Jane`
+	item.ID = `Doe-Jane`
+	eprint.Creators.Append(item)
+	id, err := SQLCreateEPrint(config, repoID, ds, eprint)
+	if err != nil || id == 0 {
+		t.Errorf("%s, failed to create test eprint, %s", repoID, err)
+		t.FailNow()
+	}
+	original, err := SQLReadEPrint(config, repoID, baseURL, id)
+	if err != nil {
+		t.Errorf("%s, %s", repoID, err)
+		t.FailNow()
+	}
+
+	// Replace the title and creators
+	eprint = new(EPrint)
+	eprint.EPrintID = id
+	eprint.Title = `TestSQLUpdateEPrint() updated`
+	eprint.Type = `article`
+	eprint.Creators = new(CreatorItemList)
+	item = new(Item)
+	item.Name = new(Name)
+	item.Name.Family = `Doe`
+	item.Name.Given = `Jill`
+	item.ID = `Doe-Jill`
+	eprint.Creators.Append(item)
+	if err := SQLUpdateEPrint(config, repoID, ds, userID, eprint); err != nil {
+		t.Errorf("%s, update failed, %s", repoID, err)
+		t.FailNow()
+	}
+	updated, err := SQLReadEPrint(config, repoID, baseURL, id)
+	if err != nil {
+		t.Errorf("%s, %s", repoID, err)
+		t.FailNow()
+	}
+	assertStringSame(t, `Title`, eprint.Title, updated.Title)
+	assertIntSame(t, `RevNumber`, original.RevNumber+1, updated.RevNumber)
+	assertStringSame(t, `Datestamp`, original.Datestamp, updated.Datestamp)
+	assertStringSame(t, `EPrintStatus`, original.EPrintStatus, updated.EPrintStatus)
+	if updated.Creators == nil || updated.Creators.Length() != 1 {
+		t.Errorf("expected a single creator, got %+v", updated.Creators)
+	} else {
+		assertStringSame(t, `Creators.ID`, `Doe-Jill`, updated.Creators.IndexOf(0).ID)
+	}
+
+	// Changing the status should fail, eprint-status moves an EPrint
+	eprint.EPrintStatus = `archive`
+	if err := SQLUpdateEPrint(config, repoID, ds, userID, eprint); err == nil {
+		t.Errorf("expected an error changing the eprint_status with an update")
+	}
+	eprint.EPrintStatus = ``
+
+	// Updating a missing record should fail
+	eprint.EPrintID = 123456790
+	if err := SQLUpdateEPrint(config, repoID, ds, userID, eprint); err == nil {
+		t.Errorf("expected an error updating a missing eprint")
+	}
+}
+
 func TestImports(t *testing.T) {
 	var err error
 	fName := `test-settings.json`
@@ -1256,16 +1341,43 @@ func TestImports(t *testing.T) {
 			eprints.EPrint[i].ImportID, eprints.EPrint[i].EPrintID = eprints.EPrint[i].EPrintID, 0
 			reviewEPrints.Append(eprints.EPrint[i])
 		}
-		ids, err := ImportEPrints(config, repoID, ds, eprints)
+		results, err := ImportEPrints(config, repoID, ds, eprints, false)
 		if err != nil {
 			t.Errorf("Failed to create eprint for %q, %s", fName, err)
 			t.FailNow()
 		}
-		if len(ids) == 0 {
-			t.Errorf("Failed to generate new eprint id for %q", fName)
+		if len(results) != eprints.Length() {
+			t.Errorf("Expected %d results for %q, got %d", eprints.Length(), fName, len(results))
 			t.FailNow()
 		}
-		reviewIds = append(reviewIds, ids...)
+		for _, result := range results {
+			if result.Status != `created` || result.EPrintID == 0 {
+				t.Errorf("Failed to generate new eprint id for %q, %+v", fName, result)
+				t.FailNow()
+			}
+			reviewIds = append(reviewIds, result.EPrintID)
+		}
+	}
+
+	// An all or nothing import with a bad record should create nothing.
+	eprints := new(EPrints)
+	eprints.Append(&EPrint{Type: `article`, Title: `Rolled back`})
+	eprints.Append(&EPrint{EPrintID: reviewIds[0], Type: `article`, Title: `Not new`})
+	results, err := ImportEPrints(config, repoID, ds, eprints, true)
+	if err == nil {
+		t.Errorf("Expected an error for all or nothing import")
+	}
+	if len(results) != 2 || results[0].Status != `rolled back` || results[0].EPrintID != 0 || results[1].Status != `failed` {
+		t.Errorf("Unexpected all or nothing results %+v", results)
+	}
+	// Without all or nothing the good record is still created.
+	eprints.EPrint[0].EPrintID = 0
+	results, err = ImportEPrints(config, repoID, ds, eprints, false)
+	if err == nil {
+		t.Errorf("Expected an error for partial import")
+	}
+	if len(results) != 2 || results[0].Status != `created` || results[0].EPrintID == 0 || results[1].Status != `failed` {
+		t.Errorf("Unexpected partial import results %+v", results)
 	}
 
 	// Make sure we can read out the records that were imported.
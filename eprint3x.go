@@ -156,7 +156,7 @@ type EPrint struct {
 	PresType     string           `xml:"pres_type,omitempty" json:"presentation_type,omitempty"`
 	Succeeds     int              `xml:"succeeds,omitempty" json:"succeeds,omitempty"`
 	Commentary   int              `xml:"commentary,omitempty" json:"commentary,omitempty"`
-	ContactEMail string           `xml:"contact_email,omitempty" json:"contect_email,omitempty"`
+	ContactEMail string           `xml:"contact_email,omitempty" json:"contact_email,omitempty"`
 	// NOTE: EPrints XML doesn't include fileinfo
 	FileInfo          string                   `xml:"-" json:"-"`
 	Latitude          float64                  `xml:"latitude,omitempty" json:"latitude,omitempty"`
@@ -209,7 +209,7 @@ type EPrint struct {
 	PatentApplicant          string                  `xml:"patent_applicant,omitempty" json:"patent_applicant,omitempty"`
 	PatentNumber             string                  `xml:"patent_number,omitempty" json:"patent_number,omitempty"`
 	PatentAssignee           *PatentAssigneeItemList `xml:"patent_assignee,omitempty" json:"patent_assignee,omitempty"`
-	PatentClassificationText string                  `xml:"-" json:"-"`
+	PatentClassificationText string                  `xml:"patent_classification,omitempty" json:"patent_classification,omitempty"`
 	//PatentClassification     *PatentClassificationItemList `xml:"patent_classification,omitempty" json:"patent_classification,omitempty"`
 	RelatedPatents *RelatedPatentItemList `xml:"related_patents,omitempty" json:"related_patents,omitempty"`
 
@@ -281,6 +281,62 @@ func (eprint *EPrint) PubDate() string {
 	return ""
 }
 
+// keywordsXML holds the keywords element which may be either
+// longtext or (as in the EPrints default configuration) a list of
+// items.
+type keywordsXML struct {
+	Items []string `xml:"item"`
+	Value string   `xml:",chardata"`
+}
+
+// UnmarshalXML is a custom XML unmarshaler for EPrint. Our EPrints
+// store keywords as a longtext, some repositories use a list of
+// keyword items. Items are joined with "; " so they are not dropped.
+func (eprint *EPrint) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// NOTE: the alias type must be exported for encoding/xml to
+	// populate the embedded fields.
+	type EPrintAlias EPrint
+	aux := struct {
+		*EPrintAlias
+		Keywords *keywordsXML `xml:"keywords,omitempty"`
+	}{EPrintAlias: (*EPrintAlias)(eprint)}
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err
+	}
+	if aux.Keywords != nil {
+		if s := strings.TrimSpace(aux.Keywords.Value); s != "" {
+			eprint.Keywords = s
+		} else {
+			keywords := []string{}
+			for _, keyword := range aux.Keywords.Items {
+				if keyword = strings.TrimSpace(keyword); keyword != "" {
+					keywords = append(keywords, keyword)
+				}
+			}
+			eprint.Keywords = strings.Join(keywords, "; ")
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON is a custom JSON unmarshaler for EPrint. Older versions
+// of eprinttools wrote the contact email as "contect_email", it is
+// still accepted when "contact_email" is not present.
+func (eprint *EPrint) UnmarshalJSON(src []byte) error {
+	type EPrintAlias EPrint
+	aux := struct {
+		*EPrintAlias
+		ContectEMail string `json:"contect_email,omitempty"`
+	}{EPrintAlias: (*EPrintAlias)(eprint)}
+	if err := json.Unmarshal(src, &aux); err != nil {
+		return err
+	}
+	if eprint.ContactEMail == "" && aux.ContectEMail != "" {
+		eprint.ContactEMail = aux.ContectEMail
+	}
+	return nil
+}
+
 // Item is a generic type used by various fields (e.g. Creator, Division, OptionMajor)
 type Item struct {
 	XMLName     xml.Name `xml:"item" json:"-"`
@@ -446,6 +502,30 @@ func (item *Item) MarshalJSON() ([]byte, error) {
 		m["orcid"] = s
 		flatten = false
 	}
+	if s := strings.TrimSpace(item.ROR); s != "" {
+		m["ror"] = s
+		flatten = false
+	}
+	if s := strings.TrimSpace(item.Timestamp); s != "" {
+		m["timestamp"] = s
+		flatten = false
+	}
+	if s := strings.TrimSpace(item.Status); s != "" {
+		m["status"] = s
+		flatten = false
+	}
+	if s := strings.TrimSpace(item.ReportedBy); s != "" {
+		m["reported_by"] = s
+		flatten = false
+	}
+	if s := strings.TrimSpace(item.ResolvedBy); s != "" {
+		m["resolved_by"] = s
+		flatten = false
+	}
+	if s := strings.TrimSpace(item.Comment); s != "" {
+		m["comment"] = s
+		flatten = false
+	}
 	if s := strings.TrimSpace(item.Value); s != "" {
 		if flatten == true {
 			return json.Marshal(s)
@@ -484,7 +564,16 @@ func (item *Item) UnmarshalJSON(src []byte) error {
 					name.ID = id.(string)
 				}
 				if orcid, ok := m["orcid"]; ok == true {
-					name.ID = orcid.(string)
+					name.ORCID = orcid.(string)
+				}
+				if honourific, ok := m["honourific"]; ok == true {
+					name.Honourific = honourific.(string)
+				}
+				if lineage, ok := m["lineage"]; ok == true {
+					name.Lineage = lineage.(string)
+				}
+				if value, ok := m["value"]; ok == true {
+					name.Value = value.(string)
 				}
 			}
 			item.Name = name
@@ -510,6 +599,18 @@ func (item *Item) UnmarshalJSON(src []byte) error {
 			item.URI = value.(string)
 		case "orcid":
 			item.ORCID = value.(string)
+		case "ror":
+			item.ROR = value.(string)
+		case "timestamp":
+			item.Timestamp = value.(string)
+		case "status":
+			item.Status = value.(string)
+		case "reported_by":
+			item.ReportedBy = value.(string)
+		case "resolved_by":
+			item.ResolvedBy = value.(string)
+		case "comment":
+			item.Comment = value.(string)
 		case "value":
 			item.Value = value.(string)
 		}
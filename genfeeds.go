@@ -15,6 +15,36 @@ import (
 // non-templated Markdown documents in the htdocs directory.
 //
 
+var (
+	// FeedCitationFormats lists the citation formats (e.g. "bibtex",
+	// "ris") rendered alongside the JSON record lists for groups and
+	// people. It defaults to all the supported formats.
+	FeedCitationFormats = CitationFormatNames()
+)
+
+// writeCitationFiles renders records in each of the formats in
+// FeedCitationFormats, the format's extension is appended to basename.
+func writeCitationFiles(basename string, records []*EPrint) error {
+	for _, name := range FeedCitationFormats {
+		citationFormat, ok := CitationFormats[name]
+		if !ok {
+			return fmt.Errorf("unsupported citation format %q", name)
+		}
+		fp, err := os.Create(basename + citationFormat.Extension)
+		if err != nil {
+			return err
+		}
+		if err := citationFormat.Write(fp, records); err != nil {
+			fp.Close()
+			return err
+		}
+		if err := fp.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func generateGroupDir(cfg *Config, groupID string, group *Group, m map[string]interface{}) error {
 	groupDir := path.Join(cfg.Htdocs, "groups", groupID)
 	// NOTE: Is htdocs relative to project? If so handle that case
@@ -51,6 +81,9 @@ func generateGroupDir(cfg *Config, groupID string, group *Group, m map[string]in
 				if err := jsonEncodeToFile(fName, docs, 0664); err != nil {
 					return err
 				}
+				if err := writeCitationFiles(strings.TrimSuffix(fName, ".json"), docs); err != nil {
+					return err
+				}
 
 			}
 		}
@@ -236,6 +269,9 @@ func GeneratePeopleFeed(cfg *Config, verbose bool) error {
 							if err := jsonEncodeToFile(fName, records, 0664); err != nil {
 								return err
 							}
+							if err := writeCitationFiles(fName, records); err != nil {
+								return err
+							}
 						}
 					}
 				}
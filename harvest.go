@@ -16,6 +16,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	// Caltech Library Packages
@@ -27,10 +28,18 @@ import (
 
 const (
 	mysqlTimeFmt = "2006-01-02 15:04:05"
+
+	// harvestEpoch is a date/time before EPrints existed, it is used
+	// when a repository has not been harvested before.
+	harvestEpoch = "2000-01-01 00:00:00"
 )
 
 var (
 	UseSimpleRecord bool
+
+	// HarvestWorkers when greater than zero overrides the
+	// harvest_workers set in the configuration.
+	HarvestWorkers int
 )
 
 // getDBName uses the ParseDSN function from the MySQL driver to
@@ -246,6 +255,19 @@ CREATE TABLE IF NOT EXISTS _groups (
 );
 CREATE INDEX _groups_name_i ON _groups (name ASC);
 
+-- Table Schema generated for MySQL 8
+-- for tracking the last harvest of each EPrint repository
+CREATE TABLE IF NOT EXISTS _harvest_checkpoints (
+    repository VARCHAR(256) NOT NULL PRIMARY KEY,
+    lastmod VARCHAR(256) DEFAULT "",
+    start VARCHAR(256) DEFAULT "",
+    end VARCHAR(256) DEFAULT "",
+    status VARCHAR(256) DEFAULT "",
+    harvested INTEGER DEFAULT 0,
+    failed INTEGER DEFAULT 0,
+    updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
 `
 	table := `--
 -- Table Schema generated for MySQL 8 by %s %s
@@ -286,7 +308,8 @@ CREATE INDEX %s_pubdate_i ON %s (pubdate DESC);
 
 // RunHarvester will use the config file names by cfgName and
 // the start and end time strings if set to retrieve all eprint
-// records created or modified during that time sequence.
+// records created or modified during that time sequence. If start
+// is empty each repository is harvested from its last checkpoint.
 func RunHarvester(cfgName string, start string, end string, keyList string, useSimpleRecord bool, verbose bool) error {
 	UseSimpleRecord = useSimpleRecord
 	if cfgName == "" {
@@ -301,10 +324,8 @@ func RunHarvester(cfgName string, start string, end string, keyList string, useS
 	if cfg == nil {
 		return fmt.Errorf("could not create a configuration object")
 	}
-	if start == "" {
-		// Pick a start date/time before EPrints existed.
-		start = "2000-01-01 00:00:00"
-	}
+	// NOTE: If start is empty harvestRepository will start from the
+	// repository's last checkpoint.
 	if end == "" {
 		// Pick the current date time when harvest is starting
 		end = now.Format(mysqlTimeFmt)
@@ -329,10 +350,8 @@ func RunHarvestRepoID(cfgName string, repoName, start string, end string, keyLis
 	if cfg == nil {
 		return fmt.Errorf("could not create a configuration object")
 	}
-	if start == "" {
-		// Pick a start date/time before EPrints existed.
-		start = "2000-01-01 00:00:00"
-	}
+	// NOTE: If start is empty harvestRepository will start from the
+	// repository's last checkpoint.
 	if end == "" {
 		// Pick the current date time when harvest is starting
 		end = now.Format(mysqlTimeFmt)
@@ -397,12 +416,58 @@ func getSortedUniqueIDs(ids []int) []int {
 	return uniqueIDs
 }
 
+// checkpointStart returns the timestamp to start a harvest from
+// given the repository's last checkpoint.
+func checkpointStart(checkpoint *HarvestCheckpoint) string {
+	if checkpoint == nil || checkpoint.LastModified == "" {
+		// Pick a start date/time before EPrints existed.
+		return harvestEpoch
+	}
+	return checkpoint.LastModified
+}
+
+// nextCheckpoint returns the checkpoint after harvesting a range.
+// The checkpoint is only advanced to the end of the range if every
+// record was harvested and the range starts at or before the current
+// checkpoint. It never moves backwards (e.g. when re-harvesting an
+// older range). NOTE: the end of the range is saved rather than the
+// newest record lastmod, a record lastmod can be later than end and
+// records modified in between would never be harvested.
+func nextCheckpoint(checkpoint *HarvestCheckpoint, repoName string, start string, end string, harvested int, failed int) *HarvestCheckpoint {
+	next := &HarvestCheckpoint{
+		Repository: repoName,
+		Start:      start,
+		End:        end,
+		Status:     "ok",
+		Harvested:  harvested,
+		Failed:     failed,
+	}
+	if checkpoint != nil {
+		next.LastModified = checkpoint.LastModified
+	}
+	switch {
+	case failed > 0 && harvested == 0:
+		next.Status = "failed"
+	case failed > 0:
+		next.Status = "partial"
+	case start <= checkpointStart(checkpoint) && end > next.LastModified:
+		next.LastModified = end
+	}
+	return next
+}
+
 // harvestRepository takes a configuration with open database connections.
 // a repository name (i.e. eprint database name) along with a start and
 // end timestamp. It harvests records created/modified from the repository
-// in time range.
+// in time range. If start is empty the range starts at the last
+// checkpoint for the repository. The checkpoint is only advanced
+// after all the records in the range are harvested.
 func harvestRepository(cfg *Config, repoName string, start string, end string, keyList string, verbose bool) error {
-	var ids []int
+	var (
+		ids        []int
+		checkpoint *HarvestCheckpoint
+		err        error
+	)
 	if keyList != "" {
 		fp, err := os.Open(keyList)
 		if err != nil {
@@ -428,6 +493,16 @@ func harvestRepository(cfg *Config, repoName string, start string, end string, k
 			log.Printf("Retrieved %d keys from %q", len(ids), keyList)
 		}
 	} else {
+		checkpoint, err = GetHarvestCheckpoint(cfg, repoName)
+		if err != nil {
+			return err
+		}
+		if start == "" {
+			start = checkpointStart(checkpoint)
+			if verbose {
+				log.Printf("Harvesting %s since %s", repoName, start)
+			}
+		}
 		createdIDs, err := GetEPrintIDsInTimestampRange(cfg, repoName, "datestamp", start, end)
 		if err != nil {
 			return err
@@ -446,7 +521,6 @@ func harvestRepository(cfg *Config, repoName string, start string, end string, k
 	}
 	ids = getSortedUniqueIDs(ids)
 	tot := len(ids)
-	modValue := calcModValue(tot)
 	t0 := time.Now()
 	if verbose {
 		log.Printf("Processing %d unique keys", tot)
@@ -468,68 +542,176 @@ func harvestRepository(cfg *Config, repoName string, start string, end string, k
 		}
 	}
 
-	for i, id := range ids {
-		err := harvestEPrintRecord(cfg, repoName, id)
-		if err != nil {
-			log.Printf("Harvesting EPrint %d (%s) failed, %s", id, progress(t0, i, tot), err)
+	harvested, failed := harvestIDs(cfg, repoName, ids, harvestWorkers(cfg), verbose)
+	log.Printf("Harvested %q in %v", repoName, time.Since(t0).Truncate(time.Second))
+	// NOTE: A key list harvest isn't a time range so it doesn't
+	// move the checkpoint.
+	if keyList == "" {
+		next := nextCheckpoint(checkpoint, repoName, start, end, harvested, failed)
+		if err := SaveHarvestCheckpoint(cfg, next); err != nil {
+			return err
 		}
-		if verbose && ((i % modValue) == 0) {
-			log.Printf("Harvested EPrint %d (%s)", id, progress(t0, i, tot))
+		if verbose {
+			log.Printf("Checkpoint for %s is %q (%s, %d harvested, %d failed)", repoName, next.LastModified, next.Status, harvested, failed)
 		}
 	}
-	log.Printf("Harvested %q in %v", repoName, time.Since(t0).Truncate(time.Second))
 	return nil
 }
 
-// harvestEPrintRecord takes a configuration with open database connections
-// a repository name and EPrint record ID and populates a JSON datastore
-// of harvested EPrints.
-func harvestEPrintRecord(cfg *Config, repoName string, eprintID int) error {
+// harvestResult holds an EPrint record read from a repository
+// along with the JSON source to be saved in the JSON store.
+type harvestResult struct {
+	i        int
+	eprintID int
+	eprint   *EPrint
+	src      []byte
+	action   string
+	err      error
+}
+
+// harvestWorkers returns the number of harvest workers to use.
+func harvestWorkers(cfg *Config) int {
+	if HarvestWorkers > 0 {
+		return HarvestWorkers
+	}
+	if cfg.HarvestWorkers > 0 {
+		return cfg.HarvestWorkers
+	}
+	return 1
+}
+
+// harvestIDs harvests the list of ids for repoName. When workers is
+// greater than one the EPrint records are read concurrently by a pool
+// of workers, the number of queries in flight is bounded by the
+// repository's max_connections. Records are saved to the JSON
+// store by a single writer so the jsonstore and _aggregate_* tables
+// are never written concurrently. Progress is reported in the order
+// of ids. Returns the counts harvested and failed.
+func harvestIDs(cfg *Config, repoName string, ids []int, workers int, verbose bool) (int, int) {
+	tot := len(ids)
+	modValue := calcModValue(tot)
+	t0 := time.Now()
+	harvested, failed := 0, 0
+	save := func(result *harvestResult) {
+		if result.err == nil {
+			result.err = saveEPrintRecord(cfg, repoName, result)
+		}
+		if result.err != nil {
+			log.Printf("Harvesting EPrint %d (%s) failed, %s", result.eprintID, progress(t0, result.i, tot), result.err)
+			failed++
+			return
+		}
+		harvested++
+	}
+	if workers <= 1 {
+		for i, id := range ids {
+			save(readEPrintRecord(cfg, repoName, i, id))
+			if verbose && ((i % modValue) == 0) {
+				log.Printf("Harvested EPrint %d (%s)", id, progress(t0, i, tot))
+			}
+		}
+		return harvested, failed
+	}
+	if verbose {
+		log.Printf("Harvesting %s with %d workers", repoName, workers)
+	}
+	jobs := make(chan int)
+	results := make(chan *harvestResult, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- readEPrintRecord(cfg, repoName, i, ids[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range ids {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	// NOTE: records finish out of order, done tracks them so
+	// progress is reported in the order of ids.
+	done := make([]bool, tot)
+	next := 0
+	for result := range results {
+		save(result)
+		done[result.i] = true
+		for ; next < tot && done[next]; next++ {
+			if verbose && ((next % modValue) == 0) {
+				log.Printf("Harvested EPrint %d (%s)", ids[next], progress(t0, next, tot))
+			}
+		}
+	}
+	return harvested, failed
+}
+
+// readEPrintRecord takes a configuration with open database
+// connections, a repository name and EPrint record ID and reads the
+// record rendering the JSON source to store. It is safe to call
+// concurrently.
+func readEPrintRecord(cfg *Config, repoName string, i int, eprintID int) *harvestResult {
+	result := &harvestResult{i: i, eprintID: eprintID}
 	ds, ok := cfg.Repositories[repoName]
 	if !ok {
-		return fmt.Errorf("data source not found for %q looking up eprint %d", repoName, eprintID)
+		result.err = fmt.Errorf("data source not found for %q looking up eprint %d", repoName, eprintID)
+		return result
 	}
 	eprint, err := SQLReadEPrint(cfg, repoName, ds.BaseURL, eprintID)
 	if err != nil {
-		return err
+		result.err = err
+		return result
 	}
-	action := "created"
+	result.eprint = eprint
+	result.action = "created"
 	if eprint.Datestamp != eprint.LastModified {
-		action = "updated"
+		result.action = "updated"
 	}
 	if eprint.EPrintStatus == "deletion" {
-		action = "deleted"
+		result.action = "deleted"
 	}
 	// NOTE: Need to render synthetic fields ... like primary_object
 	eprint.SyntheticFields()
 
 	// NOTE: If we want to use simplified records we need to do the
 	// crosswalk here.
-	var src []byte
 	if UseSimpleRecord {
 		simple := new(simplified.Record)
 		err = CrosswalkEPrintToRecord(eprint, simple)
 		if err != nil {
-			return fmt.Errorf("failed to crosswalk eprint %d, %s", eprintID, err)
+			result.err = fmt.Errorf("failed to crosswalk eprint %d, %s", eprintID, err)
+			return result
 		}
-		src, _ = jsonEncode(simple)
+		result.src, _ = jsonEncode(simple)
 	} else {
-		src, _ = jsonEncode(eprint)
+		result.src, _ = jsonEncode(eprint)
 	}
-	err = SaveJSONDocument(cfg, repoName, eprintID, src, action, eprint.Datestamp, eprint.LastModified, eprint.PubDate(), eprint.EPrintStatus, eprint.IsPublic(), eprint.Type, eprint.ThesisType)
+	return result
+}
+
+// saveEPrintRecord saves a record read by readEPrintRecord to the
+// JSON store and aggregates it.
+func saveEPrintRecord(cfg *Config, repoName string, result *harvestResult) error {
+	eprint := result.eprint
+	err := SaveJSONDocument(cfg, repoName, result.eprintID, result.src, result.action, eprint.Datestamp, eprint.LastModified, eprint.PubDate(), eprint.EPrintStatus, eprint.IsPublic(), eprint.Type, eprint.ThesisType)
 	if err != nil {
 		return err
 	}
 	// Since we can save the JSON recordd, need to aggregate the contents of it.
-	aggregateEPrintRecord(cfg, repoName, eprintID, eprint)
-	return err
+	aggregateEPrintRecord(cfg, repoName, result.eprintID, eprint)
+	return nil
 }
 
 // aggregatePersons aggregates by the person related roles, e.g. creator, editor, contributor, advisor, committee memember
 func aggregatePersons(cfg *Config, repoName string, collection string, role string, eprintID int, recordType string, thesisType string, isPublic bool, pubDate string, personIDs []string) {
 	// Normalize the person id from the crosswalks using in repositories.
 	deleteStmt := fmt.Sprintf(`DELETE FROM _aggregate_%s WHERE repository = ? AND collection = ? AND eprintid = ?`, role)
-	cfg.Jdb.Exec(deleteStmt)
+	cfg.Jdb.Exec(deleteStmt, repoName, collection, eprintID)
 	if len(personIDs) > 0 {
 		insertStmt := fmt.Sprintf(`INSERT INTO _aggregate_%s (repository, collection, eprintid, person_id, record_type, thesis_type, is_public, pubdate) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, role)
 		for _, personID := range personIDs {
@@ -542,7 +724,7 @@ func aggregatePersons(cfg *Config, repoName string, collection string, role stri
 
 func aggregateOptions(cfg *Config, repoName string, collection string, tableName string, eprintID int, recordType string, thesisType string, isPublic bool, pubDate string, options []string) {
 	deleteStmt := fmt.Sprintf(`DELETE FROM %s WHERE repository = ? AND collection = ? AND eprintid = ?`, tableName)
-	cfg.Jdb.Exec(deleteStmt)
+	cfg.Jdb.Exec(deleteStmt, repoName, collection, eprintID)
 	if len(options) > 0 {
 		insertStmt := fmt.Sprintf(`INSERT INTO %s (repository, collection, eprintid, record_type, thesis_type, is_public, pubdate, local_option) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, tableName)
 		for _, option := range options {
@@ -565,7 +747,7 @@ func aggregateGroup(cfg *Config, repoName string, collection string, eprintID in
 // aggregateGroups aggregates a list of groups by group name
 func aggregateGroups(cfg *Config, repoName string, collection string, eprintID int, recordType string, thesisType string, isPublic bool, pubDate string, groups []string) {
 	deleteStmt := `DELETE FROM _aggregate_groups WHERE repository = ? AND collection = ? AND eprintid = ?`
-	cfg.Jdb.Exec(deleteStmt)
+	cfg.Jdb.Exec(deleteStmt, repoName, collection, eprintID)
 	if len(groups) > 0 {
 		for _, groupName := range groups {
 			// We need to check to see if group name is defined in groups.csv (i.e. _groups)
@@ -98,3 +98,77 @@ func Test03Genfeeds(t *testing.T) {
 	}
 }
 
+
+// TestHarvestCheckpoint tests how checkpoints start and advance
+func TestHarvestCheckpoint(t *testing.T) {
+	if start := checkpointStart(nil); start != harvestEpoch {
+		t.Errorf("expected %q, got %q", harvestEpoch, start)
+	}
+	checkpoint := &HarvestCheckpoint{Repository: "lemurprints", LastModified: "2022-05-31 12:00:00"}
+	if start := checkpointStart(checkpoint); start != checkpoint.LastModified {
+		t.Errorf("expected %q, got %q", checkpoint.LastModified, start)
+	}
+
+	// A committed harvest advances the checkpoint to the end of the range
+	next := nextCheckpoint(checkpoint, "lemurprints", "2022-05-31 12:00:00", "2022-06-30 00:00:00", 10, 0)
+	if next.Status != "ok" || next.LastModified != "2022-06-30 00:00:00" || next.Harvested != 10 {
+		t.Errorf("expected checkpoint to advance, got %+v", next)
+	}
+	// Nothing to harvest in the range, checkpoint advances to the end
+	next = nextCheckpoint(checkpoint, "lemurprints", "2022-05-31 12:00:00", "2022-06-30 00:00:00", 0, 0)
+	if next.Status != "ok" || next.LastModified != "2022-06-30 00:00:00" {
+		t.Errorf("expected checkpoint to advance, got %+v", next)
+	}
+	// Failures don't advance the checkpoint
+	next = nextCheckpoint(checkpoint, "lemurprints", "2022-05-31 12:00:00", "2022-06-30 00:00:00", 9, 1)
+	if next.Status != "partial" || next.LastModified != checkpoint.LastModified || next.Failed != 1 {
+		t.Errorf("expected partial harvest at %q, got %+v", checkpoint.LastModified, next)
+	}
+	next = nextCheckpoint(checkpoint, "lemurprints", "2022-05-31 12:00:00", "2022-06-30 00:00:00", 0, 3)
+	if next.Status != "failed" || next.LastModified != checkpoint.LastModified {
+		t.Errorf("expected failed harvest at %q, got %+v", checkpoint.LastModified, next)
+	}
+	// Re-harvesting an older range doesn't move the checkpoint backwards
+	next = nextCheckpoint(checkpoint, "lemurprints", "2021-01-01", "2021-12-31", 5, 0)
+	if next.LastModified != checkpoint.LastModified {
+		t.Errorf("expected checkpoint to stay at %q, got %+v", checkpoint.LastModified, next)
+	}
+	// A range starting after the checkpoint would leave a gap
+	next = nextCheckpoint(checkpoint, "lemurprints", "2022-06-15 00:00:00", "2022-06-30 00:00:00", 5, 0)
+	if next.LastModified != checkpoint.LastModified {
+		t.Errorf("expected checkpoint to stay at %q, got %+v", checkpoint.LastModified, next)
+	}
+	// First harvest
+	next = nextCheckpoint(nil, "lemurprints", harvestEpoch, "2022-06-30 00:00:00", 1, 0)
+	if next.LastModified != "2022-06-30 00:00:00" || next.Repository != "lemurprints" {
+		t.Errorf("expected first checkpoint, got %+v", next)
+	}
+}
+
+// TestHarvestWorkers tests the worker pool collects every result
+func TestHarvestWorkers(t *testing.T) {
+	cfg := new(Config)
+	cfg.Repositories = map[string]*DataSource{}
+	if workers := harvestWorkers(cfg); workers != 1 {
+		t.Errorf("expected 1 worker by default, got %d", workers)
+	}
+	cfg.HarvestWorkers = 4
+	if workers := harvestWorkers(cfg); workers != 4 {
+		t.Errorf("expected 4 workers from configuration, got %d", workers)
+	}
+	HarvestWorkers = 8
+	if workers := harvestWorkers(cfg); workers != 8 {
+		t.Errorf("expected 8 workers from HarvestWorkers, got %d", workers)
+	}
+	HarvestWorkers = 0
+
+	// NOTE: "lemurprints" isn't configured so each record fails
+	// without needing a database connection.
+	ids := []int{1, 2, 3, 5, 8, 13, 21, 34, 55, 89}
+	for _, workers := range []int{1, 3, 16} {
+		harvested, failed := harvestIDs(cfg, "lemurprints", ids, workers, false)
+		if harvested != 0 || failed != len(ids) {
+			t.Errorf("%d workers expected 0 harvested, %d failed, got %d, %d", workers, len(ids), harvested, failed)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+//
+// Package identifier parses, validates and normalizes the identifiers
+// found in EPrint records, DOI, ORCID, ISSN, ISBN, PMID and PMCID.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package identifier
+
+//
+// identifier.go accepts the many shapes identifiers are stored in
+// (e.g. DOI as URLs, ORCID without hyphens, ISBN-10 and ISBN-13),
+// checks their check digits and returns a canonical form along
+// with the other forms used when looking them up.
+//
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Kinds of identifiers supported by Normalize and Forms
+const (
+	DOI   = "doi"
+	ORCID = "orcid"
+	ISSN  = "issn"
+	ISBN  = "isbn"
+	PMID  = "pmid"
+	PMCID = "pmcid"
+)
+
+var (
+	reDOI   = regexp.MustCompile(`^10\.[0-9]{4,9}(\.[0-9]+)*/\S+$`)
+	reORCID = regexp.MustCompile(`^[0-9]{15}[0-9X]$`)
+	reISSN  = regexp.MustCompile(`^[0-9]{7}[0-9X]$`)
+	reISBN  = regexp.MustCompile(`^([0-9]{9}[0-9X]|[0-9]{13})$`)
+	rePMID  = regexp.MustCompile(`^[0-9]{1,9}$`)
+
+	// doiPrefixes are removed from the start of a DOI
+	doiPrefixes = []string{
+		"https://doi.org/", "http://doi.org/",
+		"https://dx.doi.org/", "http://dx.doi.org/",
+		"doi.org/", "dx.doi.org/",
+		"info:doi/", "doi:",
+	}
+
+	// orcidPrefixes are removed from the start of an ORCID
+	orcidPrefixes = []string{
+		"https://orcid.org/", "http://orcid.org/",
+		"https://www.orcid.org/", "http://www.orcid.org/",
+		"orcid.org/", "orcid:",
+	}
+)
+
+// trimPrefixFold removes the first prefix found ignoring case
+func trimPrefixFold(s string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if len(s) >= len(prefix) && strings.EqualFold(s[0:len(prefix)], prefix) {
+			return s[len(prefix):]
+		}
+	}
+	return s
+}
+
+// compact removes spaces and hyphens (including the unicode
+// dashes copied from PDFs) and upper cases s.
+func compact(s string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '\u2010', '\u2011', '\u2012', '\u2013', '\u2014', '\t':
+			return -1
+		}
+		return r
+	}, s))
+}
+
+// lastPathElement returns the last non-empty path element of a URL,
+// s is returned if it isn't a URL.
+func lastPathElement(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+// mod11CheckDigit returns the ISSN and ISBN-10 check digit, "X"
+// stands for 10. The weights count down from len(digits)+1.
+func mod11CheckDigit(digits string) string {
+	sum, weight := 0, len(digits)+1
+	for _, c := range digits {
+		sum += int(c-'0') * weight
+		weight--
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return "X"
+	}
+	return fmt.Sprintf("%d", check)
+}
+
+// NormalizeDOI returns a DOI without a URL or "doi:" prefix (e.g.
+// "10.1021/acsami.7b15651") or an error if it isn't a DOI.
+func NormalizeDOI(s string) (string, error) {
+	doi := trimPrefixFold(strings.TrimSpace(s), doiPrefixes)
+	if strings.Contains(s, "://") {
+		if unescaped, err := url.PathUnescape(doi); err == nil {
+			doi = unescaped
+		}
+	}
+	if !reDOI.MatchString(doi) {
+		return "", fmt.Errorf("%q is not a DOI", s)
+	}
+	return doi, nil
+}
+
+// DOIURL returns the https://doi.org URL of a DOI
+func DOIURL(doi string) string {
+	return "https://doi.org/" + doi
+}
+
+// orcidCheckDigit returns the ISO 7064 11-2 check digit of the first
+// 15 digits of an ORCID
+func orcidCheckDigit(digits string) string {
+	total := 0
+	for _, c := range digits {
+		total = (total + int(c-'0')) * 2
+	}
+	check := (12 - total%11) % 11
+	if check == 10 {
+		return "X"
+	}
+	return fmt.Sprintf("%d", check)
+}
+
+// NormalizeORCID returns an ORCID in its hyphenated form (e.g.
+// "0000-0002-1825-0097"). The orcid.org URL, hyphens and spaces
+// are optional, the check digit must be correct.
+func NormalizeORCID(s string) (string, error) {
+	orcid := compact(trimPrefixFold(strings.TrimSpace(s), orcidPrefixes))
+	if !reORCID.MatchString(orcid) {
+		return "", fmt.Errorf("%q is not an ORCID", s)
+	}
+	if orcidCheckDigit(orcid[0:15]) != orcid[15:] {
+		return "", fmt.Errorf("%q has an incorrect check digit", s)
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", orcid[0:4], orcid[4:8], orcid[8:12], orcid[12:16]), nil
+}
+
+// ORCIDURL returns the https://orcid.org URL of an ORCID
+func ORCIDURL(orcid string) string {
+	return "https://orcid.org/" + orcid
+}
+
+// NormalizeISSN returns an ISSN in its hyphenated form (e.g.
+// "0378-5955"). A leading "ISSN" and the hyphen are optional, the
+// check digit must be correct.
+func NormalizeISSN(s string) (string, error) {
+	issn := compact(strings.TrimSpace(s))
+	issn = strings.TrimPrefix(strings.TrimPrefix(issn, "ISSN"), ":")
+	if !reISSN.MatchString(issn) {
+		return "", fmt.Errorf("%q is not an ISSN", s)
+	}
+	if mod11CheckDigit(issn[0:7]) != issn[7:] {
+		return "", fmt.Errorf("%q has an incorrect check digit", s)
+	}
+	return issn[0:4] + "-" + issn[4:], nil
+}
+
+// isbn13CheckDigit returns the check digit of the first 12 digits
+// of an ISBN-13
+func isbn13CheckDigit(digits string) string {
+	sum := 0
+	for i, c := range digits {
+		if i%2 == 0 {
+			sum += int(c - '0')
+		} else {
+			sum += int(c-'0') * 3
+		}
+	}
+	return fmt.Sprintf("%d", (10-sum%10)%10)
+}
+
+// ParseISBN returns an ISBN-10 or ISBN-13 without hyphens or spaces
+// (e.g. "0306406152" or "9780306406157"). A leading "ISBN" is
+// optional, the check digit must be correct.
+func ParseISBN(s string) (string, error) {
+	isbn := compact(strings.TrimSpace(s))
+	for _, prefix := range []string{"ISBN13", "ISBN10", "ISBN"} {
+		if strings.HasPrefix(isbn, prefix) {
+			isbn = strings.TrimPrefix(isbn[len(prefix):], ":")
+			break
+		}
+	}
+	if !reISBN.MatchString(isbn) {
+		return "", fmt.Errorf("%q is not an ISBN", s)
+	}
+	if len(isbn) == 10 && mod11CheckDigit(isbn[0:9]) != isbn[9:] {
+		return "", fmt.Errorf("%q has an incorrect check digit", s)
+	}
+	if len(isbn) == 13 && isbn13CheckDigit(isbn[0:12]) != isbn[12:] {
+		return "", fmt.Errorf("%q has an incorrect check digit", s)
+	}
+	return isbn, nil
+}
+
+// ISBN10To13 converts an ISBN-10 to an ISBN-13
+func ISBN10To13(s string) (string, error) {
+	isbn, err := ParseISBN(s)
+	if err != nil {
+		return "", err
+	}
+	if len(isbn) == 13 {
+		return isbn, nil
+	}
+	isbn = "978" + isbn[0:9]
+	return isbn + isbn13CheckDigit(isbn), nil
+}
+
+// ISBN13To10 converts an ISBN-13 to an ISBN-10, only ISBN-13 starting
+// with 978 have an ISBN-10.
+func ISBN13To10(s string) (string, error) {
+	isbn, err := ParseISBN(s)
+	if err != nil {
+		return "", err
+	}
+	if len(isbn) == 10 {
+		return isbn, nil
+	}
+	if !strings.HasPrefix(isbn, "978") {
+		return "", fmt.Errorf("%q has no ISBN-10", s)
+	}
+	isbn = isbn[3:12]
+	return isbn + mod11CheckDigit(isbn), nil
+}
+
+// NormalizeISBN returns an ISBN as an ISBN-13 without hyphens
+func NormalizeISBN(s string) (string, error) {
+	return ISBN10To13(s)
+}
+
+// NormalizePMID returns a PubMed ID as digits without leading zeros.
+// A "PMID" prefix or PubMed URL are accepted.
+func NormalizePMID(s string) (string, error) {
+	pmid := compact(lastPathElement(strings.TrimSpace(s)))
+	pmid = strings.TrimPrefix(strings.TrimPrefix(pmid, "PMID"), ":")
+	if !rePMID.MatchString(pmid) || strings.Trim(pmid, "0") == "" {
+		return "", fmt.Errorf("%q is not a PMID", s)
+	}
+	return strings.TrimLeft(pmid, "0"), nil
+}
+
+// NormalizePMCID returns a PubMed Central ID with its "PMC" prefix
+// (e.g. "PMC1234567"). The prefix is optional and PMC article URLs
+// are accepted.
+func NormalizePMCID(s string) (string, error) {
+	pmcid := compact(lastPathElement(strings.TrimSpace(s)))
+	pmcid = strings.TrimPrefix(strings.TrimPrefix(pmcid, "PMCID"), ":")
+	pmcid = strings.TrimPrefix(pmcid, "PMC")
+	if !rePMID.MatchString(pmcid) || strings.Trim(pmcid, "0") == "" {
+		return "", fmt.Errorf("%q is not a PMCID", s)
+	}
+	return "PMC" + strings.TrimLeft(pmcid, "0"), nil
+}
+
+// Normalize returns the canonical form of an identifier of kind
+// (e.g. identifier.ORCID).
+func Normalize(kind string, s string) (string, error) {
+	switch kind {
+	case DOI:
+		return NormalizeDOI(s)
+	case ORCID:
+		return NormalizeORCID(s)
+	case ISSN:
+		return NormalizeISSN(s)
+	case ISBN:
+		return NormalizeISBN(s)
+	case PMID:
+		return NormalizePMID(s)
+	case PMCID:
+		return NormalizePMCID(s)
+	}
+	return "", fmt.Errorf("%q is not a supported identifier", kind)
+}
+
+// Forms returns the forms an identifier may be stored in, starting
+// with the canonical form. It is used to look up an identifier
+// however it was entered, e.g. an ISBN-13 and the matching ISBN-10.
+func Forms(kind string, s string) ([]string, error) {
+	value, err := Normalize(kind, s)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case DOI:
+		forms := []string{value}
+		for _, prefix := range doiPrefixes {
+			if strings.Contains(prefix, "://") || prefix == "doi:" {
+				forms = append(forms, prefix+value)
+			}
+		}
+		return forms, nil
+	case ORCID:
+		forms := []string{value, compact(value)}
+		for _, prefix := range orcidPrefixes {
+			if strings.Contains(prefix, "://") {
+				forms = append(forms, prefix+value)
+			}
+		}
+		return forms, nil
+	case ISSN:
+		return []string{value, compact(value)}, nil
+	case ISBN:
+		if isbn10, err := ISBN13To10(value); err == nil {
+			return []string{value, isbn10}, nil
+		}
+		return []string{value}, nil
+	case PMCID:
+		return []string{value, strings.TrimPrefix(value, "PMC")}, nil
+	}
+	return []string{value}, nil
+}
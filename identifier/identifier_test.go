@@ -0,0 +1,236 @@
+package identifier
+
+import (
+	"testing"
+)
+
+func TestNormalizeDOI(t *testing.T) {
+	for src, expected := range map[string]string{
+		"10.1021/acsami.7b15651":                        "10.1021/acsami.7b15651",
+		" https://doi.org/10.1021/acsami.7b15651 ":      "10.1021/acsami.7b15651",
+		"http://dx.doi.org/10.1093/mnras/stu2495":       "10.1093/mnras/stu2495",
+		"HTTPS://DOI.ORG/10.1093/mnras/stu2495":         "10.1093/mnras/stu2495",
+		"doi:10.1093/mnras/stu2495":                     "10.1093/mnras/stu2495",
+		"info:doi/10.1000/182":                          "10.1000/182",
+		"https://doi.org/10.1002%2F(SICI)1097-4636":     "10.1002/(SICI)1097-4636",
+		"10.22002/D1.868":                               "10.22002/D1.868",
+		"10.1000.10/123456":                             "10.1000.10/123456",
+		"https://resolver.caltech.edu/CaltechAUTHORS:1": "",
+		"1021/acsami.7b15651":                           "",
+		"10.1021/":                                      "",
+		"":                                              "",
+	} {
+		doi, err := NormalizeDOI(src)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%q, expected an error, got %q", src, doi)
+			}
+			continue
+		}
+		if err != nil || doi != expected {
+			t.Errorf("%q, expected %q, got %q, %v", src, expected, doi, err)
+		}
+	}
+	assertEqual(t, "DOIURL", "https://doi.org/10.1000/182", DOIURL("10.1000/182"))
+}
+
+func TestNormalizeORCID(t *testing.T) {
+	for src, expected := range map[string]string{
+		"0000-0002-1825-0097":                   "0000-0002-1825-0097",
+		"0000000218250097":                      "0000-0002-1825-0097",
+		"https://orcid.org/0000-0002-1825-0097": "0000-0002-1825-0097",
+		"http://orcid.org/0000000218250097":     "0000-0002-1825-0097",
+		"0000-0002-1694-233x":                   "0000-0002-1694-233X",
+		"0000 0001 5109 3700":                   "0000-0001-5109-3700",
+		"0000-0002-1825-0098":                   "",
+		"0000-0002-1825-009":                    "",
+		"0000-0002-1825-00A7":                   "",
+	} {
+		orcid, err := NormalizeORCID(src)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%q, expected an error, got %q", src, orcid)
+			}
+			continue
+		}
+		if err != nil || orcid != expected {
+			t.Errorf("%q, expected %q, got %q, %v", src, expected, orcid, err)
+		}
+	}
+	assertEqual(t, "ORCIDURL", "https://orcid.org/0000-0002-1825-0097", ORCIDURL("0000-0002-1825-0097"))
+}
+
+func TestNormalizeISSN(t *testing.T) {
+	for src, expected := range map[string]string{
+		"0378-5955":       "0378-5955",
+		"03785955":        "0378-5955",
+		"ISSN 0378-5955":  "0378-5955",
+		"ISSN: 0378-5955": "0378-5955",
+		"2049-3630":       "2049-3630",
+		"0000-006x":       "0000-006X",
+		"0378-5954":       "",
+		"0378-595":        "",
+	} {
+		issn, err := NormalizeISSN(src)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%q, expected an error, got %q", src, issn)
+			}
+			continue
+		}
+		if err != nil || issn != expected {
+			t.Errorf("%q, expected %q, got %q, %v", src, expected, issn, err)
+		}
+	}
+}
+
+func TestISBN(t *testing.T) {
+	for src, expected := range map[string]string{
+		"0-306-40615-2":              "0306406152",
+		"978-0-306-40615-7":          "9780306406157",
+		"ISBN 0-8044-2957-X":         "080442957X",
+		"ISBN-13: 978-0-306-40615-7": "9780306406157",
+		"0-306-40615-3":              "",
+		"978-0-306-40615-8":          "",
+		"12345":                      "",
+	} {
+		isbn, err := ParseISBN(src)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%q, expected an error, got %q", src, isbn)
+			}
+			continue
+		}
+		if err != nil || isbn != expected {
+			t.Errorf("%q, expected %q, got %q, %v", src, expected, isbn, err)
+		}
+	}
+	isbn, err := ISBN10To13("0-306-40615-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "ISBN10To13", "9780306406157", isbn)
+	isbn, err = ISBN13To10("978-0-8044-2957-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "ISBN13To10", "080442957X", isbn)
+	if _, err := ISBN13To10("979-10-90636-07-1"); err == nil {
+		t.Errorf("expected an error, 979 ISBN-13 have no ISBN-10")
+	}
+	isbn, err = NormalizeISBN("080442957X")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "NormalizeISBN", "9780804429573", isbn)
+}
+
+func TestNormalizePubMed(t *testing.T) {
+	for src, expected := range map[string]string{
+		"12345678":       "12345678",
+		"PMID: 12345678": "12345678",
+		"https://pubmed.ncbi.nlm.nih.gov/12345678/": "12345678",
+		"0012345": "12345",
+		"PMC123":  "",
+		"0":       "",
+	} {
+		pmid, err := NormalizePMID(src)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%q, expected an error, got %q", src, pmid)
+			}
+			continue
+		}
+		if err != nil || pmid != expected {
+			t.Errorf("%q, expected %q, got %q, %v", src, expected, pmid, err)
+		}
+	}
+	for src, expected := range map[string]string{
+		"PMC1234567": "PMC1234567",
+		"pmc1234567": "PMC1234567",
+		"1234567":    "PMC1234567",
+		"https://www.ncbi.nlm.nih.gov/pmc/articles/PMC1234567/": "PMC1234567",
+		"PMCID: PMC1234567": "PMC1234567",
+		"PMC":               "",
+		"PMC12a":            "",
+	} {
+		pmcid, err := NormalizePMCID(src)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%q, expected an error, got %q", src, pmcid)
+			}
+			continue
+		}
+		if err != nil || pmcid != expected {
+			t.Errorf("%q, expected %q, got %q, %v", src, expected, pmcid, err)
+		}
+	}
+}
+
+func TestForms(t *testing.T) {
+	forms, err := Forms(ISBN, "0-306-40615-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertForms(t, []string{"9780306406157", "0306406152"}, forms)
+	forms, err = Forms(ISSN, "ISSN 0378-5955")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertForms(t, []string{"0378-5955", "03785955"}, forms)
+	forms, err = Forms(ORCID, "0000000218250097")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forms[0] != "0000-0002-1825-0097" || !contains(forms, "0000000218250097") || !contains(forms, "https://orcid.org/0000-0002-1825-0097") {
+		t.Errorf("unexpected ORCID forms %q", forms)
+	}
+	forms, err = Forms(DOI, "https://doi.org/10.1000/182")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forms[0] != "10.1000/182" || !contains(forms, "https://doi.org/10.1000/182") || !contains(forms, "doi:10.1000/182") {
+		t.Errorf("unexpected DOI forms %q", forms)
+	}
+	forms, err = Forms(PMCID, "PMC1234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertForms(t, []string{"PMC1234567", "1234567"}, forms)
+	if _, err := Forms("ark", "ark:/13030/tf5p30086k"); err == nil {
+		t.Errorf("expected an error for an unsupported identifier")
+	}
+	if _, err := Forms(ISSN, "not an issn"); err == nil {
+		t.Errorf("expected an error for an invalid ISSN")
+	}
+}
+
+func assertEqual(t *testing.T, label string, expected string, got string) {
+	t.Helper()
+	if expected != got {
+		t.Errorf("%s, expected %q, got %q", label, expected, got)
+	}
+}
+
+func assertForms(t *testing.T, expected []string, got []string) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Errorf("expected %q, got %q", expected, got)
+		return
+	}
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Errorf("expected %q, got %q", expected, got)
+			return
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
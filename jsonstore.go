@@ -96,6 +96,31 @@ func GetJSONDocument(cfg *Config, repoName string, id int) ([]byte, error) {
 	return src, err
 }
 
+// GetJSONDocumentIDs takes a configuration and repoName and returns
+// the ids held in the JSON store for that repository. If publicOnly
+// is true then only ids of public records are returned.
+func GetJSONDocumentIDs(cfg *Config, repoName string, publicOnly bool) ([]int, error) {
+	stmt := fmt.Sprintf("SELECT id FROM %s ORDER BY id", repoName)
+	if publicOnly {
+		stmt = fmt.Sprintf("SELECT id FROM %s WHERE is_public = TRUE ORDER BY id", repoName)
+	}
+	rows, err := cfg.Jdb.Query(stmt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ids for %s, %s", repoName, err)
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to get id in %s, %s", repoName, err)
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	return ids, err
+}
+
 // pruneEPrint removes emails addresses and .Notes from the EPrint record
 func pruneEPrint(eprint *EPrint) *EPrint {
 	// FIXME: Do we want to follow the internal flag or just
@@ -423,3 +448,73 @@ func GetGroupAggregations(cfg *Config, groupID string) (map[string]map[string][]
 	err = rows.Err()
 	return m, err
 }
+
+// HarvestCheckpoint holds the state of the last harvest of a
+// repository. It is stored in the _harvest_checkpoints table.
+type HarvestCheckpoint struct {
+	// Repository is the repository id (i.e. table name in the jsonstore)
+	Repository string `json:"repository"`
+	// LastModified is the latest lastmod timestamp seen in a
+	// committed harvest, the next harvest starts from here.
+	LastModified string `json:"lastmod,omitempty"`
+	// Start and End are the range requested in the last harvest
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// Status of the last harvest, "ok", "failed" or "partial"
+	Status string `json:"status,omitempty"`
+	// Harvested is the count of records harvested in the last run
+	Harvested int `json:"harvested"`
+	// Failed is the count of records which failed in the last run
+	Failed  int       `json:"failed"`
+	Updated time.Time `json:"updated,omitempty"`
+}
+
+// harvestCheckpointSchema is used to create the checkpoint table
+// in jsonstores which were created before checkpoints existed.
+const harvestCheckpointSchema = `CREATE TABLE IF NOT EXISTS _harvest_checkpoints (
+    repository VARCHAR(256) NOT NULL PRIMARY KEY,
+    lastmod VARCHAR(256) DEFAULT "",
+    start VARCHAR(256) DEFAULT "",
+    end VARCHAR(256) DEFAULT "",
+    status VARCHAR(256) DEFAULT "",
+    harvested INTEGER DEFAULT 0,
+    failed INTEGER DEFAULT 0,
+    updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+)`
+
+// GetHarvestCheckpoint returns the checkpoint for repoName. If no
+// harvest has been recorded then nil is returned without an error.
+func GetHarvestCheckpoint(cfg *Config, repoName string) (*HarvestCheckpoint, error) {
+	if _, err := cfg.Jdb.Exec(harvestCheckpointSchema); err != nil {
+		return nil, fmt.Errorf("failed to create _harvest_checkpoints, %s", err)
+	}
+	stmt := `SELECT repository, lastmod, start, end, status, harvested, failed, updated FROM _harvest_checkpoints WHERE repository = ? LIMIT 1`
+	rows, err := cfg.Jdb.Query(stmt, repoName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkpoint for %s, %s", repoName, err)
+	}
+	defer rows.Close()
+	var checkpoint *HarvestCheckpoint
+	for rows.Next() {
+		var updated string
+		checkpoint = new(HarvestCheckpoint)
+		if err := rows.Scan(&checkpoint.Repository, &checkpoint.LastModified, &checkpoint.Start, &checkpoint.End, &checkpoint.Status, &checkpoint.Harvested, &checkpoint.Failed, &updated); err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint for %s, %s", repoName, err)
+		}
+		checkpoint.Updated, _ = time.Parse(MySQLTimestamp, updated)
+	}
+	err = rows.Err()
+	return checkpoint, err
+}
+
+// SaveHarvestCheckpoint saves the checkpoint for a repository
+func SaveHarvestCheckpoint(cfg *Config, checkpoint *HarvestCheckpoint) error {
+	if _, err := cfg.Jdb.Exec(harvestCheckpointSchema); err != nil {
+		return fmt.Errorf("failed to create _harvest_checkpoints, %s", err)
+	}
+	stmt := `REPLACE INTO _harvest_checkpoints (repository, lastmod, start, end, status, harvested, failed) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if _, err := cfg.Jdb.Exec(stmt, checkpoint.Repository, checkpoint.LastModified, checkpoint.Start, checkpoint.End, checkpoint.Status, checkpoint.Harvested, checkpoint.Failed); err != nil {
+		return fmt.Errorf("failed to save checkpoint for %s, %s", checkpoint.Repository, err)
+	}
+	return nil
+}
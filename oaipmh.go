@@ -0,0 +1,984 @@
+package eprinttools
+
+//
+// oaipmh.go implements an OAI-PMH 2.0 data provider for the extended
+// EPrints API. It is built on the same SQL functions as the other
+// end points so it can be used against the EPrints MySQL database
+// directly without going through the EPrints Perl OAI provider.
+//
+// See https://www.openarchives.org/OAI/openarchivesprotocol.html
+//
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// oaiPageSize is the number of headers or records returned
+	// before a resumptionToken is issued.
+	oaiPageSize = 100
+
+	// oaiTimestamp is the UTC date time granularity we support
+	oaiTimestamp = "2006-01-02T15:04:05Z"
+
+	// oaiEarliest is used when we can't determine the earliest
+	// datestamp from the repository.
+	oaiEarliest = "2000-01-01 00:00:00"
+
+	oaiPMHNamespace    = "http://www.openarchives.org/OAI/2.0/"
+	oaiPMHSchema       = "http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd"
+	oaiDCNamespace     = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	oaiDCSchema        = "http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+	dcNamespace        = "http://purl.org/dc/elements/1.1/"
+	eprintsNamespace   = "http://eprints.org/ep2/data/2.0"
+	eprintsSchema      = "http://eprints.org/ep2/data/2.0"
+	xsiNamespace       = "http://www.w3.org/2001/XMLSchema-instance"
+	oaiIdentifierSpace = "http://www.openarchives.org/OAI/2.0/oai-identifier"
+)
+
+// OAIPMH is the outer element of an OAI-PMH response
+type OAIPMH struct {
+	XMLName        xml.Name         `xml:"OAI-PMH"`
+	XMLNS          string           `xml:"xmlns,attr"`
+	XMLNSXSI       string           `xml:"xmlns:xsi,attr"`
+	SchemaLocation string           `xml:"xsi:schemaLocation,attr"`
+	ResponseDate   string           `xml:"responseDate"`
+	Request        *OAIRequest      `xml:"request"`
+	Errors         []*OAIError      `xml:"error,omitempty"`
+	Identify       *OAIIdentify     `xml:"Identify,omitempty"`
+	ListMetadata   *OAIListMetadata `xml:"ListMetadataFormats,omitempty"`
+	ListSets       *OAIListSets     `xml:"ListSets,omitempty"`
+	ListIDs        *OAIListIDs      `xml:"ListIdentifiers,omitempty"`
+	ListRecords    *OAIListRecords  `xml:"ListRecords,omitempty"`
+	GetRecord      *OAIGetRecord    `xml:"GetRecord,omitempty"`
+}
+
+// OAIRequest echos the request recieved
+type OAIRequest struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	Set             string `xml:"set,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+	URL             string `xml:",chardata"`
+}
+
+// OAIError holds an OAI-PMH error code and message
+type OAIError struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+// OAIIdentify describes the repository
+type OAIIdentify struct {
+	RepositoryName    string          `xml:"repositoryName"`
+	BaseURL           string          `xml:"baseURL"`
+	ProtocolVersion   string          `xml:"protocolVersion"`
+	AdminEmail        []string        `xml:"adminEmail,omitempty"`
+	EarliestDatestamp string          `xml:"earliestDatestamp"`
+	DeletedRecord     string          `xml:"deletedRecord"`
+	Granularity       string          `xml:"granularity"`
+	Description       *OAIDescription `xml:"description,omitempty"`
+}
+
+// OAIDescription holds the oai-identifier description for Identify
+type OAIDescription struct {
+	OAIIdentifier *OAIIdentifierDescription `xml:"oai-identifier"`
+}
+
+// OAIIdentifierDescription describes the scheme used for identifiers
+type OAIIdentifierDescription struct {
+	XMLNS                string `xml:"xmlns,attr"`
+	Scheme               string `xml:"scheme"`
+	RepositoryIdentifier string `xml:"repositoryIdentifier"`
+	Delimiter            string `xml:"delimiter"`
+	SampleIdentifier     string `xml:"sampleIdentifier"`
+}
+
+// OAIMetadataFormat describes a supported metadata format
+type OAIMetadataFormat struct {
+	MetadataPrefix    string `xml:"metadataPrefix"`
+	Schema            string `xml:"schema"`
+	MetadataNamespace string `xml:"metadataNamespace"`
+}
+
+// OAIListMetadata holds the ListMetadataFormats response
+type OAIListMetadata struct {
+	Formats []*OAIMetadataFormat `xml:"metadataFormat"`
+}
+
+// OAISet describes a set
+type OAISet struct {
+	SetSpec string `xml:"setSpec"`
+	SetName string `xml:"setName"`
+}
+
+// OAIListSets holds the ListSets response
+type OAIListSets struct {
+	Sets            []*OAISet           `xml:"set"`
+	ResumptionToken *OAIResumptionToken `xml:"resumptionToken,omitempty"`
+}
+
+// OAIHeader is the header of a record
+type OAIHeader struct {
+	Status     string   `xml:"status,attr,omitempty"`
+	Identifier string   `xml:"identifier"`
+	Datestamp  string   `xml:"datestamp"`
+	SetSpec    []string `xml:"setSpec,omitempty"`
+}
+
+// OAIMetadata holds the metadata of a record already rendered as XML
+type OAIMetadata struct {
+	Src []byte `xml:",innerxml"`
+}
+
+// OAIRecord is a header plus metadata
+type OAIRecord struct {
+	Header   *OAIHeader   `xml:"header"`
+	Metadata *OAIMetadata `xml:"metadata,omitempty"`
+}
+
+// OAIResumptionToken is used for flow control
+type OAIResumptionToken struct {
+	CompleteListSize int    `xml:"completeListSize,attr"`
+	Cursor           int    `xml:"cursor,attr"`
+	Token            string `xml:",chardata"`
+}
+
+// OAIListIDs holds the ListIdentifiers response
+type OAIListIDs struct {
+	Headers         []*OAIHeader        `xml:"header"`
+	ResumptionToken *OAIResumptionToken `xml:"resumptionToken,omitempty"`
+}
+
+// OAIListRecords holds the ListRecords response
+type OAIListRecords struct {
+	Records         []*OAIRecord        `xml:"record"`
+	ResumptionToken *OAIResumptionToken `xml:"resumptionToken,omitempty"`
+}
+
+// OAIGetRecord holds the GetRecord response
+type OAIGetRecord struct {
+	Record *OAIRecord `xml:"record"`
+}
+
+// OAIDC is the oai_dc (unqualified Dublin Core) metadata format
+type OAIDC struct {
+	XMLName        xml.Name `xml:"oai_dc:dc"`
+	XMLNSOAIDC     string   `xml:"xmlns:oai_dc,attr"`
+	XMLNSDC        string   `xml:"xmlns:dc,attr"`
+	XMLNSXSI       string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Title          []string `xml:"dc:title,omitempty"`
+	Creator        []string `xml:"dc:creator,omitempty"`
+	Subject        []string `xml:"dc:subject,omitempty"`
+	Description    []string `xml:"dc:description,omitempty"`
+	Publisher      []string `xml:"dc:publisher,omitempty"`
+	Contributor    []string `xml:"dc:contributor,omitempty"`
+	Date           []string `xml:"dc:date,omitempty"`
+	Type           []string `xml:"dc:type,omitempty"`
+	Format         []string `xml:"dc:format,omitempty"`
+	Identifier     []string `xml:"dc:identifier,omitempty"`
+	Source         []string `xml:"dc:source,omitempty"`
+	Language       []string `xml:"dc:language,omitempty"`
+	Relation       []string `xml:"dc:relation,omitempty"`
+	Rights         []string `xml:"dc:rights,omitempty"`
+}
+
+// oaiMetadataFormats lists the metadata formats we can disseminate
+var oaiMetadataFormats = []*OAIMetadataFormat{
+	{
+		MetadataPrefix:    "oai_dc",
+		Schema:            oaiDCSchema,
+		MetadataNamespace: oaiDCNamespace,
+	},
+	{
+		MetadataPrefix:    "eprint",
+		Schema:            eprintsSchema,
+		MetadataNamespace: eprintsNamespace,
+	},
+}
+
+// oaiSetFields are the EPrint fields used to derive sets. The
+// set spec takes the form FIELD:VALUE, e.g. "type:article".
+var oaiSetFields = []string{"collection", "type", "local_group"}
+
+// oaiVerbArgs maps the verbs to their allowed and required arguments.
+var oaiVerbArgs = map[string]map[string]bool{
+	"Identify":            {},
+	"ListMetadataFormats": {"identifier": false},
+	"ListSets":            {"resumptionToken": false},
+	"ListIdentifiers":     {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+	"ListRecords":         {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+	"GetRecord":           {"identifier": true, "metadataPrefix": true},
+}
+
+// oaiResumption holds the state encoded in a resumption token
+type oaiResumption struct {
+	MetadataPrefix string
+	From           string
+	Until          string
+	Set            string
+	Offset         int
+}
+
+// encodeResumptionToken renders the harvest state as an opaque string.
+func encodeResumptionToken(rt *oaiResumption) string {
+	q := url.Values{}
+	q.Set("m", rt.MetadataPrefix)
+	q.Set("f", rt.From)
+	q.Set("u", rt.Until)
+	q.Set("s", rt.Set)
+	q.Set("o", strconv.Itoa(rt.Offset))
+	return base64.RawURLEncoding.EncodeToString([]byte(q.Encode()))
+}
+
+// decodeResumptionToken reverses encodeResumptionToken
+func decodeResumptionToken(token string) (*oaiResumption, error) {
+	src, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resumption token")
+	}
+	q, err := url.ParseQuery(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("invalid resumption token")
+	}
+	rt := new(oaiResumption)
+	rt.MetadataPrefix = q.Get("m")
+	rt.From = q.Get("f")
+	rt.Until = q.Get("u")
+	rt.Set = q.Get("s")
+	rt.Offset, err = strconv.Atoi(q.Get("o"))
+	if err != nil || rt.Offset < 0 {
+		return nil, fmt.Errorf("invalid resumption token")
+	}
+	return rt, nil
+}
+
+// oaiSetSafe reports if a byte can appear unescaped in a setSpec.
+func oaiSetSafe(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') ||
+		('0' <= c && c <= '9') || strings.IndexByte("-_.!*'()", c) >= 0
+}
+
+// encodeSetValue escapes a field value so it is a valid setSpec
+// component. Characters outside the setSpec alphabet are written
+// as "~" followed by two hex digits.
+func encodeSetValue(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if oaiSetSafe(s[i]) {
+			sb.WriteByte(s[i])
+		} else {
+			fmt.Fprintf(&sb, "~%02X", s[i])
+		}
+	}
+	return sb.String()
+}
+
+// decodeSetValue reverses encodeSetValue
+func decodeSetValue(s string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '~' {
+			if i+2 >= len(s) {
+				return "", fmt.Errorf("invalid set value %q", s)
+			}
+			b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid set value %q", s)
+			}
+			sb.WriteByte(byte(b))
+			i += 2
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// makeSetSpec returns the setSpec for field and value
+func makeSetSpec(field string, value string) string {
+	return fmt.Sprintf("%s:%s", field, encodeSetValue(value))
+}
+
+// parseSetSpec splits a setSpec into field and value
+func parseSetSpec(setSpec string) (string, string, error) {
+	parts := strings.SplitN(setSpec, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("unsupported set %q", setSpec)
+	}
+	found := false
+	for _, field := range oaiSetFields {
+		if parts[0] == field {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", "", fmt.Errorf("unsupported set %q", setSpec)
+	}
+	value, err := decodeSetValue(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+	return parts[0], value, nil
+}
+
+// eprintSetSpecs returns the setSpecs an EPrint belongs to
+func eprintSetSpecs(eprint *EPrint) []string {
+	setSpecs := []string{}
+	if eprint.Collection != "" {
+		setSpecs = append(setSpecs, makeSetSpec("collection", eprint.Collection))
+	}
+	if eprint.Type != "" {
+		setSpecs = append(setSpecs, makeSetSpec("type", eprint.Type))
+	}
+	if eprint.LocalGroup != nil {
+		for _, item := range eprint.LocalGroup.Items {
+			if s := strings.TrimSpace(item.Value); s != "" {
+				setSpecs = append(setSpecs, makeSetSpec("local_group", s))
+			}
+		}
+	}
+	return setSpecs
+}
+
+// oaiNamespace returns the repository identifier used in
+// OAI identifiers, e.g. "authors.library.caltech.edu". If the
+// data source has no base url then the repoID is used.
+func oaiNamespace(repoID string, ds *DataSource) string {
+	if ds != nil && ds.BaseURL != "" {
+		if u, err := url.Parse(ds.BaseURL); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+	return repoID
+}
+
+// makeOAIIdentifier returns an OAI identifier for an eprint id
+func makeOAIIdentifier(namespace string, eprintID int) string {
+	return fmt.Sprintf("oai:%s:%d", namespace, eprintID)
+}
+
+// parseOAIIdentifier returns the eprint id from an OAI identifier
+func parseOAIIdentifier(namespace string, identifier string) (int, error) {
+	prefix := fmt.Sprintf("oai:%s:", namespace)
+	if !strings.HasPrefix(identifier, prefix) {
+		return 0, fmt.Errorf("unknown identifier %q", identifier)
+	}
+	eprintID, err := strconv.Atoi(strings.TrimPrefix(identifier, prefix))
+	if err != nil || eprintID <= 0 {
+		return 0, fmt.Errorf("unknown identifier %q", identifier)
+	}
+	return eprintID, nil
+}
+
+// oaiToTimestamp converts an OAI-PMH from/until value to a
+// MySQL style timestamp. If roundDown is false a day granularity
+// value is expanded to the end of the day.
+func oaiToTimestamp(s string, roundDown bool) (string, error) {
+	if t, err := time.Parse(oaiTimestamp, s); err == nil {
+		return t.Format(timestamp), nil
+	}
+	t, err := time.Parse(datestamp, s)
+	if err != nil {
+		return "", fmt.Errorf("illegal date %q", s)
+	}
+	if roundDown {
+		return t.Format(timestamp), nil
+	}
+	return t.Add((24 * time.Hour) - time.Second).Format(timestamp), nil
+}
+
+// timestampToOAI converts an EPrint timestamp to OAI-PMH granularity
+func timestampToOAI(s string) string {
+	if t, err := time.Parse(timestamp, s); err == nil {
+		return t.Format(oaiTimestamp)
+	}
+	if t, err := time.Parse(datestamp, s); err == nil {
+		return t.Format(oaiTimestamp)
+	}
+	return s
+}
+
+// eprintToOAIDC crosswalks an EPrint into unqualified Dublin Core
+func eprintToOAIDC(eprint *EPrint) *OAIDC {
+	dc := new(OAIDC)
+	dc.XMLNSOAIDC = oaiDCNamespace
+	dc.XMLNSDC = dcNamespace
+	dc.XMLNSXSI = xsiNamespace
+	dc.SchemaLocation = fmt.Sprintf("%s %s", oaiDCNamespace, oaiDCSchema)
+	if eprint.Title != "" {
+		dc.Title = append(dc.Title, eprint.Title)
+	}
+	if eprint.AltTitle != nil {
+		for _, item := range eprint.AltTitle.Items {
+			if item.Value != "" {
+				dc.Title = append(dc.Title, item.Value)
+			}
+		}
+	}
+	if eprint.Creators != nil {
+		for _, item := range eprint.Creators.Items {
+			if s := itemToDCName(item); s != "" {
+				dc.Creator = append(dc.Creator, s)
+			}
+		}
+	}
+	if eprint.CorpCreators != nil {
+		for _, item := range eprint.CorpCreators.Items {
+			if s := itemToDCName(item); s != "" {
+				dc.Creator = append(dc.Creator, s)
+			}
+		}
+	}
+	if eprint.Subjects != nil {
+		for _, item := range eprint.Subjects.Items {
+			if item.Value != "" {
+				dc.Subject = append(dc.Subject, item.Value)
+			}
+		}
+	}
+	if eprint.Keywords != "" {
+		for _, keyword := range strings.Split(eprint.Keywords, ";") {
+			if s := strings.TrimSpace(keyword); s != "" {
+				dc.Subject = append(dc.Subject, s)
+			}
+		}
+	}
+	if eprint.Abstract != "" {
+		dc.Description = append(dc.Description, eprint.Abstract)
+	}
+	if eprint.Publisher != "" {
+		dc.Publisher = append(dc.Publisher, eprint.Publisher)
+	}
+	if eprint.Editors != nil {
+		for _, item := range eprint.Editors.Items {
+			if s := itemToDCName(item); s != "" {
+				dc.Contributor = append(dc.Contributor, s)
+			}
+		}
+	}
+	if eprint.Contributors != nil {
+		for _, item := range eprint.Contributors.Items {
+			if s := itemToDCName(item); s != "" {
+				dc.Contributor = append(dc.Contributor, s)
+			}
+		}
+	}
+	if eprint.Date != "" {
+		dc.Date = append(dc.Date, eprint.Date)
+	}
+	if eprint.Type != "" {
+		dc.Type = append(dc.Type, eprint.Type)
+	}
+	if eprint.Documents != nil {
+		for _, doc := range *eprint.Documents {
+			if doc.Security == "public" && doc.MimeType != "" {
+				dc.Format = append(dc.Format, doc.MimeType)
+			}
+		}
+	}
+	if eprint.ID != "" {
+		dc.Identifier = append(dc.Identifier, eprint.ID)
+	}
+	if eprint.OfficialURL != "" {
+		dc.Identifier = append(dc.Identifier, eprint.OfficialURL)
+	}
+	if eprint.DOI != "" {
+		dc.Identifier = append(dc.Identifier, fmt.Sprintf("https://doi.org/%s", eprint.DOI))
+	}
+	if eprint.Publication != "" {
+		dc.Source = append(dc.Source, eprint.Publication)
+	}
+	if eprint.Language != "" {
+		dc.Language = append(dc.Language, eprint.Language)
+	}
+	if eprint.RelatedURL != nil {
+		for _, item := range eprint.RelatedURL.Items {
+			if item.URL != "" {
+				dc.Relation = append(dc.Relation, item.URL)
+			}
+		}
+	}
+	if eprint.Rights != "" {
+		dc.Rights = append(dc.Rights, eprint.Rights)
+	}
+	return dc
+}
+
+// itemToDCName renders a person or organization item as a
+// Dublin Core name string, e.g. "Doe, Jane".
+func itemToDCName(item *Item) string {
+	if item.Name != nil {
+		family, given := strings.TrimSpace(item.Name.Family), strings.TrimSpace(item.Name.Given)
+		switch {
+		case family != "" && given != "":
+			return fmt.Sprintf("%s, %s", family, given)
+		case family != "":
+			return family
+		case given != "":
+			return given
+		case strings.TrimSpace(item.Name.Value) != "":
+			return strings.TrimSpace(item.Name.Value)
+		}
+	}
+	return strings.TrimSpace(item.Value)
+}
+
+// oaiMetadataSrc renders the eprint in the requested metadata format
+func oaiMetadataSrc(eprint *EPrint, metadataPrefix string) ([]byte, error) {
+	switch metadataPrefix {
+	case "oai_dc":
+		return xml.MarshalIndent(eprintToOAIDC(eprint), "", "  ")
+	case "eprint":
+		eprints := NewEPrints()
+		eprints.XMLNS = eprintsNamespace
+		eprints.Append(eprint)
+		return xml.MarshalIndent(eprints, "", "  ")
+	}
+	return nil, fmt.Errorf("cannot disseminate format %q", metadataPrefix)
+}
+
+// hasMetadataFormat returns true if metadataPrefix is supported
+func hasMetadataFormat(metadataPrefix string) bool {
+	for _, format := range oaiMetadataFormats {
+		if format.MetadataPrefix == metadataPrefix {
+			return true
+		}
+	}
+	return false
+}
+
+// oaiEarliestDatestamp returns the earliest datestamp in repository
+func oaiEarliestDatestamp(config *Config, repoID string) string {
+	stmt := `SELECT CONCAT(datestamp_year, "-",
+LPAD(IFNULL(datestamp_month, 1), 2, "0"), "-",
+LPAD(IFNULL(datestamp_day, 1), 2, "0"), " ",
+LPAD(IFNULL(datestamp_hour, 0), 2, "0"), ":",
+LPAD(IFNULL(datestamp_minute, 0), 2, "0"), ":",
+LPAD(IFNULL(datestamp_second, 0), 2, "0")) AS earliest
+FROM eprint WHERE datestamp_year IS NOT NULL
+ORDER BY datestamp_year ASC, datestamp_month ASC, datestamp_day ASC, datestamp_hour ASC, datestamp_minute ASC, datestamp_second ASC LIMIT 1`
+	values, err := sqlQueryStringIDs(config, repoID, stmt)
+	if err != nil || len(values) == 0 {
+		return timestampToOAI(oaiEarliest)
+	}
+	return timestampToOAI(values[0])
+}
+
+// oaiSetEPrintIDs returns the eprint ids belonging to a set
+func oaiSetEPrintIDs(config *Config, repoID string, field string, value string) ([]int, error) {
+	if field == "local_group" {
+		return GetEPrintIDsForItem(config, repoID, field, value)
+	}
+	return GetEPrintIDsForUniqueID(config, repoID, field, value)
+}
+
+// intersectIDs returns the ids in a that are also in b, preserving
+// the order of a.
+func intersectIDs(a []int, b []int) []int {
+	m := map[int]bool{}
+	for _, id := range b {
+		m[id] = true
+	}
+	ids := []int{}
+	for _, id := range a {
+		if m[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// oaiCandidateIDs returns the sorted list of eprint ids modified in
+// the from/until range and belonging to the set (if provided).
+func oaiCandidateIDs(config *Config, repoID string, rt *oaiResumption) ([]int, error) {
+	start, end := oaiEarliest, time.Now().UTC().Format(timestamp)
+	if rt.From != "" {
+		start, _ = oaiToTimestamp(rt.From, true)
+	}
+	if rt.Until != "" {
+		end, _ = oaiToTimestamp(rt.Until, false)
+	}
+	ids, err := GetEPrintIDsInTimestampRange(config, repoID, "lastmod", start, end)
+	if err != nil {
+		return nil, err
+	}
+	if rt.Set != "" {
+		field, value, err := parseSetSpec(rt.Set)
+		if err != nil {
+			return nil, err
+		}
+		setIDs, err := oaiSetEPrintIDs(config, repoID, field, value)
+		if err != nil {
+			return nil, err
+		}
+		ids = intersectIDs(ids, setIDs)
+	}
+	// NOTE: ids need a stable order for resumption tokens to work
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// oaiSetSpecs returns the setSpecs for an eprint reading only the
+// collection, type and local_group fields.
+func (api *EP3API) oaiSetSpecs(repoID string, ds *DataSource, eprintID int) ([]string, error) {
+	db, ok := api.Config.Connections[repoID]
+	if !ok {
+		return nil, fmt.Errorf("no database connection for %s", repoID)
+	}
+	eprint := new(EPrint)
+	columns, values := []string{}, []interface{}{}
+	if hasColumn(ds.TableMap, "eprint", "collection") {
+		columns, values = append(columns, "IFNULL(collection, '')"), append(values, &eprint.Collection)
+	}
+	if hasColumn(ds.TableMap, "eprint", "type") {
+		columns, values = append(columns, "IFNULL(type, '')"), append(values, &eprint.Type)
+	}
+	if len(columns) > 0 {
+		stmt := fmt.Sprintf("SELECT %s FROM eprint WHERE eprintid = ? LIMIT 1", strings.Join(columns, ", "))
+		if err := db.QueryRow(stmt, eprintID).Scan(values...); err != nil {
+			return nil, fmt.Errorf("SQL error, %q, %s", stmt, err)
+		}
+	}
+	eprint.LocalGroup = eprintIDToLocalGroup(repoID, eprintID, &dbQueryer{db}, ds.TableMap)
+	return eprintSetSpecs(eprint), nil
+}
+
+// oaiReadRecord reads an eprint and returns an OAI record. If the
+// eprint is not public and not deleted then nil is returned. Deleted
+// eprints are returned as a header with status "deleted". The full
+// record is only read when the metadata is needed, headers are built
+// from SQLReadEPrintVersion and oaiSetSpecs.
+func (api *EP3API) oaiReadRecord(repoID string, ds *DataSource, eprintID int, metadataPrefix string, headerOnly bool) (*OAIRecord, error) {
+	version, err := SQLReadEPrintVersion(api.Config, repoID, eprintID)
+	if err != nil {
+		return nil, err
+	}
+	isPublic := version.IsPublic()
+	if !isPublic && version.EPrintStatus != "deletion" {
+		return nil, nil
+	}
+	header := new(OAIHeader)
+	header.Identifier = makeOAIIdentifier(oaiNamespace(repoID, ds), eprintID)
+	record := new(OAIRecord)
+	record.Header = header
+	if headerOnly || !isPublic {
+		if !version.LastModified.IsZero() {
+			header.Datestamp = timestampToOAI(version.LastModified.Format(timestamp))
+		}
+		if header.SetSpec, err = api.oaiSetSpecs(repoID, ds, eprintID); err != nil {
+			return nil, err
+		}
+		if !isPublic {
+			header.Status = "deleted"
+		}
+		return record, nil
+	}
+	eprint, err := SQLReadEPrint(api.Config, repoID, ds.BaseURL, eprintID)
+	if err != nil {
+		return nil, err
+	}
+	header.Datestamp = timestampToOAI(eprint.LastModified)
+	header.SetSpec = eprintSetSpecs(eprint)
+	src, err := oaiMetadataSrc(eprint, metadataPrefix)
+	if err != nil {
+		return nil, err
+	}
+	record.Metadata = &OAIMetadata{Src: src}
+	return record, nil
+}
+
+// oaiListRecords handles ListIdentifiers and ListRecords, returning
+// a page of records and the next resumption token if any.
+func (api *EP3API) oaiListRecords(repoID string, ds *DataSource, rt *oaiResumption, headerOnly bool) ([]*OAIRecord, *OAIResumptionToken, error) {
+	ids, err := oaiCandidateIDs(api.Config, repoID, rt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rt.Offset > len(ids) {
+		return nil, nil, fmt.Errorf("offset out of range")
+	}
+	records := []*OAIRecord{}
+	i := rt.Offset
+	for ; i < len(ids) && len(records) < oaiPageSize; i++ {
+		record, err := api.oaiReadRecord(repoID, ds, ids[i], rt.MetadataPrefix, headerOnly)
+		if err != nil {
+			api.Log.Printf("ERROR: (%s) OAI-PMH read %d, %s", repoID, ids[i], err)
+			continue
+		}
+		if record != nil {
+			records = append(records, record)
+		}
+	}
+	var token *OAIResumptionToken
+	if i < len(ids) {
+		next := *rt
+		next.Offset = i
+		token = &OAIResumptionToken{
+			CompleteListSize: len(ids),
+			Cursor:           rt.Offset,
+			Token:            encodeResumptionToken(&next),
+		}
+	} else if rt.Offset > 0 {
+		// NOTE: The last page of an incomplete list has an
+		// empty resumption token.
+		token = &OAIResumptionToken{
+			CompleteListSize: len(ids),
+			Cursor:           rt.Offset,
+		}
+	}
+	return records, token, nil
+}
+
+// oaiListSets returns the sets derived from collection, type
+// and local_group.
+func (api *EP3API) oaiListSets(repoID string) ([]*OAISet, error) {
+	sets := []*OAISet{}
+	for _, field := range oaiSetFields {
+		var (
+			values []string
+			err    error
+		)
+		if field == "local_group" {
+			values, err = GetAllItems(api.Config, repoID, field)
+		} else {
+			values, err = GetAllUniqueID(api.Config, repoID, field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			if strings.TrimSpace(value) == "" {
+				continue
+			}
+			sets = append(sets, &OAISet{
+				SetSpec: makeSetSpec(field, value),
+				SetName: fmt.Sprintf("%s = %s", strings.ReplaceAll(field, "_", " "), value),
+			})
+		}
+	}
+	return sets, nil
+}
+
+// validateOAIArgs checks the request arguments against the verb.
+// It returns an OAI error code and message if there is a problem.
+func validateOAIArgs(form url.Values) (string, string) {
+	verbs, ok := form["verb"]
+	if !ok || len(verbs) != 1 {
+		return "badVerb", "verb missing or repeated"
+	}
+	allowed, ok := oaiVerbArgs[verbs[0]]
+	if !ok {
+		return "badVerb", fmt.Sprintf("illegal verb %q", verbs[0])
+	}
+	for key, values := range form {
+		if key == "verb" {
+			continue
+		}
+		if _, ok := allowed[key]; !ok {
+			return "badArgument", fmt.Sprintf("illegal argument %q", key)
+		}
+		if len(values) != 1 {
+			return "badArgument", fmt.Sprintf("repeated argument %q", key)
+		}
+	}
+	if _, ok := form["resumptionToken"]; ok {
+		// resumptionToken is an exclusive argument
+		if len(form) != 2 {
+			return "badArgument", "resumptionToken is an exclusive argument"
+		}
+		return "", ""
+	}
+	for key, required := range allowed {
+		if _, ok := form[key]; required && !ok {
+			return "badArgument", fmt.Sprintf("missing required argument %q", key)
+		}
+	}
+	for _, key := range []string{"from", "until"} {
+		if s := form.Get(key); s != "" {
+			if _, err := oaiToTimestamp(s, true); err != nil {
+				return "badArgument", fmt.Sprintf("illegal %s, %s", key, err)
+			}
+		}
+	}
+	if from, until := form.Get("from"), form.Get("until"); from != "" && until != "" {
+		if len(from) != len(until) {
+			return "badArgument", "from and until must have the same granularity"
+		}
+		if from > until {
+			return "badArgument", "from must be earlier than until"
+		}
+	}
+	return "", ""
+}
+
+// packageOAIPMH writes the OAI-PMH response
+func (api *EP3API) packageOAIPMH(w http.ResponseWriter, repoID string, response *OAIPMH) (int, error) {
+	src, err := xml.MarshalIndent(response, "", "  ")
+	if err != nil {
+		api.Log.Printf("ERROR: (%s) package OAI-PMH error, %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="utf-8"?>`)
+	fmt.Fprintf(w, "%s", src)
+	return 200, nil
+}
+
+// oaiEndPoint implements an OAI-PMH 2.0 data provider for
+// a repository. Requests maybe made with GET or POST.
+func (api *EP3API) oaiEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if strings.HasSuffix(r.URL.Path, "/help") {
+		return api.packageDocument(w, oaiDocument(repoID))
+	}
+	ds, ok := api.Config.Repositories[repoID]
+	if !ok {
+		api.Log.Printf("Data Source not found for %q", repoID)
+		return 404, fmt.Errorf("not found")
+	}
+	if err := r.ParseForm(); err != nil {
+		return 400, fmt.Errorf("bad request, %s", err)
+	}
+	form := r.Form
+	response := new(OAIPMH)
+	response.XMLNS = oaiPMHNamespace
+	response.XMLNSXSI = xsiNamespace
+	response.SchemaLocation = fmt.Sprintf("%s %s", oaiPMHNamespace, oaiPMHSchema)
+	response.ResponseDate = time.Now().UTC().Format(oaiTimestamp)
+	response.Request = new(OAIRequest)
+	response.Request.URL = fmt.Sprintf("%s/%s/oai", strings.TrimSuffix(api.Config.BaseURL, "/"), repoID)
+
+	oaiError := func(code string, msg string) (int, error) {
+		response.Errors = append(response.Errors, &OAIError{Code: code, Message: msg})
+		return api.packageOAIPMH(w, repoID, response)
+	}
+	if code, msg := validateOAIArgs(form); code != "" {
+		// NOTE: the request element shouldn't echo attributes
+		// when we have a badVerb or badArgument error.
+		return oaiError(code, msg)
+	}
+	verb := form.Get("verb")
+	response.Request.Verb = verb
+	response.Request.Identifier = form.Get("identifier")
+	response.Request.MetadataPrefix = form.Get("metadataPrefix")
+	response.Request.From = form.Get("from")
+	response.Request.Until = form.Get("until")
+	response.Request.Set = form.Get("set")
+	response.Request.ResumptionToken = form.Get("resumptionToken")
+	namespace := oaiNamespace(repoID, ds)
+
+	switch verb {
+	case "Identify":
+		identify := new(OAIIdentify)
+		identify.RepositoryName = repoID
+		identify.BaseURL = response.Request.URL
+		identify.ProtocolVersion = "2.0"
+		identify.EarliestDatestamp = oaiEarliestDatestamp(api.Config, repoID)
+		identify.DeletedRecord = "transient"
+		identify.Granularity = "YYYY-MM-DDThh:mm:ssZ"
+		identify.Description = &OAIDescription{
+			OAIIdentifier: &OAIIdentifierDescription{
+				XMLNS:                oaiIdentifierSpace,
+				Scheme:               "oai",
+				RepositoryIdentifier: namespace,
+				Delimiter:            ":",
+				SampleIdentifier:     makeOAIIdentifier(namespace, 1),
+			},
+		}
+		response.Identify = identify
+	case "ListMetadataFormats":
+		if identifier := form.Get("identifier"); identifier != "" {
+			eprintID, err := parseOAIIdentifier(namespace, identifier)
+			if err != nil {
+				return oaiError("idDoesNotExist", err.Error())
+			}
+			if ids, err := GetEPrintIDsForUniqueID(api.Config, repoID, "eprintid", strconv.Itoa(eprintID)); err != nil || len(ids) == 0 {
+				return oaiError("idDoesNotExist", fmt.Sprintf("unknown identifier %q", identifier))
+			}
+		}
+		response.ListMetadata = &OAIListMetadata{Formats: oaiMetadataFormats}
+	case "ListSets":
+		if form.Get("resumptionToken") != "" {
+			// NOTE: we return all sets in one response
+			return oaiError("badResumptionToken", "ListSets does not issue resumption tokens")
+		}
+		sets, err := api.oaiListSets(repoID)
+		if err != nil {
+			api.Log.Printf("ERROR: (%s) OAI-PMH ListSets, %s", repoID, err)
+			return 500, fmt.Errorf("internal server error")
+		}
+		if len(sets) == 0 {
+			return oaiError("noSetHierarchy", "repository does not support sets")
+		}
+		response.ListSets = &OAIListSets{Sets: sets}
+	case "ListIdentifiers", "ListRecords":
+		rt := new(oaiResumption)
+		if token := form.Get("resumptionToken"); token != "" {
+			var err error
+			if rt, err = decodeResumptionToken(token); err != nil {
+				return oaiError("badResumptionToken", err.Error())
+			}
+		} else {
+			rt.MetadataPrefix = form.Get("metadataPrefix")
+			rt.From = form.Get("from")
+			rt.Until = form.Get("until")
+			rt.Set = form.Get("set")
+		}
+		if !hasMetadataFormat(rt.MetadataPrefix) {
+			return oaiError("cannotDisseminateFormat", fmt.Sprintf("%q not supported", rt.MetadataPrefix))
+		}
+		if rt.Set != "" {
+			if _, _, err := parseSetSpec(rt.Set); err != nil {
+				return oaiError("badArgument", err.Error())
+			}
+		}
+		records, token, err := api.oaiListRecords(repoID, ds, rt, (verb == "ListIdentifiers"))
+		if err != nil {
+			if rt.Offset > 0 {
+				return oaiError("badResumptionToken", err.Error())
+			}
+			api.Log.Printf("ERROR: (%s) OAI-PMH %s, %s", repoID, verb, err)
+			return 500, fmt.Errorf("internal server error")
+		}
+		if len(records) == 0 {
+			return oaiError("noRecordsMatch", "no records match the request")
+		}
+		if verb == "ListIdentifiers" {
+			headers := []*OAIHeader{}
+			for _, record := range records {
+				headers = append(headers, record.Header)
+			}
+			response.ListIDs = &OAIListIDs{Headers: headers, ResumptionToken: token}
+		} else {
+			response.ListRecords = &OAIListRecords{Records: records, ResumptionToken: token}
+		}
+	case "GetRecord":
+		metadataPrefix := form.Get("metadataPrefix")
+		if !hasMetadataFormat(metadataPrefix) {
+			return oaiError("cannotDisseminateFormat", fmt.Sprintf("%q not supported", metadataPrefix))
+		}
+		eprintID, err := parseOAIIdentifier(namespace, form.Get("identifier"))
+		if err != nil {
+			return oaiError("idDoesNotExist", err.Error())
+		}
+		record, err := api.oaiReadRecord(repoID, ds, eprintID, metadataPrefix, false)
+		if err != nil || record == nil {
+			return oaiError("idDoesNotExist", fmt.Sprintf("unknown identifier %q", form.Get("identifier")))
+		}
+		response.GetRecord = &OAIGetRecord{Record: record}
+	}
+	return api.packageOAIPMH(w, repoID, response)
+}
@@ -0,0 +1,176 @@
+package eprinttools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestOAIResumptionToken(t *testing.T) {
+	expected := &oaiResumption{
+		MetadataPrefix: "oai_dc",
+		From:           "2021-01-01",
+		Until:          "2021-12-31",
+		Set:            "local_group:Caltech~20Lemur~20Lab",
+		Offset:         200,
+	}
+	token := encodeResumptionToken(expected)
+	rt, err := decodeResumptionToken(token)
+	if err != nil {
+		t.Fatalf("decodeResumptionToken(%q) failed, %s", token, err)
+	}
+	if *rt != *expected {
+		t.Errorf("expected %+v, got %+v", expected, rt)
+	}
+	for _, token := range []string{"", "not a token!", encodeResumptionToken(&oaiResumption{Offset: -1})} {
+		if _, err := decodeResumptionToken(token); err == nil {
+			t.Errorf("expected error for token %q", token)
+		}
+	}
+}
+
+func TestOAISetSpec(t *testing.T) {
+	for _, value := range []string{"article", "Caltech Lemur Lab", "Tom's ~group~: 100%"} {
+		setSpec := makeSetSpec("local_group", value)
+		for _, c := range []byte(strings.TrimPrefix(setSpec, "local_group:")) {
+			if !oaiSetSafe(c) && c != '~' {
+				t.Errorf("setSpec %q not escaped", setSpec)
+				break
+			}
+		}
+		field, s, err := parseSetSpec(setSpec)
+		if err != nil {
+			t.Errorf("parseSetSpec(%q) failed, %s", setSpec, err)
+			continue
+		}
+		if field != "local_group" || s != value {
+			t.Errorf("expected local_group %q, got %s %q", value, field, s)
+		}
+	}
+	for _, setSpec := range []string{"type", "type:", "creator_id:Doe-J", "type:~2"} {
+		if _, _, err := parseSetSpec(setSpec); err == nil {
+			t.Errorf("expected error for setSpec %q", setSpec)
+		}
+	}
+}
+
+func TestOAIIdentifier(t *testing.T) {
+	ds := &DataSource{BaseURL: "https://authors.library.caltech.edu"}
+	namespace := oaiNamespace("caltechauthors", ds)
+	if namespace != "authors.library.caltech.edu" {
+		t.Errorf("expected authors.library.caltech.edu, got %q", namespace)
+	}
+	identifier := makeOAIIdentifier(namespace, 106567)
+	if identifier != "oai:authors.library.caltech.edu:106567" {
+		t.Errorf("unexpected identifier %q", identifier)
+	}
+	if eprintID, err := parseOAIIdentifier(namespace, identifier); err != nil || eprintID != 106567 {
+		t.Errorf("expected 106567, got %d, %s", eprintID, err)
+	}
+	if _, err := parseOAIIdentifier(namespace, "oai:example.edu:1"); err == nil {
+		t.Errorf("expected error for foreign identifier")
+	}
+	if namespace := oaiNamespace("lemurprints", nil); namespace != "lemurprints" {
+		t.Errorf("expected lemurprints, got %q", namespace)
+	}
+}
+
+func TestOAIArgs(t *testing.T) {
+	for q, expected := range map[string]string{
+		"verb=Identify":                          "",
+		"":                                       "badVerb",
+		"verb=Identify&verb=ListSets":            "badVerb",
+		"verb=Dance":                             "badVerb",
+		"verb=Identify&set=type:article":         "badArgument",
+		"verb=ListRecords":                       "badArgument",
+		"verb=ListRecords&metadataPrefix=oai_dc": "",
+		"verb=ListRecords&metadataPrefix=oai_dc&from=2021-01-01":                            "",
+		"verb=ListRecords&metadataPrefix=oai_dc&from=yesterday":                             "badArgument",
+		"verb=ListRecords&metadataPrefix=oai_dc&from=2021-01-01&until=2021-12-31T00:00:00Z": "badArgument",
+		"verb=ListRecords&metadataPrefix=oai_dc&from=2021-02-01&until=2021-01-01":           "badArgument",
+		"verb=ListRecords&resumptionToken=abc":                                              "",
+		"verb=ListRecords&resumptionToken=abc&metadataPrefix=oai_dc":                        "badArgument",
+		"verb=GetRecord&metadataPrefix=oai_dc":                                              "badArgument",
+	} {
+		form, _ := url.ParseQuery(q)
+		if code, msg := validateOAIArgs(form); code != expected {
+			t.Errorf("%q expected %q, got %q %s", q, expected, code, msg)
+		}
+	}
+}
+
+func TestOAITimestamps(t *testing.T) {
+	for s, expected := range map[string]string{
+		"2021-03-04":           "2021-03-04 00:00:00",
+		"2021-03-04T05:06:07Z": "2021-03-04 05:06:07",
+	} {
+		if got, err := oaiToTimestamp(s, true); err != nil || got != expected {
+			t.Errorf("oaiToTimestamp(%q) expected %q, got %q, %s", s, expected, got, err)
+		}
+	}
+	if got, _ := oaiToTimestamp("2021-03-04", false); got != "2021-03-04 23:59:59" {
+		t.Errorf("expected end of day, got %q", got)
+	}
+	if got := timestampToOAI("2020-11-10 00:32:30"); got != "2020-11-10T00:32:30Z" {
+		t.Errorf("expected 2020-11-10T00:32:30Z, got %q", got)
+	}
+}
+
+func TestOAIMetadata(t *testing.T) {
+	fName := path.Join("testdata", "test_eprint1.xml")
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		t.Fatalf("Failed to read %q, %s", fName, err)
+	}
+	eprints := NewEPrints()
+	if err := xml.Unmarshal(src, &eprints); err != nil {
+		t.Fatalf("Failed to unmarshal %q, %s", fName, err)
+	}
+	if len(eprints.EPrint) == 0 {
+		t.Fatalf("Expected at least 1 test record in %q", fName)
+	}
+	eprint := eprints.EPrint[0]
+	dc := eprintToOAIDC(eprint)
+	if len(dc.Title) == 0 || dc.Title[0] != eprint.Title {
+		t.Errorf("expected title %q, got %+v", eprint.Title, dc.Title)
+	}
+	if eprint.Creators != nil && len(eprint.Creators.Items) > 0 && len(dc.Creator) == 0 {
+		t.Errorf("expected creators in oai_dc")
+	}
+	for _, metadataPrefix := range []string{"oai_dc", "eprint"} {
+		src, err := oaiMetadataSrc(eprint, metadataPrefix)
+		if err != nil {
+			t.Errorf("oaiMetadataSrc(%q) failed, %s", metadataPrefix, err)
+			continue
+		}
+		// Make sure the embedded metadata is well formed
+		record := &OAIRecord{Header: &OAIHeader{Identifier: "oai:example.edu:1"}, Metadata: &OAIMetadata{Src: src}}
+		out, err := xml.Marshal(record)
+		if err != nil {
+			t.Errorf("xml.Marshal(record) failed, %s", err)
+			continue
+		}
+		decoder := xml.NewDecoder(bytes.NewReader(out))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s record is not well formed, %s", metadataPrefix, err)
+				break
+			}
+		}
+	}
+	if _, err := oaiMetadataSrc(eprint, "marc21"); err == nil {
+		t.Errorf("expected error for unsupported format")
+	}
+	if setSpecs := eprintSetSpecs(eprint); eprint.Type != "" && len(setSpecs) == 0 {
+		t.Errorf("expected set specs for eprint")
+	}
+}
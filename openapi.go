@@ -0,0 +1,328 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * openapi.go generates an OpenAPI 3 description of the extended
+ * EPrints API for a repository from the end points registered for it.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// OpenAPIVersion is the version of the OpenAPI specification used
+	OpenAPIVersion = "3.0.3"
+)
+
+// OpenAPI is an OpenAPI 3 document
+type OpenAPI struct {
+	OpenAPI    string                      `json:"openapi"`
+	Info       *OpenAPIInfo                `json:"info"`
+	Servers    []*OpenAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]*OpenAPIPathItem `json:"paths"`
+	Components *OpenAPIComponents          `json:"components,omitempty"`
+	Security   []map[string][]string       `json:"security,omitempty"`
+}
+
+// OpenAPIInfo describes the API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIServer is the base URL of the API
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIPathItem holds the operations for a path
+type OpenAPIPathItem struct {
+	Get  *OpenAPIOperation `json:"get,omitempty"`
+	Put  *OpenAPIOperation `json:"put,omitempty"`
+	Post *OpenAPIOperation `json:"post,omitempty"`
+}
+
+// OpenAPIOperation describes a method on a path
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is a path or query parameter
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody describes the body of a PUT or POST
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType holds the schema of a content type
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema,omitempty"`
+}
+
+// OpenAPISchema is the (small) subset of JSON schema used here
+type OpenAPISchema struct {
+	Type   string         `json:"type,omitempty"`
+	Format string         `json:"format,omitempty"`
+	Enum   []string       `json:"enum,omitempty"`
+	Items  *OpenAPISchema `json:"items,omitempty"`
+}
+
+// OpenAPIComponents holds the security schemes
+type OpenAPIComponents struct {
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OpenAPISecurityScheme describes an authentication method
+type OpenAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+var (
+	// openAPIParamRE matches the path parameters in a path template
+	openAPIParamRE = regexp.MustCompile(`\{([A-Z_]+)\}`)
+)
+
+// openAPIResponses returns the responses for a path
+func openAPIResponses(response string) map[string]*OpenAPIResponse {
+	jsonContent := func(schema *OpenAPISchema) map[string]*OpenAPIMediaType {
+		return map[string]*OpenAPIMediaType{"application/json": {Schema: schema}}
+	}
+	ok := &OpenAPIResponse{Description: "OK"}
+	switch response {
+	case "ids":
+		ok.Content = jsonContent(&OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "integer"}})
+	case "strings":
+		ok.Content = jsonContent(&OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "string"}})
+	case "object":
+		ok.Content = jsonContent(&OpenAPISchema{Type: "object"})
+	case "bool":
+		ok.Content = jsonContent(&OpenAPISchema{Type: "boolean"})
+	case "results":
+		ok.Content = jsonContent(&OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "object"}})
+	case "xml":
+		ok.Content = map[string]*OpenAPIMediaType{"text/xml": {Schema: &OpenAPISchema{Type: "string"}}}
+	case "changes":
+		ok.Content = map[string]*OpenAPIMediaType{
+			"application/atom+xml": {Schema: &OpenAPISchema{Type: "string"}},
+			"text/event-stream":    {Schema: &OpenAPISchema{Type: "string"}},
+		}
+	case "encoded":
+		ok.Content = map[string]*OpenAPIMediaType{}
+		for _, encoder := range Encoders() {
+			ok.Content[encoder.ContentType] = &OpenAPIMediaType{Schema: &OpenAPISchema{Type: "string"}}
+		}
+	}
+	responses := map[string]*OpenAPIResponse{
+		"200": ok,
+		"400": {Description: "Bad Request"},
+		"404": {Description: "Not Found"},
+	}
+	if response == "encoded" {
+		responses["406"] = &OpenAPIResponse{Description: "Not Acceptable"}
+	}
+	return responses
+}
+
+// openAPIQuery returns the query parameters for a route
+func openAPIQuery(endPoint *EndPoint, tableMap map[string][]string, response string) []*OpenAPIParameter {
+	parameters := []*OpenAPIParameter{}
+	for _, q := range endPoint.Query {
+		parameter := &OpenAPIParameter{Name: q.Name, In: "query", Description: q.Description, Schema: &OpenAPISchema{Type: "string"}}
+		if q.Name == "format" && response == "encoded" {
+			for _, encoder := range Encoders() {
+				parameter.Schema.Enum = append(parameter.Schema.Enum, encoder.Name)
+			}
+		}
+		parameters = append(parameters, parameter)
+	}
+	if endPoint.Name == "search" {
+		// NOTE: only the fields the repository supports are described
+		for _, field := range SearchFields() {
+			sf := searchFields[field]
+			if hasColumn(tableMap, sf.Table, sf.Column) {
+				parameters = append(parameters, &OpenAPIParameter{Name: field, In: "query", Description: fmt.Sprintf("filter by %s", sf.Column), Schema: &OpenAPISchema{Type: "string"}})
+			}
+		}
+		parameters = append(parameters,
+			&OpenAPIParameter{Name: "op", In: "query", Description: "combine the filters with and (default) or or", Schema: &OpenAPISchema{Type: "string", Enum: []string{"and", "or"}}},
+			&OpenAPIParameter{Name: "is_public", In: "query", Description: "only return public records", Schema: &OpenAPISchema{Type: "boolean"}},
+			&OpenAPIParameter{Name: "from", In: "query", Description: "published on or after an approximate date", Schema: &OpenAPISchema{Type: "string"}},
+			&OpenAPIParameter{Name: "until", In: "query", Description: "published on or before an approximate date", Schema: &OpenAPISchema{Type: "string"}},
+		)
+	}
+	if response == "ids" || response == "strings" {
+		parameters = append(parameters,
+			&OpenAPIParameter{Name: "limit", In: "query", Description: fmt.Sprintf("page size, at most %d", MaxPageLimit), Schema: &OpenAPISchema{Type: "integer"}},
+			&OpenAPIParameter{Name: "offset", In: "query", Description: "index of the first item of the page", Schema: &OpenAPISchema{Type: "integer"}},
+			&OpenAPIParameter{Name: "order", In: "query", Description: "sort order", Schema: &OpenAPISchema{Type: "string", Enum: []string{"asc", "desc"}}},
+			&OpenAPIParameter{Name: "cursor", In: "query", Description: "cursor from the next link of the previous page", Schema: &OpenAPISchema{Type: "string"}},
+		)
+	}
+	return parameters
+}
+
+// openAPIOperationID returns an operation id, e.g. "getCreatorIdByCREATORID"
+func openAPIOperationID(method string, route string, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(strings.TrimSuffix(route, ".json"), func(r rune) bool { return r == '-' || r == '.' }) {
+		sb.WriteString(strings.ToUpper(part[0:1]) + part[1:])
+	}
+	for i, m := range openAPIParamRE.FindAllStringSubmatch(path, -1) {
+		if i == 0 {
+			sb.WriteString("By")
+		} else {
+			sb.WriteString("And")
+		}
+		sb.WriteString(strings.ReplaceAll(m[1], "_", ""))
+	}
+	return sb.String()
+}
+
+// OpenAPIDocument returns the OpenAPI 3 description of the routes
+// registered for a repository.
+func OpenAPIDocument(config *Config, repoID string) (*OpenAPI, error) {
+	routes, ok := config.Routes[repoID]
+	if !ok {
+		return nil, fmt.Errorf("%q not found", repoID)
+	}
+	var tableMap map[string][]string
+	if dataSource, ok := config.Repositories[repoID]; ok {
+		tableMap = dataSource.TableMap
+	}
+	doc := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info: &OpenAPIInfo{
+			Title:       fmt.Sprintf("%s extended EPrints API", repoID),
+			Description: "The extended EPrints API provided by ep3apid, add \"/help\" to a path for its documentation.",
+			Version:     Version,
+		},
+		Paths: map[string]*OpenAPIPathItem{},
+	}
+	if config.BaseURL != "" {
+		doc.Servers = []*OpenAPIServer{{URL: strings.TrimSuffix(config.BaseURL, "/")}}
+	}
+	if config.AuthEnabled() {
+		doc.Components = &OpenAPIComponents{
+			SecuritySchemes: map[string]*OpenAPISecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+				"basic":  {Type: "http", Scheme: "basic"},
+			},
+		}
+		doc.Security = []map[string][]string{{"bearer": {}}, {"basic": {}}}
+	}
+	names := []string{}
+	for route := range routes {
+		names = append(names, route)
+	}
+	sort.Strings(names)
+	for _, route := range names {
+		endPoint := LookupEndPoint(route)
+		if endPoint == nil {
+			return nil, fmt.Errorf("route %q is not a registered end point", route)
+		}
+		methods := endPoint.Methods
+		if len(methods) == 0 {
+			methods = []string{"GET"}
+		}
+		paths := endPoint.Paths
+		if len(paths) == 0 {
+			paths = []*EndPointPath{{Summary: fmt.Sprintf("see /%s/%s/help", repoID, route), Response: "object"}}
+		}
+		for _, p := range paths {
+			pathItem := new(OpenAPIPathItem)
+			for _, method := range methods {
+				operation := &OpenAPIOperation{
+					OperationID: openAPIOperationID(method, route, p.Path),
+					Summary:     p.Summary,
+					Tags:        []string{route},
+					Responses:   openAPIResponses(p.Response),
+				}
+				for _, m := range openAPIParamRE.FindAllStringSubmatch(p.Path, -1) {
+					operation.Parameters = append(operation.Parameters, &OpenAPIParameter{Name: m[1], In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+				}
+				operation.Parameters = append(operation.Parameters, openAPIQuery(endPoint, tableMap, p.Response)...)
+				if method == "PUT" || (method == "POST" && route != "oai") {
+					operation.RequestBody = &OpenAPIRequestBody{
+						Required: true,
+						Content: map[string]*OpenAPIMediaType{
+							"application/xml":  {Schema: &OpenAPISchema{Type: "string"}},
+							"application/json": {Schema: &OpenAPISchema{Type: "object"}},
+						},
+					}
+					if route == "document-upload" {
+						operation.RequestBody.Content = map[string]*OpenAPIMediaType{
+							"multipart/form-data": {Schema: &OpenAPISchema{Type: "object"}},
+						}
+					}
+				}
+				switch method {
+				case "GET":
+					pathItem.Get = operation
+				case "PUT":
+					pathItem.Put = operation
+				case "POST":
+					pathItem.Post = operation
+				}
+			}
+			doc.Paths[fmt.Sprintf("/%s/%s%s", repoID, route, p.Path)] = pathItem
+		}
+	}
+	return doc, nil
+}
+
+// openAPIEndPoint returns the OpenAPI document for a repository
+func (api *EP3API) openAPIEndPoint(w http.ResponseWriter, r *http.Request, repoID string, args []string) (int, error) {
+	if len(args) > 0 {
+		return 404, fmt.Errorf("not found")
+	}
+	doc, err := OpenAPIDocument(api.Config, repoID)
+	if err != nil {
+		api.Log.Printf("ERROR: (%s) openapi error, %s", repoID, err)
+		return 500, fmt.Errorf("internal server error")
+	}
+	src, err := json.MarshalIndent(doc, "", "  ")
+	return api.packageJSON(w, repoID, src, err)
+}
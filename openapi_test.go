@@ -0,0 +1,146 @@
+package eprinttools
+
+import (
+	"encoding/json"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func openAPITestEP3API() *EP3API {
+	api := &EP3API{
+		Config: &Config{
+			BaseURL: "http://localhost:8484",
+			Repositories: map[string]*DataSource{
+				"authors": {
+					TableMap: map[string][]string{
+						"eprint":                 {"eprintid", "type", "eprint_status", "issn", "pmc_id", "pmid", "patent_applicant", "patent_number", "patent_classification"},
+						"eprint_patent_assignee": {"eprintid", "pos", "patent_assignee"},
+						"eprint_creators_id":     {"eprintid", "pos", "creators_id"},
+					},
+				},
+				"thesis": {
+					TableMap: map[string][]string{
+						"eprint": {"eprintid", "type", "eprint_status"},
+					},
+				},
+			},
+		},
+		Log: log.Default(),
+	}
+	for repoID, dataSource := range api.Config.Repositories {
+		api.registerRoutes(repoID, dataSource)
+	}
+	return api
+}
+
+func TestOpenAPIRoutesDescribed(t *testing.T) {
+	api := openAPITestEP3API()
+	for repoID, routes := range api.Config.Routes {
+		doc, err := OpenAPIDocument(api.Config, repoID)
+		if err != nil {
+			t.Fatalf("OpenAPIDocument(%q) failed, %s", repoID, err)
+		}
+		for route := range routes {
+			if endPoint := LookupEndPoint(route); endPoint == nil || len(endPoint.Paths) == 0 {
+				t.Errorf("route %q is not described", route)
+				continue
+			}
+			prefix := "/" + repoID + "/" + route
+			found := false
+			for p := range doc.Paths {
+				if p == prefix || strings.HasPrefix(p, prefix+"/") {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("route %q missing from %s paths", route, repoID)
+			}
+		}
+		for p, pathItem := range doc.Paths {
+			route := strings.SplitN(strings.TrimPrefix(p, "/"+repoID+"/"), "/", 2)[0]
+			if _, ok := routes[route]; !ok {
+				t.Errorf("%q describes an unregistered route", p)
+			}
+			for _, operation := range []*OpenAPIOperation{pathItem.Get, pathItem.Put, pathItem.Post} {
+				if operation == nil {
+					continue
+				}
+				for _, m := range openAPIParamRE.FindAllStringSubmatch(p, -1) {
+					declared := false
+					for _, parameter := range operation.Parameters {
+						if parameter.In == "path" && parameter.Name == m[1] {
+							declared = true
+						}
+					}
+					if !declared {
+						t.Errorf("%s %s does not declare path parameter %q", operation.OperationID, p, m[1])
+					}
+				}
+			}
+		}
+	}
+	// Routes without the columns are not registered or described
+	doc, _ := OpenAPIDocument(api.Config, "thesis")
+	if _, ok := doc.Paths["/thesis/patent-number"]; ok {
+		t.Errorf("expected no patent-number route for thesis")
+	}
+	if _, ok := doc.Paths["/authors/patent-number/{PATENT_NUMBER}"]; ok {
+		t.Errorf("expected only thesis paths in thesis document")
+	}
+	if _, err := OpenAPIDocument(api.Config, "lemurprints"); err == nil {
+		t.Errorf("expected error for unknown repository")
+	}
+}
+
+func TestOpenAPISearchParameters(t *testing.T) {
+	api := openAPITestEP3API()
+	for repoID, expected := range map[string]map[string]bool{
+		"authors": {"creator_id": true, "issn": true, "group": false},
+		"thesis":  {"creator_id": false, "type": true, "issn": false},
+	} {
+		doc, err := OpenAPIDocument(api.Config, repoID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parameters := map[string]bool{}
+		for _, parameter := range doc.Paths["/"+repoID+"/search"].Get.Parameters {
+			parameters[parameter.Name] = true
+		}
+		for name, ok := range expected {
+			if parameters[name] != ok {
+				t.Errorf("%s search parameter %q expected %t", repoID, name, ok)
+			}
+		}
+	}
+}
+
+func TestOpenAPIEndPoint(t *testing.T) {
+	api := openAPITestEP3API()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/authors/openapi.json", nil)
+	if code, err := api.openAPIEndPoint(w, r, "authors", []string{}); err != nil || code != 200 {
+		t.Fatalf("expected 200, got %d, %s", code, err)
+	}
+	doc := new(OpenAPI)
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("expected JSON, %s", err)
+	}
+	if doc.OpenAPI != OpenAPIVersion || len(doc.Paths) == 0 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+	operationIDs := map[string]bool{}
+	for _, pathItem := range doc.Paths {
+		for _, operation := range []*OpenAPIOperation{pathItem.Get, pathItem.Put, pathItem.Post} {
+			if operation == nil {
+				continue
+			}
+			if operationIDs[operation.OperationID] {
+				t.Errorf("duplicate operationId %q", operation.OperationID)
+			}
+			operationIDs[operation.OperationID] = true
+		}
+	}
+}
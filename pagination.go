@@ -0,0 +1,188 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * pagination.go implements limit, offset, cursor and order options
+ * for the id lists returned by the extended API. The options are
+ * applied in the SQL so the database does the work.
+ */
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxPageLimit is the largest page size accepted
+	MaxPageLimit = 10000
+)
+
+// Page holds the pagination options for an id list. Total is set
+// when the list is queried.
+type Page struct {
+	// Limit is the maximum number of ids returned, zero is no limit
+	Limit int `json:"limit,omitempty"`
+	// Offset is the number of ids skipped
+	Offset int `json:"offset"`
+	// Order is "asc", "desc" or empty for the list's natural order
+	Order string `json:"order,omitempty"`
+	// Total is the number of ids in the whole list
+	Total int `json:"total"`
+}
+
+// ParsePage reads "limit", "offset", "cursor" and "order" from
+// query parameters. It returns nil if none are present. A cursor
+// can't be combined with the other parameters.
+func ParsePage(q url.Values) (*Page, error) {
+	if q.Get("cursor") != "" {
+		for _, key := range []string{"limit", "offset", "order"} {
+			if q.Get(key) != "" {
+				return nil, fmt.Errorf("cursor can't be combined with %s", key)
+			}
+		}
+		return DecodePageCursor(q.Get("cursor"))
+	}
+	if q.Get("limit") == "" && q.Get("offset") == "" && q.Get("order") == "" {
+		return nil, nil
+	}
+	page := new(Page)
+	var err error
+	if s := q.Get("limit"); s != "" {
+		if page.Limit, err = strconv.Atoi(s); err != nil {
+			return nil, fmt.Errorf("limit %q not valid, %s", s, err)
+		}
+	}
+	if s := q.Get("offset"); s != "" {
+		if page.Offset, err = strconv.Atoi(s); err != nil {
+			return nil, fmt.Errorf("offset %q not valid, %s", s, err)
+		}
+	}
+	page.Order = strings.ToLower(q.Get("order"))
+	if err := page.validate(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// validate checks the page options
+func (page *Page) validate() error {
+	if page.Limit < 0 || page.Limit > MaxPageLimit {
+		return fmt.Errorf("limit must be between 0 and %d", MaxPageLimit)
+	}
+	if page.Offset < 0 {
+		return fmt.Errorf("offset must not be negative")
+	}
+	if page.Order != "" && page.Order != "asc" && page.Order != "desc" {
+		return fmt.Errorf("order must be asc or desc")
+	}
+	return nil
+}
+
+// Cursor returns an opaque cursor for the next page or an empty
+// string if this is the last page.
+func (page *Page) Cursor() string {
+	if page.Limit == 0 || page.Offset+page.Limit >= page.Total {
+		return ""
+	}
+	q := url.Values{}
+	q.Set("l", strconv.Itoa(page.Limit))
+	q.Set("o", strconv.Itoa(page.Offset+page.Limit))
+	q.Set("d", page.Order)
+	return base64.RawURLEncoding.EncodeToString([]byte(q.Encode()))
+}
+
+// DecodePageCursor reverses Page.Cursor
+func DecodePageCursor(cursor string) (*Page, error) {
+	src, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("cursor not valid")
+	}
+	q, err := url.ParseQuery(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("cursor not valid")
+	}
+	page := new(Page)
+	if page.Limit, err = strconv.Atoi(q.Get("l")); err != nil {
+		return nil, fmt.Errorf("cursor not valid")
+	}
+	if page.Offset, err = strconv.Atoi(q.Get("o")); err != nil {
+		return nil, fmt.Errorf("cursor not valid")
+	}
+	page.Order = q.Get("d")
+	if err := page.validate(); err != nil {
+		return nil, fmt.Errorf("cursor not valid, %s", err)
+	}
+	return page, nil
+}
+
+// WithPage returns a copy of config which applies page to the id
+// list queries (e.g. GetAllEPrintIDs, GetEPrintIDsForYear).
+func (config *Config) WithPage(page *Page) *Config {
+	c := *config
+	c.page = page
+	return &c
+}
+
+// pageStatement rewrites stmt to return the page of results. The
+// list is wrapped in a derived table when it is re-ordered or
+// already has a LIMIT. Otherwise the list's first column is added
+// to its ORDER BY so rows sharing the same sort values (e.g. the
+// same date) are returned in the same order on every page.
+func pageStatement(stmt string, args []interface{}, page *Page) (string, []interface{}) {
+	stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+	upper := strings.ToUpper(stmt)
+	if page.Order != "" || strings.Contains(upper, " LIMIT ") {
+		stmt = fmt.Sprintf("SELECT * FROM (%s) AS page_list ORDER BY 1", stmt)
+		if page.Order != "" {
+			stmt += " " + strings.ToUpper(page.Order)
+		}
+	} else if i := strings.LastIndex(upper, "ORDER BY"); i >= 0 && !strings.Contains(upper[i:], ")") {
+		stmt += ", 1"
+	} else {
+		stmt += " ORDER BY 1"
+	}
+	limit := uint64(page.Limit)
+	if limit == 0 {
+		// NOTE: MySQL requires a LIMIT with an OFFSET
+		limit = 18446744073709551615
+	}
+	return stmt + " LIMIT ? OFFSET ?", append(append([]interface{}{}, args...), limit, page.Offset)
+}
+
+// paginate sets the page's Total for stmt and returns the
+// statement and args for the requested page. If config has no
+// page stmt and args are returned unchanged.
+func (config *Config) paginate(repoID string, stmt string, args []interface{}) (string, []interface{}, error) {
+	page := config.page
+	if page == nil {
+		return stmt, args, nil
+	}
+	db, ok := config.Connections[repoID]
+	if !ok {
+		return "", nil, fmt.Errorf("bad request")
+	}
+	countStmt := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS page_list", strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+	if err := db.QueryRow(countStmt, args...).Scan(&page.Total); err != nil {
+		return "", nil, fmt.Errorf("ERROR: query error (%q), %s", repoID, err)
+	}
+	stmt, args = pageStatement(stmt, args, page)
+	return stmt, args, nil
+}
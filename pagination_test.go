@@ -0,0 +1,113 @@
+package eprinttools
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	for q, expected := range map[string]*Page{
+		"":                           nil,
+		"eprint_status=archive":      nil,
+		"limit=10":                   {Limit: 10},
+		"limit=10&offset=20":         {Limit: 10, Offset: 20},
+		"order=DESC":                 {Order: "desc"},
+		"limit=5&offset=5&order=asc": {Limit: 5, Offset: 5, Order: "asc"},
+	} {
+		values, _ := url.ParseQuery(q)
+		page, err := ParsePage(values)
+		if err != nil {
+			t.Errorf("ParsePage(%q) failed, %s", q, err)
+			continue
+		}
+		if expected == nil || page == nil {
+			if expected != page {
+				t.Errorf("ParsePage(%q) expected %+v, got %+v", q, expected, page)
+			}
+			continue
+		}
+		if *expected != *page {
+			t.Errorf("ParsePage(%q) expected %+v, got %+v", q, expected, page)
+		}
+	}
+	for _, q := range []string{"limit=ten", "limit=-1", "limit=100000", "offset=-5", "order=sideways", "cursor=!!!", "cursor=abc&limit=10"} {
+		values, _ := url.ParseQuery(q)
+		if _, err := ParsePage(values); err == nil {
+			t.Errorf("ParsePage(%q) expected an error", q)
+		}
+	}
+}
+
+func TestPageCursor(t *testing.T) {
+	page := &Page{Limit: 10, Offset: 20, Order: "desc", Total: 35}
+	cursor := page.Cursor()
+	if cursor == "" {
+		t.Fatalf("expected a cursor for %+v", page)
+	}
+	next, err := ParsePage(url.Values{"cursor": []string{cursor}})
+	if err != nil {
+		t.Fatalf("ParsePage(cursor) failed, %s", err)
+	}
+	if next.Limit != 10 || next.Offset != 30 || next.Order != "desc" {
+		t.Errorf("unexpected next page %+v", next)
+	}
+	next.Total = 35
+	if cursor := next.Cursor(); cursor != "" {
+		t.Errorf("expected no cursor for last page %+v, got %q", next, cursor)
+	}
+	if cursor := (&Page{Offset: 10, Total: 35}).Cursor(); cursor != "" {
+		t.Errorf("expected no cursor without a limit, got %q", cursor)
+	}
+}
+
+func TestPageStatement(t *testing.T) {
+	stmt, args := pageStatement(`SELECT eprintid FROM eprint WHERE eprint_status = ? ORDER BY eprintid`, []interface{}{"archive"}, &Page{Limit: 10, Offset: 20})
+	assertStringSame(t, "stmt", `SELECT eprintid FROM eprint WHERE eprint_status = ? ORDER BY eprintid, 1 LIMIT ? OFFSET ?`, stmt)
+	if len(args) != 3 || args[0] != "archive" || args[1] != uint64(10) || args[2] != 20 {
+		t.Errorf("unexpected args %+v", args)
+	}
+	stmt, _ = pageStatement(`SELECT eprintid FROM eprint ORDER BY eprintid`, nil, &Page{Order: "desc"})
+	assertStringSame(t, "stmt", `SELECT * FROM (SELECT eprintid FROM eprint ORDER BY eprintid) AS page_list ORDER BY 1 DESC LIMIT ? OFFSET ?`, stmt)
+	// Lists sorted by date get a unique tie-breaker
+	stmt, _ = pageStatement(`SELECT eprintid FROM eprint ORDER BY date_year DESC, date_month DESC, date_day DESC`, nil, &Page{Limit: 10})
+	assertStringSame(t, "stmt", `SELECT eprintid FROM eprint ORDER BY date_year DESC, date_month DESC, date_day DESC, 1 LIMIT ? OFFSET ?`, stmt)
+	stmt, _ = pageStatement(`SELECT eprintid FROM eprint WHERE eprintid IN (SELECT eprintid FROM eprint_creators_id ORDER BY pos)`, nil, &Page{Limit: 10})
+	assertStringSame(t, "stmt", `SELECT eprintid FROM eprint WHERE eprintid IN (SELECT eprintid FROM eprint_creators_id ORDER BY pos) ORDER BY 1 LIMIT ? OFFSET ?`, stmt)
+	stmt, _ = pageStatement(`SELECT eprintid FROM eprint ORDER BY lastmod_year DESC LIMIT 25`, nil, &Page{Limit: 10})
+	assertStringSame(t, "stmt", `SELECT * FROM (SELECT eprintid FROM eprint ORDER BY lastmod_year DESC LIMIT 25) AS page_list ORDER BY 1 LIMIT ? OFFSET ?`, stmt)
+}
+
+func TestPackagePage(t *testing.T) {
+	api := new(EP3API)
+	api.Log = log.New(ioutil.Discard, "", 0)
+	api.Config = &Config{BaseURL: "http://localhost:8484"}
+	page := &Page{Limit: 2, Offset: 0, Total: 5}
+	r := httptest.NewRequest("GET", "/lemurprints/keys?limit=2&eprint_status=archive", nil)
+	r = r.WithContext(context.WithValue(r.Context(), pageContextKey{}, page))
+	if api.config(r).page != page || api.Config.page != nil {
+		t.Errorf("expected request config to carry the page")
+	}
+	w := httptest.NewRecorder()
+	if status, err := api.packageIntIDs(w, r, "lemurprints", []int{1, 2}, nil); err != nil || status != 200 {
+		t.Fatalf("packageIntIDs failed, %d %s", status, err)
+	}
+	envelope := new(pageEnvelope)
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("expected a page envelope, %s", err)
+	}
+	assertIntSame(t, "total", 5, envelope.Total)
+	u, err := url.Parse(envelope.Next)
+	if err != nil || u.Path != "/lemurprints/keys" {
+		t.Fatalf("unexpected next link %q", envelope.Next)
+	}
+	assertStringSame(t, "eprint_status", "archive", u.Query().Get("eprint_status"))
+	next, err := ParsePage(u.Query())
+	if err != nil || next.Offset != 2 || next.Limit != 2 {
+		t.Errorf("unexpected next page %+v, %v", next, err)
+	}
+}
@@ -0,0 +1,367 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * roundtrip.go implements a verifier that checks EPrints XML
+ * survives a round trip through our XML, JSON and simplified
+ * record representations without silently dropping fields.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	// Caltech Library Packages
+	"github.com/caltechlibrary/simplified"
+)
+
+// RoundTripDiff describes a field whose value changed (or was
+// dropped) during a round trip.
+type RoundTripDiff struct {
+	// Stage is where the difference was found, "decode", "xml",
+	// "json" or "simplified".
+	Stage string `json:"stage"`
+	// Path identifies the field, e.g. eprint[0].creators.items[1].orcid
+	Path string `json:"path"`
+	// Expected is the value before the round trip
+	Expected string `json:"expected,omitempty"`
+	// Got is the value after the round trip
+	Got string `json:"got,omitempty"`
+}
+
+// String renders a RoundTripDiff as a single line
+func (d *RoundTripDiff) String() string {
+	return fmt.Sprintf("%s: %s expected %q, got %q", d.Stage, d.Path, d.Expected, d.Got)
+}
+
+// VerifyRoundTrip decodes an EPrints XML document and checks that no
+// elements are dropped in decoding. It then passes the result to
+// VerifyEPrintsRoundTrip.
+func VerifyRoundTrip(src []byte) ([]*RoundTripDiff, error) {
+	eprints := NewEPrints()
+	if err := xml.Unmarshal(src, &eprints); err != nil {
+		return nil, err
+	}
+	diffs, err := verifyDecodeXML(src, eprints)
+	if err != nil {
+		return nil, err
+	}
+	more, err := VerifyEPrintsRoundTrip(eprints)
+	if err != nil {
+		return nil, err
+	}
+	return append(diffs, more...), nil
+}
+
+// VerifyEPrintsRoundTrip re-encodes eprints as XML and JSON, decodes
+// them again and reports field level differences. Each EPrint is also
+// crosswalked to a simplified record and back (via
+// CrosswalkRecordToEPrint) and the two simplified records compared.
+func VerifyEPrintsRoundTrip(eprints *EPrints) ([]*RoundTripDiff, error) {
+	diffs := []*RoundTripDiff{}
+
+	// EPrints XML round trip
+	src, err := xml.Marshal(eprints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode XML, %s", err)
+	}
+	fromXML := NewEPrints()
+	if err := xml.Unmarshal(src, &fromXML); err != nil {
+		return nil, fmt.Errorf("failed to decode XML, %s", err)
+	}
+	diffStructs("xml", "xml", "eprints", reflect.ValueOf(eprints), reflect.ValueOf(fromXML), &diffs)
+
+	// EPrints JSON round trip
+	src, err = json.Marshal(eprints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON, %s", err)
+	}
+	fromJSON := NewEPrints()
+	if err := json.Unmarshal(src, &fromJSON); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON, %s", err)
+	}
+	diffStructs("json", "json", "eprints", reflect.ValueOf(eprints), reflect.ValueOf(fromJSON), &diffs)
+
+	// Simplified record round trip
+	for i, eprint := range eprints.EPrint {
+		rec := new(simplified.Record)
+		if err := CrosswalkEPrintToRecord(eprint, rec); err != nil {
+			return nil, fmt.Errorf("eprint[%d] failed to crosswalk to simplified record, %s", i, err)
+		}
+		recSrc, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		rec2 := new(simplified.Record)
+		if err := json.Unmarshal(recSrc, &rec2); err != nil {
+			return nil, err
+		}
+		eprint2 := new(EPrint)
+		if err := CrosswalkRecordToEPrint(rec2, eprint2); err != nil {
+			return nil, fmt.Errorf("eprint[%d] failed to crosswalk from simplified record, %s", i, err)
+		}
+		rec3 := new(simplified.Record)
+		if err := CrosswalkEPrintToRecord(eprint2, rec3); err != nil {
+			return nil, fmt.Errorf("eprint[%d] failed to crosswalk to simplified record, %s", i, err)
+		}
+		expected, err := jsonTree(rec)
+		if err != nil {
+			return nil, err
+		}
+		got, err := jsonTree(rec3)
+		if err != nil {
+			return nil, err
+		}
+		diffTrees("simplified", fmt.Sprintf("eprint[%d]", i), expected, got, &diffs)
+	}
+	return diffs, nil
+}
+
+// verifyDecodeXML checks that every non-empty element inside an
+// <eprint> maps to a field populated by xml.Unmarshal.
+func verifyDecodeXML(src []byte, eprints *EPrints) ([]*RoundTripDiff, error) {
+	diffs := []*RoundTripDiff{}
+	fields := xmlFieldIndex(reflect.TypeOf(EPrint{}))
+	decoder := xml.NewDecoder(bytes.NewReader(src))
+	i, depth := -1, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && t.Name.Local == "eprint" {
+				i++
+			}
+			if depth == 3 && i >= 0 && i < len(eprints.EPrint) {
+				// Read the element so we know if it is empty
+				var element struct {
+					Inner string `xml:",innerxml"`
+				}
+				if err := decoder.DecodeElement(&element, &t); err != nil {
+					return nil, err
+				}
+				depth--
+				if strings.TrimSpace(element.Inner) == "" {
+					continue
+				}
+				path := fmt.Sprintf("eprint[%d].%s", i, t.Name.Local)
+				fieldIndex, ok := fields[t.Name.Local]
+				if !ok {
+					diffs = append(diffs, &RoundTripDiff{Stage: "decode", Path: path, Expected: strings.TrimSpace(element.Inner), Got: "unknown element"})
+					continue
+				}
+				value := reflect.ValueOf(eprints.EPrint[i]).Elem().FieldByIndex(fieldIndex)
+				if value.Kind() == reflect.Int && strings.TrimSpace(element.Inner) == "0" {
+					continue
+				}
+				if value.IsZero() {
+					diffs = append(diffs, &RoundTripDiff{Stage: "decode", Path: path, Expected: strings.TrimSpace(element.Inner), Got: "dropped"})
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return diffs, nil
+}
+
+// xmlFieldIndex maps XML element names to struct field indexes
+func xmlFieldIndex(t reflect.Type) map[string][]int {
+	fields := map[string][]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := tagName(field, "xml")
+		if name == "" || name == "-" {
+			continue
+		}
+		// NOTE: "documents>document" is held in the documents element.
+		if j := strings.Index(name, ">"); j >= 0 {
+			name = name[0:j]
+		}
+		fields[name] = field.Index
+	}
+	return fields
+}
+
+// tagName returns the name portion of a struct tag
+func tagName(field reflect.StructField, key string) string {
+	tag, ok := field.Tag.Lookup(key)
+	if !ok {
+		return ""
+	}
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[0:i]
+	}
+	return tag
+}
+
+// skipField returns true if a field isn't carried by the format
+func skipField(field reflect.StructField, format string) bool {
+	if field.PkgPath != "" || field.Type == reflect.TypeOf(xml.Name{}) {
+		return true
+	}
+	tag := field.Tag.Get(format)
+	if tag == "-" {
+		return true
+	}
+	// NOTE: attributes other than id aren't round tripped in JSON
+	return format == "xml" && strings.Contains(tag, ",attr") && tagName(field, "json") == "-"
+}
+
+// fieldLabel returns the name used for a field in a diff path
+func fieldLabel(field reflect.StructField, format string) string {
+	if name := tagName(field, format); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// diffStructs walks expected and got reporting differences
+func diffStructs(stage string, format string, path string, expected reflect.Value, got reflect.Value, diffs *[]*RoundTripDiff) {
+	// Treat nil pointers and interfaces like zero values
+	for expected.Kind() == reflect.Ptr || expected.Kind() == reflect.Interface {
+		if expected.IsNil() {
+			expected = reflect.Zero(expected.Type().Elem())
+			if expected.Kind() == reflect.Interface {
+				break
+			}
+		} else {
+			expected = expected.Elem()
+		}
+	}
+	for got.Kind() == reflect.Ptr || got.Kind() == reflect.Interface {
+		if got.IsNil() {
+			got = reflect.Zero(got.Type().Elem())
+			if got.Kind() == reflect.Interface {
+				break
+			}
+		} else {
+			got = got.Elem()
+		}
+	}
+	if expected.Kind() != got.Kind() {
+		*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: fmt.Sprintf("%v", expected), Got: fmt.Sprintf("%v", got)})
+		return
+	}
+	switch expected.Kind() {
+	case reflect.Struct:
+		if t, ok := expected.Interface().(time.Time); ok {
+			if !t.Equal(got.Interface().(time.Time)) {
+				*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: t.String(), Got: got.Interface().(time.Time).String()})
+			}
+			return
+		}
+		for i := 0; i < expected.NumField(); i++ {
+			field := expected.Type().Field(i)
+			if skipField(field, format) {
+				continue
+			}
+			diffStructs(stage, format, path+"."+fieldLabel(field, format), expected.Field(i), got.Field(i), diffs)
+		}
+	case reflect.Slice, reflect.Array:
+		if expected.Len() != got.Len() {
+			*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: fmt.Sprintf("%d items", expected.Len()), Got: fmt.Sprintf("%d items", got.Len())})
+			return
+		}
+		for i := 0; i < expected.Len(); i++ {
+			diffStructs(stage, format, fmt.Sprintf("%s[%d]", path, i), expected.Index(i), got.Index(i), diffs)
+		}
+	case reflect.Map:
+		// Maps (e.g. primary_object) are compared as JSON
+		e, _ := json.Marshal(expected.Interface())
+		g, _ := json.Marshal(got.Interface())
+		if !bytes.Equal(e, g) && !(expected.Len() == 0 && got.Len() == 0) {
+			*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: string(e), Got: string(g)})
+		}
+	case reflect.String:
+		if strings.TrimSpace(expected.String()) != strings.TrimSpace(got.String()) {
+			*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: expected.String(), Got: got.String()})
+		}
+	case reflect.Invalid:
+		return
+	default:
+		if !reflect.DeepEqual(expected.Interface(), got.Interface()) {
+			*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: fmt.Sprintf("%v", expected), Got: fmt.Sprintf("%v", got)})
+		}
+	}
+}
+
+// jsonTree renders obj as JSON then decodes it into generic maps,
+// slices and scalars for comparison.
+func jsonTree(obj interface{}) (interface{}, error) {
+	src, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(src, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// diffTrees compares two decoded JSON trees reporting differences
+func diffTrees(stage string, path string, expected interface{}, got interface{}, diffs *[]*RoundTripDiff) {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: fmt.Sprintf("%v", expected), Got: fmt.Sprintf("%v", got)})
+			return
+		}
+		keys := []string{}
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffTrees(stage, path+"."+k, e[k], g[k], diffs)
+		}
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(e) != len(g) {
+			*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: fmt.Sprintf("%d items", len(e)), Got: fmt.Sprintf("%v", got)})
+			return
+		}
+		for i := range e {
+			diffTrees(stage, fmt.Sprintf("%s[%d]", path, i), e[i], g[i], diffs)
+		}
+	default:
+		if !reflect.DeepEqual(expected, got) {
+			*diffs = append(*diffs, &RoundTripDiff{Stage: stage, Path: path, Expected: fmt.Sprintf("%v", expected), Got: fmt.Sprintf("%v", got)})
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package eprinttools
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVerifyRoundTrip checks our test EPrints XML survives the XML,
+// JSON and simplified record round trips without dropping fields.
+func TestVerifyRoundTrip(t *testing.T) {
+	fNames := []string{}
+	for _, pattern := range []string{"srctest/*.xml", "testdata/*.xml"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatalf("filepath.Glob(%q) failed, %s", pattern, err)
+		}
+		fNames = append(fNames, matches...)
+	}
+	if len(fNames) == 0 {
+		t.Skipf("no EPrints XML test files found")
+	}
+	for _, fName := range fNames {
+		src, err := ioutil.ReadFile(fName)
+		if err != nil {
+			t.Errorf("Failed to read %q, %s", fName, err)
+			continue
+		}
+		diffs, err := VerifyRoundTrip(src)
+		if err != nil {
+			t.Errorf("VerifyRoundTrip(%q) failed, %s", fName, err)
+			continue
+		}
+		for _, diff := range diffs {
+			t.Errorf("%s %s", fName, diff)
+		}
+	}
+}
+
+func TestRoundTripFields(t *testing.T) {
+	src := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<eprints xmlns="http://eprints.org/ep2/data/2.0">
+  <eprint id="https://example.edu/id/eprint/1">
+    <eprintid>1</eprintid>
+    <type>patent</type>
+    <title>A widget</title>
+    <contact_email>jane@example.edu</contact_email>
+    <patent_classification>G01N 21/00</patent_classification>
+    <keywords>
+      <item>widgets</item>
+      <item>gadgets</item>
+    </keywords>
+    <creators>
+      <item>
+        <name><family>Doe</family><given>Jane</given></name>
+        <orcid>0000-0002-1825-0097</orcid>
+      </item>
+    </creators>
+    <corp_creators>
+      <item>
+        <name>Example Lab</name>
+        <ror>https://ror.org/05dxps055</ror>
+      </item>
+    </corp_creators>
+  </eprint>
+</eprints>`)
+	eprints := NewEPrints()
+	if err := xml.Unmarshal(src, &eprints); err != nil {
+		t.Fatalf("xml.Unmarshal() failed, %s", err)
+	}
+	eprint := eprints.EPrint[0]
+	if eprint.Keywords != "widgets; gadgets" {
+		t.Errorf("expected keyword items to be joined, got %q", eprint.Keywords)
+	}
+	if eprint.PatentClassificationText != "G01N 21/00" {
+		t.Errorf("expected patent_classification, got %q", eprint.PatentClassificationText)
+	}
+
+	jsonSrc, err := json.Marshal(eprints)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed, %s", err)
+	}
+	for _, s := range []string{`"contact_email"`, `"patent_classification"`, `"ror"`, `"orcid"`} {
+		if !strings.Contains(string(jsonSrc), s) {
+			t.Errorf("expected %s in JSON, got %s", s, jsonSrc)
+		}
+	}
+	fromJSON := NewEPrints()
+	if err := json.Unmarshal(jsonSrc, &fromJSON); err != nil {
+		t.Fatalf("json.Unmarshal() failed, %s", err)
+	}
+	got := fromJSON.EPrint[0]
+	if got.ContactEMail != eprint.ContactEMail {
+		t.Errorf("expected contact_email %q, got %q", eprint.ContactEMail, got.ContactEMail)
+	}
+	if got.Creators == nil || len(got.Creators.Items) != 1 || got.Creators.Items[0].ORCID != "0000-0002-1825-0097" {
+		t.Errorf("expected creator orcid to survive JSON round trip, got %+v", got.Creators)
+	}
+	if got.CorpCreators == nil || len(got.CorpCreators.Items) != 1 || got.CorpCreators.Items[0].ROR != "https://ror.org/05dxps055" {
+		t.Errorf("expected corp creator ror to survive JSON round trip, got %+v", got.CorpCreators)
+	}
+
+	diffs, err := VerifyRoundTrip(src)
+	if err != nil {
+		t.Fatalf("VerifyRoundTrip() failed, %s", err)
+	}
+	for _, diff := range diffs {
+		t.Errorf("%s", diff)
+	}
+
+	// A dropped field should be reported
+	diffs, err = VerifyRoundTrip([]byte(`<eprints><eprint><eprintid>2</eprintid><not_a_field>x</not_a_field></eprint></eprints>`))
+	if err != nil {
+		t.Fatalf("VerifyRoundTrip() failed, %s", err)
+	}
+	if len(diffs) != 1 || diffs[0].Stage != "decode" || diffs[0].Path != "eprint[0].not_a_field" {
+		t.Errorf("expected a decode diff for not_a_field, got %+v", diffs)
+	}
+}
+
+// TestContectEMail checks JSON written before contact_email was
+// spelled correctly still decodes.
+func TestContectEMail(t *testing.T) {
+	eprint := new(EPrint)
+	if err := json.Unmarshal([]byte(`{"eprint_id":1,"title":"A title","contect_email":"jane@example.edu"}`), eprint); err != nil {
+		t.Fatalf("json.Unmarshal() failed, %s", err)
+	}
+	if eprint.ContactEMail != "jane@example.edu" || eprint.Title != "A title" {
+		t.Errorf("expected contect_email to decode, got %q, %q", eprint.ContactEMail, eprint.Title)
+	}
+	eprint = new(EPrint)
+	if err := json.Unmarshal([]byte(`{"contact_email":"new@example.edu","contect_email":"old@example.edu"}`), eprint); err != nil {
+		t.Fatalf("json.Unmarshal() failed, %s", err)
+	}
+	if eprint.ContactEMail != "new@example.edu" {
+		t.Errorf("expected contact_email to take precedence, got %q", eprint.ContactEMail)
+	}
+	src, _ := json.Marshal(eprint)
+	if strings.Contains(string(src), "contect_email") {
+		t.Errorf("contect_email should not be written, %s", src)
+	}
+}
@@ -0,0 +1,224 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * search.go implements compound searches of an EPrints repository.
+ * A search combines several field filters with AND or OR into a
+ * single parameterized SQL query returning eprint ids.
+ */
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// searchField describes a field that can be searched. Fields in the
+// eprint table are compared directly, fields in side tables are
+// matched with a sub-select on eprintid.
+type searchField struct {
+	Table  string
+	Column string
+}
+
+var (
+	// searchFields maps a search parameter to the field searched
+	searchFields = map[string]*searchField{
+		"creator_id":    {"eprint_creators_id", "creators_id"},
+		"creator_orcid": {"eprint_creators_orcid", "creators_orcid"},
+		"group":         {"eprint_local_group", "local_group"},
+		"funder":        {"eprint_funders_agency", "funders_agency"},
+		"type":          {"eprint", "type"},
+		"status":        {"eprint", "eprint_status"},
+		"issn":          {"eprint", "issn"},
+	}
+
+	// searchOptions are parameters accepted along with the fields
+	searchOptions = []string{"op", "is_public", "from", "until", "limit", "offset", "cursor", "order"}
+)
+
+// SearchFilter is a single field filter, e.g. group = "GALCIT"
+type SearchFilter struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// SearchQuery holds the filters of a compound search. Filters are
+// combined with Op ("and" or "or"). IsPublic and the publication
+// date range (From, Until as approximate dates, e.g. 2020, 2020-05)
+// always apply.
+type SearchQuery struct {
+	Op       string          `json:"op"`
+	Filters  []*SearchFilter `json:"filters"`
+	IsPublic bool            `json:"is_public,omitempty"`
+	From     string          `json:"from,omitempty"`
+	Until    string          `json:"until,omitempty"`
+}
+
+// SearchFields returns the names of the fields that can be searched
+func SearchFields() []string {
+	fields := []string{}
+	for field := range searchFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// ParseSearchQuery reads a SearchQuery from query parameters. Each
+// field parameter may be repeated. Unknown parameters are an error.
+func ParseSearchQuery(q url.Values) (*SearchQuery, error) {
+	query := &SearchQuery{Op: "and"}
+	keys := []string{}
+	for key := range q {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := searchFields[key]; ok {
+			for _, value := range q[key] {
+				if value = strings.TrimSpace(value); value != "" {
+					query.Filters = append(query.Filters, &SearchFilter{Field: key, Value: value})
+				}
+			}
+			continue
+		}
+		known := false
+		for _, option := range searchOptions {
+			if key == option {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unsupported search parameter %q", key)
+		}
+	}
+	if op := strings.ToLower(q.Get("op")); op != "" {
+		if op != "and" && op != "or" {
+			return nil, fmt.Errorf("op must be and or or")
+		}
+		query.Op = op
+	}
+	switch strings.ToLower(q.Get("is_public")) {
+	case "", "false":
+	case "true":
+		query.IsPublic = true
+	default:
+		return nil, fmt.Errorf("is_public must be true or false")
+	}
+	query.From, query.Until = q.Get("from"), q.Get("until")
+	if query.From != "" {
+		if _, err := time.Parse(datestamp, expandAproxDate(query.From, true)); err != nil {
+			return nil, fmt.Errorf("from %q not valid, %s", query.From, err)
+		}
+	}
+	if query.Until != "" {
+		if _, err := time.Parse(datestamp, expandAproxDate(query.Until, false)); err != nil {
+			return nil, fmt.Errorf("until %q not valid, %s", query.Until, err)
+		}
+	}
+	if len(query.Filters) == 0 && !query.IsPublic && query.From == "" && query.Until == "" {
+		return nil, fmt.Errorf("missing search filters")
+	}
+	return query, nil
+}
+
+// searchesPrivate returns true if the search asks for records that
+// are not public, i.e. "is_public=false" or a status filter other
+// than "archive" (e.g. inbox, buffer or deletion).
+func searchesPrivate(q url.Values, query *SearchQuery) bool {
+	if strings.ToLower(q.Get("is_public")) == "false" {
+		return true
+	}
+	for _, filter := range query.Filters {
+		if filter.Field == "status" && filter.Value != "archive" {
+			return true
+		}
+	}
+	return false
+}
+
+// searchStatement compiles query into a SQL statement and its
+// arguments. Fields not in the repository's table map are rejected.
+func searchStatement(tableMap map[string][]string, query *SearchQuery) (string, []interface{}, error) {
+	conditions, args := []string{}, []interface{}{}
+	for _, filter := range query.Filters {
+		field, ok := searchFields[filter.Field]
+		if !ok {
+			return "", nil, fmt.Errorf("unsupported search field %q", filter.Field)
+		}
+		if !hasColumn(tableMap, field.Table, field.Column) {
+			return "", nil, fmt.Errorf("search field %q not supported by repository", filter.Field)
+		}
+		if field.Table == "eprint" {
+			conditions = append(conditions, fmt.Sprintf("eprint.%s = ?", field.Column))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("eprint.eprintid IN (SELECT %s.eprintid FROM %s WHERE %s.%s = ?)", field.Table, field.Table, field.Table, field.Column))
+		}
+		args = append(args, filter.Value)
+	}
+	where := []string{}
+	if len(conditions) > 0 {
+		op := " AND "
+		if query.Op == "or" {
+			op = " OR "
+		}
+		where = append(where, "("+strings.Join(conditions, op)+")")
+	}
+	if query.IsPublic {
+		where = append(where, `(eprint.eprint_status = "archive" AND eprint.metadata_visibility = "show")`)
+	}
+	if query.From != "" || query.Until != "" {
+		start, end := "0000-01-01", "9999-12-31"
+		if query.From != "" {
+			start = expandAproxDate(query.From, true)
+		}
+		if query.Until != "" {
+			end = expandAproxDate(query.Until, false)
+		}
+		where = append(where, `(eprint.date_type = "published" AND CONCAT(eprint.date_year, "-",
+LPAD(IFNULL(eprint.date_month, 1), 2, "0"), "-",
+LPAD(IFNULL(eprint.date_day, 1), 2, "0")) >= ? AND CONCAT(eprint.date_year, "-",
+LPAD(IFNULL(eprint.date_month, 12), 2, "0"), "-",
+LPAD(IFNULL(eprint.date_day, 28), 2, "0")) <= ?)`)
+		args = append(args, start, end)
+	}
+	if len(where) == 0 {
+		return "", nil, fmt.Errorf("missing search filters")
+	}
+	stmt := fmt.Sprintf(`SELECT eprint.eprintid AS eprintid FROM eprint
+WHERE %s
+ORDER BY eprint.date_year DESC, eprint.date_month DESC, eprint.date_day DESC, eprint.eprintid DESC`, strings.Join(where, " AND "))
+	return stmt, args, nil
+}
+
+// SearchEPrintIDs returns the eprint ids matching query in repoID
+func SearchEPrintIDs(config *Config, repoID string, query *SearchQuery) ([]int, error) {
+	ds, ok := config.Repositories[repoID]
+	if !ok {
+		return nil, fmt.Errorf("not found, %q not defined", repoID)
+	}
+	stmt, args, err := searchStatement(ds.TableMap, query)
+	if err != nil {
+		return nil, err
+	}
+	return sqlQueryIntIDs(config, repoID, stmt, args...)
+}
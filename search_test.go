@@ -0,0 +1,132 @@
+package eprinttools
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	q, _ := url.ParseQuery("creator_id=Doe-J&group=GALCIT&group=Lemur+Lab&op=OR&from=2020&until=2021-02&limit=10")
+	query, err := ParseSearchQuery(q)
+	if err != nil {
+		t.Fatalf("ParseSearchQuery failed, %s", err)
+	}
+	assertStringSame(t, "op", "or", query.Op)
+	assertIntSame(t, "filters", 3, len(query.Filters))
+	assertStringSame(t, "until", "2021-02", query.Until)
+	for _, s := range []string{"", "title=Lemurs", "group=GALCIT&op=xor", "is_public=maybe", "from=last-year", "limit=10"} {
+		q, _ := url.ParseQuery(s)
+		if _, err := ParseSearchQuery(q); err == nil {
+			t.Errorf("ParseSearchQuery(%q) expected an error", s)
+		}
+	}
+}
+
+func TestSearchStatement(t *testing.T) {
+	tableMap := map[string][]string{
+		"eprint":             {"eprintid", "type", "eprint_status", "issn"},
+		"eprint_creators_id": {"eprintid", "pos", "creators_id"},
+		"eprint_local_group": {"eprintid", "pos", "local_group"},
+	}
+	query := &SearchQuery{
+		Op: "and",
+		Filters: []*SearchFilter{
+			{Field: "creator_id", Value: "Doe-J"},
+			{Field: "type", Value: "article"},
+		},
+		IsPublic: true,
+		From:     "2020",
+	}
+	stmt, args, err := searchStatement(tableMap, query)
+	if err != nil {
+		t.Fatalf("searchStatement failed, %s", err)
+	}
+	for _, expected := range []string{
+		"eprint.eprintid IN (SELECT eprint_creators_id.eprintid FROM eprint_creators_id WHERE eprint_creators_id.creators_id = ?) AND eprint.type = ?",
+		`eprint.eprint_status = "archive"`,
+		`eprint.date_type = "published"`,
+	} {
+		if !strings.Contains(stmt, expected) {
+			t.Errorf("expected %q in %s", expected, stmt)
+		}
+	}
+	if strings.Count(stmt, "?") != len(args) {
+		t.Errorf("expected %d args, got %d", strings.Count(stmt, "?"), len(args))
+	}
+	if len(args) != 4 || args[0] != "Doe-J" || args[2] != "2020-01-01" || args[3] != "9999-12-31" {
+		t.Errorf("unexpected args %+v", args)
+	}
+
+	query.Op = "or"
+	stmt, _, _ = searchStatement(tableMap, query)
+	if !strings.Contains(stmt, "creators_id = ?) OR eprint.type = ?") {
+		t.Errorf("expected OR in %s", stmt)
+	}
+
+	// Fields not in the table map are rejected
+	query.Filters = append(query.Filters, &SearchFilter{Field: "funder", Value: "NSF"})
+	if _, _, err := searchStatement(tableMap, query); err == nil {
+		t.Errorf("expected an error for a field not in the table map")
+	}
+}
+
+// TestSearchPrivateStatus checks callers without read-private can't
+// list non-public records with the status or is_public filters.
+func TestSearchPrivateStatus(t *testing.T) {
+	for s, expected := range map[string]bool{
+		"status=archive":                    true,
+		"status=inbox":                      false,
+		"status=archive&status=buffer":      false,
+		"group=GALCIT&is_public=false":      false,
+		"group=GALCIT&is_public=true":       true,
+		"group=GALCIT&status=deletion":      false,
+		"type=article&op=or&status=ARCHIVE": false,
+	} {
+		q, _ := url.ParseQuery(s)
+		query, err := ParseSearchQuery(q)
+		if err != nil {
+			t.Fatalf("ParseSearchQuery(%q) failed, %s", s, err)
+		}
+		if searchesPrivate(q, query) == expected {
+			t.Errorf("searchesPrivate(%q) expected %t", s, !expected)
+		}
+	}
+
+	api := metricsTestEP3API()
+	api.Config.APITokens = []*APIToken{{Name: "feeds"}}
+	for _, target := range []string{
+		"/lemurprints/search?status=inbox",
+		"/lemurprints/search?status=buffer&type=article",
+		"/lemurprints/search?group=GALCIT&is_public=false",
+	} {
+		w := httptest.NewRecorder()
+		r := withAuth(httptest.NewRequest("GET", target, nil), new(apiAuth))
+		if status, err := api.searchEndPoint(w, r, "lemurprints", nil); status != 401 || err == nil {
+			t.Errorf("anonymous %s expected 401, got %d %v", target, status, err)
+		}
+		token := &APIToken{Name: "feeds", Scopes: map[string][]string{"lemurprints": {ScopeReadPublic}}}
+		r = withAuth(httptest.NewRequest("GET", target, nil), &apiAuth{Name: token.Name, Token: token})
+		if status, err := api.searchEndPoint(w, r, "lemurprints", nil); status != 403 || err == nil {
+			t.Errorf("read-public %s expected 403, got %d %v", target, status, err)
+		}
+	}
+}
+
+func TestExpandAproxDate(t *testing.T) {
+	for _, c := range []struct {
+		dt        string
+		roundDown bool
+		expected  string
+	}{
+		{"2020", true, "2020-01-01"},
+		{"2020", false, "2020-12-31"},
+		{"2021-02", true, "2021-02-01"},
+		{"2021-02", false, "2021-02-28"},
+		{"2020-02", false, "2020-02-29"},
+		{"2020-05-21", false, "2020-05-21"},
+	} {
+		assertStringSame(t, c.dt, c.expected, expandAproxDate(c.dt, c.roundDown))
+	}
+}
@@ -0,0 +1,424 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * searchindex.go implements a flattened index document model for
+ * integrating harvested EPrint records with search engines such as
+ * Solr, OpenSearch, LunrJS and Pagefind.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// IndexSolr is the Solr JSON update format (a JSON array of documents)
+	IndexSolr = "solr"
+	// IndexOpenSearch is the OpenSearch (Elasticsearch) bulk NDJSON format
+	IndexOpenSearch = "opensearch"
+	// IndexLunr is a JSON document ready to be loaded into a LunrJS index
+	IndexLunr = "lunr"
+	// IndexPagefind is a list of Pagefind custom records
+	IndexPagefind = "pagefind"
+)
+
+var (
+	// IndexFormats lists the supported index export formats
+	IndexFormats = []string{IndexSolr, IndexOpenSearch, IndexLunr, IndexPagefind}
+
+	// indexFileNames maps an index format to the file name rendered
+	// by GenerateSearchIndex.
+	indexFileNames = map[string]string{
+		IndexSolr:       "solr.json",
+		IndexOpenSearch: "opensearch.ndjson",
+		IndexLunr:       "lunr.json",
+		IndexPagefind:   "pagefind-entry.json",
+	}
+
+	// lunrFields are the fields indexed by LunrJS
+	lunrFields = []string{"title", "abstract", "creators", "groups", "subjects", "keywords", "doi"}
+)
+
+// IndexDocument is a flattened representation of an EPrint record
+// suitable for a search engine. All fields are either strings or
+// lists of strings so they can be indexed without nested documents.
+type IndexDocument struct {
+	// ID is unique across repositories, REPO_ID:EPRINT_ID
+	ID         string `json:"id"`
+	Repository string `json:"repository"`
+	EPrintID   int    `json:"eprintid"`
+	URL        string `json:"url,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Abstract   string `json:"abstract,omitempty"`
+	// Creators holds display names, "Family, Given"
+	Creators []string `json:"creators,omitempty"`
+	// CreatorIDs holds the local person ids of creators
+	CreatorIDs []string `json:"creator_ids,omitempty"`
+	// CreatorORCIDs holds the ORCID of creators
+	CreatorORCIDs []string `json:"creator_orcids,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+	Subjects      []string `json:"subjects,omitempty"`
+	Keywords      []string `json:"keywords,omitempty"`
+	Type          string   `json:"type,omitempty"`
+	ThesisType    string   `json:"thesis_type,omitempty"`
+	DOI           string   `json:"doi,omitempty"`
+	// PubDate is the publication date, PubYear and PubDecade are
+	// facets derived from it (e.g. "2021", "2020s")
+	PubDate   string `json:"pubdate,omitempty"`
+	PubYear   string `json:"pub_year,omitempty"`
+	PubDecade string `json:"pub_decade,omitempty"`
+}
+
+// appendUnique appends s to l if it is not empty or already in l
+func appendUnique(l []string, s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return l
+	}
+	for _, val := range l {
+		if val == s {
+			return l
+		}
+	}
+	return append(l, s)
+}
+
+// IndexDocumentFromEPrint flattens an EPrint into an IndexDocument
+func IndexDocumentFromEPrint(repoName string, eprint *EPrint) *IndexDocument {
+	doc := new(IndexDocument)
+	doc.ID = fmt.Sprintf("%s:%d", repoName, eprint.EPrintID)
+	doc.Repository = repoName
+	doc.EPrintID = eprint.EPrintID
+	doc.URL = eprint.OfficialURL
+	if doc.URL == "" {
+		doc.URL = eprint.ID
+	}
+	doc.Title = strings.TrimSpace(eprint.Title)
+	doc.Abstract = strings.TrimSpace(eprint.Abstract)
+	if eprint.Creators != nil {
+		for _, item := range eprint.Creators.Items {
+			if item.Name == nil {
+				continue
+			}
+			name := strings.TrimSpace(item.Name.Family)
+			if given := strings.TrimSpace(item.Name.Given); given != "" {
+				name = fmt.Sprintf("%s, %s", name, given)
+			}
+			if name == "" {
+				name = item.Name.Value
+			}
+			doc.Creators = appendUnique(doc.Creators, name)
+			doc.CreatorIDs = appendUnique(doc.CreatorIDs, item.ID)
+			doc.CreatorORCIDs = appendUnique(doc.CreatorORCIDs, item.ORCID)
+		}
+	}
+	if eprint.LocalGroup != nil {
+		for _, item := range eprint.LocalGroup.Items {
+			doc.Groups = appendUnique(doc.Groups, item.Value)
+		}
+	}
+	if eprint.Subjects != nil {
+		for _, item := range eprint.Subjects.Items {
+			doc.Subjects = appendUnique(doc.Subjects, item.Value)
+		}
+	}
+	for _, keyword := range strings.Split(eprint.Keywords, ";") {
+		doc.Keywords = appendUnique(doc.Keywords, keyword)
+	}
+	doc.Type = eprint.Type
+	doc.ThesisType = eprint.ThesisType
+	doc.DOI = strings.TrimSpace(eprint.DOI)
+	doc.PubDate = eprint.PubDate()
+	if doc.PubDate == "" {
+		doc.PubDate = eprint.Date
+	}
+	if len(doc.PubDate) >= 4 {
+		doc.PubYear = doc.PubDate[0:4]
+		doc.PubDecade = doc.PubDate[0:3] + "0s"
+	}
+	return doc
+}
+
+// WriteSolrIndex writes docs in Solr's JSON update format, a JSON
+// array of documents.
+func WriteSolrIndex(w io.Writer, docs []*IndexDocument) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(docs)
+}
+
+// WriteOpenSearchIndex writes docs in the OpenSearch bulk API format,
+// newline delimited JSON pairing an index action with each document.
+func WriteOpenSearchIndex(w io.Writer, indexName string, docs []*IndexDocument) error {
+	encoder := json.NewEncoder(w)
+	for _, doc := range docs {
+		action := map[string]map[string]string{
+			"index": {"_index": indexName, "_id": doc.ID},
+		}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lunrDocument is a document with multi-value fields joined so
+// LunrJS can tokenize them.
+type lunrDocument struct {
+	ID        string `json:"id"`
+	URL       string `json:"url,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Abstract  string `json:"abstract,omitempty"`
+	Creators  string `json:"creators,omitempty"`
+	Groups    string `json:"groups,omitempty"`
+	Subjects  string `json:"subjects,omitempty"`
+	Keywords  string `json:"keywords,omitempty"`
+	DOI       string `json:"doi,omitempty"`
+	Type      string `json:"type,omitempty"`
+	PubYear   string `json:"pub_year,omitempty"`
+	PubDecade string `json:"pub_decade,omitempty"`
+}
+
+// LunrIndex holds the documents and the configuration needed to
+// build a LunrJS index, e.g.
+//
+//	idx = lunr(function () {
+//	  this.ref(data.ref)
+//	  data.fields.forEach(f => this.field(f))
+//	  data.documents.forEach(d => this.add(d))
+//	})
+type LunrIndex struct {
+	Ref       string          `json:"ref"`
+	Fields    []string        `json:"fields"`
+	Documents []*lunrDocument `json:"documents"`
+}
+
+// WriteLunrIndex writes docs as a LunrIndex JSON document
+func WriteLunrIndex(w io.Writer, docs []*IndexDocument) error {
+	index := &LunrIndex{
+		Ref:       "id",
+		Fields:    lunrFields,
+		Documents: []*lunrDocument{},
+	}
+	for _, doc := range docs {
+		index.Documents = append(index.Documents, &lunrDocument{
+			ID:        doc.ID,
+			URL:       doc.URL,
+			Title:     doc.Title,
+			Abstract:  doc.Abstract,
+			Creators:  strings.Join(doc.Creators, "; "),
+			Groups:    strings.Join(doc.Groups, "; "),
+			Subjects:  strings.Join(doc.Subjects, "; "),
+			Keywords:  strings.Join(doc.Keywords, "; "),
+			DOI:       doc.DOI,
+			Type:      doc.Type,
+			PubYear:   doc.PubYear,
+			PubDecade: doc.PubDecade,
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(index)
+}
+
+// PagefindRecord is a Pagefind custom record as accepted by
+// Pagefind's addCustomRecord().
+type PagefindRecord struct {
+	URL      string              `json:"url"`
+	Content  string              `json:"content"`
+	Language string              `json:"language"`
+	Meta     map[string]string   `json:"meta,omitempty"`
+	Filters  map[string][]string `json:"filters,omitempty"`
+	Sort     map[string]string   `json:"sort,omitempty"`
+}
+
+// WritePagefindIndex writes docs as a JSON list of PagefindRecord
+func WritePagefindIndex(w io.Writer, docs []*IndexDocument) error {
+	records := []*PagefindRecord{}
+	for _, doc := range docs {
+		record := &PagefindRecord{
+			URL:      doc.URL,
+			Language: "en",
+			Meta:     map[string]string{"title": doc.Title},
+			Filters:  map[string][]string{},
+			Sort:     map[string]string{},
+		}
+		content := []string{doc.Title}
+		if len(doc.Creators) > 0 {
+			content = append(content, strings.Join(doc.Creators, "; "))
+		}
+		if doc.Abstract != "" {
+			content = append(content, doc.Abstract)
+		}
+		if len(doc.Keywords) > 0 {
+			content = append(content, strings.Join(doc.Keywords, "; "))
+		}
+		record.Content = strings.Join(content, "\n\n")
+		if doc.DOI != "" {
+			record.Meta["doi"] = doc.DOI
+		}
+		if doc.PubDate != "" {
+			record.Meta["pubdate"] = doc.PubDate
+			record.Sort["pubdate"] = doc.PubDate
+		}
+		if doc.Type != "" {
+			record.Filters["type"] = []string{doc.Type}
+		}
+		if doc.PubYear != "" {
+			record.Filters["year"] = []string{doc.PubYear}
+		}
+		if len(doc.Groups) > 0 {
+			record.Filters["group"] = doc.Groups
+		}
+		if len(doc.Creators) > 0 {
+			record.Filters["creator"] = doc.Creators
+		}
+		if len(doc.Subjects) > 0 {
+			record.Filters["subject"] = doc.Subjects
+		}
+		records = append(records, record)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(records)
+}
+
+// WriteSearchIndex writes docs in the index format requested. The
+// indexName is used by formats which name the target index.
+func WriteSearchIndex(w io.Writer, format string, indexName string, docs []*IndexDocument) error {
+	switch format {
+	case IndexSolr:
+		return WriteSolrIndex(w, docs)
+	case IndexOpenSearch:
+		return WriteOpenSearchIndex(w, indexName, docs)
+	case IndexLunr:
+		return WriteLunrIndex(w, docs)
+	case IndexPagefind:
+		return WritePagefindIndex(w, docs)
+	}
+	return fmt.Errorf("unsupported index format %q, expected one of %s", format, strings.Join(IndexFormats, ", "))
+}
+
+// GenerateSearchIndex reads the harvested records for each repository
+// from the JSON store and renders the index formats requested to
+// htdocs/search/REPO_ID/. If formats is empty all formats are rendered.
+func GenerateSearchIndex(cfg *Config, formats []string, verbose bool) error {
+	if len(formats) == 0 {
+		formats = IndexFormats
+	}
+	for _, format := range formats {
+		if _, ok := indexFileNames[format]; !ok {
+			return fmt.Errorf("unsupported index format %q, expected one of %s", format, strings.Join(IndexFormats, ", "))
+		}
+	}
+	repoNames := []string{}
+	for repoName := range cfg.Repositories {
+		repoNames = append(repoNames, repoName)
+	}
+	sort.Strings(repoNames)
+	for _, repoName := range repoNames {
+		indexDir := path.Join(cfg.Htdocs, "search", repoName)
+		// NOTE: Is htdocs relative to project? If so handle that case
+		if !(strings.HasPrefix(cfg.Htdocs, "/") || strings.HasPrefix(indexDir, cfg.ProjectDir)) {
+			indexDir = path.Join(cfg.ProjectDir, indexDir)
+		}
+		if _, err := os.Stat(indexDir); os.IsNotExist(err) {
+			if err := os.MkdirAll(indexDir, 0775); err != nil {
+				return err
+			}
+		}
+		ids, err := GetJSONDocumentIDs(cfg, repoName, true)
+		if err != nil {
+			return err
+		}
+		docs := []*IndexDocument{}
+		tot := len(ids)
+		modValue := calcModValue(tot)
+		t0 := time.Now()
+		for i, id := range ids {
+			eprint := new(EPrint)
+			if err := GetDocumentAsEPrint(cfg, repoName, id, eprint); err != nil {
+				return err
+			}
+			docs = append(docs, IndexDocumentFromEPrint(repoName, eprint))
+			if verbose && ((i % modValue) == 0) {
+				log.Printf("indexed %s %d, (%s)", repoName, id, progress(t0, i, tot))
+			}
+		}
+		for _, format := range formats {
+			fName := path.Join(indexDir, indexFileNames[format])
+			if verbose {
+				log.Printf("Writing %d %s index documents to %s", len(docs), format, fName)
+			}
+			fp, err := os.Create(fName)
+			if err != nil {
+				return err
+			}
+			if err := WriteSearchIndex(fp, format, repoName, docs); err != nil {
+				fp.Close()
+				return fmt.Errorf("failed to write %s, %s", fName, err)
+			}
+			if err := fp.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunGenIndex will use the config file names by cfgName and render
+// the search index formats requested for each repository in the
+// htdocs directory indicated in the configuration file.
+func RunGenIndex(cfgName string, formats []string, verbose bool) error {
+	if cfgName == "" {
+		return fmt.Errorf("Configuration filename missing")
+	}
+	t0 := time.Now()
+	appName := path.Base(os.Args[0])
+	// Read in the configuration for this harvester instance.
+	cfg, err := LoadConfig(cfgName)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return fmt.Errorf("could not create a configuration object")
+	}
+	if err := OpenJSONStore(cfg); err != nil {
+		return err
+	}
+	defer cfg.Jdb.Close()
+	log.Printf("%s started %v", appName, t0.Format("2006-01-02 15:04:05"))
+	if err := GenerateSearchIndex(cfg, formats, verbose); err != nil {
+		return err
+	}
+	if verbose {
+		log.Printf("%s run time %v", appName, time.Since(t0).Truncate(time.Second))
+	}
+	return nil
+}
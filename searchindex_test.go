@@ -0,0 +1,130 @@
+package eprinttools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestIndexDocumentFromEPrint(t *testing.T) {
+	eprint := &EPrint{
+		EPrintID:   7,
+		ID:         "https://example.edu/id/eprint/7",
+		Title:      " A Title ",
+		Abstract:   "An abstract.",
+		Type:       "article",
+		Date:       "1913-08-01",
+		DateType:   "published",
+		DOI:        "10.1103/PhysRev.2.109",
+		Keywords:   "electron; oil drop;",
+		Creators:   &CreatorItemList{},
+		LocalGroup: &LocalGroupItemList{},
+		Subjects:   &SubjectItemList{},
+	}
+	eprint.Creators.Items = []*Item{
+		{Name: &Name{Family: "Millikan", Given: "Robert A."}, ID: "Millikan-R-A", ORCID: "0000-0002-1825-0097"},
+		{Name: &Name{Family: "Fletcher", Given: "Harvey"}},
+	}
+	eprint.LocalGroup.Items = []*Item{{Value: "Ryerson Laboratory"}}
+	eprint.Subjects.Items = []*Item{{Value: "physics"}}
+	doc := IndexDocumentFromEPrint("lemurprints", eprint)
+	assertStringSame(t, "id", "lemurprints:7", doc.ID)
+	assertStringSame(t, "url", eprint.ID, doc.URL)
+	assertStringSame(t, "title", "A Title", doc.Title)
+	assertStringSame(t, "pubdate", "1913-08-01", doc.PubDate)
+	assertStringSame(t, "pub_year", "1913", doc.PubYear)
+	assertStringSame(t, "pub_decade", "1910s", doc.PubDecade)
+	assertStringSame(t, "creators", "Millikan, Robert A.; Fletcher, Harvey", strings.Join(doc.Creators, "; "))
+	assertStringSame(t, "creator_orcids", "0000-0002-1825-0097", strings.Join(doc.CreatorORCIDs, "; "))
+	assertStringSame(t, "creator_ids", "Millikan-R-A", strings.Join(doc.CreatorIDs, "; "))
+	assertStringSame(t, "keywords", "electron; oil drop", strings.Join(doc.Keywords, "; "))
+	assertStringSame(t, "groups", "Ryerson Laboratory", strings.Join(doc.Groups, "; "))
+	assertStringSame(t, "subjects", "physics", strings.Join(doc.Subjects, "; "))
+}
+
+func TestWriteSearchIndex(t *testing.T) {
+	fName := path.Join("testdata", "test_eprint1.xml")
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		t.Fatalf("Failed to read %q, %s", fName, err)
+	}
+	eprints := NewEPrints()
+	if err := xml.Unmarshal(src, &eprints); err != nil {
+		t.Fatalf("Failed to unmarshal %q, %s", fName, err)
+	}
+	docs := []*IndexDocument{}
+	for _, eprint := range eprints.EPrint {
+		docs = append(docs, IndexDocumentFromEPrint("lemurprints", eprint))
+	}
+	if len(docs) == 0 {
+		t.Fatalf("Expected at least 1 test record in %q", fName)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteSearchIndex(buf, IndexSolr, "lemurprints", docs); err != nil {
+		t.Fatalf("solr index failed, %s", err)
+	}
+	solrDocs := []*IndexDocument{}
+	if err := json.Unmarshal(buf.Bytes(), &solrDocs); err != nil {
+		t.Errorf("solr index is not a JSON array, %s", err)
+	}
+	assertIntSame(t, "solr documents", len(docs), len(solrDocs))
+
+	buf.Reset()
+	if err := WriteSearchIndex(buf, IndexOpenSearch, "lemurprints", docs); err != nil {
+		t.Fatalf("opensearch index failed, %s", err)
+	}
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		m := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Errorf("opensearch line %d is not JSON, %s", i, err)
+			continue
+		}
+		if (i % 2) == 0 {
+			if _, ok := m["index"]; !ok {
+				t.Errorf("opensearch line %d expected an index action, got %s", i, scanner.Bytes())
+			}
+		} else if m["id"] != docs[i/2].ID {
+			t.Errorf("opensearch line %d expected id %q, got %v", i, docs[i/2].ID, m["id"])
+		}
+	}
+	assertIntSame(t, "opensearch lines", len(docs)*2, i)
+
+	buf.Reset()
+	if err := WriteSearchIndex(buf, IndexLunr, "lemurprints", docs); err != nil {
+		t.Fatalf("lunr index failed, %s", err)
+	}
+	lunrIndex := new(LunrIndex)
+	if err := json.Unmarshal(buf.Bytes(), &lunrIndex); err != nil {
+		t.Errorf("lunr index is not JSON, %s", err)
+	}
+	assertStringSame(t, "lunr ref", "id", lunrIndex.Ref)
+	assertIntSame(t, "lunr documents", len(docs), len(lunrIndex.Documents))
+
+	buf.Reset()
+	if err := WriteSearchIndex(buf, IndexPagefind, "lemurprints", docs); err != nil {
+		t.Fatalf("pagefind index failed, %s", err)
+	}
+	records := []*PagefindRecord{}
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Errorf("pagefind index is not JSON, %s", err)
+	}
+	assertIntSame(t, "pagefind records", len(docs), len(records))
+	for _, record := range records {
+		if record.URL == "" || record.Content == "" {
+			t.Errorf("pagefind record missing url or content, %+v", record)
+		}
+	}
+
+	if err := WriteSearchIndex(buf, "marc21", "lemurprints", docs); err == nil {
+		t.Errorf("expected error for unsupported index format")
+	}
+}
@@ -0,0 +1,358 @@
+// Package eprinttools is a collection of structures, functions and programs// for working with the EPrints XML and EPrints REST API
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+package eprinttools
+
+/**
+ * validate.go implements checking EPrint records against a
+ * repository's tables, column types and validation rules before
+ * they are imported.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationRules are the per repository rules used to validate an
+// EPrint record beyond the column types of its tables.
+type ValidationRules struct {
+	// Types are the accepted EPrint types (e.g. "article")
+	Types []string `json:"types,omitempty"`
+
+	// DateTypes are the accepted date_type values (e.g. "published")
+	DateTypes []string `json:"date_types,omitempty"`
+
+	// Required maps an EPrint type to the fields that must have
+	// a value, the fields listed for "*" are required for every type.
+	// Field names are the EPrints field names (e.g. "creators").
+	Required map[string][]string `json:"required,omitempty"`
+}
+
+// ValidationProblem describes one problem found with a record
+type ValidationProblem struct {
+	// Field is the EPrints field or column, item list columns
+	// include the position of the item (e.g. "creators_name_family[2]").
+	Field string `json:"field" xml:"field"`
+	// Code is one of "required", "not_allowed", "too_long",
+	// "out_of_range", "bad_format" or "unknown_field"
+	Code string `json:"code" xml:"code"`
+	// Message describes the problem
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationReport lists the problems found with one record
+type ValidationReport struct {
+	// Pos is the position of the record in the list validated
+	Pos int `json:"pos" xml:"pos"`
+	// EPrintID, if the record has one
+	EPrintID int `json:"eprint_id,omitempty" xml:"eprint_id,omitempty"`
+	// DOI, if the record has one
+	DOI string `json:"doi,omitempty" xml:"doi,omitempty"`
+	// Valid is true if there were no problems
+	Valid    bool                 `json:"valid" xml:"valid"`
+	Problems []*ValidationProblem `json:"problems,omitempty" xml:"problems>problem,omitempty"`
+}
+
+var (
+	// DefaultValidationRules are used when a data source doesn't
+	// define its own. The types and date types are those of a
+	// standard EPrints 3 install.
+	DefaultValidationRules = &ValidationRules{
+		Types: []string{
+			"article", "book_section", "monograph", "conference_item",
+			"book", "thesis", "patent", "artefact", "exhibition",
+			"composition", "performance", "image", "video", "audio",
+			"dataset", "experiment", "teaching_resource", "other",
+		},
+		DateTypes: []string{"published", "submitted", "completed"},
+		Required: map[string][]string{
+			"*": {"title", "type"},
+		},
+	}
+
+	// columnTypeRE splits a MySQL column type into its name, size
+	// and the remaining attributes (e.g. "int(11) unsigned").
+	columnTypeRE = regexp.MustCompile(`^([a-z]+)(?:\(([^)]*)\))?\s*(.*)$`)
+
+	// intRanges are the signed ranges of the MySQL integer types
+	intRanges = map[string][2]int64{
+		"tinyint":   {-128, 127},
+		"smallint":  {-32768, 32767},
+		"mediumint": {-8388608, 8388607},
+		"int":       {-2147483648, 2147483647},
+		"integer":   {-2147483648, 2147483647},
+		"bigint":    {-9223372036854775808, 9223372036854775807},
+	}
+
+	// textSizes are the maximum size in bytes of the MySQL text types
+	textSizes = map[string]int{
+		"tinytext":   255,
+		"text":       65535,
+		"mediumtext": 16777215,
+	}
+)
+
+// validationRules returns the data source's rules or the defaults
+func validationRules(ds *DataSource) *ValidationRules {
+	if ds != nil && ds.Validation != nil {
+		return ds.Validation
+	}
+	return DefaultValidationRules
+}
+
+// ValidateEPrint checks an EPrint against the data source's validation
+// rules and, when the data source is connected, the tables and column
+// types the record would be written to. An empty list means the record
+// is valid. The EPrint is not changed.
+func ValidateEPrint(ds *DataSource, eprint *EPrint) []*ValidationProblem {
+	problems := []*ValidationProblem{}
+	addProblem := func(field string, code string, format string, args ...interface{}) {
+		problems = append(problems, &ValidationProblem{
+			Field:   field,
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	rules := validationRules(ds)
+
+	// Check the values of the controlled fields
+	if eprint.Type != "" && len(rules.Types) > 0 && !slices.Contains(rules.Types, eprint.Type) {
+		addProblem("type", "not_allowed", "type %q not allowed, expected %s", eprint.Type, strings.Join(rules.Types, ", "))
+	}
+	if eprint.DateType != "" && len(rules.DateTypes) > 0 && !slices.Contains(rules.DateTypes, eprint.DateType) {
+		addProblem("date_type", "not_allowed", "date_type %q not allowed, expected %s", eprint.DateType, strings.Join(rules.DateTypes, ", "))
+	}
+	if _, ok := StatusTransitions[eprint.EPrintStatus]; eprint.EPrintStatus != "" && !ok {
+		addProblem("eprint_status", "not_allowed", "eprint_status %q not allowed, expected inbox, buffer, archive or deletion", eprint.EPrintStatus)
+	}
+	for _, date := range []struct {
+		field string
+		value string
+	}{
+		{"date", eprint.Date},
+		{"thesis_submitted_date", eprint.ThesisSubmittedDate},
+		{"thesis_defense_date", eprint.ThesisDefenseDate},
+		{"thesis_approved_date", eprint.ThesisApprovedDate},
+		{"thesis_public_date", eprint.ThesisPublicDate},
+		{"gradofc_approval_date", eprint.GradOfficeApprovalDate},
+	} {
+		if year, _, _ := approxYMD(date.value); date.value != "" && year == 0 {
+			addProblem(date.field, "bad_format", "%s %q not valid, expected YYYY, YYYY-MM or YYYY-MM-DD", date.field, date.value)
+		}
+	}
+
+	// Check the required fields for the type
+	fields := eprintFieldValues(eprint)
+	required := append([]string{}, rules.Required["*"]...)
+	if eprint.Type != "" {
+		required = append(required, rules.Required[eprint.Type]...)
+	}
+	for _, field := range required {
+		value, ok := fields[field]
+		switch {
+		case !ok:
+			addProblem(field, "unknown_field", "required field %q is not an EPrint field", field)
+		case isEmptyValue(value):
+			addProblem(field, "required", "%s is required", field)
+		}
+	}
+
+	// Check the values against the column types
+	if ds == nil || ds.TableMap == nil || ds.ColumnTypes == nil {
+		return problems
+	}
+	if columns, ok := ds.TableMap[`eprint`]; ok {
+		columnsSQL, values := eprintToColumnsAndValues(eprint, columns, false)
+		for i, column := range columnsSQL {
+			if code, msg := checkColumnValue(ds.ColumnTypes[`eprint`][column], values[i]); code != "" {
+				addProblem(column, code, "%s %s", column, msg)
+			}
+		}
+	}
+	tableNames := []string{}
+	for tableName := range ds.TableMap {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	for _, tableName := range tableNames {
+		// NOTE: these are the tables sqlCreateEPrint skips
+		if tableName == `eprint` || tableName == `eprint_keyword` ||
+			strings.HasPrefix(tableName, `document`) || strings.HasPrefix(tableName, `file`) {
+			continue
+		}
+		columns := ds.TableMap[tableName]
+		itemList, err := eprintItemList(eprint, tableName, columns)
+		if err != nil || itemList == nil {
+			continue
+		}
+		for pos := 0; pos < itemList.Length(); pos++ {
+			item := itemList.IndexOf(pos)
+			if item == nil {
+				continue
+			}
+			if item.Name == nil && hasNameColumn(columns) {
+				addProblem(fmt.Sprintf("%s[%d]", strings.TrimPrefix(tableName, `eprint_`), pos), "required", "%s item %d is missing a name", strings.TrimPrefix(tableName, `eprint_`), pos)
+				named := *item
+				named.Name = new(Name)
+				item = &named
+			}
+			columnsSQL, values, err := itemToColumnsAndValues(tableName, eprint.EPrintID, pos, item, columns)
+			if err != nil {
+				continue
+			}
+			for i, column := range columnsSQL {
+				if code, msg := checkColumnValue(ds.ColumnTypes[tableName][column], values[i]); code != "" {
+					addProblem(fmt.Sprintf("%s[%d]", column, pos), code, "%s %s", column, msg)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// ValidateEPrints validates each EPrint in a list returning a
+// report per record.
+func ValidateEPrints(ds *DataSource, eprints *EPrints) []*ValidationReport {
+	reports := []*ValidationReport{}
+	if eprints == nil {
+		return reports
+	}
+	for i, eprint := range eprints.EPrint {
+		problems := ValidateEPrint(ds, eprint)
+		reports = append(reports, &ValidationReport{
+			Pos:      i,
+			EPrintID: eprint.EPrintID,
+			DOI:      eprint.DOI,
+			Valid:    len(problems) == 0,
+			Problems: problems,
+		})
+	}
+	return reports
+}
+
+// eprintFieldValues maps the EPrints field names (the XML element
+// names) of an EPrint to their values.
+func eprintFieldValues(eprint *EPrint) map[string]interface{} {
+	fields := map[string]interface{}{}
+	v := reflect.ValueOf(eprint).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("xml"), ",")
+		if name == "" || name == "-" || !t.Field(i).IsExported() {
+			continue
+		}
+		fields[name] = v.Field(i).Interface()
+	}
+	return fields
+}
+
+// isEmptyValue returns true for zero values and empty item lists
+func isEmptyValue(value interface{}) bool {
+	if itemList, ok := value.(ItemsInterface); ok {
+		return itemList.Length() == 0
+	}
+	return value == nil || reflect.ValueOf(value).IsZero()
+}
+
+// hasNameColumn returns true if an item list table stores names
+func hasNameColumn(columns []string) bool {
+	for _, column := range columns {
+		for _, suffix := range []string{`_family`, `_given`, `_honourific`, `_lineage`, `_name`} {
+			if strings.HasSuffix(column, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkColumnValue checks a value will fit a MySQL column type. It
+// returns a problem code and message or empty strings if it fits.
+// Column types that aren't recognized are not checked.
+func checkColumnValue(columnType string, value interface{}) (string, string) {
+	m := columnTypeRE.FindStringSubmatch(strings.ToLower(columnType))
+	if m == nil {
+		return "", ""
+	}
+	name, size, attributes := m[1], m[2], m[3]
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", ""
+		}
+		v = v.Elem()
+	}
+	switch name {
+	case "char", "varchar":
+		n, err := strconv.Atoi(size)
+		if err != nil || v.Kind() != reflect.String {
+			return "", ""
+		}
+		if l := utf8.RuneCountInString(v.String()); l > n {
+			return "too_long", fmt.Sprintf("is %d characters, the limit is %d", l, n)
+		}
+	case "tinytext", "text", "mediumtext":
+		if v.Kind() == reflect.String && len(v.String()) > textSizes[name] {
+			return "too_long", fmt.Sprintf("is %d bytes, the limit is %d", len(v.String()), textSizes[name])
+		}
+	case "enum":
+		if v.Kind() != reflect.String || v.String() == "" {
+			return "", ""
+		}
+		allowed := []string{}
+		for _, s := range strings.Split(size, ",") {
+			allowed = append(allowed, strings.Trim(strings.TrimSpace(s), `'`))
+		}
+		if !slices.Contains(allowed, strings.ToLower(v.String())) {
+			return "not_allowed", fmt.Sprintf("%q not allowed, expected %s", v.String(), strings.Join(allowed, ", "))
+		}
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint":
+		var i int64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = v.Int()
+		case reflect.String:
+			if v.String() == "" {
+				return "", ""
+			}
+			n, err := strconv.ParseInt(v.String(), 10, 64)
+			if err != nil {
+				return "bad_format", fmt.Sprintf("%q is not an integer", v.String())
+			}
+			i = n
+		default:
+			return "", ""
+		}
+		lower, upper := intRanges[name][0], intRanges[name][1]
+		if strings.Contains(attributes, "unsigned") {
+			lower, upper = 0, upper*2+1
+			if name == "bigint" {
+				upper = intRanges[name][1]
+			}
+		}
+		if i < lower || i > upper {
+			return "out_of_range", fmt.Sprintf("%d is out of range (%d to %d)", i, lower, upper)
+		}
+	}
+	return "", ""
+}
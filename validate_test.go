@@ -0,0 +1,210 @@
+package eprinttools
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// hasProblem returns true if problems includes field with code
+func hasProblem(problems []*ValidationProblem, field string, code string) bool {
+	for _, problem := range problems {
+		if problem.Field == field && problem.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckColumnValue(t *testing.T) {
+	s, i := "abcdef", 300
+	for _, test := range []struct {
+		columnType string
+		value      interface{}
+		code       string
+	}{
+		{"varchar(255)", &s, ""},
+		{"varchar(5)", &s, "too_long"},
+		{"VARCHAR(6)", "abcdef", ""},
+		{"varchar(3)", "ééé", ""},
+		{"char(2)", "abc", "too_long"},
+		{"tinytext", strings.Repeat("x", 256), "too_long"},
+		{"text", strings.Repeat("x", 256), ""},
+		{"longtext", strings.Repeat("x", 70000), ""},
+		{"int(11)", &i, ""},
+		{"tinyint(4)", &i, "out_of_range"},
+		{"smallint(5) unsigned", -1, "out_of_range"},
+		{"smallint(5) unsigned", 65535, ""},
+		{"int(11)", "42", ""},
+		{"int(11)", "forty two", "bad_format"},
+		{"int(11)", "", ""},
+		{"enum('TRUE','FALSE')", "TRUE", ""},
+		{"enum('TRUE','FALSE')", "MAYBE", "not_allowed"},
+		{"float", 1.5, ""},
+		{"", "anything", ""},
+	} {
+		code, msg := checkColumnValue(test.columnType, test.value)
+		if code != test.code {
+			t.Errorf("%s (%v), expected %q, got %q %s", test.columnType, test.value, test.code, code, msg)
+		}
+	}
+}
+
+func TestValidateEPrint(t *testing.T) {
+	// Default rules without a connected data source
+	eprint := &EPrint{
+		Title:    "A valid title",
+		Type:     "article",
+		DateType: "published",
+		Date:     "2021-04",
+	}
+	if problems := ValidateEPrint(nil, eprint); len(problems) > 0 {
+		t.Errorf("expected no problems, got %+v", problems[0])
+	}
+	eprint = &EPrint{
+		Type:         "posted-content",
+		DateType:     "issued",
+		Date:         "April 2021",
+		EPrintStatus: "published",
+	}
+	problems := ValidateEPrint(new(DataSource), eprint)
+	for _, expected := range [][2]string{
+		{"title", "required"},
+		{"type", "not_allowed"},
+		{"date_type", "not_allowed"},
+		{"date", "bad_format"},
+		{"eprint_status", "not_allowed"},
+	} {
+		if !hasProblem(problems, expected[0], expected[1]) {
+			t.Errorf("expected %s %s problem, got %+v", expected[0], expected[1], problems)
+		}
+	}
+
+	// Repository rules, tables and column types
+	ds := &DataSource{
+		Validation: &ValidationRules{
+			Types: []string{"article", "book"},
+			Required: map[string][]string{
+				"*":       {"title"},
+				"article": {"creators", "publication"},
+				"book":    {"no_such_field"},
+			},
+		},
+		TableMap: map[string][]string{
+			"eprint":               {"eprintid", "title", "type", "volume"},
+			"eprint_creators_name": {"eprintid", "pos", "creators_name_family", "creators_name_given"},
+			"eprint_keyword":       {"eprintid", "pos", "keyword"},
+			"document":             {"docid", "eprintid"},
+		},
+		ColumnTypes: map[string]map[string]string{
+			"eprint": {
+				"eprintid": "int(11)",
+				"title":    "longtext",
+				"type":     "varchar(255)",
+				"volume":   "varchar(6)",
+			},
+			"eprint_creators_name": {
+				"eprintid":             "int(11)",
+				"pos":                  "int(11)",
+				"creators_name_family": "varchar(10)",
+				"creators_name_given":  "varchar(255)",
+			},
+		},
+	}
+	eprint = &EPrint{
+		Title:       "A title",
+		Type:        "article",
+		Publication: "Journal of Tests",
+		Volume:      "1234567",
+		Creators:    new(CreatorItemList),
+	}
+	eprint.Creators.Append(&Item{Name: &Name{Family: "Doe", Given: "Jane"}})
+	eprint.Creators.Append(&Item{Name: &Name{Family: "Featherstonehaugh", Given: "John"}})
+	eprint.Creators.Append(&Item{ID: "Missing-N"})
+	problems = ValidateEPrint(ds, eprint)
+	for _, expected := range [][2]string{
+		{"volume", "too_long"},
+		{"creators_name_family[1]", "too_long"},
+		{"creators_name[2]", "required"},
+	} {
+		if !hasProblem(problems, expected[0], expected[1]) {
+			t.Errorf("expected %s %s problem, got %+v", expected[0], expected[1], problems)
+		}
+	}
+	if len(problems) != 3 {
+		for _, problem := range problems {
+			t.Logf("%+v", problem)
+		}
+		t.Errorf("expected 3 problems, got %d", len(problems))
+	}
+	if eprint.Creators.IndexOf(2).Name != nil {
+		t.Errorf("ValidateEPrint should not change the EPrint")
+	}
+
+	eprint = &EPrint{Title: "A title", Type: "article"}
+	problems = ValidateEPrint(ds, eprint)
+	if !hasProblem(problems, "creators", "required") || !hasProblem(problems, "publication", "required") {
+		t.Errorf("expected creators and publication to be required, got %+v", problems)
+	}
+	eprint = &EPrint{Title: "A title", Type: "book"}
+	if problems = ValidateEPrint(ds, eprint); !hasProblem(problems, "no_such_field", "unknown_field") {
+		t.Errorf("expected an unknown field problem, got %+v", problems)
+	}
+	eprint = &EPrint{Title: "A title", Type: "thesis"}
+	if problems = ValidateEPrint(ds, eprint); !hasProblem(problems, "type", "not_allowed") {
+		t.Errorf("expected type not allowed, got %+v", problems)
+	}
+}
+
+func TestValidateEPrints(t *testing.T) {
+	eprints := new(EPrints)
+	eprints.Append(&EPrint{Title: "A title", Type: "article", DOI: "10.1000/valid"})
+	eprints.Append(&EPrint{Type: "article", DOI: "10.1000/untitled"})
+	reports := ValidateEPrints(nil, eprints)
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(reports))
+	}
+	if !reports[0].Valid || len(reports[0].Problems) != 0 {
+		t.Errorf("expected first record to be valid, %+v", reports[0])
+	}
+	if reports[1].Valid || reports[1].Pos != 1 || reports[1].DOI != "10.1000/untitled" {
+		t.Errorf("expected second record not to be valid, %+v", reports[1])
+	}
+}
+
+// recordExecer records the statements passed to Exec
+type recordExecer struct {
+	stmts []string
+	args  [][]interface{}
+}
+
+func (r *recordExecer) Exec(stmt string, args ...interface{}) (sql.Result, error) {
+	r.stmts = append(r.stmts, stmt)
+	r.args = append(r.args, args)
+	return nil, nil
+}
+
+func TestInsertItemList(t *testing.T) {
+	eprint := &EPrint{EPrintID: 7, Creators: new(CreatorItemList)}
+	eprint.Creators.Append(&Item{Name: &Name{Family: "Doe", Given: "Jane"}, ORCID: "0000-0002-1825-0097"})
+	eprint.Creators.Append(&Item{Name: &Name{Family: "Roe", Given: "Richard"}})
+	db := new(recordExecer)
+	columns := []string{"eprintid", "pos", "creators_name_family", "creators_name_given"}
+	if err := insertItemList(db, "lemurprints", "eprint_creators_name", columns, eprint); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.stmts) != 3 {
+		t.Fatalf("expected a DELETE and two INSERT, got %q", db.stmts)
+	}
+	expected := `INSERT INTO eprint_creators_name (eprintid, pos, creators_name_family, creators_name_given) VALUES (?, ?, ?, ?)`
+	assertStringSame(t, "stmt", expected, db.stmts[2])
+	if len(db.args[2]) != 4 || db.args[2][0] != 7 || db.args[2][1] != 1 || db.args[2][2] != "Roe" || db.args[2][3] != "Richard" {
+		t.Errorf("unexpected values %v", db.args[2])
+	}
+	if err := insertItemList(db, "lemurprints", "eprint_no_such_table", columns, eprint); err == nil {
+		t.Errorf("expected an error for an unknown table")
+	}
+	if err := insertItemList(db, "lemurprints", "eprint_creators_name", []string{"creators_unknown"}, eprint); err == nil {
+		t.Errorf("expected an error for an unknown column")
+	}
+}